@@ -21,6 +21,7 @@ import (
 	"github.com/metalsoft-io/metalcloud-cli/pkg/datacenter"
 	"github.com/metalsoft-io/metalcloud-cli/pkg/drive"
 	"github.com/metalsoft-io/metalcloud-cli/pkg/extension"
+	"github.com/metalsoft-io/metalcloud-cli/pkg/export"
 	"github.com/metalsoft-io/metalcloud-cli/pkg/firewall"
 	"github.com/metalsoft-io/metalcloud-cli/pkg/firmware"
 	"github.com/metalsoft-io/metalcloud-cli/pkg/infrastructure"
@@ -223,6 +224,7 @@ func getCommands(clients map[string]metalcloud.MetalCloudClient, permissions []s
 		drive.SharedDriveCmds,
 		extension.ExtensionCmds,
 		extension.ExtensionInstanceCmds,
+		export.ExportCmds,
 		firewall.FirewallRuleCmds,
 		firmware.FirmwareCatalogCmds,
 		infrastructure.InfrastructureCmds,
@@ -235,6 +237,7 @@ func getCommands(clients map[string]metalcloud.MetalCloudClient, permissions []s
 		ostemplate.OsTemplatesCmds,
 		reports.ReportsCmds,
 		secret.SecretsCmds,
+		server.RackCmds,
 		server.ServersCmds,
 		shellcompletion.ShellCompletionCmds,
 		stagedefinition.StageDefinitionsCmds,
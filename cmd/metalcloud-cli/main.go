@@ -5,6 +5,7 @@ package main
 //go:generate mockgen -source=../metal-cloud-sdk-go/metal_cloud_client.go -destination=helpers/mock_client.go
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -46,6 +47,14 @@ func main() {
 
 	err = command.ExecuteCommand(os.Args, commands, clients, client2, client2Version, permissions)
 
+	var exitCodeError *command.ExitCodeError
+	if errors.As(err, &exitCodeError) {
+		if exitCodeError.Message != "" {
+			fmt.Fprintf(os.Stderr, "%s\n", exitCodeError.Message)
+		}
+		os.Exit(exitCodeError.Code)
+	}
+
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(-2)
@@ -6,10 +6,12 @@ import (
 	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/url"
+	"os"
 	"reflect"
 	"slices"
 	"strconv"
@@ -395,6 +397,12 @@ func RequestInputSilent(s string) ([]byte, error) {
 	return content, nil
 }
 
+// StdoutIsTerminal returns true if the configured output channel is an interactive terminal
+func StdoutIsTerminal() bool {
+	f, ok := configuration.GetStdout().(*os.File)
+	return ok && terminal.IsTerminal(int(f.Fd()))
+}
+
 func RequestInput(s string) ([]byte, error) {
 	fmt.Fprintf(configuration.GetStdout(), s)
 	reader := bufio.NewReader(configuration.GetStdin())
@@ -436,6 +444,17 @@ func validateArguments(args []string) (string, string, int) {
 	return subject, predicate, count
 }
 
+// ExitCodeError is returned by commands that need the cli to exit with a specific code, such as monitoring checks.
+// The output returned together with the error is still printed.
+type ExitCodeError struct {
+	Code    int
+	Message string
+}
+
+func (e *ExitCodeError) Error() string {
+	return e.Message
+}
+
 func helpMessage(err error, subject string, predicate string) error {
 	message := err.Error()
 
@@ -532,6 +551,13 @@ func ExecuteCommand(args []string, commands []Command, clients map[string]metalc
 
 		ret, err = cmd.ExecuteFunc(cmd, client)
 	}
+
+	var exitCodeError *ExitCodeError
+	if errors.As(err, &exitCodeError) {
+		fmt.Fprint(configuration.GetStdout(), ret)
+		return err
+	}
+
 	if err != nil {
 		return helpMessage(err, subject, predicate)
 	}
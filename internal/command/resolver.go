@@ -0,0 +1,177 @@
+package command
+
+import (
+	"fmt"
+	"sync"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+)
+
+// DefaultResolverConcurrency is the number of lookups that are run in parallel by Prefetch
+const DefaultResolverConcurrency = 8
+
+// Resolver caches the objects that list commands look up to decorate their rows
+// such as the owner of a subnet pool or the switch it is attached to.
+// A resolver is meant to be used for a single command invocation and is safe for concurrent use.
+// Each object is retrieved at most once, errors included.
+type Resolver struct {
+	client metalcloud.MetalCloudClient
+
+	mu      sync.Mutex
+	entries map[string]*resolverEntry
+}
+
+type resolverEntry struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+// NewResolver returns an empty resolver that uses the given client
+func NewResolver(client metalcloud.MetalCloudClient) *Resolver {
+	return &Resolver{
+		client:  client,
+		entries: map[string]*resolverEntry{},
+	}
+}
+
+// resolve returns the cached value for key calling get the first time the key is requested.
+// Concurrent callers of the same key wait for the first call to complete.
+func (r *Resolver) resolve(key string, get func() (interface{}, error)) (interface{}, error) {
+	r.mu.Lock()
+	e, ok := r.entries[key]
+	if !ok {
+		e = &resolverEntry{}
+		r.entries[key] = e
+	}
+	r.mu.Unlock()
+
+	e.once.Do(func() {
+		e.value, e.err = get()
+	})
+
+	return e.value, e.err
+}
+
+// User returns the user with the given id
+func (r *Resolver) User(id int) (*metalcloud.User, error) {
+	v, err := r.resolve(fmt.Sprintf("user:%d", id), func() (interface{}, error) {
+		return r.client.UserGet(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*metalcloud.User), nil
+}
+
+// Switch returns the switch with the given id. Credentials are only included if showCredentials is set.
+func (r *Resolver) Switch(id int, showCredentials bool) (*metalcloud.SwitchDevice, error) {
+	v, err := r.resolve(fmt.Sprintf("switch:%d:%t", id, showCredentials), func() (interface{}, error) {
+		return r.client.SwitchDeviceGet(id, showCredentials)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*metalcloud.SwitchDevice), nil
+}
+
+// SwitchController returns the switch controller with the given id. Credentials are only included if showCredentials is set.
+func (r *Resolver) SwitchController(id int, showCredentials bool) (*metalcloud.SwitchDeviceController, error) {
+	v, err := r.resolve(fmt.Sprintf("switch_controller:%d:%t", id, showCredentials), func() (interface{}, error) {
+		return r.client.SwitchDeviceControllerGet(id, showCredentials)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*metalcloud.SwitchDeviceController), nil
+}
+
+// ServerType returns the server type with the given id
+func (r *Resolver) ServerType(id int) (*metalcloud.ServerType, error) {
+	v, err := r.resolve(fmt.Sprintf("server_type:%d", id), func() (interface{}, error) {
+		return r.client.ServerTypeGet(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*metalcloud.ServerType), nil
+}
+
+// Datacenter returns the datacenter with the given name
+func (r *Resolver) Datacenter(name string) (*metalcloud.Datacenter, error) {
+	v, err := r.resolve("datacenter:"+name, func() (interface{}, error) {
+		return r.client.DatacenterGet(name)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*metalcloud.Datacenter), nil
+}
+
+// VolumeTemplate returns the volume template with the given id
+func (r *Resolver) VolumeTemplate(id int) (*metalcloud.VolumeTemplate, error) {
+	v, err := r.resolve(fmt.Sprintf("volume_template:%d", id), func() (interface{}, error) {
+		return r.client.VolumeTemplateGet(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*metalcloud.VolumeTemplate), nil
+}
+
+// ExternalConnection returns the external connection with the given id
+func (r *Resolver) ExternalConnection(id int) (*metalcloud.ExternalConnection, error) {
+	v, err := r.resolve(fmt.Sprintf("external_connection:%d", id), func() (interface{}, error) {
+		return r.client.ExternalConnectionGet(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*metalcloud.ExternalConnection), nil
+}
+
+// SubnetPool returns the subnet pool with the given id
+func (r *Resolver) SubnetPool(id int) (*metalcloud.SubnetPool, error) {
+	v, err := r.resolve(fmt.Sprintf("subnet_pool:%d", id), func() (interface{}, error) {
+		return r.client.SubnetPoolGet(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*metalcloud.SubnetPool), nil
+}
+
+// Prefetch calls fetch for every index in [0, count) running at most concurrency calls in parallel.
+// It returns the error of the lowest index that failed, if any.
+func Prefetch(count int, concurrency int, fetch func(i int) error) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	errs := make([]error, count)
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			errs[i] = fetch(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	. "github.com/onsi/gomega"
+)
+
+func TestResolverCachesLookups(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		UserGet(1).
+		Return(&metalcloud.User{UserID: 1, UserEmail: "a@b.com"}, nil).
+		Times(1)
+
+	client.EXPECT().
+		SwitchDeviceGet(2, false).
+		Return(nil, fmt.Errorf("not found")).
+		Times(1)
+
+	client.EXPECT().
+		SwitchDeviceGet(2, true).
+		Return(&metalcloud.SwitchDevice{NetworkEquipmentID: 2, NetworkEquipmentManagementPassword: "secret"}, nil).
+		Times(1)
+
+	resolver := NewResolver(client)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			u, err := resolver.User(1)
+			Expect(err).To(BeNil())
+			Expect(u.UserEmail).To(Equal("a@b.com"))
+
+			_, err = resolver.Switch(2, false)
+			Expect(err).NotTo(BeNil())
+		}()
+	}
+	wg.Wait()
+
+	sw, err := resolver.Switch(2, true)
+	Expect(err).To(BeNil())
+	Expect(sw.NetworkEquipmentManagementPassword).To(Equal("secret"))
+}
+
+func TestPrefetch(t *testing.T) {
+	RegisterTestingT(t)
+
+	results := make([]int, 50)
+
+	err := Prefetch(len(results), 4, func(i int) error {
+		results[i] = i * 2
+		return nil
+	})
+	Expect(err).To(BeNil())
+
+	for i, r := range results {
+		Expect(r).To(Equal(i * 2))
+	}
+
+	err = Prefetch(10, 3, func(i int) error {
+		if i == 3 || i == 7 {
+			return fmt.Errorf("error %d", i)
+		}
+		return nil
+	})
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal("error 3"))
+
+	Expect(Prefetch(0, 0, func(i int) error { return fmt.Errorf("unexpected") })).To(Succeed())
+}
@@ -178,6 +178,20 @@ func GetKnownHostsPath() (string, error) {
 	return knownHostsFilePath, nil
 }
 
+// GetReportSnapshotsPath returns the directory in which report snapshots are stored
+func GetReportSnapshotsPath() (string, error) {
+	if v := os.Getenv("METALCLOUD_REPORT_SNAPSHOTS_PATH"); v != "" {
+		return v, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(homeDir, ".metalcloud", "snapshots"), nil
+}
+
 func GetAPIKey() (string, error) {
 	if apiKey := os.Getenv("METALCLOUD_API_KEY"); apiKey == "" {
 		return "", fmt.Errorf("METALCLOUD_API_KEY must be set")
@@ -0,0 +1,258 @@
+package ipam
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+)
+
+const (
+	KindSubnetPool = "subnet pool"
+	KindSubnetOOB  = "OOB subnet"
+)
+
+// Range is an interval of IP addresses used by a subnet pool or an OOB subnet
+type Range struct {
+	Start netip.Addr
+	End   netip.Addr
+	Kind  string
+	ID    int
+	Label string
+}
+
+func (r Range) String() string {
+	s := fmt.Sprintf("%s-%s", r.Start, r.End)
+	if p, ok := r.prefix(); ok {
+		s = p.String()
+	}
+
+	if r.Kind == "" {
+		return s
+	}
+
+	s = fmt.Sprintf("%s #%d %s", r.Kind, r.ID, s)
+	if r.Label != "" {
+		s = fmt.Sprintf("%s (%s)", s, r.Label)
+	}
+
+	return s
+}
+
+// prefix returns the range as a prefix if it is one
+func (r Range) prefix() (netip.Prefix, bool) {
+	for bits := 0; bits <= r.Start.BitLen(); bits++ {
+		p := netip.PrefixFrom(r.Start, bits).Masked()
+		if p.Addr() == r.Start && lastAddr(p) == r.End {
+			return p, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// Overlaps returns true if the two ranges have at least one address in common
+func (r Range) Overlaps(o Range) bool {
+	if r.Start.Is4() != o.Start.Is4() {
+		return false
+	}
+	return r.Start.Compare(o.End) <= 0 && o.Start.Compare(r.End) <= 0
+}
+
+// Contains returns true if all the addresses of o are in r
+func (r Range) Contains(o Range) bool {
+	if r.Start.Is4() != o.Start.Is4() {
+		return false
+	}
+	return r.Start.Compare(o.Start) <= 0 && o.End.Compare(r.End) <= 0
+}
+
+// PrefixRange returns the range of addresses of a prefix given as an address and a prefix size
+func PrefixRange(address string, size int) (Range, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(address))
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid prefix address %s: %v", address, err)
+	}
+
+	p, err := addr.Prefix(size)
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid prefix size %d for %s", size, address)
+	}
+
+	return Range{Start: p.Addr(), End: lastAddr(p)}, nil
+}
+
+// AddressRange returns the range between two addresses
+func AddressRange(start string, end string) (Range, error) {
+	s, err := netip.ParseAddr(strings.TrimSpace(start))
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid range start %s: %v", start, err)
+	}
+
+	e, err := netip.ParseAddr(strings.TrimSpace(end))
+	if err != nil {
+		return Range{}, fmt.Errorf("invalid range end %s: %v", end, err)
+	}
+
+	if s.Is4() != e.Is4() || s.Compare(e) > 0 {
+		return Range{}, fmt.Errorf("invalid range %s-%s", start, end)
+	}
+
+	return Range{Start: s, End: e}, nil
+}
+
+// GetDatacenterRanges returns the ranges used by the subnet pools and OOB subnets of a datacenter.
+// All datacenters are included if datacenter is empty.
+func GetDatacenterRanges(datacenter string, client metalcloud.MetalCloudClient) ([]Range, error) {
+
+	filter := ""
+	if datacenter != "" {
+		filter = "datacenter_name:" + datacenter
+	}
+
+	pools, err := client.SubnetPoolSearch(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	ranges := []Range{}
+	for _, p := range *pools {
+		r, err := PrefixRange(p.SubnetPoolPrefixHumanReadable, p.SubnetPoolPrefixSize)
+		if err != nil {
+			// the prefixes returned by the server are expected to be valid
+			continue
+		}
+
+		r.Kind = KindSubnetPool
+		r.ID = p.SubnetPoolID
+		r.Label = p.SubnetPoolLabel
+		ranges = append(ranges, r)
+	}
+
+	subnets, err := client.SubnetOOBSearch(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range *subnets {
+		r, err := AddressRange(s.SubnetOOBRangeStartHumanReadable, s.SubnetOOBRangeEndHumanReadable)
+		if err != nil {
+			continue
+		}
+
+		r.Kind = KindSubnetOOB
+		r.ID = s.SubnetOOBID
+		r.Label = s.SubnetOOBLabel
+		ranges = append(ranges, r)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Start.Less(ranges[j].Start)
+	})
+
+	return ranges, nil
+}
+
+// FindOverlaps returns the ranges that overlap r
+func FindOverlaps(r Range, ranges []Range) []Range {
+	overlaps := []Range{}
+	for _, o := range ranges {
+		if r.Overlaps(o) {
+			overlaps = append(overlaps, o)
+		}
+	}
+	return overlaps
+}
+
+// CheckOverlaps returns an error listing the subnet pools and OOB subnets of the datacenter that overlap r
+func CheckOverlaps(r Range, datacenter string, client metalcloud.MetalCloudClient) error {
+
+	ranges, err := GetDatacenterRanges(datacenter, client)
+	if err != nil {
+		return err
+	}
+
+	overlaps := FindOverlaps(r, ranges)
+	if len(overlaps) == 0 {
+		return nil
+	}
+
+	descriptions := []string{}
+	for _, o := range overlaps {
+		descriptions = append(descriptions, o.String())
+	}
+
+	return fmt.Errorf("%s overlaps %s. Use --force to create it anyway", r, strings.Join(descriptions, ", "))
+}
+
+// FindFree returns the first prefix of the given size inside within that does not overlap any of the used ranges
+func FindFree(within netip.Prefix, size int, used []Range) (netip.Prefix, error) {
+
+	within = within.Masked()
+
+	if size < within.Bits() || size > within.Addr().BitLen() {
+		return netip.Prefix{}, fmt.Errorf("prefix size /%d does not fit in %s", size, within)
+	}
+
+	blockSize := new(big.Int).Lsh(big.NewInt(1), uint(within.Addr().BitLen()-size))
+	end := addrToInt(lastAddr(within))
+
+	candidate := addrToInt(within.Addr())
+
+	for candidate.Cmp(end) <= 0 {
+		p := netip.PrefixFrom(intToAddr(candidate, within.Addr().Is4()), size)
+		r := Range{Start: p.Addr(), End: lastAddr(p)}
+
+		overlaps := FindOverlaps(r, used)
+		if len(overlaps) == 0 {
+			return p, nil
+		}
+
+		// skip to the first aligned block after the last overlapping range
+		last := addrToInt(overlaps[0].End)
+		for _, o := range overlaps[1:] {
+			if e := addrToInt(o.End); e.Cmp(last) > 0 {
+				last = e
+			}
+		}
+
+		last.Add(last, big.NewInt(1))
+		rem := new(big.Int).Mod(last, blockSize)
+		if rem.Sign() != 0 {
+			last.Add(last, new(big.Int).Sub(blockSize, rem))
+		}
+
+		candidate = last
+	}
+
+	return netip.Prefix{}, fmt.Errorf("no free /%d prefix found in %s", size, within)
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	p = p.Masked()
+
+	hostBits := uint(p.Addr().BitLen() - p.Bits())
+	n := addrToInt(p.Addr())
+	n.Add(n, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), hostBits), big.NewInt(1)))
+
+	return intToAddr(n, p.Addr().Is4())
+}
+
+func addrToInt(a netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(a.AsSlice())
+}
+
+func intToAddr(n *big.Int, is4 bool) netip.Addr {
+	size := 16
+	if is4 {
+		size = 4
+	}
+
+	b := make([]byte, size)
+	n.FillBytes(b)
+
+	a, _ := netip.AddrFromSlice(b)
+	return a
+}
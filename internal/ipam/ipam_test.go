@@ -0,0 +1,112 @@
+package ipam
+
+import (
+	"net/netip"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	. "github.com/onsi/gomega"
+)
+
+func TestRangeOverlaps(t *testing.T) {
+	RegisterTestingT(t)
+
+	pool, err := PrefixRange("10.0.0.0", 24)
+	Expect(err).To(BeNil())
+	Expect(pool.String()).To(Equal("10.0.0.0/24"))
+
+	// the address is masked
+	pool2, err := PrefixRange("10.0.1.77", 24)
+	Expect(err).To(BeNil())
+	Expect(pool2.String()).To(Equal("10.0.1.0/24"))
+
+	oob, err := AddressRange("10.0.0.200", "10.0.1.10")
+	Expect(err).To(BeNil())
+	Expect(oob.String()).To(Equal("10.0.0.200-10.0.1.10"))
+
+	Expect(pool.Overlaps(pool2)).To(BeFalse())
+	Expect(pool.Overlaps(oob)).To(BeTrue())
+	Expect(oob.Overlaps(pool2)).To(BeTrue())
+
+	Expect(pool.Contains(Range{Start: netip.MustParseAddr("10.0.0.10"), End: netip.MustParseAddr("10.0.0.20")})).To(BeTrue())
+	Expect(pool.Contains(oob)).To(BeFalse())
+
+	v6, err := PrefixRange("fd00::", 64)
+	Expect(err).To(BeNil())
+	Expect(v6.Overlaps(pool)).To(BeFalse())
+
+	_, err = AddressRange("10.0.1.0", "10.0.0.0")
+	Expect(err).NotTo(BeNil())
+
+	_, err = PrefixRange("10.0.0.0", 33)
+	Expect(err).NotTo(BeNil())
+}
+
+func TestFindFree(t *testing.T) {
+	RegisterTestingT(t)
+
+	used := []Range{}
+	for _, p := range []string{"10.0.0.0/24", "10.0.1.0/25", "10.0.3.0/24"} {
+		prefix := netip.MustParsePrefix(p)
+		r, _ := PrefixRange(prefix.Addr().String(), prefix.Bits())
+		used = append(used, r)
+	}
+
+	free, err := FindFree(netip.MustParsePrefix("10.0.0.0/16"), 24, used)
+	Expect(err).To(BeNil())
+	Expect(free.String()).To(Equal("10.0.2.0/24"))
+
+	free, err = FindFree(netip.MustParsePrefix("10.0.0.0/16"), 25, used)
+	Expect(err).To(BeNil())
+	Expect(free.String()).To(Equal("10.0.1.128/25"))
+
+	free, err = FindFree(netip.MustParsePrefix("10.0.0.0/16"), 22, used)
+	Expect(err).To(BeNil())
+	Expect(free.String()).To(Equal("10.0.4.0/22"))
+
+	_, err = FindFree(netip.MustParsePrefix("10.0.0.0/23"), 24, used)
+	Expect(err).NotTo(BeNil())
+
+	_, err = FindFree(netip.MustParsePrefix("10.0.0.0/24"), 16, used)
+	Expect(err).NotTo(BeNil())
+
+	free, err = FindFree(netip.MustParsePrefix("fd00::/48"), 64, nil)
+	Expect(err).To(BeNil())
+	Expect(free.String()).To(Equal("fd00::/64"))
+}
+
+func TestCheckOverlaps(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		SubnetPoolSearch("datacenter_name:dc1").
+		Return(&[]metalcloud.SubnetPool{
+			{SubnetPoolID: 1, SubnetPoolPrefixHumanReadable: "10.0.0.0", SubnetPoolPrefixSize: 24, SubnetPoolLabel: "wan"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SubnetOOBSearch("datacenter_name:dc1").
+		Return(&[]metalcloud.SubnetOOB{
+			{SubnetOOBID: 2, SubnetOOBRangeStartHumanReadable: "172.16.0.10", SubnetOOBRangeEndHumanReadable: "172.16.0.100"},
+		}, nil).
+		AnyTimes()
+
+	r, _ := PrefixRange("172.16.0.0", 24)
+	err := CheckOverlaps(r, "dc1", client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal("172.16.0.0/24 overlaps OOB subnet #2 172.16.0.10-172.16.0.100. Use --force to create it anyway"))
+
+	r, _ = AddressRange("10.0.0.5", "10.0.0.6")
+	err = CheckOverlaps(r, "dc1", client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("overlaps subnet pool #1 10.0.0.0/24 (wan)"))
+
+	r, _ = PrefixRange("10.0.1.0", 24)
+	Expect(CheckOverlaps(r, "dc1", client)).To(Succeed())
+}
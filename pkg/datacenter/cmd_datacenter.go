@@ -153,6 +153,8 @@ func datacenterListCmd(c *command.Command, client metalcloud.MetalCloudClient) (
 		},
 	}
 
+	resolver := command.NewResolver(client)
+
 	data := [][]interface{}{}
 	for _, dc := range *dList {
 
@@ -178,7 +180,7 @@ func datacenterListCmd(c *command.Command, client metalcloud.MetalCloudClient) (
 
 		userStr := ""
 		if dc.UserID != 0 {
-			user, err := client.UserGet(dc.UserID)
+			user, err := resolver.User(dc.UserID)
 			if err != nil {
 				return "", err
 			}
@@ -0,0 +1,470 @@
+package export
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+)
+
+var ExportCmds = []command.Command{
+	{
+		Description:  "Export an infrastructure as Terraform configuration.",
+		Subject:      "export",
+		AltSubject:   "export",
+		Predicate:    "terraform",
+		AltPredicate: "tf",
+		FlagSet:      flag.NewFlagSet("export terraform", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"infrastructure_id_or_label": c.FlagSet.String("infrastructure", command.NilDefaultStr, colors.Red("(Required)")+" Infrastructure's id or label. Note that using the 'label' might be ambiguous in certain situations."),
+				"no_import":                  c.FlagSet.Bool("no-import", false, colors.Green("(Flag)")+" If set the import blocks are not generated."),
+			}
+		},
+		ExecuteFunc:   exportTerraformCmd,
+		Endpoint:      configuration.UserEndpoint,
+		AdminEndpoint: configuration.DeveloperEndpoint,
+		Example: `
+metalcloud-cli export terraform --infrastructure 12345 > main.tf
+terraform init && terraform plan
+
+The generated import blocks require Terraform 1.5 or newer. Firewall rules are exported as part of their instance array and are imported with it.`,
+	},
+}
+
+// hclAttribute is an attribute of a block. The value is an already rendered HCL expression.
+type hclAttribute struct {
+	Name  string
+	Value string
+}
+
+// hclBlock is a minimal representation of a Terraform block used to render the configuration
+type hclBlock struct {
+	Type       string
+	Labels     []string
+	Attributes []hclAttribute
+	Blocks     []hclBlock
+}
+
+func (b *hclBlock) set(name string, value string) {
+	b.Attributes = append(b.Attributes, hclAttribute{Name: name, Value: value})
+}
+
+func (b *hclBlock) render(sb *strings.Builder, indent string) {
+	sb.WriteString(indent + b.Type)
+	for _, l := range b.Labels {
+		sb.WriteString(" " + hclString(l))
+	}
+	sb.WriteString(" {\n")
+
+	// align the equal signs the same way 'terraform fmt' does
+	width := 0
+	for _, a := range b.Attributes {
+		if len(a.Name) > width {
+			width = len(a.Name)
+		}
+	}
+
+	for _, a := range b.Attributes {
+		sb.WriteString(fmt.Sprintf("%s  %-*s = %s\n", indent, width, a.Name, a.Value))
+	}
+
+	for i, child := range b.Blocks {
+		if len(b.Attributes) > 0 || i > 0 {
+			sb.WriteString("\n")
+		}
+		child.render(sb, indent+"  ")
+	}
+
+	sb.WriteString(indent + "}\n")
+}
+
+// hclString quotes a string as a HCL literal, escaping the template sequences
+func hclString(s string) string {
+	s = strconv.Quote(s)
+	s = strings.ReplaceAll(s, "${", "$${")
+	return strings.ReplaceAll(s, "%{", "%%{")
+}
+
+func hclInt(i int) string {
+	return strconv.Itoa(i)
+}
+
+func hclBool(b bool) string {
+	return strconv.FormatBool(b)
+}
+
+func hclList(values []string) string {
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// hclStringMap renders custom variables as a map of strings, as the provider expects them
+func hclStringMap(v interface{}) (string, bool) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		return "", false
+	}
+
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := []string{}
+	for _, k := range keys {
+		items = append(items, fmt.Sprintf("%s = %s", hclString(k), hclString(fmt.Sprintf("%v", m[k]))))
+	}
+
+	return "{ " + strings.Join(items, ", ") + " }", true
+}
+
+var terraformNameRegexp = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// terraformNames hands out unique resource names derived from the MetalSoft labels
+type terraformNames map[string]bool
+
+func (n terraformNames) get(label string, id int) string {
+	name := strings.Trim(terraformNameRegexp.ReplaceAllString(strings.ToLower(label), "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "r_" + name
+	}
+
+	if n[name] {
+		name = fmt.Sprintf("%s_%d", name, id)
+	}
+	n[name] = true
+
+	return name
+}
+
+func importBlock(address string, id int) hclBlock {
+	b := hclBlock{Type: "import"}
+	b.set("to", address)
+	b.set("id", hclString(hclInt(id)))
+	return b
+}
+
+func exportTerraformCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	infra, err := command.GetInfrastructureFromCommand("infrastructure", c, client)
+	if err != nil {
+		return "", err
+	}
+
+	blocks, err := getTerraformBlocks(infra, client, !command.GetBoolParam(c.Arguments["no_import"]))
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("# Generated by metalcloud-cli from infrastructure %s (#%d)\n\n", infra.InfrastructureLabel, infra.InfrastructureID))
+
+	for i, b := range blocks {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		b.render(&sb, "")
+	}
+
+	return sb.String(), nil
+}
+
+// getTerraformBlocks walks the infrastructure and returns the blocks describing it,
+// followed by the import blocks if requested
+func getTerraformBlocks(infra *metalcloud.Infrastructure, client metalcloud.MetalCloudClient, withImports bool) ([]hclBlock, error) {
+	names := terraformNames{}
+	blocks := []hclBlock{}
+	imports := []hclBlock{}
+	dependencies := []string{}
+
+	provider := hclBlock{
+		Type: "terraform",
+		Blocks: []hclBlock{
+			{
+				Type: "required_providers",
+				Attributes: []hclAttribute{
+					{Name: "metalcloud", Value: "{ source = \"metalsoft-io/metalcloud\" }"},
+				},
+			},
+		},
+	}
+	blocks = append(blocks, provider)
+
+	infraName := names.get(infra.InfrastructureLabel, infra.InfrastructureID)
+	infraRef := fmt.Sprintf("data.metalcloud_infrastructure.%s.infrastructure_id", infraName)
+
+	infraData := hclBlock{Type: "data", Labels: []string{"metalcloud_infrastructure", infraName}}
+	infraData.set("infrastructure_label", hclString(infra.InfrastructureLabel))
+	infraData.set("datacenter_name", hclString(infra.DatacenterName))
+	blocks = append(blocks, infraData)
+
+	nwList, err := client.Networks(infra.InfrastructureID)
+	if err != nil {
+		return nil, err
+	}
+
+	networks := []metalcloud.Network{}
+	for _, n := range *nwList {
+		networks = append(networks, n)
+	}
+	sort.Slice(networks, func(i, j int) bool {
+		return networks[i].NetworkID < networks[j].NetworkID
+	})
+
+	networkRefs := map[int]string{}
+
+	for _, n := range networks {
+		label := n.NetworkLabel
+		if n.NetworkOperation != nil && n.NetworkOperation.NetworkLabel != "" {
+			label = n.NetworkOperation.NetworkLabel
+		}
+
+		name := names.get(label, n.NetworkID)
+		address := "metalcloud_network." + name
+		networkRefs[n.NetworkID] = address + ".id"
+
+		b := hclBlock{Type: "resource", Labels: []string{"metalcloud_network", name}}
+		b.set("infrastructure_id", infraRef)
+		b.set("network_label", hclString(label))
+		b.set("network_type", hclString(n.NetworkType))
+		blocks = append(blocks, b)
+
+		imports = append(imports, importBlock(address, n.NetworkID))
+		dependencies = append(dependencies, address)
+	}
+
+	iaMap, err := client.InstanceArrays(infra.InfrastructureID)
+	if err != nil {
+		return nil, err
+	}
+
+	iaList := []metalcloud.InstanceArray{}
+	for _, ia := range *iaMap {
+		iaList = append(iaList, ia)
+	}
+	sort.Slice(iaList, func(i, j int) bool {
+		return iaList[i].InstanceArrayID < iaList[j].InstanceArrayID
+	})
+
+	iaRefs := map[int]string{}
+
+	for _, ia := range iaList {
+		// the provider manages the desired state which is held by the operation object
+		op := metalcloud.InstanceArrayOperation{
+			InstanceArrayLabel:           ia.InstanceArrayLabel,
+			InstanceArrayInstanceCount:   ia.InstanceArrayInstanceCount,
+			InstanceArrayBootMethod:      ia.InstanceArrayBootMethod,
+			InstanceArrayFirewallManaged: ia.InstanceArrayFirewallManaged,
+			InstanceArrayFirewallRules:   ia.InstanceArrayFirewallRules,
+			VolumeTemplateID:             ia.VolumeTemplateID,
+			InstanceArrayCustomVariables: ia.InstanceArrayCustomVariables,
+		}
+		if ia.InstanceArrayOperation != nil {
+			op = *ia.InstanceArrayOperation
+		}
+
+		name := names.get(op.InstanceArrayLabel, ia.InstanceArrayID)
+		address := "metalcloud_instance_array." + name
+		iaRefs[ia.InstanceArrayID] = address + ".id"
+
+		b := hclBlock{Type: "resource", Labels: []string{"metalcloud_instance_array", name}}
+		b.set("infrastructure_id", infraRef)
+		b.set("instance_array_label", hclString(op.InstanceArrayLabel))
+		b.set("instance_array_instance_count", hclInt(op.InstanceArrayInstanceCount))
+		if op.InstanceArrayBootMethod != "" {
+			b.set("instance_array_boot_method", hclString(op.InstanceArrayBootMethod))
+		}
+		if op.VolumeTemplateID != 0 {
+			b.set("volume_template_id", hclInt(op.VolumeTemplateID))
+		}
+		b.set("instance_array_firewall_managed", hclBool(op.InstanceArrayFirewallManaged))
+		if v, ok := hclStringMap(op.InstanceArrayCustomVariables); ok {
+			b.set("instance_array_custom_variables", v)
+		}
+
+		interfaces := append([]metalcloud.InstanceArrayInterface{}, ia.InstanceArrayInterfaces...)
+		sort.Slice(interfaces, func(i, j int) bool {
+			return interfaces[i].InstanceArrayInterfaceIndex < interfaces[j].InstanceArrayInterfaceIndex
+		})
+
+		for _, intf := range interfaces {
+			ref, ok := networkRefs[intf.NetworkID]
+			if !ok {
+				continue
+			}
+
+			ib := hclBlock{Type: "interface"}
+			ib.set("interface_index", hclInt(intf.InstanceArrayInterfaceIndex))
+			ib.set("network_id", ref)
+			b.Blocks = append(b.Blocks, ib)
+		}
+
+		networkProfiles, err := client.NetworkProfileListByInstanceArray(ia.InstanceArrayID)
+		if err != nil {
+			return nil, err
+		}
+
+		networkIDs := []int{}
+		for networkID := range *networkProfiles {
+			networkIDs = append(networkIDs, networkID)
+		}
+		sort.Ints(networkIDs)
+
+		for _, networkID := range networkIDs {
+			ref, ok := networkRefs[networkID]
+			if !ok || (*networkProfiles)[networkID] == 0 {
+				continue
+			}
+
+			pb := hclBlock{Type: "network_profile"}
+			pb.set("network_id", ref)
+			pb.set("network_profile_id", hclInt((*networkProfiles)[networkID]))
+			b.Blocks = append(b.Blocks, pb)
+		}
+
+		for _, fw := range op.InstanceArrayFirewallRules {
+			fb := hclBlock{Type: "firewall_rule"}
+			if fw.FirewallRuleDescription != "" {
+				fb.set("firewall_rule_description", hclString(fw.FirewallRuleDescription))
+			}
+			if fw.FirewallRuleProtocol != "" {
+				fb.set("firewall_rule_protocol", hclString(fw.FirewallRuleProtocol))
+			}
+			if fw.FirewallRuleIPAddressType != "" {
+				fb.set("firewall_rule_ip_address_type", hclString(fw.FirewallRuleIPAddressType))
+			}
+			if fw.FirewallRulePortRangeStart != 0 {
+				fb.set("firewall_rule_port_range_start", hclInt(fw.FirewallRulePortRangeStart))
+			}
+			if fw.FirewallRulePortRangeEnd != 0 {
+				fb.set("firewall_rule_port_range_end", hclInt(fw.FirewallRulePortRangeEnd))
+			}
+			if fw.FirewallRuleSourceIPAddressRangeStart != "" {
+				fb.set("firewall_rule_source_ip_address_range_start", hclString(fw.FirewallRuleSourceIPAddressRangeStart))
+			}
+			if fw.FirewallRuleSourceIPAddressRangeEnd != "" {
+				fb.set("firewall_rule_source_ip_address_range_end", hclString(fw.FirewallRuleSourceIPAddressRangeEnd))
+			}
+			if fw.FirewallRuleDestinationIPAddressRangeStart != "" {
+				fb.set("firewall_rule_destination_ip_address_range_start", hclString(fw.FirewallRuleDestinationIPAddressRangeStart))
+			}
+			if fw.FirewallRuleDestinationIPAddressRangeEnd != "" {
+				fb.set("firewall_rule_destination_ip_address_range_end", hclString(fw.FirewallRuleDestinationIPAddressRangeEnd))
+			}
+			fb.set("firewall_rule_enabled", hclBool(fw.FirewallRuleEnabled))
+			b.Blocks = append(b.Blocks, fb)
+		}
+
+		blocks = append(blocks, b)
+
+		imports = append(imports, importBlock(address, ia.InstanceArrayID))
+		dependencies = append(dependencies, address)
+	}
+
+	daMap, err := client.DriveArrays(infra.InfrastructureID)
+	if err != nil {
+		return nil, err
+	}
+
+	daList := []metalcloud.DriveArray{}
+	for _, da := range *daMap {
+		daList = append(daList, da)
+	}
+	sort.Slice(daList, func(i, j int) bool {
+		return daList[i].DriveArrayID < daList[j].DriveArrayID
+	})
+
+	for _, da := range daList {
+		label := da.DriveArrayLabel
+		storageType := da.DriveArrayStorageType
+		size := da.DriveSizeMBytesDefault
+		templateID := da.VolumeTemplateID
+		if op := da.DriveArrayOperation; op != nil {
+			label = op.DriveArrayLabel
+			storageType = op.DriveArrayStorageType
+			size = op.DriveSizeMBytesDefault
+			templateID = op.VolumeTemplateID
+		}
+
+		name := names.get(label, da.DriveArrayID)
+		address := "metalcloud_drive_array." + name
+
+		b := hclBlock{Type: "resource", Labels: []string{"metalcloud_drive_array", name}}
+		b.set("infrastructure_id", infraRef)
+		if ref, ok := iaRefs[da.InstanceArrayID]; ok {
+			b.set("instance_array_id", ref)
+		}
+		b.set("drive_array_label", hclString(label))
+		b.set("drive_array_storage_type", hclString(storageType))
+		b.set("drive_size_mbytes_default", hclInt(size))
+		if templateID != 0 {
+			b.set("volume_template_id", hclInt(templateID))
+		}
+		blocks = append(blocks, b)
+
+		imports = append(imports, importBlock(address, da.DriveArrayID))
+		dependencies = append(dependencies, address)
+	}
+
+	sdMap, err := client.SharedDrives(infra.InfrastructureID)
+	if err != nil {
+		return nil, err
+	}
+
+	sdList := []metalcloud.SharedDrive{}
+	for _, sd := range *sdMap {
+		sdList = append(sdList, sd)
+	}
+	sort.Slice(sdList, func(i, j int) bool {
+		return sdList[i].SharedDriveID < sdList[j].SharedDriveID
+	})
+
+	for _, sd := range sdList {
+		name := names.get(sd.SharedDriveLabel, sd.SharedDriveID)
+		address := "metalcloud_shared_drive." + name
+
+		attached := []string{}
+		for _, iaID := range sd.SharedDriveAttachedInstanceArrays {
+			if ref, ok := iaRefs[iaID]; ok {
+				attached = append(attached, ref)
+			}
+		}
+
+		b := hclBlock{Type: "resource", Labels: []string{"metalcloud_shared_drive", name}}
+		b.set("infrastructure_id", infraRef)
+		b.set("shared_drive_label", hclString(sd.SharedDriveLabel))
+		b.set("shared_drive_size_mbytes", hclInt(sd.SharedDriveSizeMbytes))
+		b.set("shared_drive_storage_type", hclString(sd.SharedDriveStorageType))
+		b.set("shared_drive_attached_instance_arrays", hclList(attached))
+		blocks = append(blocks, b)
+
+		imports = append(imports, importBlock(address, sd.SharedDriveID))
+		dependencies = append(dependencies, address)
+	}
+
+	deployerName := names.get(infra.InfrastructureLabel+"_deployer", infra.InfrastructureID)
+	deployerAddress := "metalcloud_infrastructure_deployer." + deployerName
+
+	deployer := hclBlock{Type: "resource", Labels: []string{"metalcloud_infrastructure_deployer", deployerName}}
+	deployer.set("infrastructure_id", infraRef)
+	deployer.set("prevent_deploy", hclBool(true))
+	deployer.set("depends_on", hclList(dependencies))
+	blocks = append(blocks, deployer)
+
+	imports = append(imports, importBlock(deployerAddress, infra.InfrastructureID))
+
+	if withImports {
+		blocks = append(blocks, imports...)
+	}
+
+	return blocks, nil
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	. "github.com/onsi/gomega"
+)
+
+func TestExportTerraformCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	infra := metalcloud.Infrastructure{
+		InfrastructureID:    10002,
+		InfrastructureLabel: "test-infra",
+		DatacenterName:      "dc1",
+	}
+
+	ia := metalcloud.InstanceArray{
+		InstanceArrayID:            11,
+		InstanceArrayLabel:         "web-servers",
+		InstanceArrayInstanceCount: 2,
+		VolumeTemplateID:           70,
+		InstanceArrayInterfaces: []metalcloud.InstanceArrayInterface{
+			{InstanceArrayInterfaceIndex: 0, NetworkID: 30},
+		},
+		InstanceArrayFirewallRules: []metalcloud.FirewallRule{
+			{
+				FirewallRuleDescription:    "ssh",
+				FirewallRuleProtocol:       "tcp",
+				FirewallRuleIPAddressType:  "ipv4",
+				FirewallRulePortRangeStart: 22,
+				FirewallRulePortRangeEnd:   22,
+				FirewallRuleEnabled:        true,
+			},
+		},
+		InstanceArrayCustomVariables: map[string]interface{}{"role": "${web}"},
+	}
+
+	client.EXPECT().
+		InfrastructureGet(infra.InfrastructureID).
+		Return(&infra, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		Networks(infra.InfrastructureID).
+		Return(&map[string]metalcloud.Network{
+			"wan": {NetworkID: 30, NetworkType: "wan", NetworkLabel: "wan"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InstanceArrays(infra.InfrastructureID).
+		Return(&map[string]metalcloud.InstanceArray{"web-servers": ia}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		NetworkProfileListByInstanceArray(ia.InstanceArrayID).
+		Return(&map[int]int{30: 40}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		DriveArrays(infra.InfrastructureID).
+		Return(&map[string]metalcloud.DriveArray{
+			"data": {DriveArrayID: 50, DriveArrayLabel: "data", DriveArrayStorageType: "iscsi_ssd", DriveSizeMBytesDefault: 40960, InstanceArrayID: ia.InstanceArrayID},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SharedDrives(infra.InfrastructureID).
+		Return(&map[string]metalcloud.SharedDrive{
+			"shared": {SharedDriveID: 60, SharedDriveLabel: "shared", SharedDriveSizeMbytes: 2048, SharedDriveStorageType: "iscsi_hdd", SharedDriveAttachedInstanceArrays: []int{ia.InstanceArrayID}},
+		}, nil).
+		AnyTimes()
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"infrastructure_id_or_label": "10002",
+	})
+
+	ret, err := exportTerraformCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("data \"metalcloud_infrastructure\" \"test_infra\" {\n  infrastructure_label = \"test-infra\"\n  datacenter_name      = \"dc1\"\n}"))
+	Expect(ret).To(ContainSubstring("resource \"metalcloud_network\" \"wan\" {"))
+	Expect(ret).To(ContainSubstring("resource \"metalcloud_instance_array\" \"web_servers\" {"))
+	Expect(ret).To(ContainSubstring("  instance_array_instance_count   = 2\n"))
+	Expect(ret).To(ContainSubstring("  instance_array_custom_variables = { \"role\" = \"$${web}\" }\n"))
+	Expect(ret).To(ContainSubstring("  interface {\n    interface_index = 0\n    network_id      = metalcloud_network.wan.id\n  }"))
+	Expect(ret).To(ContainSubstring("    network_profile_id = 40\n"))
+	Expect(ret).To(ContainSubstring("    firewall_rule_port_range_start = 22\n"))
+	Expect(ret).To(ContainSubstring("  instance_array_id         = metalcloud_instance_array.web_servers.id\n"))
+	Expect(ret).To(ContainSubstring("  shared_drive_attached_instance_arrays = [metalcloud_instance_array.web_servers.id]\n"))
+	Expect(ret).To(ContainSubstring("import {\n  to = metalcloud_instance_array.web_servers\n  id = \"11\"\n}"))
+	Expect(ret).To(ContainSubstring("import {\n  to = metalcloud_drive_array.data\n  id = \"50\"\n}"))
+	Expect(ret).To(ContainSubstring("import {\n  to = metalcloud_infrastructure_deployer.test_infra_deployer\n  id = \"10002\"\n}"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"infrastructure_id_or_label": "10002",
+		"no_import":                  true,
+	})
+
+	ret, err = exportTerraformCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).NotTo(ContainSubstring("import {"))
+}
@@ -68,6 +68,93 @@ var FirewallRuleCmds = []command.Command{
 		},
 		ExecuteFunc: firewallRuleDeleteCmd,
 	},
+	{
+		Description:  "Export instance array firewall rules to a file that can be used with sync.",
+		Subject:      "firewall-rule",
+		AltSubject:   "fw",
+		Predicate:    "export",
+		AltPredicate: "dump",
+		FlagSet:      flag.NewFlagSet("export firewall rules", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"instance_array_id": c.FlagSet.Int("ia", command.NilDefaultInt, colors.Red("(Required)")+" The instance array id"),
+				"format":            c.FlagSet.String("format", "yaml", colors.Green("(Optional)")+" The output format. Supported values are 'json','yaml'."),
+			}
+		},
+		ExecuteFunc: firewallRuleExportCmd,
+		Example: `
+metalcloud-cli firewall-rule export --ia 12 > rules.yaml
+`,
+	},
+	{
+		Description:  "Replace instance array firewall rules with the rules from a file.",
+		Subject:      "firewall-rule",
+		AltSubject:   "fw",
+		Predicate:    "sync",
+		AltPredicate: "apply",
+		FlagSet:      flag.NewFlagSet("sync firewall rules", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"instance_array_id":     c.FlagSet.Int("ia", command.NilDefaultInt, colors.Green("(Optional)")+" The instance array id. Defaults to the instanceArrayID from the file."),
+				"read_config_from_file": c.FlagSet.String("file", command.NilDefaultStr, colors.Red("(Required)")+" Read the firewall rules from this file."),
+				"read_config_from_pipe": c.FlagSet.Bool("pipe", false, colors.Green("(Flag)")+" If set, read the firewall rules from the pipe instead of from a file."),
+				"format":                c.FlagSet.String("format", "yaml", colors.Green("(Optional)")+" The input format. Supported values are 'json','yaml'."),
+				"dry_run":               c.FlagSet.Bool("dry-run", false, colors.Green("(Flag)")+" If set only the changes are shown."),
+				"skip_lint":             c.FlagSet.Bool("skip-lint", false, colors.Green("(Flag)")+" If set the rules are not checked with firewall-rule lint before they are applied."),
+				"autoconfirm":           c.FlagSet.Bool("autoconfirm", false, colors.Green("(Flag)")+" If set it will assume action is confirmed"),
+			}
+		},
+		ExecuteFunc: firewallRuleSyncCmd,
+		Example: `
+The rules that are in the file but not on the instance array are added, the rules that are only on the instance array
+are removed and the rules whose description or enabled flag differ are updated, all in a single edit of the instance array.
+The instance array is read again before the edit and nothing is changed if it was modified in the meantime.
+The rules are checked as with firewall-rule lint first. Warnings are shown and errors stop the sync.
+
+rules.yaml:
+instanceArrayID: 12
+rules:
+  - protocol: tcp
+    port: "22"
+    source: 10.0.0.0-10.0.0.255
+    description: ssh from the management network
+  - protocol: tcp
+    port: 8000-8080
+    ipAddressType: ipv6
+  - protocol: icmp
+    enabled: false
+
+metalcloud-cli firewall-rule sync --file rules.yaml --dry-run
+metalcloud-cli firewall-rule sync --ia 13 --file rules.yaml --autoconfirm
+`,
+	},
+	{
+		Description:  "Check instance array firewall rules for duplicates, shadowed rules and sensitive ports open to any address.",
+		Subject:      "firewall-rule",
+		AltSubject:   "fw",
+		Predicate:    "lint",
+		AltPredicate: "check",
+		FlagSet:      flag.NewFlagSet("lint firewall rules", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"instance_array_id":     c.FlagSet.Int("ia", command.NilDefaultInt, colors.Green("(Optional)")+" The instance array id. Required unless -file or -pipe is used."),
+				"read_config_from_file": c.FlagSet.String("file", command.NilDefaultStr, colors.Green("(Optional)")+" Check the firewall rules from this file instead, in the yaml or json format used by firewall-rule sync."),
+				"read_config_from_pipe": c.FlagSet.Bool("pipe", false, colors.Green("(Flag)")+" If set, check the firewall rules from the pipe instead, in the format used by firewall-rule sync."),
+				"format":                c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
+			}
+		},
+		ExecuteFunc: firewallRuleLintCmd,
+		Example: `
+The following problems are reported, the INDEX column is the index shown by firewall-rule list:
+  invalid      unknown protocols or IP address types, invalid ports and addresses, IPv6 addresses in ipv4 rules and the reverse
+  duplicate    rules identical to an earlier rule
+  shadowed     rules whose traffic is already allowed by an earlier, broader rule
+  open-to-any  rules that allow all traffic or sensitive ports such as ssh, rdp or databases from any source to any destination
+
+metalcloud-cli firewall-rule lint --ia 12
+metalcloud-cli firewall-rule lint --file rules.yaml --format yaml
+`,
+	},
 }
 
 func firewallRuleListCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
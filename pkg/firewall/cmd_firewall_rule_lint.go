@@ -0,0 +1,402 @@
+package firewall
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/ipam"
+	"github.com/metalsoft-io/tableformatter"
+)
+
+const (
+	lintSeverityError   = "error"
+	lintSeverityWarning = "warning"
+)
+
+// sensitivePorts are the ports of services that should not be reachable from any address
+var sensitivePorts = []struct {
+	Port     int
+	Protocol string
+	Service  string
+}{
+	{22, "tcp", "ssh"},
+	{23, "tcp", "telnet"},
+	{111, "tcp", "rpcbind"},
+	{135, "tcp", "msrpc"},
+	{139, "tcp", "netbios"},
+	{161, "udp", "snmp"},
+	{445, "tcp", "smb"},
+	{623, "udp", "ipmi"},
+	{1433, "tcp", "mssql"},
+	{2049, "tcp", "nfs"},
+	{2375, "tcp", "docker"},
+	{3306, "tcp", "mysql"},
+	{3389, "tcp", "rdp"},
+	{5432, "tcp", "postgresql"},
+	{5900, "tcp", "vnc"},
+	{6379, "tcp", "redis"},
+	{9200, "tcp", "elasticsearch"},
+	{11211, "tcp", "memcached"},
+	{27017, "tcp", "mongodb"},
+}
+
+// lintFinding is a problem found in a firewall rule. Index is the position of the rule as shown by firewall-rule list.
+type lintFinding struct {
+	Index    int
+	Severity string
+	Check    string
+	Message  string
+}
+
+// lintRule is a firewall rule with its addresses parsed. Nil addresses match any address.
+type lintRule struct {
+	rule        metalcloud.FirewallRule
+	source      *ipam.Range
+	destination *ipam.Range
+	valid       bool
+}
+
+func firewallRuleLintCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	var rules []metalcloud.FirewallRule
+	title := ""
+
+	if _, ok := command.GetStringParamOk(c.Arguments["read_config_from_file"]); ok || command.GetBoolParam(c.Arguments["read_config_from_pipe"]) {
+		// the output format can be a table, so the input is read as yaml which also accepts json
+		input := command.Command{Arguments: map[string]interface{}{}}
+		for k, v := range c.Arguments {
+			input.Arguments[k] = v
+		}
+		inputFormat := "yaml"
+		input.Arguments["format"] = &inputFormat
+
+		var set firewallRuleSet
+		err := command.GetRawObjectFromCommand(&input, &set)
+		if err != nil {
+			return "", err
+		}
+
+		rules, err = firewallRulesFromSet(set)
+		if err != nil {
+			return "", err
+		}
+
+		title = "Problems found in the firewall rules of the file"
+	} else {
+		instanceArrayID, ok := command.GetIntParamOk(c.Arguments["instance_array_id"])
+		if !ok {
+			return "", fmt.Errorf("-ia or -file is required")
+		}
+
+		retIA, err := client.InstanceArrayGet(instanceArrayID)
+		if err != nil {
+			return "", err
+		}
+
+		rules = retIA.InstanceArrayOperation.InstanceArrayFirewallRules
+		title = fmt.Sprintf("Problems found in the firewall rules of instance array %s (#%d)", retIA.InstanceArrayLabel, retIA.InstanceArrayID)
+	}
+
+	findings := lintFirewallRules(rules)
+
+	format := command.GetStringParam(c.Arguments["format"])
+	if len(findings) == 0 && format == "" {
+		return fmt.Sprintf("No problems found in %d firewall rules.\n", len(rules)), nil
+	}
+
+	return renderLintFindings(findings, title, format)
+}
+
+func renderLintFindings(findings []lintFinding, title string, format string) (string, error) {
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "INDEX",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "SEVERITY",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 8,
+		},
+		{
+			FieldName: "CHECK",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "MESSAGE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 60,
+		},
+	}
+
+	data := [][]interface{}{}
+	for _, f := range findings {
+		data = append(data, []interface{}{
+			f.Index,
+			f.Severity,
+			f.Check,
+			f.Message,
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	return table.RenderTable("Problems", title, format)
+}
+
+// lintFirewallRules returns the problems found in a list of firewall rules in the order of the rules
+func lintFirewallRules(rules []metalcloud.FirewallRule) []lintFinding {
+
+	findings := []lintFinding{}
+	parsed := []lintRule{}
+
+	for i, fw := range rules {
+		r, problems := parseLintRule(fw)
+		for _, p := range problems {
+			findings = append(findings, lintFinding{Index: i, Severity: lintSeverityError, Check: "invalid", Message: p})
+		}
+		parsed = append(parsed, r)
+
+		if !r.valid {
+			continue
+		}
+
+		if f, ok := lintRuleShadowed(i, parsed); ok {
+			findings = append(findings, f)
+		}
+
+		findings = append(findings, lintRuleOpenToAny(i, r)...)
+	}
+
+	return findings
+}
+
+// parseLintRule parses the addresses of a rule and returns the problems that make the rule invalid
+func parseLintRule(fw metalcloud.FirewallRule) (lintRule, []string) {
+
+	r := lintRule{rule: fw}
+	problems := []string{}
+
+	switch fw.FirewallRuleProtocol {
+	case "", "all", "icmp", "tcp", "udp":
+	default:
+		problems = append(problems, fmt.Sprintf("unknown protocol %s", fw.FirewallRuleProtocol))
+	}
+
+	if fw.FirewallRuleIPAddressType != "ipv4" && fw.FirewallRuleIPAddressType != "ipv6" {
+		problems = append(problems, fmt.Sprintf("unknown IP address type %q", fw.FirewallRuleIPAddressType))
+	}
+
+	if fw.FirewallRulePortRangeStart < 0 || fw.FirewallRulePortRangeEnd > 65535 || fw.FirewallRulePortRangeStart > fw.FirewallRulePortRangeEnd {
+		problems = append(problems, fmt.Sprintf("invalid port range %d-%d", fw.FirewallRulePortRangeStart, fw.FirewallRulePortRangeEnd))
+	}
+
+	var err error
+
+	r.source, err = parseLintAddress(fw.FirewallRuleSourceIPAddressRangeStart, fw.FirewallRuleSourceIPAddressRangeEnd)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("invalid source: %v", err))
+	}
+
+	r.destination, err = parseLintAddress(fw.FirewallRuleDestinationIPAddressRangeStart, fw.FirewallRuleDestinationIPAddressRangeEnd)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("invalid destination: %v", err))
+	}
+
+	for _, a := range []struct {
+		name string
+		r    *ipam.Range
+	}{{"source", r.source}, {"destination", r.destination}} {
+		if a.r == nil {
+			continue
+		}
+
+		if fw.FirewallRuleIPAddressType == "ipv4" && !a.r.Start.Is4() {
+			problems = append(problems, fmt.Sprintf("the %s %s is an IPv6 address but the rule is for ipv4", a.name, a.r))
+		}
+
+		if fw.FirewallRuleIPAddressType == "ipv6" && a.r.Start.Is4() {
+			problems = append(problems, fmt.Sprintf("the %s %s is an IPv4 address but the rule is for ipv6", a.name, a.r))
+		}
+	}
+
+	r.valid = len(problems) == 0
+
+	return r, problems
+}
+
+// parseLintAddress parses an address, a range of addresses or a CIDR. It returns nil if the address is empty.
+func parseLintAddress(start string, end string) (*ipam.Range, error) {
+
+	if start == "" && end == "" {
+		return nil, nil
+	}
+
+	if strings.Contains(start, "/") {
+		p, err := netip.ParsePrefix(start)
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := ipam.PrefixRange(p.Addr().String(), p.Bits())
+		if err != nil {
+			return nil, err
+		}
+		return &r, nil
+	}
+
+	if end == "" {
+		end = start
+	}
+
+	r, err := ipam.AddressRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// lintRuleShadowed checks if the last rule is a duplicate of an earlier rule or matches only traffic already allowed by an earlier rule
+func lintRuleShadowed(i int, parsed []lintRule) (lintFinding, bool) {
+
+	r := parsed[i]
+
+	for j := 0; j < i; j++ {
+		earlier := parsed[j]
+		if !earlier.valid {
+			continue
+		}
+
+		if firewallRuleMatches(earlier.rule, r.rule) {
+			return lintFinding{
+				Index:    i,
+				Severity: lintSeverityWarning,
+				Check:    "duplicate",
+				Message:  fmt.Sprintf("duplicate of rule %d", j),
+			}, true
+		}
+
+		if earlier.rule.FirewallRuleEnabled && lintRuleCovers(earlier, r) {
+			return lintFinding{
+				Index:    i,
+				Severity: lintSeverityWarning,
+				Check:    "shadowed",
+				Message:  fmt.Sprintf("has no effect, its traffic is already allowed by rule %d (%s)", j, describeFirewallRule(earlier.rule)),
+			}, true
+		}
+	}
+
+	return lintFinding{}, false
+}
+
+// lintRuleCovers returns true if all the traffic matched by b is also matched by a
+func lintRuleCovers(a lintRule, b lintRule) bool {
+
+	if a.rule.FirewallRuleIPAddressType != b.rule.FirewallRuleIPAddressType {
+		return false
+	}
+
+	if !protocolCovers(a.rule.FirewallRuleProtocol, b.rule.FirewallRuleProtocol) {
+		return false
+	}
+
+	if a.rule.FirewallRulePortRangeStart != 0 {
+		if b.rule.FirewallRulePortRangeStart == 0 ||
+			b.rule.FirewallRulePortRangeStart < a.rule.FirewallRulePortRangeStart ||
+			b.rule.FirewallRulePortRangeEnd > a.rule.FirewallRulePortRangeEnd {
+			return false
+		}
+	}
+
+	return addressCovers(a.source, b.source) && addressCovers(a.destination, b.destination)
+}
+
+func protocolCovers(a string, b string) bool {
+	if a == "" || a == "all" {
+		return true
+	}
+	return a == b
+}
+
+func addressCovers(a *ipam.Range, b *ipam.Range) bool {
+	if a == nil {
+		return true
+	}
+	if b == nil {
+		return false
+	}
+	return a.Contains(*b)
+}
+
+// lintAddressIsAny returns true if the address is empty or covers the entire address family, such as 0.0.0.0/0,
+// 0.0.0.0-255.255.255.255 or ::/0
+func lintAddressIsAny(r *ipam.Range) bool {
+	if r == nil {
+		return true
+	}
+
+	all, err := ipam.PrefixRange(r.Start.String(), 0)
+	if err != nil {
+		return false
+	}
+
+	return r.Start == all.Start && r.End == all.End
+}
+
+// lintRuleOpenToAny checks if an enabled rule allows a sensitive port from any source to any destination
+func lintRuleOpenToAny(i int, r lintRule) []lintFinding {
+
+	findings := []lintFinding{}
+
+	if !r.rule.FirewallRuleEnabled || !lintAddressIsAny(r.source) || !lintAddressIsAny(r.destination) {
+		return findings
+	}
+
+	protocol := r.rule.FirewallRuleProtocol
+	start := r.rule.FirewallRulePortRangeStart
+	end := r.rule.FirewallRulePortRangeEnd
+
+	if protocol == "icmp" {
+		return findings
+	}
+
+	if (protocol == "" || protocol == "all") && start == 0 {
+		return append(findings, lintFinding{
+			Index:    i,
+			Severity: lintSeverityWarning,
+			Check:    "open-to-any",
+			Message:  "allows all traffic from any source to any destination",
+		})
+	}
+
+	services := []string{}
+	for _, p := range sensitivePorts {
+		if protocol != "" && protocol != "all" && protocol != p.Protocol {
+			continue
+		}
+		if start == 0 || (start <= p.Port && p.Port <= end) {
+			services = append(services, fmt.Sprintf("%d (%s)", p.Port, p.Service))
+		}
+	}
+
+	if len(services) > 0 {
+		findings = append(findings, lintFinding{
+			Index:    i,
+			Severity: lintSeverityWarning,
+			Check:    "open-to-any",
+			Message:  fmt.Sprintf("allows sensitive ports from any source to any destination: %s", strings.Join(services, ", ")),
+		})
+	}
+
+	return findings
+}
@@ -0,0 +1,229 @@
+package firewall
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	. "github.com/onsi/gomega"
+)
+
+func TestLintFirewallRules(t *testing.T) {
+	RegisterTestingT(t)
+
+	rules := []metalcloud.FirewallRule{
+		// 0: ssh from a /24
+		{
+			FirewallRuleProtocol:                  "tcp",
+			FirewallRuleIPAddressType:             "ipv4",
+			FirewallRulePortRangeStart:            22,
+			FirewallRulePortRangeEnd:              22,
+			FirewallRuleSourceIPAddressRangeStart: "10.0.0.0/24",
+			FirewallRuleSourceIPAddressRangeEnd:   "10.0.0.0/24",
+			FirewallRuleEnabled:                   true,
+		},
+		// 1: duplicate of 0
+		{
+			FirewallRuleProtocol:                  "tcp",
+			FirewallRuleIPAddressType:             "ipv4",
+			FirewallRulePortRangeStart:            22,
+			FirewallRulePortRangeEnd:              22,
+			FirewallRuleSourceIPAddressRangeStart: "10.0.0.0/24",
+			FirewallRuleSourceIPAddressRangeEnd:   "10.0.0.0/24",
+			FirewallRuleDescription:               "again",
+			FirewallRuleEnabled:                   true,
+		},
+		// 2: shadowed by 0
+		{
+			FirewallRuleProtocol:                  "tcp",
+			FirewallRuleIPAddressType:             "ipv4",
+			FirewallRulePortRangeStart:            22,
+			FirewallRulePortRangeEnd:              22,
+			FirewallRuleSourceIPAddressRangeStart: "10.0.0.10",
+			FirewallRuleSourceIPAddressRangeEnd:   "10.0.0.20",
+			FirewallRuleEnabled:                   true,
+		},
+		// 3: not shadowed, the source is wider
+		{
+			FirewallRuleProtocol:                  "tcp",
+			FirewallRuleIPAddressType:             "ipv4",
+			FirewallRulePortRangeStart:            22,
+			FirewallRulePortRangeEnd:              22,
+			FirewallRuleSourceIPAddressRangeStart: "10.0.0.200",
+			FirewallRuleSourceIPAddressRangeEnd:   "10.0.1.10",
+			FirewallRuleEnabled:                   true,
+		},
+		// 4: IPv6 address in an ipv4 rule
+		{
+			FirewallRuleProtocol:                  "tcp",
+			FirewallRuleIPAddressType:             "ipv4",
+			FirewallRulePortRangeStart:            443,
+			FirewallRulePortRangeEnd:              443,
+			FirewallRuleSourceIPAddressRangeStart: "2001:db8::1",
+			FirewallRuleSourceIPAddressRangeEnd:   "2001:db8::1",
+			FirewallRuleEnabled:                   true,
+		},
+		// 5: databases open to everyone
+		{
+			FirewallRuleProtocol:       "tcp",
+			FirewallRuleIPAddressType:  "ipv4",
+			FirewallRulePortRangeStart: 3000,
+			FirewallRulePortRangeEnd:   6000,
+			FirewallRuleEnabled:        true,
+		},
+		// 6: everything open to everyone but disabled
+		{
+			FirewallRuleProtocol:      "all",
+			FirewallRuleIPAddressType: "ipv6",
+		},
+		// 7: everything open to everyone
+		{
+			FirewallRuleIPAddressType: "ipv6",
+			FirewallRuleEnabled:       true,
+		},
+		// 8: shadowed by 7
+		{
+			FirewallRuleProtocol:       "udp",
+			FirewallRuleIPAddressType:  "ipv6",
+			FirewallRulePortRangeStart: 53,
+			FirewallRulePortRangeEnd:   53,
+			FirewallRuleEnabled:        true,
+		},
+	}
+
+	findings := lintFirewallRules(rules)
+
+	Expect(findings).To(Equal([]lintFinding{
+		{Index: 1, Severity: lintSeverityWarning, Check: "duplicate", Message: "duplicate of rule 0"},
+		{Index: 2, Severity: lintSeverityWarning, Check: "shadowed", Message: "has no effect, its traffic is already allowed by rule 0 (tcp port 22 from 10.0.0.0/24 to any (ipv4))"},
+		{Index: 4, Severity: lintSeverityError, Check: "invalid", Message: "the source 2001:db8::1/128 is an IPv6 address but the rule is for ipv4"},
+		{Index: 5, Severity: lintSeverityWarning, Check: "open-to-any", Message: "allows sensitive ports from any source to any destination: 3306 (mysql), 3389 (rdp), 5432 (postgresql), 5900 (vnc)"},
+		{Index: 7, Severity: lintSeverityWarning, Check: "open-to-any", Message: "allows all traffic from any source to any destination"},
+		{Index: 8, Severity: lintSeverityWarning, Check: "shadowed", Message: "has no effect, its traffic is already allowed by rule 7 (any port any from any to any (ipv6))"},
+	}))
+}
+
+func TestLintFirewallRulesOpenToWholeAddressFamily(t *testing.T) {
+	RegisterTestingT(t)
+
+	rules := []metalcloud.FirewallRule{
+		// 0: ssh from 0.0.0.0/0
+		{
+			FirewallRuleProtocol:                  "tcp",
+			FirewallRuleIPAddressType:             "ipv4",
+			FirewallRulePortRangeStart:            22,
+			FirewallRulePortRangeEnd:              22,
+			FirewallRuleSourceIPAddressRangeStart: "0.0.0.0/0",
+			FirewallRuleSourceIPAddressRangeEnd:   "0.0.0.0/0",
+			FirewallRuleEnabled:                   true,
+		},
+		// 1: rdp from the whole IPv4 range
+		{
+			FirewallRuleProtocol:                  "tcp",
+			FirewallRuleIPAddressType:             "ipv4",
+			FirewallRulePortRangeStart:            3389,
+			FirewallRulePortRangeEnd:              3389,
+			FirewallRuleSourceIPAddressRangeStart: "0.0.0.0",
+			FirewallRuleSourceIPAddressRangeEnd:   "255.255.255.255",
+			FirewallRuleEnabled:                   true,
+		},
+		// 2: ssh from ::/0 to the whole IPv6 range
+		{
+			FirewallRuleProtocol:                       "tcp",
+			FirewallRuleIPAddressType:                  "ipv6",
+			FirewallRulePortRangeStart:                 22,
+			FirewallRulePortRangeEnd:                   22,
+			FirewallRuleSourceIPAddressRangeStart:      "::/0",
+			FirewallRuleSourceIPAddressRangeEnd:        "::/0",
+			FirewallRuleDestinationIPAddressRangeStart: "::",
+			FirewallRuleDestinationIPAddressRangeEnd:   "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			FirewallRuleEnabled:                        true,
+		},
+		// 3: ssh from a range that is almost the whole IPv4 range
+		{
+			FirewallRuleProtocol:                  "tcp",
+			FirewallRuleIPAddressType:             "ipv4",
+			FirewallRulePortRangeStart:            22,
+			FirewallRulePortRangeEnd:              22,
+			FirewallRuleSourceIPAddressRangeStart: "0.0.0.1",
+			FirewallRuleSourceIPAddressRangeEnd:   "255.255.255.255",
+			FirewallRuleEnabled:                   true,
+		},
+	}
+
+	findings := lintFirewallRules(rules)
+
+	Expect(findings).To(Equal([]lintFinding{
+		{Index: 0, Severity: lintSeverityWarning, Check: "open-to-any", Message: "allows sensitive ports from any source to any destination: 22 (ssh)"},
+		{Index: 1, Severity: lintSeverityWarning, Check: "open-to-any", Message: "allows sensitive ports from any source to any destination: 3389 (rdp)"},
+		{Index: 2, Severity: lintSeverityWarning, Check: "open-to-any", Message: "allows sensitive ports from any source to any destination: 22 (ssh)"},
+		{Index: 3, Severity: lintSeverityWarning, Check: "shadowed", Message: "has no effect, its traffic is already allowed by rule 0 (tcp port 22 from 0.0.0.0/0 to any (ipv4))"},
+	}))
+}
+
+func TestFirewallRuleLintCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		InstanceArrayGet(11).
+		Return(getFirewallSyncInstanceArray(1), nil).
+		AnyTimes()
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"instance_array_id": 11,
+	})
+
+	ret, err := firewallRuleLintCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal("No problems found in 3 firewall rules.\n"))
+
+	name := writeFirewallRuleSetFile(t, "rules:\n  - protocol: tcp\n    port: \"22\"\n  - protocol: tcp\n    port: 20-25\n    source: 10.0.0.1\n")
+	defer syscall.Unlink(name)
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": name,
+		"format":                "csv",
+	})
+
+	ret, err = firewallRuleLintCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal("INDEX,SEVERITY,CHECK,MESSAGE\n0,warning,open-to-any,allows sensitive ports from any source to any destination: 22 (ssh)\n"))
+}
+
+func TestFirewallRuleSyncCmdLintErrors(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		InstanceArrayGet(gomock.Any()).
+		Times(0)
+
+	name := writeFirewallRuleSetFile(t, "instanceArrayID: 11\nrules:\n  - protocol: tcp\n    source: 10.0.0.1\n    ipAddressType: ipv6\n")
+	defer syscall.Unlink(name)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(os.Stdin, &stdout)
+	defer configuration.SetConsoleIOChannel(os.Stdin, os.Stdout)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": name,
+		"format":                "yaml",
+		"autoconfirm":           true,
+	})
+
+	_, err := firewallRuleSyncCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal("the firewall rules of the file have errors. Nothing was changed"))
+	Expect(stdout.String()).To(ContainSubstring("the source 10.0.0.1/32 is an IPv4 address but the rule is for ipv6"))
+}
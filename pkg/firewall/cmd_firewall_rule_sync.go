@@ -0,0 +1,389 @@
+package firewall
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	"gopkg.in/yaml.v3"
+)
+
+// firewallRuleSet is the file format used by firewall-rule export and firewall-rule sync
+type firewallRuleSet struct {
+	InstanceArrayID int                `json:"instanceArrayID,omitempty" yaml:"instanceArrayID,omitempty"`
+	Rules           []firewallRuleSpec `json:"rules" yaml:"rules"`
+}
+
+// firewallRuleSpec is a firewall rule with the ports and addresses written as in firewall-rule add.
+// Empty ports and addresses match any port or address. Rules are enabled unless enabled is set to false.
+type firewallRuleSpec struct {
+	Protocol      string `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	IPAddressType string `json:"ipAddressType,omitempty" yaml:"ipAddressType,omitempty"`
+	Port          string `json:"port,omitempty" yaml:"port,omitempty"`
+	Source        string `json:"source,omitempty" yaml:"source,omitempty"`
+	Destination   string `json:"destination,omitempty" yaml:"destination,omitempty"`
+	Description   string `json:"description,omitempty" yaml:"description,omitempty"`
+	Enabled       *bool  `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+}
+
+// firewallRuleChange is a difference between the current and the desired firewall rules
+type firewallRuleChange struct {
+	Op      string
+	Current metalcloud.FirewallRule
+	Desired metalcloud.FirewallRule
+}
+
+const (
+	firewallRuleAdd    = "+"
+	firewallRuleRemove = "-"
+	firewallRuleUpdate = "~"
+)
+
+func firewallRuleExportCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	instanceArrayID, ok := command.GetIntParamOk(c.Arguments["instance_array_id"])
+	if !ok {
+		return "", fmt.Errorf("-ia is required")
+	}
+
+	retIA, err := client.InstanceArrayGet(instanceArrayID)
+	if err != nil {
+		return "", err
+	}
+
+	set := firewallRuleSet{
+		InstanceArrayID: retIA.InstanceArrayID,
+		Rules:           []firewallRuleSpec{},
+	}
+
+	for _, fw := range retIA.InstanceArrayOperation.InstanceArrayFirewallRules {
+		set.Rules = append(set.Rules, firewallRuleToSpec(fw))
+	}
+
+	switch command.GetStringParam(c.Arguments["format"]) {
+	case "json", "JSON":
+		b, err := json.MarshalIndent(set, "", "\t")
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	case "", "yaml", "YAML":
+		b, err := yaml.Marshal(set)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	}
+
+	return "", fmt.Errorf("invalid format '%s'. Supported values are 'json','yaml'", command.GetStringParam(c.Arguments["format"]))
+}
+
+func firewallRuleSyncCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	var set firewallRuleSet
+	err := command.GetRawObjectFromCommand(c, &set)
+	if err != nil {
+		return "", err
+	}
+
+	instanceArrayID := set.InstanceArrayID
+	if v, ok := command.GetIntParamOk(c.Arguments["instance_array_id"]); ok {
+		instanceArrayID = v
+	}
+
+	if instanceArrayID == 0 {
+		return "", fmt.Errorf("-ia is required when the file does not contain the instanceArrayID")
+	}
+
+	desired, err := firewallRulesFromSet(set)
+	if err != nil {
+		return "", err
+	}
+
+	if !command.GetBoolParam(c.Arguments["skip_lint"]) {
+		findings := lintFirewallRules(desired)
+		if len(findings) > 0 {
+			ret, err := renderLintFindings(findings, "Problems found in the firewall rules of the file", "")
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprint(configuration.GetStdout(), ret)
+		}
+
+		for _, f := range findings {
+			if f.Severity == lintSeverityError {
+				return "", fmt.Errorf("the firewall rules of the file have errors. Nothing was changed")
+			}
+		}
+	}
+
+	retIA, err := client.InstanceArrayGet(instanceArrayID)
+	if err != nil {
+		return "", err
+	}
+
+	if !retIA.InstanceArrayOperation.InstanceArrayFirewallManaged {
+		return "", fmt.Errorf("the instance array %s [#%d] has firewall management disabled", retIA.InstanceArrayLabel, retIA.InstanceArrayID)
+	}
+
+	current := retIA.InstanceArrayOperation.InstanceArrayFirewallRules
+	changes := diffFirewallRules(current, desired)
+
+	fmt.Fprint(configuration.GetStdout(), renderFirewallRuleChanges(retIA, changes))
+
+	if len(changes) == 0 || command.GetBoolParam(c.Arguments["dry_run"]) {
+		return "", nil
+	}
+
+	confirm, err := command.ConfirmCommand(c, func() string {
+
+		confirmationMessage := fmt.Sprintf("Applying %d firewall rule changes to instance array %s (#%d).  Are you sure? Type \"yes\" to continue:",
+			len(changes),
+			retIA.InstanceArrayLabel,
+			retIA.InstanceArrayID,
+		)
+
+		if strings.HasSuffix(os.Args[0], ".test") {
+			confirmationMessage = ""
+		}
+
+		return confirmationMessage
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !confirm {
+		return "", fmt.Errorf("operation not confirmed. Aborting")
+	}
+
+	// the instance array is read again so that changes made by someone else since the diff was shown are not overwritten
+	latestIA, err := client.InstanceArrayGet(instanceArrayID)
+	if err != nil {
+		return "", err
+	}
+
+	if latestIA.InstanceArrayOperation.InstanceArrayChangeID != retIA.InstanceArrayOperation.InstanceArrayChangeID ||
+		!firewallRuleListsEqual(latestIA.InstanceArrayOperation.InstanceArrayFirewallRules, current) {
+		return "", fmt.Errorf("the instance array %s [#%d] was changed since its firewall rules were read. Nothing was changed, run the command again to see the new differences", retIA.InstanceArrayLabel, retIA.InstanceArrayID)
+	}
+
+	latestIA.InstanceArrayOperation.InstanceArrayFirewallRules = desired
+
+	bFalse := false
+	_, err = client.InstanceArrayEdit(latestIA.InstanceArrayID, *latestIA.InstanceArrayOperation, &bFalse, nil, nil, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Firewall rules of instance array %s (#%d) synced.\n", latestIA.InstanceArrayLabel, latestIA.InstanceArrayID), nil
+}
+
+func firewallRulesFromSet(set firewallRuleSet) ([]metalcloud.FirewallRule, error) {
+	rules := []metalcloud.FirewallRule{}
+	for i, spec := range set.Rules {
+		fw, err := firewallRuleFromSpec(spec)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+		rules = append(rules, fw)
+	}
+	return rules, nil
+}
+
+// diffFirewallRules returns the rules to add, remove and update to get from current to desired.
+// Rules are matched by protocol, IP address type, ports and addresses. Matched rules with a different
+// description or enabled flag are updated. The order of the rules is not considered a change.
+func diffFirewallRules(current []metalcloud.FirewallRule, desired []metalcloud.FirewallRule) []firewallRuleChange {
+
+	changes := []firewallRuleChange{}
+	matched := make([]bool, len(current))
+
+	for _, d := range desired {
+		found := false
+		for i, cur := range current {
+			if matched[i] || !firewallRuleMatches(cur, d) {
+				continue
+			}
+
+			matched[i] = true
+			found = true
+
+			if cur.FirewallRuleDescription != d.FirewallRuleDescription || cur.FirewallRuleEnabled != d.FirewallRuleEnabled {
+				changes = append(changes, firewallRuleChange{Op: firewallRuleUpdate, Current: cur, Desired: d})
+			}
+			break
+		}
+
+		if !found {
+			changes = append(changes, firewallRuleChange{Op: firewallRuleAdd, Desired: d})
+		}
+	}
+
+	for i, cur := range current {
+		if !matched[i] {
+			changes = append(changes, firewallRuleChange{Op: firewallRuleRemove, Current: cur})
+		}
+	}
+
+	return changes
+}
+
+// firewallRuleMatches returns true if the two rules filter the same traffic
+func firewallRuleMatches(a, b metalcloud.FirewallRule) bool {
+	return fwRulesEqual(a, b) && a.FirewallRuleIPAddressType == b.FirewallRuleIPAddressType
+}
+
+func firewallRuleListsEqual(a, b []metalcloud.FirewallRule) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func renderFirewallRuleChanges(ia *metalcloud.InstanceArray, changes []firewallRuleChange) string {
+
+	var sb strings.Builder
+
+	if len(changes) == 0 {
+		sb.WriteString(fmt.Sprintf("The firewall rules of instance array %s (#%d) are already in sync.\n", ia.InstanceArrayLabel, ia.InstanceArrayID))
+		return sb.String()
+	}
+
+	sb.WriteString(fmt.Sprintf("Firewall rule changes for instance array %s (#%d):\n", ia.InstanceArrayLabel, ia.InstanceArrayID))
+
+	counts := map[string]int{}
+	for _, ch := range changes {
+		counts[ch.Op]++
+
+		switch ch.Op {
+		case firewallRuleAdd:
+			sb.WriteString(fmt.Sprintf("%s %s\n", ch.Op, describeFirewallRule(ch.Desired)))
+		case firewallRuleRemove:
+			sb.WriteString(fmt.Sprintf("%s %s\n", ch.Op, describeFirewallRule(ch.Current)))
+		case firewallRuleUpdate:
+			details := []string{}
+			if ch.Current.FirewallRuleDescription != ch.Desired.FirewallRuleDescription {
+				details = append(details, fmt.Sprintf("description %q -> %q", ch.Current.FirewallRuleDescription, ch.Desired.FirewallRuleDescription))
+			}
+			if ch.Current.FirewallRuleEnabled != ch.Desired.FirewallRuleEnabled {
+				details = append(details, fmt.Sprintf("enabled %t -> %t", ch.Current.FirewallRuleEnabled, ch.Desired.FirewallRuleEnabled))
+			}
+			sb.WriteString(fmt.Sprintf("%s %s: %s\n", ch.Op, describeFirewallRule(ch.Current), strings.Join(details, ", ")))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("%d to add, %d to remove, %d to update.\n", counts[firewallRuleAdd], counts[firewallRuleRemove], counts[firewallRuleUpdate]))
+
+	return sb.String()
+}
+
+func describeFirewallRule(fw metalcloud.FirewallRule) string {
+	spec := firewallRuleToSpec(fw)
+
+	anyIfEmpty := func(s string) string {
+		if s == "" {
+			return "any"
+		}
+		return s
+	}
+
+	s := fmt.Sprintf("%s port %s from %s to %s (%s)",
+		anyIfEmpty(spec.Protocol),
+		anyIfEmpty(spec.Port),
+		anyIfEmpty(spec.Source),
+		anyIfEmpty(spec.Destination),
+		spec.IPAddressType,
+	)
+
+	if !fw.FirewallRuleEnabled {
+		s += " disabled"
+	}
+
+	if fw.FirewallRuleDescription != "" {
+		s += fmt.Sprintf(" %q", fw.FirewallRuleDescription)
+	}
+
+	return s
+}
+
+func firewallRuleToSpec(fw metalcloud.FirewallRule) firewallRuleSpec {
+
+	spec := firewallRuleSpec{
+		Protocol:      fw.FirewallRuleProtocol,
+		IPAddressType: fw.FirewallRuleIPAddressType,
+		Description:   fw.FirewallRuleDescription,
+	}
+
+	if fw.FirewallRulePortRangeStart != 0 {
+		spec.Port = fmt.Sprintf("%d", fw.FirewallRulePortRangeStart)
+		if fw.FirewallRulePortRangeEnd != fw.FirewallRulePortRangeStart {
+			spec.Port += fmt.Sprintf("-%d", fw.FirewallRulePortRangeEnd)
+		}
+	}
+
+	spec.Source = rangeToAddressString(fw.FirewallRuleSourceIPAddressRangeStart, fw.FirewallRuleSourceIPAddressRangeEnd)
+	spec.Destination = rangeToAddressString(fw.FirewallRuleDestinationIPAddressRangeStart, fw.FirewallRuleDestinationIPAddressRangeEnd)
+
+	if !fw.FirewallRuleEnabled {
+		bFalse := false
+		spec.Enabled = &bFalse
+	}
+
+	return spec
+}
+
+func firewallRuleFromSpec(spec firewallRuleSpec) (metalcloud.FirewallRule, error) {
+
+	var err error
+
+	fw := metalcloud.FirewallRule{
+		FirewallRuleProtocol:      spec.Protocol,
+		FirewallRuleIPAddressType: spec.IPAddressType,
+		FirewallRuleDescription:   spec.Description,
+		FirewallRuleEnabled:       spec.Enabled == nil || *spec.Enabled,
+	}
+
+	if fw.FirewallRuleIPAddressType == "" {
+		fw.FirewallRuleIPAddressType = "ipv4"
+	}
+
+	if spec.Port != "" && spec.Port != "any" {
+		fw.FirewallRulePortRangeStart, fw.FirewallRulePortRangeEnd, err = portStringToRange(spec.Port)
+		if err != nil {
+			return fw, err
+		}
+	}
+
+	if spec.Source != "" && spec.Source != "any" {
+		fw.FirewallRuleSourceIPAddressRangeStart, fw.FirewallRuleSourceIPAddressRangeEnd, err = addressStringToRange(spec.Source)
+		if err != nil {
+			return fw, err
+		}
+	}
+
+	if spec.Destination != "" && spec.Destination != "any" {
+		fw.FirewallRuleDestinationIPAddressRangeStart, fw.FirewallRuleDestinationIPAddressRangeEnd, err = addressStringToRange(spec.Destination)
+		if err != nil {
+			return fw, err
+		}
+	}
+
+	return fw, nil
+}
+
+func rangeToAddressString(start string, end string) string {
+	if start == "" || start == end {
+		return start
+	}
+	return start + "-" + end
+}
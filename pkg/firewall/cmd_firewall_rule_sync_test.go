@@ -0,0 +1,277 @@
+package firewall
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+func getFirewallSyncInstanceArray(changeID int) *metalcloud.InstanceArray {
+	return &metalcloud.InstanceArray{
+		InstanceArrayID:    11,
+		InstanceArrayLabel: "testia",
+		InstanceArrayOperation: &metalcloud.InstanceArrayOperation{
+			InstanceArrayID:              11,
+			InstanceArrayLabel:           "testia",
+			InstanceArrayChangeID:        changeID,
+			InstanceArrayFirewallManaged: true,
+			InstanceArrayFirewallRules: []metalcloud.FirewallRule{
+				{
+					FirewallRuleProtocol:                  "tcp",
+					FirewallRuleIPAddressType:             "ipv4",
+					FirewallRulePortRangeStart:            22,
+					FirewallRulePortRangeEnd:              22,
+					FirewallRuleSourceIPAddressRangeStart: "10.0.0.0",
+					FirewallRuleSourceIPAddressRangeEnd:   "10.0.0.255",
+					FirewallRuleDescription:               "ssh",
+					FirewallRuleEnabled:                   true,
+				},
+				{
+					FirewallRuleProtocol:       "udp",
+					FirewallRuleIPAddressType:  "ipv4",
+					FirewallRulePortRangeStart: 53,
+					FirewallRulePortRangeEnd:   53,
+					FirewallRuleDescription:    "dns",
+					FirewallRuleEnabled:        true,
+				},
+				{
+					FirewallRuleProtocol:      "icmp",
+					FirewallRuleIPAddressType: "ipv4",
+					FirewallRuleEnabled:       true,
+				},
+			},
+		},
+	}
+}
+
+const _firewallRuleSetFixture = `instanceArrayID: 11
+rules:
+  - protocol: tcp
+    port: "22"
+    source: 10.0.0.0-10.0.0.255
+    description: ssh
+  - protocol: udp
+    port: "53"
+    description: DNS
+    enabled: false
+  - protocol: tcp
+    port: 8000-8080
+    ipAddressType: ipv6
+`
+
+func writeFirewallRuleSetFile(t *testing.T, content string) string {
+	f, err := os.CreateTemp(os.TempDir(), "rules-*.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(content)
+	f.Close()
+	return f.Name()
+}
+
+func TestFirewallRuleExportCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		InstanceArrayGet(11).
+		Return(getFirewallSyncInstanceArray(1), nil).
+		AnyTimes()
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"instance_array_id": 11,
+		"format":            "yaml",
+	})
+
+	ret, err := firewallRuleExportCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("port: \"22\"\n      source: 10.0.0.0-10.0.0.255\n"))
+
+	var set firewallRuleSet
+	Expect(yaml.Unmarshal([]byte(ret), &set)).To(Succeed())
+	Expect(set.InstanceArrayID).To(Equal(11))
+	Expect(set.Rules).To(HaveLen(3))
+
+	// syncing an export does not change anything
+	name := writeFirewallRuleSetFile(t, ret)
+	defer syscall.Unlink(name)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(os.Stdin, &stdout)
+	defer configuration.SetConsoleIOChannel(os.Stdin, os.Stdout)
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": name,
+		"format":                "yaml",
+		"autoconfirm":           true,
+	})
+
+	ret, err = firewallRuleSyncCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal(""))
+	Expect(stdout.String()).To(Equal("The firewall rules of instance array testia (#11) are already in sync.\n"))
+}
+
+func TestFirewallRuleSyncCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	name := writeFirewallRuleSetFile(t, _firewallRuleSetFixture)
+	defer syscall.Unlink(name)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(os.Stdin, &stdout)
+	defer configuration.SetConsoleIOChannel(os.Stdin, os.Stdout)
+
+	// dry run
+	client.EXPECT().
+		InstanceArrayGet(11).
+		Return(getFirewallSyncInstanceArray(1), nil).
+		Times(1)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": name,
+		"format":                "yaml",
+		"dry_run":               true,
+	})
+
+	_, err := firewallRuleSyncCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(stdout.String()).To(Equal(`Firewall rule changes for instance array testia (#11):
+~ udp port 53 from any to any (ipv4) "dns": description "dns" -> "DNS", enabled true -> false
++ tcp port 8000-8080 from any to any (ipv6)
+- icmp port any from any to any (ipv4)
+1 to add, 1 to remove, 1 to update.
+`))
+
+	// the rules are applied in a single edit
+	client.EXPECT().
+		InstanceArrayGet(11).
+		Return(getFirewallSyncInstanceArray(1), nil).
+		Times(2)
+
+	var edited metalcloud.InstanceArrayOperation
+	client.EXPECT().
+		InstanceArrayEdit(11, gomock.Any(), gomock.Any(), nil, nil, nil).
+		DoAndReturn(func(id int, op metalcloud.InstanceArrayOperation, swap *bool, keep *bool, matches *metalcloud.ServerTypeMatches, deleted *[]int) (*metalcloud.InstanceArray, error) {
+			edited = op
+			return nil, nil
+		}).
+		Times(1)
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": name,
+		"format":                "yaml",
+		"autoconfirm":           true,
+	})
+
+	ret, err := firewallRuleSyncCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal("Firewall rules of instance array testia (#11) synced.\n"))
+
+	Expect(edited.InstanceArrayFirewallRules).To(Equal([]metalcloud.FirewallRule{
+		{
+			FirewallRuleProtocol:                  "tcp",
+			FirewallRuleIPAddressType:             "ipv4",
+			FirewallRulePortRangeStart:            22,
+			FirewallRulePortRangeEnd:              22,
+			FirewallRuleSourceIPAddressRangeStart: "10.0.0.0",
+			FirewallRuleSourceIPAddressRangeEnd:   "10.0.0.255",
+			FirewallRuleDescription:               "ssh",
+			FirewallRuleEnabled:                   true,
+		},
+		{
+			FirewallRuleProtocol:       "udp",
+			FirewallRuleIPAddressType:  "ipv4",
+			FirewallRulePortRangeStart: 53,
+			FirewallRulePortRangeEnd:   53,
+			FirewallRuleDescription:    "DNS",
+			FirewallRuleEnabled:        false,
+		},
+		{
+			FirewallRuleProtocol:       "tcp",
+			FirewallRuleIPAddressType:  "ipv6",
+			FirewallRulePortRangeStart: 8000,
+			FirewallRulePortRangeEnd:   8080,
+			FirewallRuleEnabled:        true,
+		},
+	}))
+}
+
+func TestFirewallRuleSyncCmdConcurrentEdit(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	name := writeFirewallRuleSetFile(t, _firewallRuleSetFixture)
+	defer syscall.Unlink(name)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(os.Stdin, &stdout)
+	defer configuration.SetConsoleIOChannel(os.Stdin, os.Stdout)
+
+	gomock.InOrder(
+		client.EXPECT().
+			InstanceArrayGet(11).
+			Return(getFirewallSyncInstanceArray(1), nil),
+		client.EXPECT().
+			InstanceArrayGet(11).
+			Return(getFirewallSyncInstanceArray(2), nil),
+	)
+
+	client.EXPECT().
+		InstanceArrayEdit(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Times(0)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": name,
+		"format":                "yaml",
+		"autoconfirm":           true,
+	})
+
+	_, err := firewallRuleSyncCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("was changed since its firewall rules were read"))
+}
+
+func TestFirewallRuleSyncCmdInvalidFile(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	name := writeFirewallRuleSetFile(t, "rules:\n  - protocol: tcp\n    port: abc\n")
+	defer syscall.Unlink(name)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": name,
+		"format":                "yaml",
+		"instance_array_id":     11,
+	})
+
+	_, err := firewallRuleSyncCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal("rule 0: Could not parse port definition abc"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": name,
+		"format":                "yaml",
+	})
+
+	_, err = firewallRuleSyncCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+}
@@ -106,6 +106,7 @@ var InfrastructureCmds = []command.Command{
 				"block_until_deployed":           c.FlagSet.Bool("blocking", false, colors.Green("(Flag)")+" If set, the operation will wait until deployment finishes."),
 				"block_timeout":                  c.FlagSet.Int("block-timeout", 180*60, "Block timeout in seconds. After this timeout the application will return an error. Defaults to 180 minutes."),
 				"block_check_interval":           c.FlagSet.Int("block-check-interval", 10, "Check interval for when blocking. Defaults to 10 seconds."),
+				"progress":                       c.FlagSet.String("progress", "auto", "Progress output when blocking. Supported values are 'auto','live','plain','json','none'. 'auto' shows a live view on a terminal and plain log lines otherwise, 'json' prints one event per line."),
 				"autoconfirm":                    c.FlagSet.Bool("autoconfirm", false, colors.Green("(Flag)")+" If set it will assume action is confirmed"),
 			}
 		},
@@ -164,6 +165,55 @@ var InfrastructureCmds = []command.Command{
 		Endpoint:      configuration.DeveloperEndpoint,
 		AdminEndpoint: configuration.DeveloperEndpoint,
 	},
+	{
+		Description:  "Export the topology of an infrastructure as a graph.",
+		Subject:      "infrastructure",
+		AltSubject:   "infra",
+		Predicate:    "graph",
+		AltPredicate: "topology",
+		FlagSet:      flag.NewFlagSet("graph infrastructure", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"infrastructure_id_or_label": c.FlagSet.String("id", command.NilDefaultStr, colors.Red("(Required)")+" Infrastructure's id or label. Note that using the 'label' might be ambiguous in certain situations."),
+				"format":                     c.FlagSet.String("format", "dot", "The output format. Supported values are 'dot','mermaid','json'. The default format is Graphviz dot."),
+			}
+		},
+		ExecuteFunc:   infrastructureGraphCmd,
+		Endpoint:      configuration.UserEndpoint,
+		AdminEndpoint: configuration.DeveloperEndpoint,
+		Example: `
+metalcloud-cli infrastructure graph --id 12345 --format dot | dot -Tsvg > infra.svg
+metalcloud-cli infrastructure graph --id 12345 --format mermaid > infra.mmd`,
+	},
+	{
+		Description:  "Export the instances of an infrastructure as an inventory.",
+		Subject:      "infrastructure",
+		AltSubject:   "infra",
+		Predicate:    "inventory",
+		AltPredicate: "inv",
+		FlagSet:      flag.NewFlagSet("inventory infrastructure", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"infrastructure_id_or_label": c.FlagSet.String("id", command.NilDefaultStr, colors.Red("(Required)")+" Infrastructure's id or label. Note that using the 'label' might be ambiguous in certain situations."),
+				"format":                     c.FlagSet.String("format", "ansible-json", "The output format. Supported values are 'ansible-json','ansible-ini','ssh-config'."),
+				"list":                       c.FlagSet.Bool("list", false, colors.Green("(Flag)")+" Ansible dynamic inventory protocol. Outputs all groups and host vars as JSON."),
+				"host":                       c.FlagSet.String("host", command.NilDefaultStr, "Ansible dynamic inventory protocol. Outputs the host vars of the given host as JSON."),
+				"show_credentials":           c.FlagSet.Bool("show-credentials", false, colors.Green("(Flag)")+" If set returns the instances' SSH credentials as ansible_user, ansible_password and ansible_port."),
+			}
+		},
+		ExecuteFunc:   infrastructureInventoryCmd,
+		Endpoint:      configuration.UserEndpoint,
+		AdminEndpoint: configuration.DeveloperEndpoint,
+		Example: `
+metalcloud-cli infrastructure inventory --id 12345 --format ansible-ini > hosts.ini
+metalcloud-cli infrastructure inventory --id 12345 --format ssh-config >> ~/.ssh/config
+
+To use it as an Ansible dynamic inventory script create an executable file such as:
+#!/bin/sh
+exec metalcloud-cli infrastructure inventory --id 12345 --show-credentials "$@"
+
+and run: ansible-inventory -i ./metalcloud.sh --list`,
+	},
 }
 
 func infrastructureCreateCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
@@ -504,12 +554,23 @@ func infrastructureListAdminCmd(c *command.Command, client metalcloud.MetalCloud
 			return infrastructureConfirmAndDo("Deploy", c, client,
 			func(infraID int, c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
 
+				var renderer deployProgressRenderer
+				if command.GetBoolParam(c.Arguments["block_until_deployed"]) {
+					var err error
+					renderer, err = newDeployProgressRenderer(command.GetStringParam(c.Arguments["progress"]), configuration.GetStdout())
+					if err != nil {
+						return "", err
+					}
+				}
+
 				shutDownOptions := metalcloud.ShutdownOptions{
 					HardShutdownAfterTimeout:   !command.GetBoolParam(c.Arguments["no_hard_shutdown_after_timeout"]),
 					AttemptSoftShutdown:        !command.GetBoolParam(c.Arguments["no_attempt_soft_shutdown"]),
 					SoftShutdownTimeoutSeconds: command.GetIntParam(c.Arguments["soft_shutdown_timeout_seconds"]),
 				}
 
+				started := time.Now()
+
 				err := client.InfrastructureDeploy(
 					infraID,
 					shutDownOptions,
@@ -524,7 +585,7 @@ func infrastructureListAdminCmd(c *command.Command, client metalcloud.MetalCloud
 
 					time.Sleep(time.Duration(command.GetIntParam(c.Arguments["block_check_interval"])) * time.Second) //wait until the system picks up the afc
 
-					err := loopUntilInfraReady(infraID, command.GetIntParam(c.Arguments["block_timeout"]), command.GetIntParam(c.Arguments["block_check_interval"]), client)
+					err := loopUntilInfraReady(infraID, started, command.GetIntParam(c.Arguments["block_timeout"]), command.GetIntParam(c.Arguments["block_check_interval"]), client, renderer)
 
 					if err != nil && strings.HasPrefix(err.Error(), "timeout after") {
 						return "", err
@@ -581,6 +642,9 @@ func infrastructureListAdminCmd(c *command.Command, client metalcloud.MetalCloud
 
 			data := [][]interface{}{}
 
+			// instance arrays and drive arrays often share the same volume template
+			resolver := command.NewResolver(client)
+
 			iaList, err := client.InstanceArrays(retInfra.InfrastructureID)
 			if err != nil {
 				return "", err
@@ -601,7 +665,7 @@ func infrastructureListAdminCmd(c *command.Command, client metalcloud.MetalCloud
 
 				volumeTemplateName := ""
 				if ia.InstanceArrayOperation.VolumeTemplateID != 0 {
-					vt, err := client.VolumeTemplateGet(ia.InstanceArrayOperation.VolumeTemplateID)
+					vt, err := resolver.VolumeTemplate(ia.InstanceArrayOperation.VolumeTemplateID)
 					if err != nil {
 						return "", err
 					}
@@ -655,7 +719,7 @@ func infrastructureListAdminCmd(c *command.Command, client metalcloud.MetalCloud
 
 			volumeTemplateName := ""
 			if da.DriveArrayOperation.VolumeTemplateID != 0 {
-				vt, err := client.VolumeTemplateGet(da.DriveArrayOperation.VolumeTemplateID)
+				vt, err := resolver.VolumeTemplate(da.DriveArrayOperation.VolumeTemplateID)
 				if err != nil {
 					return "", err
 				}
@@ -831,13 +895,26 @@ func listWorkflowStagesCmd(c *command.Command, client metalcloud.MetalCloudClien
 	return table.RenderTable("Workflow Stages", "", command.GetStringParam(c.Arguments["format"]))
 }
 
-// loop until infra is ready
-func loopUntilInfraReady(infraID int, timeoutSeconds int, checkIntervalSeconds int, client metalcloud.MetalCloudClient) error {
+// loop until infra is ready. If a renderer is given the progress of the deploy started at the given time is rendered on every check.
+func loopUntilInfraReady(infraID int, started time.Time, timeoutSeconds int, checkIntervalSeconds int, client metalcloud.MetalCloudClient, renderer deployProgressRenderer) error {
 	c := make(chan error, 1)
 
 	go func() {
+		collector := newDeployProgressCollector(infraID, started, client)
 		for {
-			infra, err := client.InfrastructureGet(infraID)
+			var infra *metalcloud.Infrastructure
+			var err error
+
+			if renderer != nil {
+				var p *deployProgress
+				p, err = collector.collect()
+				if err == nil {
+					infra = p.Infrastructure
+					err = renderer.Render(p)
+				}
+			} else {
+				infra, err = client.InfrastructureGet(infraID)
+			}
 
 			if err != nil {
 				c <- err
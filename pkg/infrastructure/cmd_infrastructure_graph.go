@@ -0,0 +1,371 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+)
+
+// graphNode is an element of the infrastructure topology
+type graphNode struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Label  string `json:"label"`
+	Status string `json:"status,omitempty"`
+}
+
+// graphEdge is a relation between two elements of the infrastructure topology
+type graphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label,omitempty"`
+}
+
+// infrastructureGraph is the topology of an infrastructure
+type infrastructureGraph struct {
+	Label string      `json:"label"`
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+
+	nodeIDs map[string]bool
+}
+
+func (g *infrastructureGraph) addNode(n graphNode) {
+	if g.nodeIDs == nil {
+		g.nodeIDs = map[string]bool{}
+	}
+	if g.nodeIDs[n.ID] {
+		return
+	}
+	g.nodeIDs[n.ID] = true
+	g.Nodes = append(g.Nodes, n)
+}
+
+func (g *infrastructureGraph) addEdge(from string, to string, label string) {
+	g.Edges = append(g.Edges, graphEdge{From: from, To: to, Label: label})
+}
+
+func infrastructureGraphCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	format := command.GetStringParam(c.Arguments["format"])
+	if format == "" {
+		format = "dot"
+	}
+
+	if format != "dot" && format != "mermaid" && format != "json" {
+		return "", fmt.Errorf("format '%s' not supported. Supported values are 'dot','mermaid','json'", format)
+	}
+
+	retInfra, err := command.GetInfrastructureFromCommand("id", c, client)
+	if err != nil {
+		return "", err
+	}
+
+	g, err := getInfrastructureGraph(retInfra, client)
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case "mermaid":
+		return renderGraphAsMermaid(g), nil
+	case "json":
+		b, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	}
+
+	return renderGraphAsDOT(g), nil
+}
+
+// getInfrastructureGraph walks the instance arrays, drive arrays, shared drives and networks of an infrastructure
+// using the same calls as 'infrastructure get' and 'network list'
+func getInfrastructureGraph(infra *metalcloud.Infrastructure, client metalcloud.MetalCloudClient) (*infrastructureGraph, error) {
+	g := infrastructureGraph{
+		Label: infra.InfrastructureLabel,
+	}
+
+	nwList, err := client.Networks(infra.InfrastructureID)
+	if err != nil {
+		return nil, err
+	}
+
+	networks := []metalcloud.Network{}
+	for _, n := range *nwList {
+		networks = append(networks, n)
+	}
+	sort.Slice(networks, func(i, j int) bool {
+		return networks[i].NetworkID < networks[j].NetworkID
+	})
+
+	for _, n := range networks {
+		g.addNode(graphNode{
+			ID:    fmt.Sprintf("net_%d", n.NetworkID),
+			Type:  "Network",
+			Label: fmt.Sprintf("%s %s", n.NetworkType, n.NetworkLabel),
+		})
+	}
+
+	iaMap, err := client.InstanceArrays(infra.InfrastructureID)
+	if err != nil {
+		return nil, err
+	}
+
+	iaList := []metalcloud.InstanceArray{}
+	for _, ia := range *iaMap {
+		iaList = append(iaList, ia)
+	}
+	sort.Slice(iaList, func(i, j int) bool {
+		return iaList[i].InstanceArrayID < iaList[j].InstanceArrayID
+	})
+
+	for _, ia := range iaList {
+		iaNodeID := fmt.Sprintf("ia_%d", ia.InstanceArrayID)
+
+		label := ia.InstanceArrayLabel
+		if ia.InstanceArrayOperation != nil && ia.InstanceArrayOperation.InstanceArrayLabel != "" {
+			label = ia.InstanceArrayOperation.InstanceArrayLabel
+		}
+
+		g.addNode(graphNode{
+			ID:     iaNodeID,
+			Type:   "InstanceArray",
+			Label:  label,
+			Status: ia.InstanceArrayServiceStatus,
+		})
+
+		iList, err := client.InstanceArrayInstances(ia.InstanceArrayID)
+		if err != nil {
+			return nil, err
+		}
+
+		instances := []metalcloud.Instance{}
+		for _, i := range *iList {
+			instances = append(instances, i)
+		}
+		sort.Slice(instances, func(i, j int) bool {
+			return instances[i].InstanceID < instances[j].InstanceID
+		})
+
+		for _, i := range instances {
+			instanceNodeID := fmt.Sprintf("inst_%d", i.InstanceID)
+			g.addNode(graphNode{
+				ID:     instanceNodeID,
+				Type:   "Instance",
+				Label:  i.InstanceLabel,
+				Status: i.InstanceServiceStatus,
+			})
+			g.addEdge(iaNodeID, instanceNodeID, "")
+
+			if i.ServerID != 0 {
+				serverNodeID := fmt.Sprintf("srv_%d", i.ServerID)
+				g.addNode(graphNode{
+					ID:    serverNodeID,
+					Type:  "Server",
+					Label: fmt.Sprintf("server %d", i.ServerID),
+				})
+				g.addEdge(instanceNodeID, serverNodeID, "runs on")
+			}
+		}
+
+		networkProfiles, err := client.NetworkProfileListByInstanceArray(ia.InstanceArrayID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, intf := range ia.InstanceArrayInterfaces {
+			if intf.NetworkID == 0 {
+				continue
+			}
+
+			networkNodeID := fmt.Sprintf("net_%d", intf.NetworkID)
+			g.addEdge(iaNodeID, networkNodeID, fmt.Sprintf("port %d", intf.InstanceArrayInterfaceIndex+1))
+
+			profileID := (*networkProfiles)[intf.NetworkID]
+			if profileID == 0 {
+				continue
+			}
+
+			profileNodeID := fmt.Sprintf("np_%d", profileID)
+			if !g.nodeIDs[profileNodeID] {
+				np, err := client.NetworkProfileGet(profileID)
+				if err != nil {
+					return nil, err
+				}
+
+				g.addNode(graphNode{
+					ID:    profileNodeID,
+					Type:  "NetworkProfile",
+					Label: np.NetworkProfileLabel,
+				})
+
+				for idx, vlan := range np.NetworkProfileVLANs {
+					vlanID := "auto"
+					if vlan.VlanID != nil {
+						vlanID = fmt.Sprintf("%d", *vlan.VlanID)
+					}
+
+					vlanNodeID := fmt.Sprintf("np_%d_vlan_%d", profileID, idx)
+					g.addNode(graphNode{
+						ID:    vlanNodeID,
+						Type:  "VLAN",
+						Label: fmt.Sprintf("%s %s", vlanID, vlan.PortMode),
+					})
+					g.addEdge(profileNodeID, vlanNodeID, "")
+				}
+			}
+
+			g.addEdge(profileNodeID, networkNodeID, fmt.Sprintf("applied for %s", label))
+		}
+	}
+
+	daMap, err := client.DriveArrays(infra.InfrastructureID)
+	if err != nil {
+		return nil, err
+	}
+
+	daList := []metalcloud.DriveArray{}
+	for _, da := range *daMap {
+		daList = append(daList, da)
+	}
+	sort.Slice(daList, func(i, j int) bool {
+		return daList[i].DriveArrayID < daList[j].DriveArrayID
+	})
+
+	for _, da := range daList {
+		daNodeID := fmt.Sprintf("da_%d", da.DriveArrayID)
+
+		label := da.DriveArrayLabel
+		if da.DriveArrayOperation != nil && da.DriveArrayOperation.DriveArrayLabel != "" {
+			label = da.DriveArrayOperation.DriveArrayLabel
+		}
+
+		g.addNode(graphNode{
+			ID:     daNodeID,
+			Type:   "DriveArray",
+			Label:  fmt.Sprintf("%s %d x %.1f GB %s", label, da.DriveArrayCount, float64(da.DriveSizeMBytesDefault)/1024, da.DriveArrayStorageType),
+			Status: da.DriveArrayServiceStatus,
+		})
+
+		if da.InstanceArrayID != 0 {
+			g.addEdge(daNodeID, fmt.Sprintf("ia_%d", da.InstanceArrayID), "attached to")
+		}
+	}
+
+	sdMap, err := client.SharedDrives(infra.InfrastructureID)
+	if err != nil {
+		return nil, err
+	}
+
+	sdList := []metalcloud.SharedDrive{}
+	for _, sd := range *sdMap {
+		sdList = append(sdList, sd)
+	}
+	sort.Slice(sdList, func(i, j int) bool {
+		return sdList[i].SharedDriveID < sdList[j].SharedDriveID
+	})
+
+	for _, sd := range sdList {
+		sdNodeID := fmt.Sprintf("sd_%d", sd.SharedDriveID)
+		g.addNode(graphNode{
+			ID:     sdNodeID,
+			Type:   "SharedDrive",
+			Label:  fmt.Sprintf("%s %d GB %s", sd.SharedDriveLabel, sd.SharedDriveSizeMbytes/1024, sd.SharedDriveStorageType),
+			Status: sd.SharedDriveServiceStatus,
+		})
+
+		for _, iaID := range sd.SharedDriveAttachedInstanceArrays {
+			g.addEdge(sdNodeID, fmt.Sprintf("ia_%d", iaID), "attached to")
+		}
+	}
+
+	return &g, nil
+}
+
+var graphDOTShapes = map[string]string{
+	"InstanceArray":  "box3d",
+	"Instance":       "box",
+	"Server":         "component",
+	"DriveArray":     "cylinder",
+	"SharedDrive":    "cylinder",
+	"Network":        "ellipse",
+	"NetworkProfile": "note",
+	"VLAN":           "tab",
+}
+
+func graphNodeCaption(n graphNode) string {
+	caption := fmt.Sprintf("%s\n%s", n.Type, n.Label)
+	if n.Status != "" {
+		caption = fmt.Sprintf("%s\n(%s)", caption, n.Status)
+	}
+	return caption
+}
+
+// renderGraphAsDOT renders the graph in the Graphviz DOT language
+func renderGraphAsDOT(g *infrastructureGraph) string {
+	var sb strings.Builder
+
+	quote := func(s string) string {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		s = strings.ReplaceAll(s, `"`, `\"`)
+		return `"` + strings.ReplaceAll(s, "\n", `\n`) + `"`
+	}
+
+	sb.WriteString(fmt.Sprintf("digraph %s {\n", quote(g.Label)))
+	sb.WriteString("  rankdir=LR;\n")
+
+	for _, n := range g.Nodes {
+		sb.WriteString(fmt.Sprintf("  %s [label=%s shape=%s];\n", quote(n.ID), quote(graphNodeCaption(n)), graphDOTShapes[n.Type]))
+	}
+
+	for _, e := range g.Edges {
+		if e.Label != "" {
+			sb.WriteString(fmt.Sprintf("  %s -> %s [label=%s];\n", quote(e.From), quote(e.To), quote(e.Label)))
+		} else {
+			sb.WriteString(fmt.Sprintf("  %s -> %s;\n", quote(e.From), quote(e.To)))
+		}
+	}
+
+	sb.WriteString("}\n")
+
+	return sb.String()
+}
+
+// renderGraphAsMermaid renders the graph as a Mermaid flowchart
+func renderGraphAsMermaid(g *infrastructureGraph) string {
+	var sb strings.Builder
+
+	quote := func(s string) string {
+		s = strings.ReplaceAll(s, `"`, "#quot;")
+		return `"` + strings.ReplaceAll(s, "\n", "<br/>") + `"`
+	}
+
+	sb.WriteString("graph LR\n")
+
+	for _, n := range g.Nodes {
+		switch n.Type {
+		case "DriveArray", "SharedDrive":
+			sb.WriteString(fmt.Sprintf("  %s[(%s)]\n", n.ID, quote(graphNodeCaption(n))))
+		case "Network":
+			sb.WriteString(fmt.Sprintf("  %s((%s))\n", n.ID, quote(graphNodeCaption(n))))
+		default:
+			sb.WriteString(fmt.Sprintf("  %s[%s]\n", n.ID, quote(graphNodeCaption(n))))
+		}
+	}
+
+	for _, e := range g.Edges {
+		if e.Label != "" {
+			sb.WriteString(fmt.Sprintf("  %s -->|%s| %s\n", e.From, quote(e.Label), e.To))
+		} else {
+			sb.WriteString(fmt.Sprintf("  %s --> %s\n", e.From, e.To))
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,320 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/pkg/utils"
+)
+
+// inventoryHost is an instance as seen by configuration management tools
+type inventoryHost struct {
+	Name string
+	Vars map[string]interface{}
+}
+
+// inventoryGroup is an instance array as seen by configuration management tools
+type inventoryGroup struct {
+	Name  string
+	Label string
+	Hosts []inventoryHost
+}
+
+var inventoryGroupNameRegexp = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func infrastructureInventoryCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	format := command.GetStringParam(c.Arguments["format"])
+	if format == "" {
+		format = "ansible-json"
+	}
+
+	if format != "ansible-json" && format != "ansible-ini" && format != "ssh-config" {
+		return "", fmt.Errorf("format '%s' not supported. Supported values are 'ansible-json','ansible-ini','ssh-config'", format)
+	}
+
+	host := command.GetStringParam(c.Arguments["host"])
+
+	// the dynamic inventory protocol always expects JSON
+	if command.GetBoolParam(c.Arguments["list"]) || host != "" {
+		format = "ansible-json"
+	}
+
+	retInfra, err := command.GetInfrastructureFromCommand("id", c, client)
+	if err != nil {
+		return "", err
+	}
+
+	groups, err := getInfrastructureInventory(retInfra, client, command.GetBoolParam(c.Arguments["show_credentials"]))
+	if err != nil {
+		return "", err
+	}
+
+	if host != "" {
+		return renderInventoryHostAsAnsibleJSON(groups, host)
+	}
+
+	switch format {
+	case "ansible-ini":
+		return renderInventoryAsAnsibleINI(groups), nil
+	case "ssh-config":
+		return renderInventoryAsSSHConfig(groups), nil
+	}
+
+	return renderInventoryAsAnsibleJSON(groups)
+}
+
+// getInfrastructureInventory returns the instances of an infrastructure grouped by instance array
+func getInfrastructureInventory(infra *metalcloud.Infrastructure, client metalcloud.MetalCloudClient, showCredentials bool) ([]inventoryGroup, error) {
+
+	iaMap, err := client.InstanceArrays(infra.InfrastructureID)
+	if err != nil {
+		return nil, err
+	}
+
+	iaList := []metalcloud.InstanceArray{}
+	for _, ia := range *iaMap {
+		iaList = append(iaList, ia)
+	}
+	sort.Slice(iaList, func(i, j int) bool {
+		return iaList[i].InstanceArrayID < iaList[j].InstanceArrayID
+	})
+
+	templateLabels := map[int]string{}
+	getTemplateLabel := func(templateID int) (string, error) {
+		if templateID == 0 {
+			return "", nil
+		}
+
+		if label, ok := templateLabels[templateID]; ok {
+			return label, nil
+		}
+
+		t, err := client.OSTemplateGet(templateID, false)
+		if err != nil {
+			return "", err
+		}
+
+		templateLabels[templateID] = t.VolumeTemplateLabel
+		return t.VolumeTemplateLabel, nil
+	}
+
+	groups := []inventoryGroup{}
+
+	for _, ia := range iaList {
+		group := inventoryGroup{
+			Name:  inventoryGroupNameRegexp.ReplaceAllString(ia.InstanceArrayLabel, "_"),
+			Label: ia.InstanceArrayLabel,
+		}
+
+		iList, err := client.InstanceArrayInstances(ia.InstanceArrayID)
+		if err != nil {
+			return nil, err
+		}
+
+		instances := []metalcloud.Instance{}
+		for _, i := range *iList {
+			instances = append(instances, i)
+		}
+		sort.Slice(instances, func(i, j int) bool {
+			return instances[i].InstanceID < instances[j].InstanceID
+		})
+
+		for _, instance := range instances {
+			name := instance.InstanceSubdomainPermanent
+			if name == "" {
+				name = instance.InstanceSubdomain
+			}
+			if name == "" {
+				name = instance.InstanceLabel
+			}
+
+			publicIPs := utils.GetIPsAsStringArray(instance.InstanceCredentials.IPAddressesPublic)
+			privateIPs := utils.GetIPsAsStringArray(instance.InstanceCredentials.IPAddressesPrivate)
+
+			vars := map[string]interface{}{}
+
+			// custom variables are set first so that they cannot shadow the built-in ones
+			if customVars, ok := instance.InstanceCustomVariables.(map[string]interface{}); ok {
+				for k, v := range customVars {
+					vars[k] = v
+				}
+			}
+
+			ansibleHost := name
+			if len(publicIPs) > 0 {
+				ansibleHost = publicIPs[0]
+			} else if len(privateIPs) > 0 {
+				ansibleHost = privateIPs[0]
+			}
+
+			templateID := instance.TemplateIDOrigin
+			if templateID == 0 {
+				templateID = ia.VolumeTemplateID
+			}
+
+			templateLabel, err := getTemplateLabel(templateID)
+			if err != nil {
+				return nil, err
+			}
+
+			vars["ansible_host"] = ansibleHost
+			vars["metalcloud_instance_id"] = instance.InstanceID
+			vars["metalcloud_instance_label"] = instance.InstanceLabel
+			vars["metalcloud_instance_array_label"] = ia.InstanceArrayLabel
+			vars["metalcloud_infrastructure_label"] = infra.InfrastructureLabel
+			vars["metalcloud_hostname"] = name
+			vars["metalcloud_public_ips"] = publicIPs
+			vars["metalcloud_private_ips"] = privateIPs
+			vars["metalcloud_server_id"] = instance.ServerID
+			vars["metalcloud_status"] = instance.InstanceServiceStatus
+			if templateLabel != "" {
+				vars["metalcloud_os_template"] = templateLabel
+			}
+
+			if showCredentials {
+				if v := instance.InstanceCredentials.SSH; v != nil {
+					vars["ansible_user"] = v.Username
+					vars["ansible_password"] = v.InitialPassword
+					if v.Port != 0 {
+						vars["ansible_port"] = v.Port
+					}
+				}
+			}
+
+			group.Hosts = append(group.Hosts, inventoryHost{
+				Name: name,
+				Vars: vars,
+			})
+		}
+
+		groups = append(groups, group)
+	}
+
+	return groups, nil
+}
+
+// renderInventoryAsAnsibleJSON renders the output expected from a dynamic inventory script called with --list
+func renderInventoryAsAnsibleJSON(groups []inventoryGroup) (string, error) {
+	inventory := map[string]interface{}{}
+	hostVars := map[string]interface{}{}
+	children := []string{}
+
+	for _, g := range groups {
+		hosts := []string{}
+		for _, h := range g.Hosts {
+			hosts = append(hosts, h.Name)
+			hostVars[h.Name] = h.Vars
+		}
+
+		inventory[g.Name] = map[string]interface{}{
+			"hosts": hosts,
+		}
+		children = append(children, g.Name)
+	}
+
+	inventory["all"] = map[string]interface{}{
+		"children": children,
+	}
+	inventory["_meta"] = map[string]interface{}{
+		"hostvars": hostVars,
+	}
+
+	b, err := json.MarshalIndent(inventory, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(b) + "\n", nil
+}
+
+// renderInventoryHostAsAnsibleJSON renders the output expected from a dynamic inventory script called with --host
+func renderInventoryHostAsAnsibleJSON(groups []inventoryGroup, host string) (string, error) {
+	vars := map[string]interface{}{}
+
+	for _, g := range groups {
+		for _, h := range g.Hosts {
+			if h.Name == host {
+				vars = h.Vars
+			}
+		}
+	}
+
+	b, err := json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(b) + "\n", nil
+}
+
+// renderInventoryAsAnsibleINI renders a static Ansible inventory with the host vars inline
+func renderInventoryAsAnsibleINI(groups []inventoryGroup) string {
+	var sb strings.Builder
+
+	for idx, g := range groups {
+		if idx > 0 {
+			sb.WriteString("\n")
+		}
+
+		sb.WriteString(fmt.Sprintf("[%s]\n", g.Name))
+
+		for _, h := range g.Hosts {
+			keys := []string{}
+			for k := range h.Vars {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			sb.WriteString(h.Name)
+			for _, k := range keys {
+				sb.WriteString(fmt.Sprintf(" %s=%s", k, inventoryINIValue(h.Vars[k])))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
+
+// inventoryINIValue formats a value so that Ansible's INI parser reads it back unchanged
+func inventoryINIValue(v interface{}) string {
+	if s, ok := v.(string); ok && s != "" && !strings.ContainsAny(s, " \t\"'#;=") {
+		return s
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+
+	return string(b)
+}
+
+// renderInventoryAsSSHConfig renders an OpenSSH client configuration with one entry per instance
+func renderInventoryAsSSHConfig(groups []inventoryGroup) string {
+	var sb strings.Builder
+
+	for _, g := range groups {
+		sb.WriteString(fmt.Sprintf("# %s\n", g.Label))
+
+		for _, h := range g.Hosts {
+			sb.WriteString(fmt.Sprintf("Host %s\n", h.Name))
+			sb.WriteString(fmt.Sprintf("  HostName %v\n", h.Vars["ansible_host"]))
+			if v, ok := h.Vars["ansible_user"]; ok && v != "" {
+				sb.WriteString(fmt.Sprintf("  User %v\n", v))
+			}
+			if v, ok := h.Vars["ansible_port"]; ok {
+				sb.WriteString(fmt.Sprintf("  Port %v\n", v))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	return sb.String()
+}
@@ -1,17 +1,20 @@
 package infrastructure
 
 import (
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
 	helper "github.com/metalsoft-io/metalcloud-cli/helpers"
 	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
 	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
 	. "github.com/onsi/gomega"
 )
 
@@ -615,18 +618,65 @@ func TestDeployBlocking(t *testing.T) {
 		Return(nil).
 		AnyTimes()
 
+	client.EXPECT().
+		InfrastructureDeployCustomStages(1000, gomock.Any()).
+		Return(&[]metalcloud.WorkflowStageAssociation{}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		AFCSearch("+infrastructure_id:1000", 0, deployProgressJobLimit).
+		Return(&[]metalcloud.AFCSearchResult{
+			{
+				AFCID:               5,
+				AFCFunctionName:     "instance_provision",
+				AFCStatus:           "running",
+				AFCCreatedTimestamp: time.Now().UTC().Format(time.RFC3339),
+				InfrastructureID:    1000,
+			},
+			{
+				AFCID:               4,
+				AFCFunctionName:     "instance_provision",
+				AFCStatus:           "thrown_error",
+				AFCCreatedTimestamp: "2020-01-01T00:00:00Z",
+				InfrastructureID:    1000,
+			},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InstanceArrays(1000).
+		Return(&map[string]metalcloud.InstanceArray{}, nil).
+		AnyTimes()
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(&bytes.Buffer{}, &stdout)
+
 	cmd := command.MakeCommand(map[string]interface{}{
 		"infrastructure_id_or_label": "1000",
 		"autoconfirm":                true,
 		"block_until_deployed":       true,
 		"block_timeout":              3, //3 seconds to make sure the test is short
 		"block_check_interval":       1, //1 second to make sure the test is short
+		"progress":                   "json",
 	})
 
 	//cs with infra locked
 	_, err := infrastructureDeployCmd(&cmd, client)
 	Expect(err).To(BeNil())
 
+	//the job is only reported once as it does not change, the infrastructure is reported on every status change
+	//and the job of a previous deploy is not reported
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	Expect(lines).To(HaveLen(3))
+
+	var event map[string]interface{}
+	Expect(json.Unmarshal([]byte(lines[1]), &event)).To(BeNil())
+	Expect(event["object"]).To(Equal("job"))
+	Expect(event["status"]).To(Equal("running"))
+
+	Expect(json.Unmarshal([]byte(lines[2]), &event)).To(BeNil())
+	Expect(event["object"]).To(Equal("infrastructure"))
+	Expect(event["status"]).To(Equal("finished"))
 }
 
 func TestDeployBlockingTimeouting(t *testing.T) {
@@ -674,6 +724,7 @@ func TestDeployBlockingTimeouting(t *testing.T) {
 		"block_until_deployed":       true,
 		"block_timeout":              2, //2 seconds to make sure the test is short
 		"block_check_interval":       1, //1 second to make sure the test is short
+		"progress":                   "none",
 	})
 
 	//cs with infra locked
@@ -681,3 +732,307 @@ func TestDeployBlockingTimeouting(t *testing.T) {
 	Expect(err).NotTo(BeNil())
 
 }
+
+func TestDeployProgressPlainRenderer(t *testing.T) {
+	RegisterTestingT(t)
+
+	var stdout bytes.Buffer
+	renderer, err := newDeployProgressRenderer("plain", &stdout)
+	Expect(err).To(BeNil())
+
+	p := deployProgress{
+		Infrastructure: &metalcloud.Infrastructure{InfrastructureID: 1000},
+		Items: []deployProgressItem{
+			{Object: "infrastructure", ID: 1000, Label: "test", Status: "ongoing"},
+			{Object: "job", ID: 5, Label: "instance_provision", Status: "running", Details: "retries 0/3"},
+		},
+	}
+
+	Expect(renderer.Render(&p)).To(BeNil())
+	Expect(stdout.String()).To(ContainSubstring("infrastructure #1000 test: ongoing"))
+	Expect(stdout.String()).To(ContainSubstring("job #5 instance_provision: running (retries 0/3)"))
+
+	stdout.Reset()
+	p.Items[1].Status = "thrown_error_while_retrying"
+	p.Errors = []string{"could not retrieve instances"}
+
+	Expect(renderer.Render(&p)).To(BeNil())
+	Expect(stdout.String()).NotTo(ContainSubstring("infrastructure #1000"))
+	Expect(stdout.String()).To(ContainSubstring("job #5 instance_provision: thrown_error_while_retrying"))
+	Expect(stdout.String()).To(ContainSubstring("error: could not retrieve instances"))
+
+	//an error is only reported again after it was resolved
+	stdout.Reset()
+	Expect(renderer.Render(&p)).To(BeNil())
+	Expect(stdout.String()).To(BeEmpty())
+
+	p.Errors = nil
+	Expect(renderer.Render(&p)).To(BeNil())
+
+	p.Errors = []string{"could not retrieve instances"}
+	Expect(renderer.Render(&p)).To(BeNil())
+	Expect(strings.Count(stdout.String(), "error: could not retrieve instances")).To(Equal(1))
+
+	_, err = newDeployProgressRenderer("fancy", &stdout)
+	Expect(err).NotTo(BeNil())
+}
+
+func TestDeployProgressLiveRenderer(t *testing.T) {
+	RegisterTestingT(t)
+
+	var stdout bytes.Buffer
+	renderer, err := newDeployProgressRenderer("live", &stdout)
+	Expect(err).To(BeNil())
+
+	p := deployProgress{
+		Infrastructure: &metalcloud.Infrastructure{InfrastructureID: 1000, InfrastructureLabel: "test"},
+		Items: []deployProgressItem{
+			{Object: "infrastructure", ID: 1000, Label: "test", Status: "ongoing"},
+			{Object: "job", ID: 5, Label: "instance_provision", Status: "running", Details: "retries 0/3"},
+		},
+	}
+
+	Expect(renderer.Render(&p)).To(BeNil())
+	Expect(stdout.String()).NotTo(ContainSubstring("\x1b[1A"))
+	lines := strings.Count(stdout.String(), "\n")
+
+	//the previous table is cleared on the renderer's writer before the new one is written
+	stdout.Reset()
+	Expect(renderer.Render(&p)).To(BeNil())
+	Expect(stdout.String()).To(HavePrefix(strings.Repeat("\x1b[1A\x1b[2K", lines)))
+	Expect(stdout.String()).To(ContainSubstring("instance_provision"))
+}
+
+func TestInfrastructureGraphCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := helper.NewMockMetalCloudClient(ctrl)
+
+	infra := metalcloud.Infrastructure{
+		InfrastructureID:    10002,
+		InfrastructureLabel: "testinfra",
+	}
+
+	ia := metalcloud.InstanceArray{
+		InstanceArrayID:    11,
+		InstanceArrayLabel: "master",
+		InstanceArrayInterfaces: []metalcloud.InstanceArrayInterface{
+			{
+				InstanceArrayInterfaceIndex: 0,
+				NetworkID:                   30,
+			},
+		},
+	}
+
+	vlanID := 3205
+
+	client.EXPECT().
+		InfrastructureGet(infra.InfrastructureID).
+		Return(&infra, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		Networks(infra.InfrastructureID).
+		Return(&map[string]metalcloud.Network{
+			"wan": {NetworkID: 30, NetworkType: "wan", NetworkLabel: "wan"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InstanceArrays(infra.InfrastructureID).
+		Return(&map[string]metalcloud.InstanceArray{"master": ia}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InstanceArrayInstances(ia.InstanceArrayID).
+		Return(&map[string]metalcloud.Instance{
+			"instance-100": {InstanceID: 100, InstanceLabel: "instance-100", ServerID: 200, InstanceServiceStatus: "active"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		NetworkProfileListByInstanceArray(ia.InstanceArrayID).
+		Return(&map[int]int{30: 40}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		NetworkProfileGet(40).
+		Return(&metalcloud.NetworkProfile{
+			NetworkProfileID:    40,
+			NetworkProfileLabel: "internet",
+			NetworkProfileVLANs: []metalcloud.NetworkProfileVLAN{
+				{VlanID: &vlanID, PortMode: "trunk"},
+			},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		DriveArrays(infra.InfrastructureID).
+		Return(&map[string]metalcloud.DriveArray{
+			"da": {DriveArrayID: 50, DriveArrayLabel: "da", InstanceArrayID: ia.InstanceArrayID},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SharedDrives(infra.InfrastructureID).
+		Return(&map[string]metalcloud.SharedDrive{
+			"sd": {SharedDriveID: 60, SharedDriveLabel: "sd", SharedDriveAttachedInstanceArrays: []int{ia.InstanceArrayID}},
+		}, nil).
+		AnyTimes()
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"infrastructure_id_or_label": "10002",
+	})
+
+	ret, err := infrastructureGraphCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(HavePrefix("digraph \"testinfra\" {"))
+	Expect(ret).To(ContainSubstring(`"ia_11" -> "inst_100";`))
+	Expect(ret).To(ContainSubstring(`"inst_100" -> "srv_200" [label="runs on"];`))
+	Expect(ret).To(ContainSubstring(`"ia_11" -> "net_30" [label="port 1"];`))
+	Expect(ret).To(ContainSubstring(`"np_40" -> "np_40_vlan_0";`))
+	Expect(ret).To(ContainSubstring(`"da_50" -> "ia_11" [label="attached to"];`))
+	Expect(ret).To(ContainSubstring(`"sd_60" -> "ia_11" [label="attached to"];`))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"infrastructure_id_or_label": "10002",
+		"format":                     "mermaid",
+	})
+
+	ret, err = infrastructureGraphCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(HavePrefix("graph LR\n"))
+	Expect(ret).To(ContainSubstring(`np_40_vlan_0["VLAN<br/>3205 trunk"]`))
+	Expect(ret).To(ContainSubstring(`ia_11 -->|"port 1"| net_30`))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"infrastructure_id_or_label": "10002",
+		"format":                     "json",
+	})
+
+	ret, err = infrastructureGraphCmd(&cmd, client)
+	Expect(err).To(BeNil())
+
+	var g map[string]interface{}
+	Expect(json.Unmarshal([]byte(ret), &g)).To(BeNil())
+	Expect(g["nodes"]).To(HaveLen(8))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"infrastructure_id_or_label": "10002",
+		"format":                     "png",
+	})
+
+	_, err = infrastructureGraphCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+}
+
+func TestInfrastructureInventoryCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := helper.NewMockMetalCloudClient(ctrl)
+
+	infra := metalcloud.Infrastructure{
+		InfrastructureID:    10002,
+		InfrastructureLabel: "testinfra",
+	}
+
+	ia := metalcloud.InstanceArray{
+		InstanceArrayID:    11,
+		InstanceArrayLabel: "web-servers",
+		VolumeTemplateID:   70,
+	}
+
+	client.EXPECT().
+		InfrastructureGet(infra.InfrastructureID).
+		Return(&infra, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InstanceArrays(infra.InfrastructureID).
+		Return(&map[string]metalcloud.InstanceArray{"web-servers": ia}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InstanceArrayInstances(ia.InstanceArrayID).
+		Return(&map[string]metalcloud.Instance{
+			"instance-100": {
+				InstanceID:                 100,
+				InstanceLabel:              "instance-100",
+				InstanceSubdomainPermanent: "instance-100.metalcloud.local",
+				InstanceCredentials: metalcloud.InstanceCredentials{
+					IPAddressesPublic: []metalcloud.IP{{IPHumanReadable: "192.168.0.10"}},
+					SSH:               &metalcloud.SSH{Username: "root", InitialPassword: "secret", Port: 22},
+				},
+				InstanceCustomVariables: map[string]interface{}{"role": "frontend"},
+			},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		OSTemplateGet(70, false).
+		Return(&metalcloud.OSTemplate{VolumeTemplateID: 70, VolumeTemplateLabel: "ubuntu-22-04"}, nil).
+		AnyTimes()
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"infrastructure_id_or_label": "10002",
+		"list":                       true,
+	})
+
+	ret, err := infrastructureInventoryCmd(&cmd, client)
+	Expect(err).To(BeNil())
+
+	var inventory map[string]interface{}
+	Expect(json.Unmarshal([]byte(ret), &inventory)).To(BeNil())
+	Expect(inventory["web_servers"].(map[string]interface{})["hosts"]).To(ConsistOf("instance-100.metalcloud.local"))
+
+	hostVars := inventory["_meta"].(map[string]interface{})["hostvars"].(map[string]interface{})["instance-100.metalcloud.local"].(map[string]interface{})
+	Expect(hostVars["ansible_host"]).To(Equal("192.168.0.10"))
+	Expect(hostVars["metalcloud_os_template"]).To(Equal("ubuntu-22-04"))
+	Expect(hostVars["role"]).To(Equal("frontend"))
+	Expect(hostVars).NotTo(HaveKey("ansible_password"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"infrastructure_id_or_label": "10002",
+		"host":                       "instance-100.metalcloud.local",
+		"show_credentials":           true,
+	})
+
+	ret, err = infrastructureInventoryCmd(&cmd, client)
+	Expect(err).To(BeNil())
+
+	var vars map[string]interface{}
+	Expect(json.Unmarshal([]byte(ret), &vars)).To(BeNil())
+	Expect(vars["ansible_user"]).To(Equal("root"))
+	Expect(vars["ansible_password"]).To(Equal("secret"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"infrastructure_id_or_label": "10002",
+		"format":                     "ansible-ini",
+	})
+
+	ret, err = infrastructureInventoryCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(HavePrefix("[web_servers]\ninstance-100.metalcloud.local "))
+	Expect(ret).To(ContainSubstring(" ansible_host=192.168.0.10 "))
+	Expect(ret).To(ContainSubstring(` metalcloud_public_ips=["192.168.0.10"] `))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"infrastructure_id_or_label": "10002",
+		"format":                     "ssh-config",
+		"show_credentials":           true,
+	})
+
+	ret, err = infrastructureInventoryCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("Host instance-100.metalcloud.local\n  HostName 192.168.0.10\n  User root\n  Port 22\n"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"infrastructure_id_or_label": "10002",
+		"format":                     "yaml",
+	})
+
+	_, err = infrastructureInventoryCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+}
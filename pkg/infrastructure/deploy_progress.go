@@ -0,0 +1,506 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/filtering"
+	"github.com/metalsoft-io/tableformatter"
+)
+
+// deployProgressJobLimit is the number of jobs fetched for an infrastructure with each search request
+const deployProgressJobLimit = 100
+
+// deployProgressJobMaxPages bounds the number of search requests made on every refresh
+const deployProgressJobMaxPages = 100
+
+// deployProgressClockSkew is subtracted from the deploy start time to allow for differences between the local and the server clocks
+const deployProgressClockSkew = time.Minute
+
+// deployProgressItem is a single element shown in the deploy progress: the infrastructure, a workflow stage, a job or an instance
+type deployProgressItem struct {
+	Object  string `json:"object"`
+	ID      int    `json:"id"`
+	Label   string `json:"label,omitempty"`
+	Status  string `json:"status"`
+	Details string `json:"details,omitempty"`
+}
+
+func (i deployProgressItem) key() string {
+	return fmt.Sprintf("%s:%d", i.Object, i.ID)
+}
+
+// deployProgressEvent is emitted by the plain and json renderers whenever an item changes
+type deployProgressEvent struct {
+	Timestamp string `json:"timestamp"`
+	deployProgressItem
+}
+
+// deployProgress is a snapshot of an ongoing deploy
+type deployProgress struct {
+	Infrastructure *metalcloud.Infrastructure
+	Items          []deployProgressItem
+	Errors         []string
+}
+
+// jobStatusCounts returns the number of jobs in each AFC status
+func (p *deployProgress) jobStatusCounts() map[string]int {
+	counts := map[string]int{}
+	for _, i := range p.Items {
+		if i.Object == "job" {
+			counts[i.Status]++
+		}
+	}
+	return counts
+}
+
+// deployProgressCollector gathers the infrastructure status, workflow stages, jobs and instances of a deploy.
+// Only the jobs created after the deploy started are collected, older jobs belong to previous deploys.
+type deployProgressCollector struct {
+	infraID     int
+	started     time.Time
+	client      metalcloud.MetalCloudClient
+	stageLabels map[int]string
+}
+
+func newDeployProgressCollector(infraID int, started time.Time, client metalcloud.MetalCloudClient) *deployProgressCollector {
+	return &deployProgressCollector{
+		infraID:     infraID,
+		started:     started,
+		client:      client,
+		stageLabels: map[int]string{},
+	}
+}
+
+// collect returns a snapshot of the deploy. Only a failure to retrieve the infrastructure is returned as an error,
+// the other failures are recorded in the snapshot so that the progress view keeps running.
+func (d *deployProgressCollector) collect() (*deployProgress, error) {
+	infra, err := d.client.InfrastructureGet(d.infraID)
+	if err != nil {
+		return nil, err
+	}
+
+	p := deployProgress{
+		Infrastructure: infra,
+		Items: []deployProgressItem{
+			{
+				Object: "infrastructure",
+				ID:     infra.InfrastructureID,
+				Label:  infra.InfrastructureLabel,
+				Status: infra.InfrastructureOperation.InfrastructureDeployStatus,
+			},
+		},
+	}
+
+	for _, stageType := range []string{"pre_deploy", "post_deploy"} {
+		items, err := d.collectStages(stageType)
+		if err != nil {
+			p.Errors = append(p.Errors, fmt.Sprintf("could not retrieve %s workflow stages: %v", stageType, err))
+			continue
+		}
+		p.Items = append(p.Items, items...)
+	}
+
+	items, err := d.collectJobs()
+	if err != nil {
+		p.Errors = append(p.Errors, fmt.Sprintf("could not retrieve jobs: %v", err))
+	} else {
+		p.Items = append(p.Items, items...)
+	}
+
+	items, err = d.collectInstances()
+	if err != nil {
+		p.Errors = append(p.Errors, fmt.Sprintf("could not retrieve instances: %v", err))
+	} else {
+		p.Items = append(p.Items, items...)
+	}
+
+	return &p, nil
+}
+
+func (d *deployProgressCollector) collectStages(stageType string) ([]deployProgressItem, error) {
+	list, err := d.client.InfrastructureDeployCustomStages(d.infraID, stageType)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(*list, func(i, j int) bool {
+		return (*list)[i].InfrastructureDeployCustomStageRunLevel < (*list)[j].InfrastructureDeployCustomStageRunLevel
+	})
+
+	items := []deployProgressItem{}
+	for _, s := range *list {
+		label, ok := d.stageLabels[s.StageDefinitionID]
+		if !ok {
+			stage, err := d.client.StageDefinitionGet(s.StageDefinitionID)
+			if err != nil {
+				return nil, err
+			}
+			label = stage.StageDefinitionLabel
+			d.stageLabels[s.StageDefinitionID] = label
+		}
+
+		status := "pending"
+		if s.InfrastructureDeployCustomStageExecOutputJSON != "" {
+			status = "executed"
+		}
+
+		items = append(items, deployProgressItem{
+			Object:  "stage",
+			ID:      s.InfrastructureDeployCustomStageID,
+			Label:   label,
+			Status:  status,
+			Details: fmt.Sprintf("%s, runlevel %d", s.InfrastructureDeployCustomStageType, s.InfrastructureDeployCustomStageRunLevel),
+		})
+	}
+
+	return items, nil
+}
+
+func (d *deployProgressCollector) collectJobs() ([]deployProgressItem, error) {
+	filter := filtering.ConvertToSearchFieldFormat(fmt.Sprintf("infrastructure_id:%d", d.infraID))
+
+	// the jobs are sorted by status first so all the pages are needed to find the jobs of this deploy
+	list := []metalcloud.AFCSearchResult{}
+	for page := 0; page < deployProgressJobMaxPages; page++ {
+		start := page * deployProgressJobLimit
+
+		results, err := d.client.AFCSearch(filter, start, start+deployProgressJobLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, *results...)
+
+		if len(*results) < deployProgressJobLimit {
+			break
+		}
+	}
+
+	since := d.started.Add(-deployProgressClockSkew)
+
+	items := []deployProgressItem{}
+	for _, s := range list {
+		// jobs without a valid timestamp are kept as they cannot be attributed to an older deploy
+		if created, err := time.Parse(time.RFC3339, s.AFCCreatedTimestamp); err == nil && created.Before(since) {
+			continue
+		}
+
+		details := fmt.Sprintf("retries %d/%d", s.AFCRetryCount, s.AFCRetryMax)
+		if s.InstanceID != 0 {
+			details = fmt.Sprintf("%s, instance #%d", details, s.InstanceID)
+		}
+		if s.ServerID != 0 {
+			details = fmt.Sprintf("%s, server #%d", details, s.ServerID)
+		}
+
+		items = append(items, deployProgressItem{
+			Object:  "job",
+			ID:      s.AFCID,
+			Label:   s.AFCFunctionName,
+			Status:  s.AFCStatus,
+			Details: details,
+		})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+
+	return items, nil
+}
+
+func (d *deployProgressCollector) collectInstances() ([]deployProgressItem, error) {
+	iaList, err := d.client.InstanceArrays(d.infraID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []deployProgressItem{}
+	for _, ia := range *iaList {
+		iList, err := d.client.InstanceArrayInstances(ia.InstanceArrayID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range *iList {
+			status := i.InstanceServiceStatus
+			if i.InstanceOperation.InstanceDeployStatus != "" {
+				status = fmt.Sprintf("%s (deploy %s)", status, i.InstanceOperation.InstanceDeployStatus)
+			}
+
+			details := fmt.Sprintf("instance array %s", ia.InstanceArrayLabel)
+			if i.ServerID != 0 {
+				details = fmt.Sprintf("%s, server #%d", details, i.ServerID)
+			}
+
+			items = append(items, deployProgressItem{
+				Object:  "instance",
+				ID:      i.InstanceID,
+				Label:   i.InstanceLabel,
+				Status:  status,
+				Details: details,
+			})
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+
+	return items, nil
+}
+
+// deployProgressRenderer prints deploy progress snapshots as they are collected
+type deployProgressRenderer interface {
+	Render(p *deployProgress) error
+}
+
+// newDeployProgressRenderer returns the renderer for the given mode. The 'auto' mode uses the live view
+// when stdout is a terminal and falls back to plain log lines otherwise.
+func newDeployProgressRenderer(mode string, w io.Writer) (deployProgressRenderer, error) {
+	switch mode {
+	case "", "auto":
+		if command.StdoutIsTerminal() {
+			return &deployProgressLiveRenderer{w: w}, nil
+		}
+		return &deployProgressPlainRenderer{w: w, changes: deployProgressChanges{}, errors: deployProgressErrors{}}, nil
+	case "live":
+		return &deployProgressLiveRenderer{w: w}, nil
+	case "plain":
+		return &deployProgressPlainRenderer{w: w, changes: deployProgressChanges{}, errors: deployProgressErrors{}}, nil
+	case "json":
+		return &deployProgressJSONRenderer{w: w, changes: deployProgressChanges{}, errors: deployProgressErrors{}}, nil
+	case "none":
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("progress mode '%s' not supported. Supported values are 'auto','live','plain','json','none'", mode)
+}
+
+// deployProgressChanges keeps the last seen state of every item and returns the items that changed
+type deployProgressChanges map[string]deployProgressItem
+
+func (c deployProgressChanges) update(p *deployProgress) []deployProgressItem {
+	changed := []deployProgressItem{}
+	for _, i := range p.Items {
+		prev, ok := c[i.key()]
+		if !ok || prev != i {
+			changed = append(changed, i)
+		}
+		c[i.key()] = i
+	}
+	return changed
+}
+
+// deployProgressErrors keeps the errors of the last snapshot and returns the errors that were not already reported
+type deployProgressErrors map[string]bool
+
+func (e deployProgressErrors) update(p *deployProgress) []string {
+	current := map[string]bool{}
+	added := []string{}
+	for _, err := range p.Errors {
+		if !e[err] && !current[err] {
+			added = append(added, err)
+		}
+		current[err] = true
+	}
+
+	for err := range e {
+		delete(e, err)
+	}
+	for err := range current {
+		e[err] = true
+	}
+
+	return added
+}
+
+// deployProgressLiveRenderer redraws a table with the current deploy state in place
+type deployProgressLiveRenderer struct {
+	w        io.Writer
+	prevLen  int
+	started  time.Time
+	rendered bool
+}
+
+func (r *deployProgressLiveRenderer) Render(p *deployProgress) error {
+	if !r.rendered {
+		r.started = time.Now()
+		r.rendered = true
+	}
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "OBJECT",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "ID",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "LABEL",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "STATUS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "DETAILS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 30,
+		},
+	}
+
+	data := [][]interface{}{}
+	for _, i := range p.Items {
+		//successful jobs are only counted, there can be hundreds of them
+		if i.Object == "infrastructure" || (i.Object == "job" && i.Status == "returned_success") {
+			continue
+		}
+
+		data = append(data, []interface{}{
+			i.Object,
+			i.ID,
+			i.Label,
+			colorizeDeployProgressStatus(i.Status),
+			i.Details,
+		})
+	}
+
+	counts := p.jobStatusCounts()
+	topLine := fmt.Sprintf("Infrastructure %s (%d) deploy %s - jobs: %d running, %d thrown error, %d thrown error retrying, %d returned success",
+		p.Infrastructure.InfrastructureLabel,
+		p.Infrastructure.InfrastructureID,
+		colorizeDeployProgressStatus(p.Infrastructure.InfrastructureOperation.InfrastructureDeployStatus),
+		counts["running"],
+		counts["thrown_error"],
+		counts["thrown_error_while_retrying"],
+		counts["returned_success"],
+	)
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	str, err := table.RenderTable("Deploy progress", topLine, "")
+	if err != nil {
+		return err
+	}
+
+	for _, e := range p.Errors {
+		str += colors.Red(e) + "\n"
+	}
+
+	str += fmt.Sprintf("Elapsed %s, refreshed at %s\n",
+		time.Since(r.started).Round(time.Second),
+		time.Now().Format("01-02-2006 15:04:05"))
+
+	//the previous table is cleared on the same writer the table is written to
+	if r.prevLen != 0 {
+		fmt.Fprint(r.w, strings.Repeat("\x1b[1A\x1b[2K", r.prevLen))
+	}
+	fmt.Fprint(r.w, "\x1b[1G")
+
+	fmt.Fprint(r.w, str)
+
+	r.prevLen = strings.Count(str, "\n")
+
+	return nil
+}
+
+// deployProgressPlainRenderer prints a log line for every item that changed
+type deployProgressPlainRenderer struct {
+	w       io.Writer
+	changes deployProgressChanges
+	errors  deployProgressErrors
+}
+
+func (r *deployProgressPlainRenderer) Render(p *deployProgress) error {
+	timestamp := time.Now().Format(time.RFC3339)
+
+	for _, i := range r.changes.update(p) {
+		line := fmt.Sprintf("%s %s #%d", timestamp, i.Object, i.ID)
+		if i.Label != "" {
+			line += " " + i.Label
+		}
+		line += ": " + i.Status
+		if i.Details != "" {
+			line += " (" + i.Details + ")"
+		}
+		fmt.Fprintln(r.w, line)
+	}
+
+	for _, e := range r.errors.update(p) {
+		fmt.Fprintf(r.w, "%s error: %s\n", timestamp, e)
+	}
+
+	return nil
+}
+
+// deployProgressJSONRenderer prints a JSON object per line for every item that changed
+type deployProgressJSONRenderer struct {
+	w       io.Writer
+	changes deployProgressChanges
+	errors  deployProgressErrors
+}
+
+func (r *deployProgressJSONRenderer) Render(p *deployProgress) error {
+	timestamp := time.Now().Format(time.RFC3339)
+
+	for _, i := range r.changes.update(p) {
+		b, err := json.Marshal(deployProgressEvent{
+			Timestamp:          timestamp,
+			deployProgressItem: i,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(r.w, string(b))
+	}
+
+	for _, e := range r.errors.update(p) {
+		b, err := json.Marshal(deployProgressEvent{
+			Timestamp: timestamp,
+			deployProgressItem: deployProgressItem{
+				Object:  "error",
+				Status:  "error",
+				Details: e,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(r.w, string(b))
+	}
+
+	return nil
+}
+
+func colorizeDeployProgressStatus(status string) string {
+	switch {
+	case status == "thrown_error":
+		return colors.Red(status)
+	case status == "thrown_error_while_retrying":
+		return colors.Magenta(status)
+	case status == "returned_success", status == "finished", status == "executed", strings.HasPrefix(status, "active"):
+		return colors.Green(status)
+	case status == "ordered", status == "pending", strings.HasPrefix(status, "ordered"):
+		return colors.Blue(status)
+	}
+	return colors.Yellow(status)
+}
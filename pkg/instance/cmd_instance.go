@@ -10,6 +10,7 @@ import (
 	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
 	"github.com/metalsoft-io/metalcloud-cli/internal/command"
 	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	"github.com/metalsoft-io/metalcloud-cli/pkg/utils"
 	"github.com/metalsoft-io/tableformatter"
 )
 
@@ -241,8 +242,8 @@ func instanceGetCmd(c *command.Command, client metalcloud.MetalCloudClient) (str
 		},
 	}
 
-	publicIPS := getIPsAsStringArray(instance.InstanceCredentials.IPAddressesPublic)
-	privateIPS := getIPsAsStringArray(instance.InstanceCredentials.IPAddressesPrivate)
+	publicIPS := utils.GetIPsAsStringArray(instance.InstanceCredentials.IPAddressesPublic)
+	privateIPS := utils.GetIPsAsStringArray(instance.InstanceCredentials.IPAddressesPrivate)
 
 	dataRow := []interface{}{
 		instance.InstanceID,
@@ -405,14 +406,6 @@ func instanceGetCmd(c *command.Command, client metalcloud.MetalCloudClient) (str
 	return table.RenderTransposedTable("Records", topRow, command.GetStringParam(c.Arguments["format"]))
 }
 
-func getIPsAsStringArray(ips []metalcloud.IP) []string {
-	sList := []string{}
-	for _, ip := range ips {
-		sList = append(sList, ip.IPHumanReadable)
-	}
-	return sList
-}
-
 func instanceServerReplaceCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
 	instanceID, ok := command.GetIntParamOk(c.Arguments["instance_id"])
 	if !ok {
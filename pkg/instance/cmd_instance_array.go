@@ -149,6 +149,36 @@ var InstanceArrayCmds = []command.Command{
 		Endpoint:      configuration.UserEndpoint,
 		AdminEndpoint: configuration.DeveloperEndpoint,
 	},
+	{
+		Description:  "Perform a rolling operation on the instances of an instance array.",
+		Subject:      "instance-array",
+		AltSubject:   "ia",
+		Predicate:    "rolling",
+		AltPredicate: "roll",
+		FlagSet:      flag.NewFlagSet("rolling instance array", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"instance_array_id_or_label": c.FlagSet.String("id", command.NilDefaultStr, colors.Red("(Required)")+" Instance array's id or label. Note that using the 'label' might be ambiguous in certain situations."),
+				"action":                     c.FlagSet.String("action", command.NilDefaultStr, colors.Red("(Required)")+" The operation to perform, one of: reboot, power-cycle, server-replace."),
+				"batch_size":                 c.FlagSet.Int("batch-size", 1, "The number of instances to operate on in parallel. Defaults to 1."),
+				"pause":                      c.FlagSet.String("pause", command.NilDefaultStr, "Time to wait between batches in human readable format such as '30s' or '2m'."),
+				"new_server_ids":             c.FlagSet.String("new-server-ids", command.NilDefaultStr, "Comma separated list of server ids, one for each instance in the order of the instance ids. Required for server-replace."),
+				"health_cmd":                 c.FlagSet.String("health-cmd", command.NilDefaultStr, "Shell command that must succeed before moving to the next batch. {host}, {hostname} and {instance_id} are replaced with the instance's values."),
+				"health_url":                 c.FlagSet.String("health-url", command.NilDefaultStr, "HTTP URL that must return a 2xx status before moving to the next batch. {host}, {hostname} and {instance_id} are replaced with the instance's values."),
+				"timeout":                    c.FlagSet.Int("timeout", 15*60, "Timeout in seconds for each instance to return to a running power state and pass the health checks. Defaults to 15 minutes."),
+				"check_interval":             c.FlagSet.Int("check-interval", 10, "Check interval in seconds for the power status and health checks. Defaults to 10 seconds."),
+				"reboot_settle":              c.FlagSet.Int("reboot-settle", 60, "Time in seconds to wait for a reboot to be observed as a power transition before waiting for the instance to come back and be healthy. Defaults to 60 seconds."),
+				"autoconfirm":                c.FlagSet.Bool("autoconfirm", false, colors.Green("(Flag)")+" If set it will assume action is confirmed"),
+			}
+		},
+		ExecuteFunc:   instanceArrayRollingCmd,
+		Endpoint:      configuration.DeveloperEndpoint,
+		AdminEndpoint: configuration.DeveloperEndpoint,
+		Example: `
+metalcloud-cli instance-array rolling --id 12345 --action reboot --batch-size 2 --pause 2m --reboot-settle 120 --health-url http://{host}:8080/health
+metalcloud-cli instance-array rolling --id 12345 --action power-cycle --health-cmd "ssh root@{host} systemctl is-active myservice"
+metalcloud-cli instance-array rolling --id 12345 --action server-replace --new-server-ids 101,102,103`,
+	},
 }
 
 func instanceArrayCreateCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
@@ -616,6 +646,9 @@ func instanceArrayEditCmd(c *command.Command, client metalcloud.MetalCloudClient
 			return "", err
 		}
 
+		// instances of the same array usually share the volume template and server type
+		resolver := command.NewResolver(client)
+
 		for _, i := range *iList {
 			status := i.InstanceServiceStatus
 			if i.InstanceServiceStatus != "ordered" && i.InstanceOperation.InstanceDeployType == "edit" && i.InstanceOperation.InstanceDeployStatus == "not_started" {
@@ -624,7 +657,7 @@ func instanceArrayEditCmd(c *command.Command, client metalcloud.MetalCloudClient
 
 			volumeTemplateName := ""
 			if i.InstanceOperation.TemplateIDOrigin != 0 {
-				vt, err := client.VolumeTemplateGet(i.InstanceOperation.TemplateIDOrigin)
+				vt, err := resolver.VolumeTemplate(i.InstanceOperation.TemplateIDOrigin)
 				if err != nil {
 					return "", err
 				}
@@ -633,7 +666,7 @@ func instanceArrayEditCmd(c *command.Command, client metalcloud.MetalCloudClient
 
 			serverType := ""
 			if i.ServerTypeID != 0 {
-				st, err := client.ServerTypeGet(i.ServerTypeID)
+				st, err := resolver.ServerType(i.ServerTypeID)
 				if err != nil {
 					return "", err
 				}
@@ -0,0 +1,399 @@
+package instance
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	"github.com/metalsoft-io/metalcloud-cli/pkg/utils"
+)
+
+// rollingOptions holds the parameters of a rolling operation
+type rollingOptions struct {
+	action        string
+	batchSize     int
+	pause         time.Duration
+	timeout       time.Duration
+	checkInterval time.Duration
+	rebootSettle  time.Duration
+	healthCmd     string
+	healthURL     string
+	newServerIDs  []int
+}
+
+func instanceArrayRollingCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	opts := rollingOptions{
+		action:        command.GetStringParam(c.Arguments["action"]),
+		batchSize:     1,
+		timeout:       time.Duration(command.GetIntParam(c.Arguments["timeout"])) * time.Second,
+		checkInterval: time.Duration(command.GetIntParam(c.Arguments["check_interval"])) * time.Second,
+		rebootSettle:  time.Duration(command.GetIntParam(c.Arguments["reboot_settle"])) * time.Second,
+		healthCmd:     command.GetStringParam(c.Arguments["health_cmd"]),
+		healthURL:     command.GetStringParam(c.Arguments["health_url"]),
+	}
+
+	if opts.action != "reboot" && opts.action != "power-cycle" && opts.action != "server-replace" {
+		return "", fmt.Errorf("-action is required (one of: reboot, power-cycle, server-replace)")
+	}
+
+	if v, ok := command.GetIntParamOk(c.Arguments["batch_size"]); ok {
+		if v < 1 {
+			return "", fmt.Errorf("-batch-size must be at least 1")
+		}
+		opts.batchSize = v
+	}
+
+	if v, ok := command.GetStringParamOk(c.Arguments["pause"]); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return "", fmt.Errorf("-pause is not a valid duration such as '30s' or '2m': %v", err)
+		}
+		opts.pause = d
+	}
+
+	if v, ok := command.GetStringParamOk(c.Arguments["new_server_ids"]); ok {
+		for _, s := range strings.Split(v, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return "", fmt.Errorf("-new-server-ids must be a comma separated list of server ids: %v", err)
+			}
+			opts.newServerIDs = append(opts.newServerIDs, id)
+		}
+	}
+
+	ia, err := command.GetInstanceArrayFromCommand("id", c, client)
+	if err != nil {
+		return "", err
+	}
+
+	iList, err := client.InstanceArrayInstances(ia.InstanceArrayID)
+	if err != nil {
+		return "", err
+	}
+
+	instances := []metalcloud.Instance{}
+	for _, i := range *iList {
+		instances = append(instances, i)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].InstanceID < instances[j].InstanceID
+	})
+
+	if len(instances) == 0 {
+		return "", fmt.Errorf("instance array %s (#%d) has no instances", ia.InstanceArrayLabel, ia.InstanceArrayID)
+	}
+
+	if opts.action == "server-replace" && len(opts.newServerIDs) != len(instances) {
+		return "", fmt.Errorf("-new-server-ids must contain exactly one server id for each of the %d instances, in the order of the instance ids", len(instances))
+	}
+
+	batchCount := (len(instances) + opts.batchSize - 1) / opts.batchSize
+
+	confirm, err := command.ConfirmCommand(c, func() string {
+
+		confirmationMessage := fmt.Sprintf("Performing a rolling %s of %s instances of instance array %s (#%d) in %d batches of at most %d instances.  Are you sure? Type \"yes\" to continue:",
+			colors.Red(opts.action),
+			colors.Red(fmt.Sprintf("%d", len(instances))),
+			ia.InstanceArrayLabel,
+			ia.InstanceArrayID,
+			batchCount,
+			opts.batchSize,
+		)
+
+		//this is simply so that we don't output a text on the command line under go test
+		if strings.HasSuffix(os.Args[0], ".test") {
+			confirmationMessage = ""
+		}
+
+		return confirmationMessage
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if !confirm {
+		return "", fmt.Errorf("operation not confirmed. Aborting")
+	}
+
+	if opts.action == "reboot" && opts.healthCmd == "" && opts.healthURL == "" {
+		rollingLog("warning: no -health-cmd or -health-url given, the instances are only checked to be powered on after the reset")
+	}
+
+	done := 0
+	for batch := 0; batch < batchCount; batch++ {
+		start := batch * opts.batchSize
+		end := start + opts.batchSize
+		if end > len(instances) {
+			end = len(instances)
+		}
+
+		rollingLog("batch %d/%d: %s on %d instances", batch+1, batchCount, opts.action, end-start)
+
+		// the instances of a batch are operated on in parallel
+		errs := make([]error, end-start)
+		command.Prefetch(end-start, opts.batchSize, func(i int) error {
+			newServerID := 0
+			if opts.action == "server-replace" {
+				newServerID = opts.newServerIDs[start+i]
+			}
+
+			errs[i] = rollingRunInstance(instances[start+i], newServerID, opts, client)
+			return errs[i]
+		})
+
+		for _, err := range errs {
+			if err == nil {
+				done++
+			}
+		}
+
+		for i, err := range errs {
+			if err != nil {
+				return "", rollingHalted(batch, batchCount, instances[start+i], done, len(instances), err)
+			}
+		}
+
+		if batch < batchCount-1 && opts.pause > 0 {
+			rollingLog("pausing for %s", opts.pause)
+			time.Sleep(opts.pause)
+		}
+	}
+
+	return fmt.Sprintf("Rolling %s completed on %d instances of instance array %s (#%d).\n", opts.action, done, ia.InstanceArrayLabel, ia.InstanceArrayID), nil
+}
+
+// rollingLogLock serializes the log lines of the instances operated on in parallel
+var rollingLogLock sync.Mutex
+
+func rollingLog(format string, a ...interface{}) {
+	rollingLogLock.Lock()
+	defer rollingLogLock.Unlock()
+
+	fmt.Fprintf(configuration.GetStdout(), "%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, a...))
+}
+
+func rollingHalted(batch int, batchCount int, instance metalcloud.Instance, done int, total int, err error) error {
+	return fmt.Errorf("rollout halted in batch %d/%d at instance %s (#%d): %v. %d of %d instances were completed",
+		batch+1,
+		batchCount,
+		instance.InstanceLabel,
+		instance.InstanceID,
+		err,
+		done,
+		total,
+	)
+}
+
+// rollingRunInstance performs the action on an instance and waits for it to be running and healthy
+func rollingRunInstance(instance metalcloud.Instance, newServerID int, opts rollingOptions, client metalcloud.MetalCloudClient) error {
+	if err := rollingRunAction(instance, newServerID, opts, client); err != nil {
+		return err
+	}
+
+	if err := rollingWaitForInstance(instance, opts, client); err != nil {
+		return err
+	}
+
+	return rollingHealthCheck(instance, opts, client)
+}
+
+// rollingRunAction starts the action on an instance without waiting for it to complete
+func rollingRunAction(instance metalcloud.Instance, newServerID int, opts rollingOptions, client metalcloud.MetalCloudClient) error {
+	switch opts.action {
+	case "reboot":
+		rollingLog("instance %s (#%d): reset", instance.InstanceLabel, instance.InstanceID)
+		return client.InstanceServerPowerSet(instance.InstanceID, "reset")
+
+	case "power-cycle":
+		rollingLog("instance %s (#%d): power off", instance.InstanceLabel, instance.InstanceID)
+		if err := client.InstanceServerPowerSet(instance.InstanceID, "off"); err != nil {
+			return err
+		}
+
+		if err := rollingWaitForPowerStatus(instance, "off", opts, client); err != nil {
+			return err
+		}
+
+		rollingLog("instance %s (#%d): power on", instance.InstanceLabel, instance.InstanceID)
+		return client.InstanceServerPowerSet(instance.InstanceID, "on")
+
+	case "server-replace":
+		rollingLog("instance %s (#%d): replacing server #%d with server #%d", instance.InstanceLabel, instance.InstanceID, instance.ServerID, newServerID)
+		_, err := client.InstanceServerReplace(instance.InstanceID, newServerID)
+		if err != nil {
+			return err
+		}
+
+		deadline := time.Now().Add(opts.timeout)
+		for {
+			time.Sleep(opts.checkInterval)
+
+			i, err := client.InstanceGet(instance.InstanceID)
+			if err != nil {
+				return err
+			}
+
+			if i.ServerID == newServerID && i.InstanceServiceStatus == "active" {
+				return nil
+			}
+
+			if time.Now().After(deadline) {
+				return fmt.Errorf("timed out waiting for the server to be replaced with server #%d", newServerID)
+			}
+		}
+	}
+
+	return fmt.Errorf("unsupported action %s", opts.action)
+}
+
+// rollingWaitForInstance waits for an instance to return to a running power state
+func rollingWaitForInstance(instance metalcloud.Instance, opts rollingOptions, client metalcloud.MetalCloudClient) error {
+	if opts.action == "reboot" {
+		if err := rollingWaitForReset(instance, opts, client); err != nil {
+			return err
+		}
+	}
+
+	if err := rollingWaitForPowerStatus(instance, "on", opts, client); err != nil {
+		return err
+	}
+
+	rollingLog("instance %s (#%d): powered on", instance.InstanceLabel, instance.InstanceID)
+
+	return nil
+}
+
+// rollingWaitForReset waits until the reset is observed as a power status other than 'on' or until the settle time expires
+func rollingWaitForReset(instance metalcloud.Instance, opts rollingOptions, client metalcloud.MetalCloudClient) error {
+	deadline := time.Now().Add(opts.rebootSettle)
+
+	for {
+		time.Sleep(opts.checkInterval)
+
+		pwr, err := client.InstanceServerPowerGet(instance.InstanceID)
+		if err != nil {
+			return err
+		}
+
+		if pwr != nil && *pwr != "on" {
+			rollingLog("instance %s (#%d): power status is '%s', reset in progress", instance.InstanceLabel, instance.InstanceID, *pwr)
+			return nil
+		}
+
+		if !time.Now().Before(deadline) {
+			rollingLog("instance %s (#%d): no power transition observed after %s", instance.InstanceLabel, instance.InstanceID, opts.rebootSettle)
+			return nil
+		}
+	}
+}
+
+func rollingWaitForPowerStatus(instance metalcloud.Instance, status string, opts rollingOptions, client metalcloud.MetalCloudClient) error {
+	deadline := time.Now().Add(opts.timeout)
+
+	for {
+		// the power status is not updated instantly after an operation
+		time.Sleep(opts.checkInterval)
+
+		pwr, err := client.InstanceServerPowerGet(instance.InstanceID)
+		if err != nil {
+			return err
+		}
+
+		if pwr != nil && *pwr == status {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			current := ""
+			if pwr != nil {
+				current = *pwr
+			}
+			return fmt.Errorf("timed out waiting for power status '%s', last status was '%s'", status, current)
+		}
+	}
+}
+
+// rollingHealthCheck runs the user supplied health checks until they succeed or the timeout expires
+func rollingHealthCheck(instance metalcloud.Instance, opts rollingOptions, client metalcloud.MetalCloudClient) error {
+	if opts.healthCmd == "" && opts.healthURL == "" {
+		return nil
+	}
+
+	// the instance might have been moved on a different server so the IPs are read again
+	i, err := client.InstanceGet(instance.InstanceID)
+	if err != nil {
+		return err
+	}
+
+	host := i.InstanceSubdomainPermanent
+	if ips := utils.GetIPsAsStringArray(i.InstanceCredentials.IPAddressesPublic); len(ips) > 0 {
+		host = ips[0]
+	} else if ips := utils.GetIPsAsStringArray(i.InstanceCredentials.IPAddressesPrivate); len(ips) > 0 {
+		host = ips[0]
+	}
+
+	replacer := strings.NewReplacer(
+		"{host}", host,
+		"{hostname}", i.InstanceSubdomainPermanent,
+		"{instance_id}", fmt.Sprintf("%d", i.InstanceID),
+	)
+
+	check := func() error {
+		if opts.healthCmd != "" {
+			cmd := exec.Command("sh", "-c", replacer.Replace(opts.healthCmd))
+			cmd.Env = append(os.Environ(),
+				fmt.Sprintf("METALCLOUD_INSTANCE_ID=%d", i.InstanceID),
+				fmt.Sprintf("METALCLOUD_INSTANCE_HOST=%s", host),
+				fmt.Sprintf("METALCLOUD_INSTANCE_HOSTNAME=%s", i.InstanceSubdomainPermanent),
+			)
+
+			if out, err := cmd.CombinedOutput(); err != nil {
+				return fmt.Errorf("health check command failed: %v %s", err, strings.TrimSpace(string(out)))
+			}
+		}
+
+		if opts.healthURL != "" {
+			httpClient := http.Client{Timeout: 10 * time.Second}
+
+			resp, err := httpClient.Get(replacer.Replace(opts.healthURL))
+			if err != nil {
+				return fmt.Errorf("health check probe failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode < 200 || resp.StatusCode > 299 {
+				return fmt.Errorf("health check probe returned status %d", resp.StatusCode)
+			}
+		}
+
+		return nil
+	}
+
+	deadline := time.Now().Add(opts.timeout)
+	for {
+		err := check()
+		if err == nil {
+			rollingLog("instance %s (#%d): healthy", instance.InstanceLabel, instance.InstanceID)
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return err
+		}
+
+		rollingLog("instance %s (#%d): not healthy yet: %v", instance.InstanceLabel, instance.InstanceID, err)
+		time.Sleep(opts.checkInterval)
+	}
+}
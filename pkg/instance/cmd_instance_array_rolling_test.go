@@ -0,0 +1,339 @@
+package instance
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	. "github.com/onsi/gomega"
+)
+
+func TestInstanceArrayRollingCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	ia := metalcloud.InstanceArray{
+		InstanceArrayID:    11,
+		InstanceArrayLabel: "web",
+	}
+
+	instances := map[string]metalcloud.Instance{}
+	for _, id := range []int{100, 101, 102} {
+		i := metalcloud.Instance{
+			InstanceID:      id,
+			InstanceLabel:   "instance",
+			InstanceArrayID: ia.InstanceArrayID,
+			InstanceCredentials: metalcloud.InstanceCredentials{
+				IPAddressesPrivate: []metalcloud.IP{{IPHumanReadable: "127.0.0.1"}},
+			},
+		}
+		instances[fmt.Sprintf("instance-%d", id)] = i
+
+		client.EXPECT().
+			InstanceGet(id).
+			Return(&i, nil).
+			AnyTimes()
+	}
+
+	client.EXPECT().
+		InstanceArrayGet(ia.InstanceArrayID).
+		Return(&ia, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InstanceArrayInstances(ia.InstanceArrayID).
+		Return(&instances, nil).
+		AnyTimes()
+
+	on := "on"
+	client.EXPECT().
+		InstanceServerPowerGet(gomock.Any()).
+		Return(&on, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InstanceServerPowerSet(gomock.Any(), "reset").
+		Return(nil).
+		Times(3)
+
+	var probedLock sync.Mutex
+	probed := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probedLock.Lock()
+		defer probedLock.Unlock()
+		probed = append(probed, r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(strings.NewReader(""), &stdout)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"instance_array_id_or_label": "11",
+		"action":                     "reboot",
+		"batch_size":                 2,
+		"pause":                      "0s",
+		"timeout":                    0,
+		"check_interval":             0,
+		"reboot_settle":              0,
+		"health_url":                 server.URL + "/{instance_id}",
+		"autoconfirm":                true,
+	})
+
+	ret, err := instanceArrayRollingCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("Rolling reboot completed on 3 instances"))
+	Expect(stdout.String()).To(ContainSubstring("instance instance (#100): no power transition observed after 0s"))
+	// the instances of a batch are checked in parallel
+	Expect(probed).To(HaveLen(3))
+	Expect(probed[:2]).To(ConsistOf("/100", "/101"))
+	Expect(probed[2]).To(Equal("/102"))
+	Expect(stdout.String()).To(ContainSubstring("batch 2/2: reboot on 1 instances"))
+
+	// invalid parameters are rejected before anything is done
+	cmd = command.MakeCommand(map[string]interface{}{
+		"instance_array_id_or_label": "11",
+		"action":                     "server-replace",
+		"new_server_ids":             "1,2",
+		"autoconfirm":                true,
+	})
+
+	_, err = instanceArrayRollingCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"instance_array_id_or_label": "11",
+		"action":                     "shutdown",
+	})
+
+	_, err = instanceArrayRollingCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+
+	// without health checks a reboot only waits for the instances to be powered on
+	stdout.Reset()
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"instance_array_id_or_label": "11",
+		"action":                     "reboot",
+		"batch_size":                 3,
+		"timeout":                    0,
+		"check_interval":             0,
+		"reboot_settle":              0,
+		"autoconfirm":                true,
+	})
+
+	client.EXPECT().
+		InstanceServerPowerSet(gomock.Any(), "reset").
+		Return(nil).
+		Times(3)
+
+	ret, err = instanceArrayRollingCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("Rolling reboot completed on 3 instances"))
+	Expect(stdout.String()).To(ContainSubstring("warning: no -health-cmd or -health-url given"))
+}
+
+func TestInstanceArrayRollingCmdHaltsOnFailedHealthCheck(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	ia := metalcloud.InstanceArray{
+		InstanceArrayID:    11,
+		InstanceArrayLabel: "web",
+	}
+
+	instances := map[string]metalcloud.Instance{
+		"a": {InstanceID: 100, InstanceLabel: "a"},
+		"b": {InstanceID: 101, InstanceLabel: "b"},
+	}
+
+	client.EXPECT().
+		InstanceArrayGet(ia.InstanceArrayID).
+		Return(&ia, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InstanceArrayInstances(ia.InstanceArrayID).
+		Return(&instances, nil).
+		AnyTimes()
+
+	a := instances["a"]
+	client.EXPECT().
+		InstanceGet(100).
+		Return(&a, nil).
+		AnyTimes()
+
+	off := "off"
+	on := "on"
+	gomock.InOrder(
+		client.EXPECT().InstanceServerPowerSet(100, "off").Return(nil),
+		client.EXPECT().InstanceServerPowerGet(100).Return(&off, nil),
+		client.EXPECT().InstanceServerPowerSet(100, "on").Return(nil),
+		client.EXPECT().InstanceServerPowerGet(100).Return(&on, nil),
+	)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(strings.NewReader(""), &stdout)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"instance_array_id_or_label": "11",
+		"action":                     "power-cycle",
+		"timeout":                    0,
+		"check_interval":             0,
+		"health_cmd":                 "test {instance_id} = 0",
+		"autoconfirm":                true,
+	})
+
+	_, err := instanceArrayRollingCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("rollout halted in batch 1/2 at instance a (#100)"))
+	Expect(err.Error()).To(ContainSubstring("0 of 2 instances were completed"))
+}
+
+func TestInstanceArrayRollingCmdRebootWaitsForReset(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	ia := metalcloud.InstanceArray{
+		InstanceArrayID:    11,
+		InstanceArrayLabel: "web",
+	}
+
+	instances := map[string]metalcloud.Instance{
+		"a": {InstanceID: 100, InstanceLabel: "a"},
+	}
+
+	client.EXPECT().
+		InstanceArrayGet(ia.InstanceArrayID).
+		Return(&ia, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InstanceArrayInstances(ia.InstanceArrayID).
+		Return(&instances, nil).
+		AnyTimes()
+
+	a := instances["a"]
+	client.EXPECT().
+		InstanceGet(100).
+		Return(&a, nil).
+		AnyTimes()
+
+	// the instance is still reported as on right after the reset, the transition is seen on the next check
+	on := "on"
+	off := "off"
+	gomock.InOrder(
+		client.EXPECT().InstanceServerPowerSet(100, "reset").Return(nil),
+		client.EXPECT().InstanceServerPowerGet(100).Return(&on, nil),
+		client.EXPECT().InstanceServerPowerGet(100).Return(&off, nil),
+		client.EXPECT().InstanceServerPowerGet(100).Return(&off, nil),
+		client.EXPECT().InstanceServerPowerGet(100).Return(&on, nil),
+	)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(strings.NewReader(""), &stdout)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"instance_array_id_or_label": "11",
+		"action":                     "reboot",
+		"timeout":                    60,
+		"check_interval":             0,
+		"reboot_settle":              3600,
+		"health_cmd":                 "test {instance_id} = 100",
+		"autoconfirm":                true,
+	})
+
+	ret, err := instanceArrayRollingCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("Rolling reboot completed on 1 instances"))
+	Expect(stdout.String()).To(ContainSubstring("instance a (#100): power status is 'off', reset in progress"))
+	Expect(stdout.String()).To(ContainSubstring("instance a (#100): powered on"))
+}
+
+func TestInstanceArrayRollingCmdRunsBatchInParallel(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	ia := metalcloud.InstanceArray{
+		InstanceArrayID:    11,
+		InstanceArrayLabel: "web",
+	}
+
+	instances := map[string]metalcloud.Instance{
+		"a": {InstanceID: 100, InstanceLabel: "a"},
+		"b": {InstanceID: 101, InstanceLabel: "b"},
+	}
+
+	client.EXPECT().
+		InstanceArrayGet(ia.InstanceArrayID).
+		Return(&ia, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InstanceArrayInstances(ia.InstanceArrayID).
+		Return(&instances, nil).
+		AnyTimes()
+
+	// instance a only powers off once instance b was powered off, which never happens if a is waited for alone
+	var lock sync.Mutex
+	power := map[int]string{100: "on", 101: "on"}
+	poweredOff := map[int]bool{}
+
+	client.EXPECT().
+		InstanceServerPowerSet(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(id int, operation string) error {
+			lock.Lock()
+			defer lock.Unlock()
+			power[id] = operation
+			poweredOff[id] = poweredOff[id] || operation == "off"
+			return nil
+		}).
+		Times(4)
+
+	client.EXPECT().
+		InstanceServerPowerGet(gomock.Any()).
+		DoAndReturn(func(id int) (*string, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			status := power[id]
+			if id == 100 && !poweredOff[101] {
+				status = "on"
+			}
+			return &status, nil
+		}).
+		AnyTimes()
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(strings.NewReader(""), &stdout)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"instance_array_id_or_label": "11",
+		"action":                     "power-cycle",
+		"batch_size":                 2,
+		"timeout":                    5,
+		"check_interval":             0,
+		"autoconfirm":                true,
+	})
+
+	ret, err := instanceArrayRollingCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("Rolling power-cycle completed on 2 instances"))
+}
@@ -63,12 +63,17 @@ var NetworkProfileCmds = []command.Command{
 			c.Arguments = map[string]interface{}{
 				"network_profile_id": c.FlagSet.Int("id", command.NilDefaultInt, colors.Red("(Required)")+" Network profile's id."),
 				"format":             c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
-				"raw":                c.FlagSet.Bool("raw", false, colors.Green("(Flag)")+" If set returns the raw object serialized using specified format"),
+				"raw":                c.FlagSet.Bool("raw", false, colors.Green("(Flag)")+" If set returns the raw object serialized using specified format. The output can be used as input for network-profile create."),
 			}
 		},
 		ExecuteFunc:         networkProfileGetCmd,
 		Endpoint:            configuration.DeveloperEndpoint,
 		PermissionsRequired: []string{command.NETWORK_PROFILES_READ},
+		Example: `
+#save a network profile to a file and create a copy of it:
+metalcloud-cli network-profile get --id 10 --raw --format yaml > network-profile.yaml
+metalcloud-cli network-profile create --datacenter us02-chi-qts01-dc --format yaml --raw-config ./network-profile.yaml
+`,
 	},
 	{
 		Description:  "Create network profile.",
@@ -115,6 +120,57 @@ vlans:
 metalcloud-cli network-profile create -datacenter us02-chi-qts01-dc -format yaml -raw-config ./network-profile.yaml
 
 More details available https://docs.metalsoft.io/en/latest/guides/adding_a_network_profile.html
+`,
+	},
+	{
+		Description:  "Clone a network profile, optionally into another datacenter.",
+		Subject:      "network-profile",
+		AltSubject:   "np",
+		Predicate:    "clone",
+		AltPredicate: "copy",
+		FlagSet:      flag.NewFlagSet("clone network profile", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"network_profile_id": c.FlagSet.Int("id", command.NilDefaultInt, colors.Red("(Required)")+" Id of the network profile to clone."),
+				"datacenter":         c.FlagSet.String("datacenter", command.NilDefaultStr, colors.Red("(Required)")+" Label of the datacenter in which to create the clone."),
+				"label":              c.FlagSet.String("label", command.NilDefaultStr, colors.Green("(Optional)")+" Label of the clone. Defaults to the label of the cloned network profile."),
+				"strip_resources":    c.FlagSet.Bool("strip-resources", false, colors.Green("(Flag)")+" If set, external connections and subnet pools that belong to another datacenter are removed from the clone. Subnet pools are replaced with automatically allocated ones of the same type."),
+				"return_id":          c.FlagSet.Bool("return-id", false, "Will print the ID of the created object. Useful for automating tasks."),
+			}
+		},
+		ExecuteFunc:         networkProfileCloneCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.NETWORK_PROFILES_WRITE},
+		Example: `
+#copy a network profile to another datacenter:
+metalcloud-cli network-profile clone --id 10 --datacenter uk-reading --label internet-uk
+
+External connections and subnet pools are specific to a datacenter. When cloning into another datacenter the command fails if
+the profile uses any of them, unless --strip-resources is set.
+`,
+	},
+	{
+		Description:  "Compare two network profiles.",
+		Subject:      "network-profile",
+		AltSubject:   "np",
+		Predicate:    "diff",
+		AltPredicate: "compare",
+		FlagSet:      flag.NewFlagSet("diff network profiles", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"network_profile_a": c.FlagSet.Int("a", command.NilDefaultInt, colors.Red("(Required)")+" Id of the first network profile."),
+				"network_profile_b": c.FlagSet.Int("b", command.NilDefaultInt, colors.Red("(Required)")+" Id of the second network profile."),
+				"format":            c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
+			}
+		},
+		ExecuteFunc:         networkProfileDiffCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.NETWORK_PROFILES_READ},
+		Example: `
+#compare the network type, VLANs, external connections and subnet pools of two network profiles:
+metalcloud-cli network-profile diff --a 10 --b 11
+
+VLANs are matched by VLAN ID. Automatically allocated VLANs are matched by their order and shown as auto-1, auto-2 and so on.
 `,
 	},
 	{
@@ -292,6 +348,8 @@ func networkProfileVlansListCmd(c *command.Command, client metalcloud.MetalCloud
 		},
 	}
 
+	resolver := command.NewResolver(client)
+
 	dataConfiguration := [][]interface{}{}
 	networkProfileVlans := retNP.NetworkProfileVLANs
 
@@ -301,7 +359,7 @@ func networkProfileVlansListCmd(c *command.Command, client metalcloud.MetalCloud
 		ecIds := ""
 		for index, ecId := range externalConnectionIDs {
 
-			retEC, err := client.ExternalConnectionGet(ecId)
+			retEC, err := resolver.ExternalConnection(ecId)
 			if err != nil {
 				return "", err
 			}
@@ -376,6 +434,8 @@ func networkProfileGetCmd(c *command.Command, client metalcloud.MetalCloudClient
 
 	networkProfileVlans := retNP.NetworkProfileVLANs
 
+	resolver := command.NewResolver(client)
+
 	vlanListDescriptions := []string{}
 
 	for _, vlan := range networkProfileVlans {
@@ -384,7 +444,7 @@ func networkProfileGetCmd(c *command.Command, client metalcloud.MetalCloudClient
 		ecDescriptions := []string{}
 		for _, ecId := range externalConnectionIDs {
 
-			retEC, err := client.ExternalConnectionGet(ecId)
+			retEC, err := resolver.ExternalConnection(ecId)
 			if err != nil {
 				return "", err
 			}
@@ -400,7 +460,7 @@ func networkProfileGetCmd(c *command.Command, client metalcloud.MetalCloudClient
 				subnetPoolsDescriptions = append(subnetPoolsDescriptions, colors.Blue(fmt.Sprintf("auto %s", subnet.SubnetPoolType)))
 				continue
 			}
-			retSubnet, err := client.SubnetPoolGet(*subnet.SubnetPoolID)
+			retSubnet, err := resolver.SubnetPool(*subnet.SubnetPoolID)
 			if err != nil {
 				return "", err
 			}
@@ -449,7 +509,7 @@ func networkProfileGetCmd(c *command.Command, client metalcloud.MetalCloudClient
 	format := command.GetStringParam(c.Arguments["format"])
 
 	if command.GetBoolParam(c.Arguments["raw"]) {
-		ret, err := tableformatter.RenderRawObject(*retNP, format, "Server interfaces")
+		ret, err := tableformatter.RenderRawObject(networkProfileToCreateInput(*retNP), format, "Server interfaces")
 		if err != nil {
 			return "", err
 		}
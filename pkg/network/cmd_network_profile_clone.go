@@ -0,0 +1,349 @@
+package network
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/tableformatter"
+)
+
+// networkProfileDifference is a field that has different values in two network profiles
+type networkProfileDifference struct {
+	Field string
+	A     string
+	B     string
+}
+
+// networkProfileToCreateInput returns a copy of the profile without the fields set by the server so that it can be used as input for network-profile create
+func networkProfileToCreateInput(np metalcloud.NetworkProfile) metalcloud.NetworkProfile {
+	np.NetworkProfileID = 0
+	np.NetworkProfileIsPublic = false
+	np.NetworkProfileCreatedTimestamp = ""
+	np.NetworkProfileUpdatedTimestamp = ""
+
+	vlans := []metalcloud.NetworkProfileVLAN{}
+	for _, vlan := range np.NetworkProfileVLANs {
+		vlan.ExternalConnectionIDs = append([]int{}, vlan.ExternalConnectionIDs...)
+		vlan.SubnetPools = append([]metalcloud.NetworkProfileSubnetPool{}, vlan.SubnetPools...)
+		vlans = append(vlans, vlan)
+	}
+	np.NetworkProfileVLANs = vlans
+
+	return np
+}
+
+func networkProfileCloneCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	id, ok := command.GetIntParamOk(c.Arguments["network_profile_id"])
+	if !ok {
+		return "", fmt.Errorf("-id required")
+	}
+
+	datacenter, ok := command.GetStringParamOk(c.Arguments["datacenter"])
+	if !ok {
+		return "", fmt.Errorf("-datacenter is required")
+	}
+
+	retNP, err := client.NetworkProfileGet(id)
+	if err != nil {
+		return "", err
+	}
+
+	npConf := networkProfileToCreateInput(*retNP)
+	npConf.DatacenterName = datacenter
+
+	if label, ok := command.GetStringParamOk(c.Arguments["label"]); ok {
+		npConf.NetworkProfileLabel = label
+	}
+
+	if datacenter != retNP.DatacenterName {
+		err = networkProfileCheckDatacenterResources(&npConf, client, command.GetBoolParam(c.Arguments["strip_resources"]))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	ret, err := client.NetworkProfileCreate(datacenter, npConf)
+	if err != nil {
+		return "", err
+	}
+
+	if command.GetBoolParam(c.Arguments["return_id"]) {
+		return fmt.Sprintf("%d", ret.NetworkProfileID), nil
+	}
+
+	return "", nil
+}
+
+// networkProfileCheckDatacenterResources checks that the external connections and subnet pools of the profile belong to its datacenter.
+// If strip is set those of other datacenters are removed, subnet pools being replaced with automatically allocated ones of the same type.
+func networkProfileCheckDatacenterResources(np *metalcloud.NetworkProfile, client metalcloud.MetalCloudClient, strip bool) error {
+
+	resolver := command.NewResolver(client)
+	foreign := []string{}
+
+	for i, vlan := range np.NetworkProfileVLANs {
+
+		ecIDs := []int{}
+		for _, ecID := range vlan.ExternalConnectionIDs {
+			retEC, err := resolver.ExternalConnection(ecID)
+			if err != nil {
+				return err
+			}
+
+			if retEC.DatacenterName != np.DatacenterName {
+				foreign = append(foreign, fmt.Sprintf("external connection %s (#%d) of datacenter %s", retEC.ExternalConnectionLabel, ecID, retEC.DatacenterName))
+				continue
+			}
+			ecIDs = append(ecIDs, ecID)
+		}
+
+		subnetPools := []metalcloud.NetworkProfileSubnetPool{}
+		for _, subnet := range vlan.SubnetPools {
+			if subnet.SubnetPoolID != nil {
+				retSubnet, err := resolver.SubnetPool(*subnet.SubnetPoolID)
+				if err != nil {
+					return err
+				}
+
+				if retSubnet.DatacenterName != np.DatacenterName {
+					foreign = append(foreign, fmt.Sprintf("subnet pool %s/%d (#%d) of datacenter %s", retSubnet.SubnetPoolPrefixHumanReadable, retSubnet.SubnetPoolPrefixSize, retSubnet.SubnetPoolID, retSubnet.DatacenterName))
+					subnet.SubnetPoolID = nil
+				}
+			}
+			subnetPools = append(subnetPools, subnet)
+		}
+
+		if strip {
+			np.NetworkProfileVLANs[i].ExternalConnectionIDs = ecIDs
+			np.NetworkProfileVLANs[i].SubnetPools = subnetPools
+		}
+	}
+
+	if len(foreign) > 0 && !strip {
+		return fmt.Errorf("the network profile uses resources that do not belong to datacenter %s: %s. Use --strip-resources to clone it without them", np.DatacenterName, strings.Join(foreign, ", "))
+	}
+
+	return nil
+}
+
+func networkProfileDiffCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	idA, ok := command.GetIntParamOk(c.Arguments["network_profile_a"])
+	if !ok {
+		return "", fmt.Errorf("-a required")
+	}
+
+	idB, ok := command.GetIntParamOk(c.Arguments["network_profile_b"])
+	if !ok {
+		return "", fmt.Errorf("-b required")
+	}
+
+	npA, err := client.NetworkProfileGet(idA)
+	if err != nil {
+		return "", err
+	}
+
+	npB, err := client.NetworkProfileGet(idB)
+	if err != nil {
+		return "", err
+	}
+
+	differences, err := diffNetworkProfiles(*npA, *npB, command.NewResolver(client))
+	if err != nil {
+		return "", err
+	}
+
+	format := command.GetStringParam(c.Arguments["format"])
+
+	if len(differences) == 0 && format == "" {
+		return fmt.Sprintf("Network profiles %s (#%d) and %s (#%d) are identical.\n", npA.NetworkProfileLabel, npA.NetworkProfileID, npB.NetworkProfileLabel, npB.NetworkProfileID), nil
+	}
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "FIELD",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 30,
+		},
+		{
+			FieldName: fmt.Sprintf("%s (#%d)", npA.NetworkProfileLabel, npA.NetworkProfileID),
+			FieldType: tableformatter.TypeString,
+			FieldSize: 30,
+		},
+		{
+			FieldName: fmt.Sprintf("%s (#%d)", npB.NetworkProfileLabel, npB.NetworkProfileID),
+			FieldType: tableformatter.TypeString,
+			FieldSize: 30,
+		},
+	}
+
+	data := [][]interface{}{}
+	for _, d := range differences {
+		data = append(data, []interface{}{
+			d.Field,
+			d.A,
+			d.B,
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	title := fmt.Sprintf("Differences between network profiles %s (#%d) and %s (#%d)", npA.NetworkProfileLabel, npA.NetworkProfileID, npB.NetworkProfileLabel, npB.NetworkProfileID)
+
+	return table.RenderTable("Differences", title, format)
+}
+
+// diffNetworkProfiles compares the network type and the VLANs of two profiles. VLANs are matched by VLAN ID, automatically allocated VLANs by their order.
+func diffNetworkProfiles(a metalcloud.NetworkProfile, b metalcloud.NetworkProfile, resolver *command.Resolver) ([]networkProfileDifference, error) {
+
+	differences := []networkProfileDifference{}
+
+	if a.NetworkType != b.NetworkType {
+		differences = append(differences, networkProfileDifference{"network type", a.NetworkType, b.NetworkType})
+	}
+
+	keys, vlansA := networkProfileVLANsByKey(a)
+	keysB, vlansB := networkProfileVLANsByKey(b)
+
+	for _, key := range keysB {
+		if _, ok := vlansA[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	for _, key := range keys {
+		vlanA, okA := vlansA[key]
+		vlanB, okB := vlansB[key]
+
+		field := fmt.Sprintf("vlan %s", key)
+
+		if !okA {
+			differences = append(differences, networkProfileDifference{field, "-", "present"})
+			continue
+		}
+
+		if !okB {
+			differences = append(differences, networkProfileDifference{field, "present", "-"})
+			continue
+		}
+
+		if vlanA.PortMode != vlanB.PortMode {
+			differences = append(differences, networkProfileDifference{field + " port mode", vlanA.PortMode, vlanB.PortMode})
+		}
+
+		if vlanA.ProvisionSubnetGateways != vlanB.ProvisionSubnetGateways {
+			differences = append(differences, networkProfileDifference{field + " subnet gateways", strconv.FormatBool(vlanA.ProvisionSubnetGateways), strconv.FormatBool(vlanB.ProvisionSubnetGateways)})
+		}
+
+		if vlanA.ProvisionVXLAN != vlanB.ProvisionVXLAN {
+			differences = append(differences, networkProfileDifference{field + " vxlan", strconv.FormatBool(vlanA.ProvisionVXLAN), strconv.FormatBool(vlanB.ProvisionVXLAN)})
+		}
+
+		ecA, err := describeExternalConnections(vlanA.ExternalConnectionIDs, resolver)
+		if err != nil {
+			return nil, err
+		}
+
+		ecB, err := describeExternalConnections(vlanB.ExternalConnectionIDs, resolver)
+		if err != nil {
+			return nil, err
+		}
+
+		if ecA != ecB {
+			differences = append(differences, networkProfileDifference{field + " external connections", ecA, ecB})
+		}
+
+		spA, err := describeNetworkProfileSubnetPools(vlanA.SubnetPools, resolver)
+		if err != nil {
+			return nil, err
+		}
+
+		spB, err := describeNetworkProfileSubnetPools(vlanB.SubnetPools, resolver)
+		if err != nil {
+			return nil, err
+		}
+
+		if spA != spB {
+			differences = append(differences, networkProfileDifference{field + " subnet pools", spA, spB})
+		}
+	}
+
+	return differences, nil
+}
+
+// networkProfileVLANsByKey indexes the VLANs of a profile by their VLAN ID or, for automatically allocated VLANs, by "auto" followed by their order.
+// It also returns the keys in the order of the VLANs.
+func networkProfileVLANsByKey(np metalcloud.NetworkProfile) ([]string, map[string]metalcloud.NetworkProfileVLAN) {
+	keys := []string{}
+	vlans := map[string]metalcloud.NetworkProfileVLAN{}
+	auto := 0
+
+	for _, vlan := range np.NetworkProfileVLANs {
+		key := ""
+		if vlan.VlanID != nil {
+			key = strconv.Itoa(*vlan.VlanID)
+		} else {
+			auto++
+			key = fmt.Sprintf("auto-%d", auto)
+		}
+
+		keys = append(keys, key)
+		vlans[key] = vlan
+	}
+
+	return keys, vlans
+}
+
+func describeExternalConnections(ids []int, resolver *command.Resolver) (string, error) {
+	descriptions := []string{}
+
+	for _, id := range ids {
+		retEC, err := resolver.ExternalConnection(id)
+		if err != nil {
+			return "", err
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s (#%d)", retEC.ExternalConnectionLabel, id))
+	}
+
+	return describeSortedList(descriptions), nil
+}
+
+func describeNetworkProfileSubnetPools(subnetPools []metalcloud.NetworkProfileSubnetPool, resolver *command.Resolver) (string, error) {
+	descriptions := []string{}
+
+	for _, subnet := range subnetPools {
+		description := fmt.Sprintf("auto %s", subnet.SubnetPoolType)
+
+		if subnet.SubnetPoolID != nil {
+			retSubnet, err := resolver.SubnetPool(*subnet.SubnetPoolID)
+			if err != nil {
+				return "", err
+			}
+			description = fmt.Sprintf("%s/%d (#%d)", retSubnet.SubnetPoolPrefixHumanReadable, retSubnet.SubnetPoolPrefixSize, retSubnet.SubnetPoolID)
+		}
+
+		if subnet.SubnetPoolProvidesDefaultRoute {
+			description = description + " default route"
+		}
+
+		descriptions = append(descriptions, description)
+	}
+
+	return describeSortedList(descriptions), nil
+}
+
+func describeSortedList(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	sort.Strings(items)
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,248 @@
+package network
+
+import (
+	"encoding/json"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	. "github.com/onsi/gomega"
+)
+
+func getCloneTestNetworkProfile() metalcloud.NetworkProfile {
+	vlanID := 100
+	subnetPoolID := 12
+
+	return metalcloud.NetworkProfile{
+		NetworkProfileID:               10,
+		NetworkProfileLabel:            "internet",
+		DatacenterName:                 "dc-a",
+		NetworkType:                    "wan",
+		NetworkProfileIsPublic:         true,
+		NetworkProfileCreatedTimestamp: "2024-01-01T00:00:00Z",
+		NetworkProfileUpdatedTimestamp: "2024-01-02T00:00:00Z",
+		NetworkProfileVLANs: []metalcloud.NetworkProfileVLAN{
+			{
+				VlanID:                &vlanID,
+				PortMode:              "trunk",
+				ExternalConnectionIDs: []int{5},
+				SubnetPools: []metalcloud.NetworkProfileSubnetPool{
+					{
+						SubnetPoolID:                   &subnetPoolID,
+						SubnetPoolType:                 "ipv4",
+						SubnetPoolProvidesDefaultRoute: true,
+					},
+				},
+			},
+			{
+				PortMode: "native",
+				SubnetPools: []metalcloud.NetworkProfileSubnetPool{
+					{
+						SubnetPoolType: "ipv6",
+					},
+				},
+			},
+		},
+	}
+}
+
+func expectCloneTestResources(client *mock_metalcloud.MockMetalCloudClient) {
+	client.EXPECT().
+		ExternalConnectionGet(5).
+		Return(&metalcloud.ExternalConnection{
+			ExternalConnectionID:    5,
+			ExternalConnectionLabel: "uplink-a",
+			DatacenterName:          "dc-a",
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ExternalConnectionGet(6).
+		Return(&metalcloud.ExternalConnection{
+			ExternalConnectionID:    6,
+			ExternalConnectionLabel: "uplink-b",
+			DatacenterName:          "dc-b",
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SubnetPoolGet(12).
+		Return(&metalcloud.SubnetPool{
+			SubnetPoolID:                  12,
+			SubnetPoolPrefixHumanReadable: "192.168.0.0",
+			SubnetPoolPrefixSize:          24,
+			DatacenterName:                "dc-a",
+		}, nil).
+		AnyTimes()
+}
+
+func TestNetworkProfileGetRawIsCreateInput(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	np := getCloneTestNetworkProfile()
+
+	client.EXPECT().
+		NetworkProfileGet(10).
+		Return(&np, nil).
+		AnyTimes()
+
+	expectCloneTestResources(client)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"network_profile_id": 10,
+		"raw":                true,
+		"format":             "json",
+	})
+
+	ret, err := networkProfileGetCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).NotTo(ContainSubstring("network_profile_id"))
+	Expect(ret).NotTo(ContainSubstring("timestamp"))
+
+	var npConf metalcloud.NetworkProfile
+	err = json.Unmarshal([]byte(ret), &npConf)
+	Expect(err).To(BeNil())
+	Expect(npConf).To(Equal(networkProfileToCreateInput(np)))
+	Expect(npConf.NetworkProfileLabel).To(Equal("internet"))
+}
+
+func TestNetworkProfileCloneCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	np := getCloneTestNetworkProfile()
+
+	client.EXPECT().
+		NetworkProfileGet(10).
+		Return(&np, nil).
+		AnyTimes()
+
+	expectCloneTestResources(client)
+
+	// same datacenter, with a new label
+	expected := networkProfileToCreateInput(np)
+	expected.NetworkProfileLabel = "internet-copy"
+
+	client.EXPECT().
+		NetworkProfileCreate("dc-a", expected).
+		Return(&metalcloud.NetworkProfile{NetworkProfileID: 11}, nil).
+		Times(1)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"network_profile_id": 10,
+		"datacenter":         "dc-a",
+		"label":              "internet-copy",
+		"return_id":          true,
+	})
+
+	ret, err := networkProfileCloneCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal("11"))
+
+	// another datacenter with resources of the source datacenter
+	cmd = command.MakeCommand(map[string]interface{}{
+		"network_profile_id": 10,
+		"datacenter":         "dc-b",
+	})
+
+	_, err = networkProfileCloneCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("external connection uplink-a (#5) of datacenter dc-a"))
+	Expect(err.Error()).To(ContainSubstring("subnet pool 192.168.0.0/24 (#12) of datacenter dc-a"))
+
+	// another datacenter, stripping the resources
+	expected = networkProfileToCreateInput(np)
+	expected.DatacenterName = "dc-b"
+	expected.NetworkProfileVLANs[0].ExternalConnectionIDs = []int{}
+	expected.NetworkProfileVLANs[0].SubnetPools[0].SubnetPoolID = nil
+
+	client.EXPECT().
+		NetworkProfileCreate("dc-b", expected).
+		Return(&metalcloud.NetworkProfile{NetworkProfileID: 12}, nil).
+		Times(1)
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"network_profile_id": 10,
+		"datacenter":         "dc-b",
+		"strip_resources":    true,
+	})
+
+	ret, err = networkProfileCloneCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal(""))
+
+	// the source profile is not changed
+	Expect(*np.NetworkProfileVLANs[0].SubnetPools[0].SubnetPoolID).To(Equal(12))
+	Expect(np.NetworkProfileVLANs[0].ExternalConnectionIDs).To(Equal([]int{5}))
+}
+
+func TestNetworkProfileDiffCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	npA := getCloneTestNetworkProfile()
+
+	npB := getCloneTestNetworkProfile()
+	npB.NetworkProfileID = 20
+	npB.NetworkProfileLabel = "internet-b"
+	npB.DatacenterName = "dc-b"
+	npB.NetworkType = "lan"
+	npB.NetworkProfileVLANs[0].ExternalConnectionIDs = []int{6}
+	npB.NetworkProfileVLANs[0].SubnetPools = []metalcloud.NetworkProfileSubnetPool{{SubnetPoolType: "ipv4"}}
+	npB.NetworkProfileVLANs[1].PortMode = "trunk"
+	vlanID := 200
+	npB.NetworkProfileVLANs = append(npB.NetworkProfileVLANs, metalcloud.NetworkProfileVLAN{VlanID: &vlanID, PortMode: "trunk"})
+
+	client.EXPECT().
+		NetworkProfileGet(10).
+		Return(&npA, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		NetworkProfileGet(20).
+		Return(&npB, nil).
+		AnyTimes()
+
+	expectCloneTestResources(client)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"network_profile_a": 10,
+		"network_profile_b": 20,
+		"format":            "csv",
+	})
+
+	ret, err := networkProfileDiffCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal(`FIELD,internet (#10),internet-b (#20)
+network type,wan,lan
+vlan 100 external connections,uplink-a (#5),uplink-b (#6)
+vlan 100 subnet pools,192.168.0.0/24 (#12) default route,auto ipv4
+vlan auto-1 port mode,native,trunk
+vlan 200,-,present
+`))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"network_profile_a": 10,
+		"network_profile_b": 10,
+	})
+
+	ret, err = networkProfileDiffCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal("Network profiles internet (#10) and internet (#10) are identical.\n"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"network_profile_a": 10,
+	})
+
+	_, err = networkProfileDiffCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+}
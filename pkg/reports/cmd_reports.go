@@ -3,6 +3,8 @@ package reports
 import (
 	"flag"
 	"fmt"
+	"sort"
+	"sync"
 
 	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
 	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
@@ -11,6 +13,9 @@ import (
 	"github.com/metalsoft-io/tableformatter"
 )
 
+// defaultReportConcurrency is the number of datacenters queried at the same time
+const defaultReportConcurrency = 4
+
 var ReportsCmds = []command.Command{
 	{
 		Description:  "Statistics and other reports.",
@@ -21,13 +26,149 @@ var ReportsCmds = []command.Command{
 		FlagSet:      flag.NewFlagSet("list active devices in all datacenters", flag.ExitOnError),
 		InitFunc: func(c *command.Command) {
 			c.Arguments = map[string]interface{}{
-				"format": c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
+				"format":      c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
+				"concurrency": c.FlagSet.Int("concurrency", defaultReportConcurrency, colors.Green("(Optional)")+" The number of datacenters queried at the same time."),
 			}
 		},
 		ExecuteFunc:         devicesListCmd,
 		Endpoint:            configuration.DeveloperEndpoint,
 		PermissionsRequired: []string{command.ADMIN_ACCESS},
 	},
+	{
+		Description:  "Hardware inventory per datacenter, server type and status.",
+		Subject:      "report",
+		AltSubject:   "report",
+		Predicate:    "inventory",
+		AltPredicate: "inv",
+		FlagSet:      flag.NewFlagSet("hardware inventory", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"datacenter":  c.FlagSet.String("datacenter", command.NilDefaultStr, colors.Green("(Optional)")+" Only report on this datacenter. All datacenters are included by default."),
+				"format":      c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'csv','json','md'. The default format is human readable."),
+				"concurrency": c.FlagSet.Int("concurrency", defaultReportConcurrency, colors.Green("(Optional)")+" The number of datacenters queried at the same time."),
+			}
+		},
+		ExecuteFunc:         inventoryReportCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.ADMIN_ACCESS},
+		Example: `
+Servers are grouped by datacenter, server type and status with totals for CPU cores, RAM, disks and network capacity.
+NIC_CAPACITY lists how many servers have each total network capacity. Switches are counted per driver and role.
+
+metalcloud-cli report inventory --format md > inventory.md
+metalcloud-cli report inventory --datacenter dc1 --format csv
+`,
+	},
+	{
+		Description:  "Save a snapshot of the utilization of all datacenters.",
+		Subject:      "report",
+		AltSubject:   "report",
+		Predicate:    "snapshot",
+		AltPredicate: "snap",
+		FlagSet:      flag.NewFlagSet("utilization snapshot", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"dir":         c.FlagSet.String("dir", command.NilDefaultStr, colors.Green("(Optional)")+" The directory in which the snapshot is saved. Defaults to $METALCLOUD_REPORT_SNAPSHOTS_PATH or ~/.metalcloud/snapshots."),
+				"jobs_limit":  c.FlagSet.Int("jobs-limit", 10000, colors.Green("(Optional)")+" The maximum number of failed jobs counted for each status. Counts that reach the limit are flagged as truncated."),
+				"concurrency": c.FlagSet.Int("concurrency", defaultReportConcurrency, colors.Green("(Optional)")+" The number of datacenters queried at the same time."),
+			}
+		},
+		ExecuteFunc:         reportSnapshotCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.ADMIN_ACCESS},
+		Example: `
+A snapshot contains the device counts, the number of servers in each status, the utilization of the subnet pools and
+the number of failed jobs. Run it periodically, for example from cron, and compare the snapshots with report trend.
+
+metalcloud-cli report snapshot
+metalcloud-cli report snapshot --dir /var/lib/metalcloud/snapshots
+`,
+	},
+	{
+		Description:  "Compare utilization snapshots.",
+		Subject:      "report",
+		AltSubject:   "report",
+		Predicate:    "trend",
+		AltPredicate: "trends",
+		FlagSet:      flag.NewFlagSet("utilization trend", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"since":  c.FlagSet.String("since", "30d", colors.Green("(Optional)")+" The period to compare, such as 30d, 2w or 12h. The latest snapshot is compared with the last one taken before this period."),
+				"dir":    c.FlagSet.String("dir", command.NilDefaultStr, colors.Green("(Optional)")+" The directory in which the snapshots are saved. Defaults to $METALCLOUD_REPORT_SNAPSHOTS_PATH or ~/.metalcloud/snapshots."),
+				"format": c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
+			}
+		},
+		ExecuteFunc:         reportTrendCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.ADMIN_ACCESS},
+		Example: `
+Datacenters and failed job counts that could not be queried when either snapshot was taken are left out of the
+comparison, including from the totals.
+
+metalcloud-cli report trend --since 30d
+metalcloud-cli report trend --since 2w --format csv > trend.csv
+`,
+	},
+	{
+		Description:  "Subnet pool utilization with warning and critical thresholds.",
+		Subject:      "report",
+		AltSubject:   "report",
+		Predicate:    "subnets",
+		AltPredicate: "subnet-pools",
+		FlagSet:      flag.NewFlagSet("subnet pool utilization", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"datacenter":  c.FlagSet.String("datacenter", command.NilDefaultStr, colors.Green("(Optional)")+" Only report on this datacenter. All datacenters are included by default."),
+				"warn":        c.FlagSet.Int("warn", defaultSubnetsWarn, colors.Green("(Optional)")+" The utilization percentage at which a subnet pool is reported as WARNING."),
+				"crit":        c.FlagSet.Int("crit", defaultSubnetsCrit, colors.Green("(Optional)")+" The utilization percentage at which a subnet pool is reported as CRITICAL."),
+				"format":      c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml','nagios'. The default format is human readable."),
+				"concurrency": c.FlagSet.Int("concurrency", defaultReportConcurrency, colors.Green("(Optional)")+" The number of datacenters queried at the same time."),
+			}
+		},
+		ExecuteFunc:         subnetsReportCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.ADMIN_ACCESS},
+		Example: `
+The utilization of each subnet pool and of all the pools of a datacenter with the same destination (WAN, LAN, SAN, OOB)
+and type is compared with the thresholds. FREE_PREFIXES lists how many prefixes of each size can still be allocated.
+OOB subnets do not report their utilization and are not included.
+
+With the 'nagios' and 'json' formats the exit code is 0 (OK), 1 (WARNING), 2 (CRITICAL) or 3 (UNKNOWN, a datacenter
+could not be queried or the parameters are invalid) so that the command can be used as a monitoring check:
+
+metalcloud-cli report subnets --datacenter dc1 --warn 80 --crit 95
+metalcloud-cli report subnets --warn 80 --crit 95 --format nagios
+`,
+	},
+	{
+		Description:  "VLAN allocation of the network profiles of a datacenter.",
+		Subject:      "report",
+		AltSubject:   "report",
+		Predicate:    "vlans",
+		AltPredicate: "vlan-allocation",
+		FlagSet:      flag.NewFlagSet("vlan allocation", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"datacenter":  c.FlagSet.String("datacenter", command.NilDefaultStr, colors.Red("(Required)")+" The datacenter to report on."),
+				"issues_only": c.FlagSet.Bool("issues-only", false, colors.Green("(Flag)")+" If set, only the VLANs with issues are shown."),
+				"format":      c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
+				"concurrency": c.FlagSet.Int("concurrency", defaultReportConcurrency, colors.Green("(Optional)")+" The number of infrastructures and instance arrays queried at the same time."),
+			}
+		},
+		ExecuteFunc:         vlansReportCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.ADMIN_ACCESS},
+		Example: `
+Lists every VLAN of the network profiles of the datacenter together with the instance arrays that use each profile.
+The following issues are reported:
+  collision                    the VLAN ID is used by more than one network profile
+  unused                       no instance array uses the network profile
+  missing external connection  the external connection does not exist in the datacenter
+
+metalcloud-cli report vlans --datacenter dc1
+metalcloud-cli report vlans --datacenter dc1 --issues-only --format csv
+`,
+	},
 }
 
 func getActiveServers(datacenter string, client metalcloud.MetalCloudClient) (*[]metalcloud.ServerSearchResult, error) {
@@ -99,25 +240,20 @@ func getAllActiveDevices(datacenter string, client metalcloud.MetalCloudClient)
 
 func devicesListCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
 
-	DCs, err := client.Datacenters(true)
+	concurrency, err := getReportConcurrency(c)
 	if err != nil {
 		return "", err
 	}
 
-	stats := map[string]*devicesList{}
-
-	for _, dc := range *DCs {
-		if dc.DatacenterIsMaster {
-			continue
-		}
-		deviceList, err := getAllActiveDevices(dc.DatacenterName, client)
-		if err != nil {
-			return "", err
-		}
-
-		stats[dc.DatacenterName] = deviceList
+	datacenters, err := getReportDatacenters(c, client)
+	if err != nil {
+		return "", err
 	}
 
+	results := collectPerDatacenter(datacenters, concurrency, func(datacenter string) (interface{}, error) {
+		return getAllActiveDevices(datacenter, client)
+	})
+
 	schema := []tableformatter.SchemaField{
 		{
 			FieldName: "DC_IDX",
@@ -144,6 +280,11 @@ func devicesListCmd(c *command.Command, client metalcloud.MetalCloudClient) (str
 			FieldType: tableformatter.TypeString,
 			FieldSize: 6,
 		},
+		{
+			FieldName: "ERROR",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 20,
+		},
 	}
 
 	data := [][]interface{}{}
@@ -151,9 +292,24 @@ func devicesListCmd(c *command.Command, client metalcloud.MetalCloudClient) (str
 	totalServers := 0
 	totalSwitches := 0
 	totalStorages := 0
+	failed := 0
+
+	for dc_idx, result := range results {
+
+		if result.Err != nil {
+			data = append(data, []interface{}{
+				fmt.Sprintf("%d", dc_idx),
+				result.Datacenter,
+				"-",
+				"-",
+				"-",
+				colors.Red(result.Err.Error()),
+			})
+			failed++
+			continue
+		}
 
-	dc_idx := 0
-	for datacenterName, dcStats := range stats {
+		dcStats := result.Data.(*devicesList)
 
 		serverCount := len(*dcStats.servers)
 		switchesCount := len(*dcStats.switches)
@@ -161,14 +317,14 @@ func devicesListCmd(c *command.Command, client metalcloud.MetalCloudClient) (str
 
 		row := []interface{}{
 			fmt.Sprintf("%d", dc_idx),
-			datacenterName,
+			result.Datacenter,
 			fmt.Sprintf("%d", serverCount),
 			fmt.Sprintf("%d", switchesCount),
 			fmt.Sprintf("%d", storagePoolsCount),
+			"",
 		}
 
 		data = append(data, row)
-		dc_idx++
 
 		totalServers += serverCount
 		totalSwitches += switchesCount
@@ -181,6 +337,7 @@ func devicesListCmd(c *command.Command, client metalcloud.MetalCloudClient) (str
 		colors.Bold(fmt.Sprintf("%d", totalServers)),
 		colors.Bold(fmt.Sprintf("%d", totalSwitches)),
 		colors.Bold(fmt.Sprintf("%d", totalStorages)),
+		"",
 	}
 
 	data = append(data, totalsRow)
@@ -193,7 +350,79 @@ func devicesListCmd(c *command.Command, client metalcloud.MetalCloudClient) (str
 	totalDevices := totalServers + totalSwitches + totalStorages
 
 	title := fmt.Sprint("Count of active or in-use equipment per datacenter")
+	if failed > 0 {
+		title = fmt.Sprintf("%s (%d of %d datacenters could not be queried, the totals are partial)", title, failed, len(results))
+	}
 
 	return table.RenderTable(fmt.Sprintf("Records (%d active devices across all datacenters)", totalDevices), title, command.GetStringParam(c.Arguments["format"]))
 
 }
+
+// datacenterResult holds the data collected for a datacenter or the error that prevented collecting it
+type datacenterResult struct {
+	Datacenter string
+	Data       interface{}
+	Err        error
+}
+
+// collectPerDatacenter calls collect for every datacenter using at most concurrency goroutines.
+// The results are returned in the order of the datacenters. An error in one datacenter does not stop the others.
+func collectPerDatacenter(datacenters []string, concurrency int, collect func(datacenter string) (interface{}, error)) []datacenterResult {
+	results := make([]datacenterResult, len(datacenters))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for i, dc := range datacenters {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func(result *datacenterResult, datacenter string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			result.Datacenter = datacenter
+			result.Data, result.Err = collect(datacenter)
+		}(&results[i], dc)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+// getReportDatacenters returns the datacenter given with -datacenter or all the datacenters that are not master, sorted by name
+func getReportDatacenters(c *command.Command, client metalcloud.MetalCloudClient) ([]string, error) {
+
+	if dc, ok := command.GetStringParamOk(c.Arguments["datacenter"]); ok {
+		return []string{dc}, nil
+	}
+
+	DCs, err := client.Datacenters(true)
+	if err != nil {
+		return nil, err
+	}
+
+	datacenters := []string{}
+	for _, dc := range *DCs {
+		if dc.DatacenterIsMaster {
+			continue
+		}
+		datacenters = append(datacenters, dc.DatacenterName)
+	}
+
+	sort.Strings(datacenters)
+
+	return datacenters, nil
+}
+
+func getReportConcurrency(c *command.Command) (int, error) {
+	concurrency := defaultReportConcurrency
+	if v, ok := command.GetIntParamOk(c.Arguments["concurrency"]); ok {
+		if v < 1 {
+			return 0, fmt.Errorf("-concurrency must be at least 1")
+		}
+		concurrency = v
+	}
+	return concurrency, nil
+}
@@ -0,0 +1,451 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/tableformatter"
+)
+
+// switchVendorsByDriverPrefix maps the switch drivers to the vendor of the switch operating system
+var switchVendorsByDriverPrefix = []struct {
+	prefix string
+	vendor string
+}{
+	{"os_10", "Dell"},
+	{"dell", "Dell"},
+	{"cumulus", "Nvidia"},
+	{"sonic", "SONiC"},
+	{"junos", "Juniper"},
+	{"juniper", "Juniper"},
+	{"nexus", "Cisco"},
+	{"cisco", "Cisco"},
+	{"arista", "Arista"},
+	{"hp", "HPE"},
+}
+
+// inventoryServerGroup totals the servers of a server type in a given status in a datacenter
+type inventoryServerGroup struct {
+	Datacenter      string      `json:"datacenter"`
+	ServerType      string      `json:"serverType"`
+	Status          string      `json:"status"`
+	Servers         int         `json:"servers"`
+	CPUCores        int         `json:"cpuCores"`
+	RAMGbytes       int         `json:"ramGbytes"`
+	Disks           int         `json:"disks"`
+	DiskSizeGbytes  int         `json:"diskSizeGbytes"`
+	NetworkGbps     float64     `json:"networkGbps"`
+	NICCapacityMbps map[int]int `json:"nicCapacityMbps"`
+}
+
+// inventorySwitchGroup counts the switches with the same driver and role in a datacenter
+type inventorySwitchGroup struct {
+	Datacenter string `json:"datacenter"`
+	Vendor     string `json:"vendor"`
+	Driver     string `json:"driver"`
+	Role       string `json:"role"`
+	Switches   int    `json:"switches"`
+}
+
+// inventoryError is a datacenter that could not be queried
+type inventoryError struct {
+	Datacenter string `json:"datacenter"`
+	Error      string `json:"error"`
+}
+
+type inventoryReport struct {
+	Servers  []inventoryServerGroup `json:"servers"`
+	Switches []inventorySwitchGroup `json:"switches"`
+	Errors   []inventoryError       `json:"errors,omitempty"`
+}
+
+func inventoryReportCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	format := command.GetStringParam(c.Arguments["format"])
+	switch format {
+	case "", "csv", "CSV", "json", "JSON", "md", "markdown":
+	default:
+		return "", fmt.Errorf("invalid format '%s'. Supported values are 'csv','json','md'", format)
+	}
+
+	concurrency, err := getReportConcurrency(c)
+	if err != nil {
+		return "", err
+	}
+
+	datacenters, err := getReportDatacenters(c, client)
+	if err != nil {
+		return "", err
+	}
+
+	results := collectPerDatacenter(datacenters, concurrency, func(datacenter string) (interface{}, error) {
+		servers, err := client.ServersSearch("datacenter_name:" + datacenter)
+		if err != nil {
+			return nil, err
+		}
+
+		switches, err := getAllActiveSwitches(datacenter, client)
+		if err != nil {
+			return nil, err
+		}
+
+		return inventoryReport{
+			Servers:  getInventoryServerGroups(datacenter, *servers),
+			Switches: getInventorySwitchGroups(datacenter, *switches),
+		}, nil
+	})
+
+	report := inventoryReport{
+		Servers:  []inventoryServerGroup{},
+		Switches: []inventorySwitchGroup{},
+		Errors:   []inventoryError{},
+	}
+
+	for _, result := range results {
+		if result.Err != nil {
+			report.Errors = append(report.Errors, inventoryError{
+				Datacenter: result.Datacenter,
+				Error:      result.Err.Error(),
+			})
+			continue
+		}
+
+		dcReport := result.Data.(inventoryReport)
+		report.Servers = append(report.Servers, dcReport.Servers...)
+		report.Switches = append(report.Switches, dcReport.Switches...)
+	}
+
+	switch format {
+	case "json", "JSON":
+		b, err := json.MarshalIndent(report, "", "\t")
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+
+	case "md", "markdown":
+		serversTable, switchesTable, errorsTable := getInventoryTables(report)
+
+		var sb strings.Builder
+		sb.WriteString("## Servers\n\n")
+		sb.WriteString(renderTableAsMarkdown(serversTable))
+		sb.WriteString("\n## Switches\n\n")
+		sb.WriteString(renderTableAsMarkdown(switchesTable))
+		if len(report.Errors) > 0 {
+			sb.WriteString("\n## Errors\n\n")
+			sb.WriteString(renderTableAsMarkdown(errorsTable))
+		}
+		return sb.String(), nil
+	}
+
+	serversTable, switchesTable, errorsTable := getInventoryTables(report)
+
+	servers, err := serversTable.RenderTable("Server groups", "Servers per datacenter, server type and status", format)
+	if err != nil {
+		return "", err
+	}
+
+	switches, err := switchesTable.RenderTable("Switch groups", "Switches per datacenter, driver and role", format)
+	if err != nil {
+		return "", err
+	}
+
+	separator := ""
+	if format != "" {
+		separator = "\n"
+	}
+
+	ret := servers + separator + switches
+
+	if len(report.Errors) > 0 {
+		errors, err := errorsTable.RenderTable("Errors", "Datacenters that could not be queried, their servers and switches are not included", format)
+		if err != nil {
+			return "", err
+		}
+		ret += separator + errors
+	}
+
+	return ret, nil
+}
+
+func getInventoryServerGroups(datacenter string, servers []metalcloud.ServerSearchResult) []inventoryServerGroup {
+
+	groups := map[string]*inventoryServerGroup{}
+
+	for _, s := range servers {
+		serverType := s.ServerTypeName
+		if serverType == "" {
+			serverType = "none"
+		}
+
+		key := serverType + "/" + s.ServerStatus
+		g, ok := groups[key]
+		if !ok {
+			g = &inventoryServerGroup{
+				Datacenter:      datacenter,
+				ServerType:      serverType,
+				Status:          s.ServerStatus,
+				NICCapacityMbps: map[int]int{},
+			}
+			groups[key] = g
+		}
+
+		g.Servers++
+		g.CPUCores += s.ServerProcessorCount * s.ServerProcessorCoreCount
+		g.RAMGbytes += s.ServerRAMGbytes
+		g.Disks += s.ServerDiskCount
+		g.DiskSizeGbytes += s.ServerDiskCount * s.ServerDiskSizeMbytes / 1024
+		g.NetworkGbps += float64(s.ServerNetworkTotalCapacityMbps) / 1000
+		if s.ServerNetworkTotalCapacityMbps > 0 {
+			g.NICCapacityMbps[s.ServerNetworkTotalCapacityMbps]++
+		}
+	}
+
+	list := []inventoryServerGroup{}
+	for _, g := range groups {
+		list = append(list, *g)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].ServerType != list[j].ServerType {
+			return list[i].ServerType < list[j].ServerType
+		}
+		return list[i].Status < list[j].Status
+	})
+
+	return list
+}
+
+func getInventorySwitchGroups(datacenter string, switches []metalcloud.SwitchDevice) []inventorySwitchGroup {
+
+	groups := map[string]*inventorySwitchGroup{}
+
+	for _, s := range switches {
+		key := s.NetworkEquipmentDriver + "/" + s.NetworkEquipmentProvisionerPosition
+		g, ok := groups[key]
+		if !ok {
+			g = &inventorySwitchGroup{
+				Datacenter: datacenter,
+				Vendor:     getSwitchVendor(s.NetworkEquipmentDriver),
+				Driver:     s.NetworkEquipmentDriver,
+				Role:       s.NetworkEquipmentProvisionerPosition,
+			}
+			groups[key] = g
+		}
+
+		g.Switches++
+	}
+
+	list := []inventorySwitchGroup{}
+	for _, g := range groups {
+		list = append(list, *g)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Driver != list[j].Driver {
+			return list[i].Driver < list[j].Driver
+		}
+		return list[i].Role < list[j].Role
+	})
+
+	return list
+}
+
+func getSwitchVendor(driver string) string {
+	for _, v := range switchVendorsByDriverPrefix {
+		if strings.HasPrefix(strings.ToLower(driver), v.prefix) {
+			return v.vendor
+		}
+	}
+	return "other"
+}
+
+// describeNICCapacity returns the number of servers for each total NIC capacity, for example "2x40G, 8x100G"
+func describeNICCapacity(capacities map[int]int) string {
+	speeds := []int{}
+	for speed := range capacities {
+		speeds = append(speeds, speed)
+	}
+	sort.Ints(speeds)
+
+	parts := []string{}
+	for _, speed := range speeds {
+		if speed%1000 == 0 {
+			parts = append(parts, fmt.Sprintf("%dx%dG", capacities[speed], speed/1000))
+		} else {
+			parts = append(parts, fmt.Sprintf("%dx%dM", capacities[speed], speed))
+		}
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func getInventoryTables(report inventoryReport) (tableformatter.Table, tableformatter.Table, tableformatter.Table) {
+
+	serverSchema := []tableformatter.SchemaField{
+		{
+			FieldName: "DATACENTER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "SERVER_TYPE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "STATUS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "SERVERS",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "CPU_CORES",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "RAM_GB",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "DISKS",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "DISK_GB",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "NETWORK_GBPS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "NIC_CAPACITY",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 20,
+		},
+	}
+
+	serverData := [][]interface{}{}
+	for _, g := range report.Servers {
+		serverData = append(serverData, []interface{}{
+			g.Datacenter,
+			g.ServerType,
+			g.Status,
+			g.Servers,
+			g.CPUCores,
+			g.RAMGbytes,
+			g.Disks,
+			g.DiskSizeGbytes,
+			strconv.FormatFloat(g.NetworkGbps, 'f', -1, 64),
+			describeNICCapacity(g.NICCapacityMbps),
+		})
+	}
+
+	switchSchema := []tableformatter.SchemaField{
+		{
+			FieldName: "DATACENTER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "VENDOR",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "DRIVER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "ROLE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "SWITCHES",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+	}
+
+	switchData := [][]interface{}{}
+	for _, g := range report.Switches {
+		switchData = append(switchData, []interface{}{
+			g.Datacenter,
+			g.Vendor,
+			g.Driver,
+			g.Role,
+			g.Switches,
+		})
+	}
+
+	errorSchema := []tableformatter.SchemaField{
+		{
+			FieldName: "DATACENTER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "ERROR",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 40,
+		},
+	}
+
+	errorData := [][]interface{}{}
+	for _, e := range report.Errors {
+		errorData = append(errorData, []interface{}{
+			e.Datacenter,
+			e.Error,
+		})
+	}
+
+	return tableformatter.Table{Data: serverData, Schema: serverSchema},
+		tableformatter.Table{Data: switchData, Schema: switchSchema},
+		tableformatter.Table{Data: errorData, Schema: errorSchema}
+}
+
+// renderTableAsMarkdown renders a table as a GitHub flavored markdown table
+func renderTableAsMarkdown(table tableformatter.Table) string {
+	var sb strings.Builder
+
+	header := []string{}
+	separator := []string{}
+	for _, field := range table.Schema {
+		header = append(header, field.FieldName)
+		if field.FieldType == tableformatter.TypeInt || field.FieldType == tableformatter.TypeFloat {
+			separator = append(separator, "---:")
+		} else {
+			separator = append(separator, "---")
+		}
+	}
+
+	sb.WriteString("| " + strings.Join(header, " | ") + " |\n")
+	sb.WriteString("| " + strings.Join(separator, " | ") + " |\n")
+
+	for _, row := range table.Data {
+		cells := []string{}
+		for _, v := range row {
+			cells = append(cells, strings.ReplaceAll(fmt.Sprint(v), "|", "\\|"))
+		}
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	return sb.String()
+}
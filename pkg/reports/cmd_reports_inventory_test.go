@@ -0,0 +1,88 @@
+package reports
+
+import (
+	"encoding/json"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	. "github.com/onsi/gomega"
+
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+)
+
+func TestInventoryReportCmd(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		Datacenters(true).
+		Return(&map[string]metalcloud.Datacenter{
+			"dc1":    {DatacenterName: "dc1"},
+			"master": {DatacenterName: "master", DatacenterIsMaster: true},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServersSearch("datacenter_name:dc1").
+		Return(&[]metalcloud.ServerSearchResult{
+			{ServerID: 1, ServerTypeName: "M.8", ServerStatus: "available", ServerProcessorCount: 2, ServerProcessorCoreCount: 8, ServerRAMGbytes: 64, ServerDiskCount: 2, ServerDiskSizeMbytes: 512000, ServerNetworkTotalCapacityMbps: 40000},
+			{ServerID: 2, ServerTypeName: "M.8", ServerStatus: "available", ServerProcessorCount: 2, ServerProcessorCoreCount: 8, ServerRAMGbytes: 64, ServerDiskCount: 2, ServerDiskSizeMbytes: 512000, ServerNetworkTotalCapacityMbps: 100000},
+			{ServerID: 3, ServerTypeName: "M.8", ServerStatus: "used", ServerProcessorCount: 1, ServerProcessorCoreCount: 8, ServerRAMGbytes: 32, ServerNetworkTotalCapacityMbps: 2500},
+			{ServerID: 4, ServerStatus: "registering"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SwitchDevices("dc1", "").
+		Return(&map[string]metalcloud.SwitchDevice{
+			"leaf-1":  {NetworkEquipmentDriver: "os_10", NetworkEquipmentProvisionerPosition: "leaf"},
+			"leaf-2":  {NetworkEquipmentDriver: "os_10", NetworkEquipmentProvisionerPosition: "leaf"},
+			"spine-1": {NetworkEquipmentDriver: "cumulus42", NetworkEquipmentProvisionerPosition: "spine"},
+		}, nil).
+		AnyTimes()
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"format": "csv",
+	})
+
+	ret, err := inventoryReportCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("dc1,M.8,available,2,32,128,4,2000,140,\"1x40G, 1x100G\""))
+	Expect(ret).To(ContainSubstring("dc1,M.8,used,1,8,32,0,0,2.5,1x2500M"))
+	Expect(ret).To(ContainSubstring("dc1,none,registering,1,"))
+	Expect(ret).To(ContainSubstring("dc1,Nvidia,cumulus42,spine,1"))
+	Expect(ret).To(ContainSubstring("dc1,Dell,os_10,leaf,2"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+		"format":     "json",
+	})
+
+	ret, err = inventoryReportCmd(&cmd, client)
+	Expect(err).To(BeNil())
+
+	var report inventoryReport
+	Expect(json.Unmarshal([]byte(ret), &report)).To(Succeed())
+	Expect(report.Servers).To(HaveLen(3))
+	Expect(report.Switches).To(HaveLen(2))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"format": "md",
+	})
+
+	ret, err = inventoryReportCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("| DATACENTER | SERVER_TYPE | STATUS | SERVERS |"))
+	Expect(ret).To(ContainSubstring("| dc1 | M.8 | used | 1 | 8 | 32 | 0 | 0 | 2.5 | 1x2500M |"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"format": "yaml",
+	})
+
+	_, err = inventoryReportCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+}
@@ -0,0 +1,513 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	"github.com/metalsoft-io/tableformatter"
+)
+
+const snapshotTimestampFormat = "20060102T150405Z"
+
+// snapshotJobsErrorKey is the key of the snapshot errors under which a failure to count the jobs is recorded
+const snapshotJobsErrorKey = "jobs"
+
+// snapshotJobStatuses are the job statuses that are counted as failures
+var snapshotJobStatuses = []string{"thrown_error", "thrown_error_while_retrying"}
+
+// reportSnapshot is the utilization of the environment at a point in time.
+// JobsTruncated marks the job statuses whose count reached the jobs limit and is only a lower bound.
+type reportSnapshot struct {
+	Timestamp     time.Time                     `json:"timestamp"`
+	Datacenters   map[string]snapshotDatacenter `json:"datacenters"`
+	Jobs          map[string]int                `json:"jobs"`
+	JobsTruncated map[string]bool               `json:"jobsTruncated,omitempty"`
+	Errors        map[string]string             `json:"errors,omitempty"`
+}
+
+type snapshotDatacenter struct {
+	Servers        int                  `json:"servers"`
+	ServerStatuses map[string]int       `json:"serverStatuses"`
+	Switches       int                  `json:"switches"`
+	StoragePools   int                  `json:"storagePools"`
+	SubnetPools    []snapshotSubnetPool `json:"subnetPools"`
+}
+
+type snapshotSubnetPool struct {
+	ID          int     `json:"id"`
+	Prefix      string  `json:"prefix"`
+	UsedPercent float64 `json:"usedPercent"`
+}
+
+func reportSnapshotCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	dir, err := getReportSnapshotsDir(c)
+	if err != nil {
+		return "", err
+	}
+
+	concurrency, err := getReportConcurrency(c)
+	if err != nil {
+		return "", err
+	}
+
+	datacenters, err := getReportDatacenters(c, client)
+	if err != nil {
+		return "", err
+	}
+
+	snapshot := reportSnapshot{
+		Timestamp:     time.Now().UTC().Truncate(time.Second),
+		Datacenters:   map[string]snapshotDatacenter{},
+		Jobs:          map[string]int{},
+		JobsTruncated: map[string]bool{},
+		Errors:        map[string]string{},
+	}
+
+	results := collectPerDatacenter(datacenters, concurrency, func(datacenter string) (interface{}, error) {
+		return getSnapshotDatacenter(datacenter, client)
+	})
+
+	for _, result := range results {
+		if result.Err != nil {
+			snapshot.Errors[result.Datacenter] = result.Err.Error()
+			continue
+		}
+		snapshot.Datacenters[result.Datacenter] = result.Data.(snapshotDatacenter)
+	}
+
+	limit := command.GetIntParam(c.Arguments["jobs_limit"])
+	for _, status := range snapshotJobStatuses {
+		jobs, err := client.AFCSearch("+afc_status:"+status, 0, limit)
+		if err != nil {
+			snapshot.Errors[snapshotJobsErrorKey] = err.Error()
+			break
+		}
+		snapshot.Jobs[status] = len(*jobs)
+
+		// the search does not return the total number of jobs so a full page means there might be more
+		if limit > 0 && len(*jobs) >= limit {
+			snapshot.JobsTruncated[status] = true
+		}
+	}
+
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := json.MarshalIndent(snapshot, "", "\t")
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("snapshot-%s.json", snapshot.Timestamp.Format(snapshotTimestampFormat)))
+
+	//a snapshot taken in the same second is never overwritten
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return "", fmt.Errorf("snapshot %s already exists, wait a second before taking another snapshot", path)
+		}
+		return "", err
+	}
+
+	_, err = file.Write(b)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	ret := fmt.Sprintf("Snapshot of %d datacenters saved to %s\n", len(snapshot.Datacenters), path)
+
+	errorKeys := []string{}
+	for k := range snapshot.Errors {
+		errorKeys = append(errorKeys, k)
+	}
+	sort.Strings(errorKeys)
+
+	for _, k := range errorKeys {
+		ret += fmt.Sprintf("Warning: %s could not be queried and is missing from the snapshot: %s\n", k, snapshot.Errors[k])
+	}
+
+	for _, status := range snapshotJobStatuses {
+		if snapshot.JobsTruncated[status] {
+			ret += fmt.Sprintf("Warning: the number of %s jobs reached -jobs-limit and is truncated to %d\n", status, snapshot.Jobs[status])
+		}
+	}
+
+	return ret, nil
+}
+
+func getSnapshotDatacenter(datacenter string, client metalcloud.MetalCloudClient) (snapshotDatacenter, error) {
+
+	dc := snapshotDatacenter{
+		ServerStatuses: map[string]int{},
+		SubnetPools:    []snapshotSubnetPool{},
+	}
+
+	servers, err := client.ServersSearch("datacenter_name:" + datacenter)
+	if err != nil {
+		return dc, err
+	}
+
+	for _, s := range *servers {
+		dc.Servers++
+		dc.ServerStatuses[s.ServerStatus]++
+	}
+
+	switches, err := getAllActiveSwitches(datacenter, client)
+	if err != nil {
+		return dc, err
+	}
+	dc.Switches = len(*switches)
+
+	storages, err := getAllActiveStoragePools(datacenter, client)
+	if err != nil {
+		return dc, err
+	}
+	dc.StoragePools = len(*storages)
+
+	pools, err := client.SubnetPoolSearch("datacenter_name:" + datacenter)
+	if err != nil {
+		return dc, err
+	}
+
+	for _, p := range *pools {
+		utilization, err := client.SubnetPoolPrefixSizesStats(p.SubnetPoolID)
+		if err != nil {
+			return dc, err
+		}
+
+		freePercent, err := strconv.ParseFloat(strings.Trim(utilization.IPAddressesUsableFreePercentOptimistic, "%"), 64)
+		if err != nil {
+			return dc, err
+		}
+
+		dc.SubnetPools = append(dc.SubnetPools, snapshotSubnetPool{
+			ID:          p.SubnetPoolID,
+			Prefix:      fmt.Sprintf("%s/%d", p.SubnetPoolPrefixHumanReadable, p.SubnetPoolPrefixSize),
+			UsedPercent: 100 - freePercent,
+		})
+	}
+
+	return dc, nil
+}
+
+// metrics flattens a snapshot into named values that can be compared between snapshots.
+// The datacenters in excluded are left out, as are the jobs if snapshotJobsErrorKey is excluded.
+func (s reportSnapshot) metrics(excluded map[string]bool) map[string]float64 {
+	m := map[string]float64{}
+
+	for name, dc := range s.Datacenters {
+		if excluded[name] {
+			continue
+		}
+
+		m["servers"] += float64(dc.Servers)
+		m["switches"] += float64(dc.Switches)
+		m["storage pools"] += float64(dc.StoragePools)
+
+		m[fmt.Sprintf("%s servers", name)] = float64(dc.Servers)
+		m[fmt.Sprintf("%s switches", name)] = float64(dc.Switches)
+		m[fmt.Sprintf("%s storage pools", name)] = float64(dc.StoragePools)
+
+		for status, count := range dc.ServerStatuses {
+			m[fmt.Sprintf("servers %s", status)] += float64(count)
+			m[fmt.Sprintf("%s servers %s", name, status)] = float64(count)
+		}
+
+		for _, p := range dc.SubnetPools {
+			m[fmt.Sprintf("%s subnet pool #%d %s used %%", name, p.ID, p.Prefix)] = p.UsedPercent
+		}
+	}
+
+	if !excluded[snapshotJobsErrorKey] {
+		for status, count := range s.Jobs {
+			m[snapshotJobsMetric(status)] = float64(count)
+		}
+	}
+
+	return m
+}
+
+// truncatedMetrics returns the metrics whose values are only lower bounds
+func (s reportSnapshot) truncatedMetrics() map[string]bool {
+	m := map[string]bool{}
+
+	for status, truncated := range s.JobsTruncated {
+		if truncated {
+			m[snapshotJobsMetric(status)] = true
+		}
+	}
+
+	return m
+}
+
+func snapshotJobsMetric(status string) string {
+	return fmt.Sprintf("jobs %s", status)
+}
+
+func reportTrendCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	dir, err := getReportSnapshotsDir(c)
+	if err != nil {
+		return "", err
+	}
+
+	since, err := parseSince(command.GetStringParam(c.Arguments["since"]))
+	if err != nil {
+		return "", err
+	}
+
+	snapshots, err := readReportSnapshots(dir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(snapshots) < 2 {
+		return "", fmt.Errorf("at least two snapshots are needed in %s, use report snapshot to create them", dir)
+	}
+
+	current := snapshots[len(snapshots)-1]
+	baseline := getTrendBaseline(snapshots[:len(snapshots)-1], current.Timestamp.Add(-since))
+
+	return renderReportTrend(baseline, current, command.GetStringParam(c.Arguments["format"]))
+}
+
+// getTrendBaseline returns the newest snapshot taken before the given time or the oldest snapshot if all of them are newer
+func getTrendBaseline(snapshots []reportSnapshot, before time.Time) reportSnapshot {
+	baseline := snapshots[0]
+	for _, s := range snapshots {
+		if s.Timestamp.After(before) {
+			break
+		}
+		baseline = s
+	}
+	return baseline
+}
+
+func renderReportTrend(baseline reportSnapshot, current reportSnapshot, format string) (string, error) {
+
+	// what could not be queried in either snapshot is left out, otherwise it would count as 0 and show up as a drop or growth
+	excluded := map[string]bool{}
+	for k := range baseline.Errors {
+		excluded[k] = true
+	}
+	for k := range current.Errors {
+		excluded[k] = true
+	}
+
+	before := baseline.metrics(excluded)
+	after := current.metrics(excluded)
+	beforeTruncated := baseline.truncatedMetrics()
+	afterTruncated := current.truncatedMetrics()
+
+	names := []string{}
+	for k := range before {
+		names = append(names, k)
+	}
+	for k := range after {
+		if _, ok := before[k]; !ok {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	days := current.Timestamp.Sub(baseline.Timestamp).Hours() / 24
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "METRIC",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 30,
+		},
+		{
+			FieldName: "BEFORE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 8,
+		},
+		{
+			FieldName: "NOW",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 8,
+		},
+		{
+			FieldName: "DELTA",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 8,
+		},
+		{
+			FieldName: "GROWTH",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 8,
+		},
+		{
+			FieldName: "PER_DAY",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 8,
+		},
+	}
+
+	truncated := false
+
+	data := [][]interface{}{}
+	for _, name := range names {
+		b := before[name]
+		a := after[name]
+		delta := a - b
+
+		formattedBefore := formatTrendValue(b, false)
+		formattedAfter := formatTrendValue(a, false)
+		formattedDelta := formatTrendValue(delta, true)
+
+		growth := "-"
+		if b != 0 {
+			growth = fmt.Sprintf("%+.1f%%", delta/b*100)
+		}
+
+		perDay := "-"
+		if days > 0 {
+			perDay = formatTrendValue(delta/days, true)
+		}
+
+		// a truncated value is only a lower bound so the change cannot be computed
+		if beforeTruncated[name] || afterTruncated[name] {
+			truncated = true
+
+			if beforeTruncated[name] {
+				formattedBefore += "+"
+			}
+			if afterTruncated[name] {
+				formattedAfter += "+"
+			}
+			formattedDelta = "?"
+			growth = "?"
+			perDay = "?"
+		}
+
+		data = append(data, []interface{}{
+			name,
+			formattedBefore,
+			formattedAfter,
+			formattedDelta,
+			growth,
+			perDay,
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	title := fmt.Sprintf("Changes between %s and %s (%.1f days)",
+		baseline.Timestamp.Format(time.RFC3339),
+		current.Timestamp.Format(time.RFC3339),
+		days,
+	)
+	if truncated {
+		title += ". Values marked with + reached -jobs-limit and are truncated"
+	}
+	if len(excluded) > 0 {
+		keys := []string{}
+		for k := range excluded {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		title += fmt.Sprintf(". Left out as they could not be queried in both snapshots: %s", strings.Join(keys, ", "))
+	}
+
+	return table.RenderTable("Metrics", title, format)
+}
+
+func formatTrendValue(v float64, signed bool) string {
+	if v == math.Trunc(v) {
+		if signed {
+			return fmt.Sprintf("%+d", int(v))
+		}
+		return fmt.Sprintf("%d", int(v))
+	}
+	if signed {
+		return fmt.Sprintf("%+.2f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+// readReportSnapshots reads all the snapshots in a directory sorted by their timestamp
+func readReportSnapshots(dir string) ([]reportSnapshot, error) {
+
+	files, err := filepath.Glob(filepath.Join(dir, "snapshot-*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []reportSnapshot{}
+	for _, f := range files {
+		content, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		var s reportSnapshot
+		err = json.Unmarshal(content, &s)
+		if err != nil {
+			return nil, fmt.Errorf("Error while reading %s: %v", f, err)
+		}
+
+		snapshots = append(snapshots, s)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Timestamp.Before(snapshots[j].Timestamp)
+	})
+
+	return snapshots, nil
+}
+
+// parseSince parses durations such as 30d, 2w or 12h
+func parseSince(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("-since is required")
+	}
+
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+
+	if unit, ok := units[s[len(s)-1:]]; ok {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %s", s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %s. Use a number followed by h, d or w such as 30d", s)
+	}
+
+	return d, nil
+}
+
+func getReportSnapshotsDir(c *command.Command) (string, error) {
+	if dir, ok := command.GetStringParamOk(c.Arguments["dir"]); ok {
+		return dir, nil
+	}
+	return configuration.GetReportSnapshotsPath()
+}
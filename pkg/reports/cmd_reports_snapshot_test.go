@@ -0,0 +1,262 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	. "github.com/onsi/gomega"
+
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+)
+
+func TestReportSnapshotCmd(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		Datacenters(true).
+		Return(&map[string]metalcloud.Datacenter{
+			"dc1": {DatacenterName: "dc1"},
+			"dc2": {DatacenterName: "dc2"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServersSearch("datacenter_name:dc1").
+		Return(&[]metalcloud.ServerSearchResult{
+			{ServerID: 1, ServerStatus: "used"},
+			{ServerID: 2, ServerStatus: "used"},
+			{ServerID: 3, ServerStatus: "available"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServersSearch("datacenter_name:dc2").
+		Return(nil, fmt.Errorf("timeout")).
+		AnyTimes()
+
+	client.EXPECT().
+		SwitchDevices("dc1", "").
+		Return(&map[string]metalcloud.SwitchDevice{"leaf-1": {}}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		StoragePoolSearch("datacenter_name:dc1").
+		Return(&[]metalcloud.StoragePoolSearchResult{}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SubnetPoolSearch("datacenter_name:dc1").
+		Return(&[]metalcloud.SubnetPool{{SubnetPoolID: 5, SubnetPoolPrefixHumanReadable: "10.0.0.0", SubnetPoolPrefixSize: 16}}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SubnetPoolPrefixSizesStats(5).
+		Return(&metalcloud.SubnetPoolUtilization{IPAddressesUsableFreePercentOptimistic: "75%"}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		AFCSearch("+afc_status:thrown_error", 0, 100).
+		Return(&[]metalcloud.AFCSearchResult{{AFCID: 1}, {AFCID: 2}}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		AFCSearch("+afc_status:thrown_error_while_retrying", 0, 100).
+		Return(&[]metalcloud.AFCSearchResult{}, nil).
+		AnyTimes()
+
+	dir := t.TempDir()
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"dir":        dir,
+		"jobs_limit": 100,
+	})
+
+	ret, err := reportSnapshotCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("Snapshot of 1 datacenters saved to " + dir))
+	Expect(ret).To(ContainSubstring("Warning: dc2 could not be queried and is missing from the snapshot: timeout"))
+
+	snapshots, err := readReportSnapshots(dir)
+	Expect(err).To(BeNil())
+	Expect(snapshots).To(HaveLen(1))
+	Expect(snapshots[0].Datacenters["dc1"].ServerStatuses).To(Equal(map[string]int{"used": 2, "available": 1}))
+	Expect(snapshots[0].Datacenters["dc1"].SubnetPools[0].UsedPercent).To(Equal(25.0))
+	Expect(snapshots[0].Jobs["thrown_error"]).To(Equal(2))
+	Expect(snapshots[0].JobsTruncated).To(BeEmpty())
+
+	// a full page of jobs means that the count is truncated
+	client.EXPECT().
+		AFCSearch("+afc_status:thrown_error", 0, 2).
+		Return(&[]metalcloud.AFCSearchResult{{AFCID: 1}, {AFCID: 2}}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		AFCSearch("+afc_status:thrown_error_while_retrying", 0, 2).
+		Return(&[]metalcloud.AFCSearchResult{{AFCID: 3}}, nil).
+		AnyTimes()
+
+	dir = t.TempDir()
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"dir":        dir,
+		"jobs_limit": 2,
+	})
+
+	ret, err = reportSnapshotCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("Warning: the number of thrown_error jobs reached -jobs-limit and is truncated to 2\n"))
+	Expect(ret).NotTo(ContainSubstring("thrown_error_while_retrying jobs"))
+
+	snapshots, err = readReportSnapshots(dir)
+	Expect(err).To(BeNil())
+	Expect(snapshots[0].JobsTruncated).To(Equal(map[string]bool{"thrown_error": true}))
+
+	// an existing snapshot is not overwritten
+	dir = t.TempDir()
+	now := time.Now().UTC()
+	for _, ts := range []time.Time{now, now.Add(time.Second)} {
+		path := filepath.Join(dir, fmt.Sprintf("snapshot-%s.json", ts.Format(snapshotTimestampFormat)))
+		Expect(os.WriteFile(path, []byte("{}"), 0600)).To(Succeed())
+	}
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"dir":        dir,
+		"jobs_limit": 100,
+	})
+
+	_, err = reportSnapshotCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("already exists"))
+}
+
+func TestReportTrendCmd(t *testing.T) {
+	RegisterTestingT(t)
+
+	dir := t.TempDir()
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	writeSnapshot := func(s reportSnapshot) {
+		b, err := json.Marshal(s)
+		Expect(err).To(BeNil())
+		path := filepath.Join(dir, fmt.Sprintf("snapshot-%s.json", s.Timestamp.Format(snapshotTimestampFormat)))
+		Expect(os.WriteFile(path, b, 0600)).To(Succeed())
+	}
+
+	snapshot := func(daysAgo int, used int, available int) reportSnapshot {
+		return reportSnapshot{
+			Timestamp: now.Add(-time.Duration(daysAgo) * 24 * time.Hour),
+			Datacenters: map[string]snapshotDatacenter{
+				"dc1": {
+					Servers:        used + available,
+					ServerStatuses: map[string]int{"used": used, "available": available},
+				},
+			},
+			Jobs: map[string]int{"thrown_error": 1},
+		}
+	}
+
+	writeSnapshot(snapshot(60, 10, 30))
+	writeSnapshot(snapshot(31, 20, 20))
+	writeSnapshot(snapshot(10, 25, 15))
+	writeSnapshot(snapshot(0, 30, 10))
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"dir":    dir,
+		"since":  "30d",
+		"format": "csv",
+	})
+
+	// the latest snapshot is compared with the one taken 31 days ago
+	ret, err := reportTrendCmd(&cmd, nil)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("servers used,20,30,+10,+50.0%,+0.32"))
+	Expect(ret).To(ContainSubstring("dc1 servers available,20,10,-10,-50.0%,-0.32"))
+	Expect(ret).To(ContainSubstring("servers,40,40,+0,+0.0%,+0"))
+	Expect(ret).To(ContainSubstring("jobs thrown_error,1,1,+0,+0.0%,+0"))
+
+	// a truncated job count is flagged and its change is not computed
+	truncated := snapshot(0, 30, 10)
+	truncated.Timestamp = now.Add(time.Hour)
+	truncated.Jobs["thrown_error"] = 10000
+	truncated.JobsTruncated = map[string]bool{"thrown_error": true}
+	writeSnapshot(truncated)
+
+	ret, err = reportTrendCmd(&cmd, nil)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("jobs thrown_error,1,10000+,?,?,?"))
+	Expect(ret).To(ContainSubstring("servers used,20,30,+10,+50.0%"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"dir":   dir,
+		"since": "30d",
+	})
+
+	ret, err = reportTrendCmd(&cmd, nil)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("Values marked with + reached -jobs-limit and are truncated"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"dir":    dir,
+		"since":  "1y",
+		"format": "csv",
+	})
+
+	_, err = reportTrendCmd(&cmd, nil)
+	Expect(err).NotTo(BeNil())
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"dir":   t.TempDir(),
+		"since": "30d",
+	})
+
+	_, err = reportTrendCmd(&cmd, nil)
+	Expect(err).NotTo(BeNil())
+}
+
+func TestReportTrendCmdLeavesOutFailedDatacenters(t *testing.T) {
+	RegisterTestingT(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	snapshot := func(daysAgo int, errors map[string]string) reportSnapshot {
+		s := reportSnapshot{
+			Timestamp: now.Add(-time.Duration(daysAgo) * 24 * time.Hour),
+			Datacenters: map[string]snapshotDatacenter{
+				"dc1": {Servers: 10, ServerStatuses: map[string]int{"used": 10}},
+				"dc2": {Servers: 20, ServerStatuses: map[string]int{"used": 20}},
+			},
+			Jobs:   map[string]int{"thrown_error": 3},
+			Errors: errors,
+		}
+		for k := range errors {
+			delete(s.Datacenters, k)
+			if k == snapshotJobsErrorKey {
+				s.Jobs = map[string]int{}
+			}
+		}
+		return s
+	}
+
+	// dc2 and the jobs could not be queried in the latest snapshot
+	ret, err := renderReportTrend(snapshot(7, nil), snapshot(0, map[string]string{"dc2": "timeout", "jobs": "timeout"}), "csv")
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("\nservers,10,10,+0,+0.0%,+0\n"))
+	Expect(ret).To(ContainSubstring("\ndc1 servers,10,10,+0,+0.0%,+0\n"))
+	Expect(ret).NotTo(ContainSubstring("dc2"))
+	Expect(ret).NotTo(ContainSubstring("jobs thrown_error"))
+
+	ret, err = renderReportTrend(snapshot(7, nil), snapshot(0, map[string]string{"dc2": "timeout"}), "")
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("Left out as they could not be queried in both snapshots: dc2"))
+}
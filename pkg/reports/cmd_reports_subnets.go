@@ -0,0 +1,645 @@
+package reports
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/tableformatter"
+)
+
+const (
+	defaultSubnetsWarn = 80
+	defaultSubnetsCrit = 95
+)
+
+// the statuses and exit codes used by monitoring systems such as Nagios
+const (
+	subnetStatusOK       = "OK"
+	subnetStatusWarning  = "WARNING"
+	subnetStatusCritical = "CRITICAL"
+	subnetStatusUnknown  = "UNKNOWN"
+)
+
+var subnetStatusExitCodes = map[string]int{
+	subnetStatusOK:       0,
+	subnetStatusWarning:  1,
+	subnetStatusCritical: 2,
+	subnetStatusUnknown:  3,
+}
+
+type subnetPoolUsage struct {
+	Datacenter   string         `json:"datacenter"`
+	ID           int            `json:"id"`
+	Label        string         `json:"label"`
+	Prefix       string         `json:"prefix"`
+	Type         string         `json:"type"`
+	Destination  string         `json:"destination"`
+	FreeIPs      float64        `json:"freeIPs"`
+	AllocatedIPs float64        `json:"allocatedIPs"`
+	UsedPercent  float64        `json:"usedPercent"`
+	FreePrefixes map[string]int `json:"freePrefixes"`
+	Status       string         `json:"status"`
+}
+
+// subnetDestinationUsage is the utilization of all the pools of a datacenter with the same destination and type
+type subnetDestinationUsage struct {
+	Datacenter   string  `json:"datacenter"`
+	Destination  string  `json:"destination"`
+	Type         string  `json:"type"`
+	Pools        int     `json:"pools"`
+	FreeIPs      float64 `json:"freeIPs"`
+	AllocatedIPs float64 `json:"allocatedIPs"`
+	UsedPercent  float64 `json:"usedPercent"`
+	Status       string  `json:"status"`
+}
+
+type subnetsReport struct {
+	Status       string                   `json:"status"`
+	Warn         int                      `json:"warn"`
+	Crit         int                      `json:"crit"`
+	Pools        []subnetPoolUsage        `json:"pools"`
+	Destinations []subnetDestinationUsage `json:"destinations"`
+	Errors       []inventoryError         `json:"errors"`
+}
+
+func subnetsReportCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	format := command.GetStringParam(c.Arguments["format"])
+	switch format {
+	case "", "csv", "CSV", "yaml", "YAML", "json", "JSON", "nagios":
+	default:
+		return "", fmt.Errorf("invalid format '%s'. Supported values are 'json','csv','yaml','nagios'", format)
+	}
+
+	warn := defaultSubnetsWarn
+	if v, ok := command.GetIntParamOk(c.Arguments["warn"]); ok {
+		warn = v
+	}
+
+	crit := defaultSubnetsCrit
+	if v, ok := command.GetIntParamOk(c.Arguments["crit"]); ok {
+		crit = v
+	}
+
+	if warn < 0 || crit > 100 || warn > crit {
+		return renderSubnetsReportFatal(format, warn, crit, fmt.Errorf("-warn and -crit must be between 0 and 100 and -warn must not be greater than -crit"))
+	}
+
+	concurrency, err := getReportConcurrency(c)
+	if err != nil {
+		return renderSubnetsReportFatal(format, warn, crit, err)
+	}
+
+	datacenters, err := getReportDatacenters(c, client)
+	if err != nil {
+		return renderSubnetsReportFatal(format, warn, crit, err)
+	}
+
+	results := collectPerDatacenter(datacenters, concurrency, func(datacenter string) (interface{}, error) {
+		return getSubnetPoolsUsage(datacenter, client)
+	})
+
+	report := subnetsReport{
+		Warn:         warn,
+		Crit:         crit,
+		Pools:        []subnetPoolUsage{},
+		Destinations: []subnetDestinationUsage{},
+		Errors:       []inventoryError{},
+	}
+
+	for _, result := range results {
+		if result.Err != nil {
+			report.Errors = append(report.Errors, inventoryError{
+				Datacenter: result.Datacenter,
+				Error:      result.Err.Error(),
+			})
+			continue
+		}
+
+		report.Pools = append(report.Pools, result.Data.([]subnetPoolUsage)...)
+	}
+
+	for i := range report.Pools {
+		report.Pools[i].Status = getSubnetStatus(report.Pools[i].UsedPercent, warn, crit)
+	}
+
+	report.Destinations = getSubnetDestinationsUsage(report.Pools)
+	for i := range report.Destinations {
+		report.Destinations[i].Status = getSubnetStatus(report.Destinations[i].UsedPercent, warn, crit)
+	}
+
+	report.Status = getSubnetsReportStatus(report)
+
+	switch format {
+	case "json", "JSON":
+		b, err := json.MarshalIndent(report, "", "\t")
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", getSubnetsExitCodeError(report)
+
+	case "nagios":
+		return renderSubnetsReportNagios(report), getSubnetsExitCodeError(report)
+	}
+
+	return renderSubnetsReportTables(report, format)
+}
+
+// getSubnetPoolsUsage returns the utilization of the subnet pools of a datacenter.
+// The statistics of the pools are retrieved in parallel.
+func getSubnetPoolsUsage(datacenter string, client metalcloud.MetalCloudClient) ([]subnetPoolUsage, error) {
+
+	list, err := client.SubnetPoolSearch("datacenter_name:" + datacenter)
+	if err != nil {
+		return nil, err
+	}
+
+	pools := *list
+	usage := make([]subnetPoolUsage, len(pools))
+
+	err = command.Prefetch(len(pools), command.DefaultResolverConcurrency, func(i int) error {
+		p := pools[i]
+
+		utilization, err := client.SubnetPoolPrefixSizesStats(p.SubnetPoolID)
+		if err != nil {
+			return err
+		}
+
+		free, allocated, usedPercent, err := getSubnetPoolUtilization(utilization)
+		if err != nil {
+			return fmt.Errorf("invalid statistics for subnet pool #%d: %v", p.SubnetPoolID, err)
+		}
+
+		freePrefixes := utilization.PrefixCountFree
+		if freePrefixes == nil {
+			freePrefixes = map[string]int{}
+		}
+
+		usage[i] = subnetPoolUsage{
+			Datacenter:   datacenter,
+			ID:           p.SubnetPoolID,
+			Label:        p.SubnetPoolLabel,
+			Prefix:       fmt.Sprintf("%s/%d", p.SubnetPoolPrefixHumanReadable, p.SubnetPoolPrefixSize),
+			Type:         p.SubnetPoolType,
+			Destination:  strings.ToUpper(p.SubnetPoolDestination),
+			FreeIPs:      free,
+			AllocatedIPs: allocated,
+			UsedPercent:  usedPercent,
+			FreePrefixes: freePrefixes,
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// getSubnetPoolUtilization returns the number of free and allocated usable addresses and the percentage of used addresses.
+// The percentage is computed from the counts when they are available and from the optimistic free percentage otherwise.
+func getSubnetPoolUtilization(utilization *metalcloud.SubnetPoolUtilization) (float64, float64, float64, error) {
+
+	free, errFree := strconv.ParseFloat(utilization.IPAddressesUsableCountFree, 64)
+	allocated, errAllocated := strconv.ParseFloat(utilization.IPAddressesUsableCountAllocated, 64)
+
+	if errFree == nil && errAllocated == nil && free+allocated > 0 {
+		return free, allocated, allocated / (free + allocated) * 100, nil
+	}
+
+	freePercent, err := strconv.ParseFloat(strings.Trim(utilization.IPAddressesUsableFreePercentOptimistic, "%"), 64)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	if errFree != nil {
+		free = 0
+	}
+	if errAllocated != nil {
+		allocated = 0
+	}
+
+	return free, allocated, 100 - freePercent, nil
+}
+
+// getSubnetDestinationsUsage sums the utilization of the pools per datacenter, destination and type.
+// Groups whose pools do not report address counts use the average of the pool percentages.
+func getSubnetDestinationsUsage(pools []subnetPoolUsage) []subnetDestinationUsage {
+
+	groups := map[string]*subnetDestinationUsage{}
+	percentSums := map[string]float64{}
+	keys := []string{}
+
+	for _, p := range pools {
+		key := strings.Join([]string{p.Datacenter, p.Destination, p.Type}, "/")
+
+		g, ok := groups[key]
+		if !ok {
+			g = &subnetDestinationUsage{
+				Datacenter:  p.Datacenter,
+				Destination: p.Destination,
+				Type:        p.Type,
+			}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+
+		g.Pools++
+		g.FreeIPs += p.FreeIPs
+		g.AllocatedIPs += p.AllocatedIPs
+		percentSums[key] += p.UsedPercent
+	}
+
+	sort.Strings(keys)
+
+	destinations := []subnetDestinationUsage{}
+	for _, k := range keys {
+		g := groups[k]
+		if g.FreeIPs+g.AllocatedIPs > 0 {
+			g.UsedPercent = g.AllocatedIPs / (g.FreeIPs + g.AllocatedIPs) * 100
+		} else {
+			g.UsedPercent = percentSums[k] / float64(g.Pools)
+		}
+		destinations = append(destinations, *g)
+	}
+
+	return destinations
+}
+
+func getSubnetStatus(usedPercent float64, warn int, crit int) string {
+	if usedPercent >= float64(crit) {
+		return subnetStatusCritical
+	}
+	if usedPercent >= float64(warn) {
+		return subnetStatusWarning
+	}
+	return subnetStatusOK
+}
+
+// getSubnetsReportStatus returns the most severe status of the report.
+// Datacenters that could not be queried make the report UNKNOWN unless a pool is above a threshold.
+func getSubnetsReportStatus(report subnetsReport) string {
+
+	status := subnetStatusOK
+
+	for _, p := range report.Pools {
+		if p.Status == subnetStatusCritical {
+			return subnetStatusCritical
+		}
+		if p.Status == subnetStatusWarning {
+			status = subnetStatusWarning
+		}
+	}
+
+	for _, d := range report.Destinations {
+		if d.Status == subnetStatusCritical {
+			return subnetStatusCritical
+		}
+		if d.Status == subnetStatusWarning {
+			status = subnetStatusWarning
+		}
+	}
+
+	if status == subnetStatusOK && len(report.Errors) > 0 {
+		return subnetStatusUnknown
+	}
+
+	return status
+}
+
+func getSubnetsExitCodeError(report subnetsReport) error {
+	code := subnetStatusExitCodes[report.Status]
+	if code == 0 {
+		return nil
+	}
+	return &command.ExitCodeError{Code: code}
+}
+
+// renderSubnetsReportFatal reports an error that prevents the report from being produced.
+// Monitoring systems expect an UNKNOWN status and exit code instead of a generic failure.
+func renderSubnetsReportFatal(format string, warn int, crit int, err error) (string, error) {
+
+	exitCodeError := &command.ExitCodeError{
+		Code:    subnetStatusExitCodes[subnetStatusUnknown],
+		Message: err.Error(),
+	}
+
+	switch format {
+	case "json", "JSON":
+		report := subnetsReport{
+			Status:       subnetStatusUnknown,
+			Warn:         warn,
+			Crit:         crit,
+			Pools:        []subnetPoolUsage{},
+			Destinations: []subnetDestinationUsage{},
+			Errors:       []inventoryError{{Error: err.Error()}},
+		}
+
+		b, err := json.MarshalIndent(report, "", "\t")
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", exitCodeError
+
+	case "nagios":
+		return fmt.Sprintf("SUBNETS %s - %s\n", subnetStatusUnknown, err.Error()), exitCodeError
+	}
+
+	return "", err
+}
+
+func countSubnetPools(pools []subnetPoolUsage, status string) int {
+	count := 0
+	for _, p := range pools {
+		if p.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// renderSubnetsReportNagios renders the report as a Nagios plugin output:
+// a status line with performance data followed by one line for each pool above a threshold
+func renderSubnetsReportNagios(report subnetsReport) string {
+
+	var sb strings.Builder
+
+	summary := []string{}
+	if n := countSubnetPools(report.Pools, subnetStatusCritical); n > 0 {
+		summary = append(summary, fmt.Sprintf("%d critical", n))
+	}
+	if n := countSubnetPools(report.Pools, subnetStatusWarning); n > 0 {
+		summary = append(summary, fmt.Sprintf("%d warning", n))
+	}
+
+	message := fmt.Sprintf("%d subnet pools below %d%%", len(report.Pools), report.Warn)
+	if len(summary) > 0 {
+		message = fmt.Sprintf("%s of %d subnet pools", strings.Join(summary, ", "), len(report.Pools))
+	}
+	if len(report.Errors) > 0 {
+		message += fmt.Sprintf(", %d datacenters could not be queried", len(report.Errors))
+	}
+
+	perfData := []string{}
+	for _, d := range report.Destinations {
+		perfData = append(perfData, fmt.Sprintf("'%s_%s_%s'=%.2f%%;%d;%d;0;100",
+			d.Datacenter,
+			strings.ToLower(d.Destination),
+			d.Type,
+			d.UsedPercent,
+			report.Warn,
+			report.Crit,
+		))
+	}
+
+	sb.WriteString(fmt.Sprintf("SUBNETS %s - %s", report.Status, message))
+	if len(perfData) > 0 {
+		sb.WriteString(" | " + strings.Join(perfData, " "))
+	}
+	sb.WriteString("\n")
+
+	for _, status := range []string{subnetStatusCritical, subnetStatusWarning} {
+		for _, d := range report.Destinations {
+			if d.Status == status {
+				sb.WriteString(fmt.Sprintf("%s: %s %s %s pools %.1f%% used (%.0f addresses available)\n",
+					status, d.Datacenter, d.Destination, d.Type, d.UsedPercent, d.FreeIPs))
+			}
+		}
+		for _, p := range report.Pools {
+			if p.Status == status {
+				sb.WriteString(fmt.Sprintf("%s: %s subnet pool #%d %s %s %.1f%% used (%.0f addresses available)\n",
+					status, p.Datacenter, p.ID, p.Prefix, p.Destination, p.UsedPercent, p.FreeIPs))
+			}
+		}
+	}
+
+	for _, e := range report.Errors {
+		sb.WriteString(fmt.Sprintf("%s: %s could not be queried: %s\n", subnetStatusUnknown, e.Datacenter, e.Error))
+	}
+
+	return sb.String()
+}
+
+// describeFreePrefixes returns the number of free prefixes of each size, such as "4x/27 8x/28"
+func describeFreePrefixes(freePrefixes map[string]int) string {
+
+	sizes := []int{}
+	for k := range freePrefixes {
+		size, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		sizes = append(sizes, size)
+	}
+	sort.Ints(sizes)
+
+	descriptions := []string{}
+	for _, size := range sizes {
+		descriptions = append(descriptions, fmt.Sprintf("%dx/%d", freePrefixes[strconv.Itoa(size)], size))
+	}
+
+	return strings.Join(descriptions, " ")
+}
+
+func colorSubnetStatus(status string, format string) string {
+	if format != "" {
+		return status
+	}
+
+	switch status {
+	case subnetStatusCritical, subnetStatusUnknown:
+		return colors.Red(status)
+	case subnetStatusWarning:
+		return colors.Yellow(status)
+	}
+	return colors.Green(status)
+}
+
+func renderSubnetsReportTables(report subnetsReport, format string) (string, error) {
+
+	poolsSchema := []tableformatter.SchemaField{
+		{
+			FieldName: "DATACENTER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "ID",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "LABEL",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "PREFIX",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 18,
+		},
+		{
+			FieldName: "DEST.",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 4,
+		},
+		{
+			FieldName: "USED_%",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "FREE_IPS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 8,
+		},
+		{
+			FieldName: "FREE_PREFIXES",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 20,
+		},
+		{
+			FieldName: "STATUS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 8,
+		},
+	}
+
+	pools := append([]subnetPoolUsage{}, report.Pools...)
+	sort.SliceStable(pools, func(i, j int) bool {
+		if pools[i].Datacenter != pools[j].Datacenter {
+			return pools[i].Datacenter < pools[j].Datacenter
+		}
+		return pools[i].UsedPercent > pools[j].UsedPercent
+	})
+
+	poolsData := [][]interface{}{}
+	for _, p := range pools {
+		poolsData = append(poolsData, []interface{}{
+			p.Datacenter,
+			p.ID,
+			p.Label,
+			p.Prefix,
+			p.Destination,
+			fmt.Sprintf("%.1f", p.UsedPercent),
+			fmt.Sprintf("%.0f", p.FreeIPs),
+			describeFreePrefixes(p.FreePrefixes),
+			colorSubnetStatus(p.Status, format),
+		})
+	}
+
+	destinationsSchema := []tableformatter.SchemaField{
+		{
+			FieldName: "DATACENTER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "DEST.",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 4,
+		},
+		{
+			FieldName: "TYPE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 4,
+		},
+		{
+			FieldName: "POOLS",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 5,
+		},
+		{
+			FieldName: "USED_%",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "FREE_IPS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 8,
+		},
+		{
+			FieldName: "STATUS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 8,
+		},
+	}
+
+	destinationsData := [][]interface{}{}
+	for _, d := range report.Destinations {
+		destinationsData = append(destinationsData, []interface{}{
+			d.Datacenter,
+			d.Destination,
+			d.Type,
+			d.Pools,
+			fmt.Sprintf("%.1f", d.UsedPercent),
+			fmt.Sprintf("%.0f", d.FreeIPs),
+			colorSubnetStatus(d.Status, format),
+		})
+	}
+
+	poolsTable := tableformatter.Table{
+		Data:   poolsData,
+		Schema: poolsSchema,
+	}
+
+	destinationsTable := tableformatter.Table{
+		Data:   destinationsData,
+		Schema: destinationsSchema,
+	}
+
+	ret, err := destinationsTable.RenderTable("Datacenters", fmt.Sprintf("Utilization per datacenter, destination and type. Warning at %d%%, critical at %d%%", report.Warn, report.Crit), format)
+	if err != nil {
+		return "", err
+	}
+
+	separator := ""
+	if format != "" {
+		separator = "\n"
+	}
+
+	poolsRet, err := poolsTable.RenderTable("Subnet pools", "Subnet pools sorted by utilization", format)
+	if err != nil {
+		return "", err
+	}
+	ret += separator + poolsRet
+
+	if len(report.Errors) > 0 {
+		errorsData := [][]interface{}{}
+		for _, e := range report.Errors {
+			errorsData = append(errorsData, []interface{}{e.Datacenter, e.Error})
+		}
+
+		errorsTable := tableformatter.Table{
+			Data: errorsData,
+			Schema: []tableformatter.SchemaField{
+				{
+					FieldName: "DATACENTER",
+					FieldType: tableformatter.TypeString,
+					FieldSize: 10,
+				},
+				{
+					FieldName: "ERROR",
+					FieldType: tableformatter.TypeString,
+					FieldSize: 40,
+				},
+			},
+		}
+
+		errorsRet, err := errorsTable.RenderTable("Errors", "Datacenters that could not be queried, their subnet pools are not included", format)
+		if err != nil {
+			return "", err
+		}
+		ret += separator + errorsRet
+	}
+
+	return ret, nil
+}
@@ -0,0 +1,230 @@
+package reports
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	. "github.com/onsi/gomega"
+
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+)
+
+func mockSubnetsReportClient(t *testing.T) *mock_metalcloud.MockMetalCloudClient {
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		Datacenters(true).
+		Return(&map[string]metalcloud.Datacenter{
+			"dc1": {DatacenterName: "dc1"},
+			"dc2": {DatacenterName: "dc2"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SubnetPoolSearch("datacenter_name:dc1").
+		Return(&[]metalcloud.SubnetPool{
+			{SubnetPoolID: 1, SubnetPoolPrefixHumanReadable: "10.0.0.0", SubnetPoolPrefixSize: 24, SubnetPoolType: "ipv4", SubnetPoolDestination: "wan"},
+			{SubnetPoolID: 2, SubnetPoolPrefixHumanReadable: "10.0.1.0", SubnetPoolPrefixSize: 24, SubnetPoolType: "ipv4", SubnetPoolDestination: "wan"},
+			{SubnetPoolID: 3, SubnetPoolPrefixHumanReadable: "10.1.0.0", SubnetPoolPrefixSize: 24, SubnetPoolType: "ipv4", SubnetPoolDestination: "lan"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SubnetPoolPrefixSizesStats(1).
+		Return(&metalcloud.SubnetPoolUtilization{
+			IPAddressesUsableCountFree:      "10",
+			IPAddressesUsableCountAllocated: "90",
+			PrefixCountFree:                 map[string]int{"28": 0, "30": 2},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SubnetPoolPrefixSizesStats(2).
+		Return(&metalcloud.SubnetPoolUtilization{
+			IPAddressesUsableCountFree:      "2",
+			IPAddressesUsableCountAllocated: "98",
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SubnetPoolPrefixSizesStats(3).
+		Return(&metalcloud.SubnetPoolUtilization{
+			IPAddressesUsableFreePercentOptimistic: "75",
+		}, nil).
+		AnyTimes()
+
+	return client
+}
+
+func TestSubnetsReportCmd(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSubnetsReportClient(t)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+		"format":     "json",
+	})
+
+	ret, err := subnetsReportCmd(&cmd, client)
+
+	var exitCodeError *command.ExitCodeError
+	Expect(errors.As(err, &exitCodeError)).To(BeTrue())
+	Expect(exitCodeError.Code).To(Equal(2))
+
+	var report subnetsReport
+	Expect(json.Unmarshal([]byte(ret), &report)).To(Succeed())
+
+	Expect(report.Status).To(Equal(subnetStatusCritical))
+	Expect(report.Pools).To(HaveLen(3))
+	Expect(report.Pools[0].Status).To(Equal(subnetStatusWarning))
+	Expect(report.Pools[1].Status).To(Equal(subnetStatusCritical))
+	Expect(report.Pools[2].Status).To(Equal(subnetStatusOK))
+	Expect(report.Pools[2].UsedPercent).To(Equal(25.0))
+
+	Expect(report.Destinations).To(HaveLen(2))
+	Expect(report.Destinations[0].Destination).To(Equal("LAN"))
+	Expect(report.Destinations[1].Destination).To(Equal("WAN"))
+	Expect(report.Destinations[1].Pools).To(Equal(2))
+	Expect(report.Destinations[1].UsedPercent).To(Equal(94.0))
+	Expect(report.Destinations[1].Status).To(Equal(subnetStatusWarning))
+
+	// raising the thresholds clears the alerts
+	cmd = command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+		"format":     "nagios",
+		"warn":       99,
+		"crit":       100,
+	})
+
+	ret, err = subnetsReportCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(HavePrefix("SUBNETS OK - 3 subnet pools below 99% | 'dc1_lan_ipv4'=25.00%;99;100;0;100 'dc1_wan_ipv4'=94.00%;99;100;0;100\n"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+		"format":     "csv",
+	})
+
+	ret, err = subnetsReportCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("dc1,1,,10.0.0.0/24,WAN,90.0,10,0x/28 2x/30,WARNING"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"warn": 90,
+		"crit": 80,
+	})
+
+	_, err = subnetsReportCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+}
+
+func TestSubnetsReportCmdNagios(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSubnetsReportClient(t)
+
+	client.EXPECT().
+		SubnetPoolSearch("datacenter_name:dc2").
+		Return(nil, fmt.Errorf("connection refused")).
+		AnyTimes()
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"format": "nagios",
+	})
+
+	ret, err := subnetsReportCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.(*command.ExitCodeError).Code).To(Equal(2))
+
+	Expect(ret).To(HavePrefix("SUBNETS CRITICAL - 1 critical, 1 warning of 3 subnet pools, 1 datacenters could not be queried | "))
+	Expect(ret).To(ContainSubstring("\nCRITICAL: dc1 subnet pool #2 10.0.1.0/24 WAN 98.0% used (2 addresses available)\n"))
+	Expect(ret).To(ContainSubstring("\nWARNING: dc1 WAN ipv4 pools 94.0% used (12 addresses available)\n"))
+	Expect(ret).To(ContainSubstring("\nUNKNOWN: dc2 could not be queried: connection refused\n"))
+
+	// without alerts a failed datacenter makes the check unknown
+	cmd = command.MakeCommand(map[string]interface{}{
+		"format": "nagios",
+		"warn":   99,
+		"crit":   100,
+	})
+
+	ret, err = subnetsReportCmd(&cmd, client)
+	Expect(err.(*command.ExitCodeError).Code).To(Equal(3))
+	Expect(ret).To(HavePrefix("SUBNETS UNKNOWN - "))
+}
+
+func TestSubnetsReportCmdFatalErrors(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		Datacenters(true).
+		Return(nil, fmt.Errorf("connection refused")).
+		AnyTimes()
+
+	cases := []struct {
+		args     map[string]interface{}
+		expected string
+	}{
+		{
+			args:     map[string]interface{}{"warn": 90, "crit": 80},
+			expected: "-warn and -crit must be between 0 and 100",
+		},
+		{
+			args:     map[string]interface{}{"concurrency": 0},
+			expected: "-concurrency must be at least 1",
+		},
+		{
+			args:     map[string]interface{}{},
+			expected: "connection refused",
+		},
+	}
+
+	for _, c := range cases {
+		args := map[string]interface{}{"format": "nagios"}
+		for k, v := range c.args {
+			args[k] = v
+		}
+
+		cmd := command.MakeCommand(args)
+
+		ret, err := subnetsReportCmd(&cmd, client)
+
+		var exitCodeError *command.ExitCodeError
+		Expect(errors.As(err, &exitCodeError)).To(BeTrue())
+		Expect(exitCodeError.Code).To(Equal(3))
+		Expect(exitCodeError.Message).To(ContainSubstring(c.expected))
+		Expect(ret).To(HavePrefix("SUBNETS UNKNOWN - "))
+		Expect(ret).To(ContainSubstring(c.expected))
+
+		args["format"] = "json"
+		cmd = command.MakeCommand(args)
+
+		ret, err = subnetsReportCmd(&cmd, client)
+		Expect(errors.As(err, &exitCodeError)).To(BeTrue())
+		Expect(exitCodeError.Code).To(Equal(3))
+
+		var report subnetsReport
+		Expect(json.Unmarshal([]byte(ret), &report)).To(Succeed())
+		Expect(report.Status).To(Equal(subnetStatusUnknown))
+		Expect(report.Errors).To(HaveLen(1))
+		Expect(report.Errors[0].Error).To(ContainSubstring(c.expected))
+
+		// the other formats fail as any other command
+		delete(args, "format")
+		cmd = command.MakeCommand(args)
+
+		_, err = subnetsReportCmd(&cmd, client)
+		Expect(err).NotTo(BeNil())
+		Expect(errors.As(err, &exitCodeError)).To(BeFalse())
+	}
+}
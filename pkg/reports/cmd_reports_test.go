@@ -2,7 +2,10 @@ package reports
 
 import (
 	"encoding/json"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	gomock "github.com/golang/mock/gomock"
 	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
@@ -57,6 +60,88 @@ func TestDevicesListCmdWithWatch(t *testing.T) {
 	Expect(ret).To(ContainSubstring("2"))
 }
 
+func TestDevicesListCmdWithFailingDatacenter(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		Datacenters(true).
+		Return(&map[string]metalcloud.Datacenter{
+			"dc-c": {DatacenterName: "dc-c"},
+			"dc-a": {DatacenterName: "dc-a"},
+			"dc-b": {DatacenterName: "dc-b"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServersSearch("datacenter_name:dc-b").
+		Return(nil, fmt.Errorf("connection refused")).
+		AnyTimes()
+
+	client.EXPECT().
+		ServersSearch(gomock.Any()).
+		Return(&[]metalcloud.ServerSearchResult{{ServerID: 1, ServerStatus: "used"}}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SwitchDevices(gomock.Any(), "").
+		Return(&map[string]metalcloud.SwitchDevice{}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		StoragePoolSearch(gomock.Any()).
+		Return(&[]metalcloud.StoragePoolSearchResult{}, nil).
+		AnyTimes()
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"format":      "csv",
+		"concurrency": 2,
+	})
+
+	ret, err := devicesListCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("0,dc-a,1,0,0,\n1,dc-b,-,-,-,connection refused\n2,dc-c,1,0,0,\n,TOTAL,2,0,0,"))
+}
+
+func TestCollectPerDatacenter(t *testing.T) {
+	RegisterTestingT(t)
+
+	var lock sync.Mutex
+	running := 0
+	maxRunning := 0
+
+	datacenters := []string{"dc1", "dc2", "dc3", "dc4", "dc5", "dc6"}
+
+	results := collectPerDatacenter(datacenters, 2, func(datacenter string) (interface{}, error) {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		lock.Lock()
+		running--
+		lock.Unlock()
+
+		if datacenter == "dc3" {
+			return nil, fmt.Errorf("failed")
+		}
+		return datacenter + "-data", nil
+	})
+
+	Expect(maxRunning).To(Equal(2))
+	Expect(results).To(HaveLen(6))
+	Expect(results[0].Datacenter).To(Equal("dc1"))
+	Expect(results[0].Data).To(Equal("dc1-data"))
+	Expect(results[2].Err).NotTo(BeNil())
+	Expect(results[5].Data).To(Equal("dc6-data"))
+}
+
 const _storageListFixture = "[\r\n                {\r\n                    \"storage_pool_id\": 1,\r\n                    \"storage_pool_name\": \"UnityVSA\",\r\n                    \"storage_pool_status\": \"active\",\r\n                    \"storage_pool_in_maintenance\": false,\r\n                    \"datacenter_name\": \"us02-chi-qts01-dc\",\r\n                    \"storage_type\": \"iscsi_ssd\",\r\n                    \"user_id\": null,\r\n                    \"storage_pool_iscsi_host\": \"100.96.0.2\",\r\n                    \"storage_pool_iscsi_port\": 3260,\r\n                    \"storage_pool_capacity_total_cached_real_mbytes\": 505344,\r\n                    \"storage_pool_capacity_usable_cached_real_mbytes\": 505344,\r\n                    \"storage_pool_capacity_free_cached_real_mbytes\": 496128,\r\n                    \"storage_pool_capacity_used_cached_virtual_mbytes\": 122880\r\n                }\r\n            ]"
 const _datacenterList = "{\"test\":{\"datacenter_id\":6,\"datacenter_name\":\"test\",\"datacenter_name_parent\":null,\"user_id\":null,\"datacenter_is_master\":false,\"datacenter_is_maintenance\":false,\"datacenter_type\":\"metal_cloud\",\"datacenter_display_name\":\"US02 Chi QTS01 DC\",\"datacenter_hidden\":false,\"datacenter_created_timestamp\":\"2022-02-11T11:14:08Z\",\"datacenter_updated_timestamp\":\"2022-06-09T13:32:56Z\",\"type\":\"Datacenter\",\"datacenter_tags\":[]}}"
 const _serverListFixture1 = "[\n                {\n                    \"server_id\": 16,\n                    \"server_type_name\": null,\n                    \"server_type_boot_type\": null,\n                    \"server_product_name\": null,\n                    \"datacenter_name\": \"us02-chi-qts01-dc\",\n                    \"server_status\": \"registering\",\n                    \"server_class\": \"bigdata\",\n                    \"server_created_timestamp\": \"2022-05-23T13:22:11Z\",\n                    \"server_vendor\": \"Dell Inc.\",\n                    \"server_serial_number\": null,\n                    \"server_uuid\": \"4c4c4544-0051-3810-8057-b7c04f533532\",\n                    \"server_vendor_sku_id\": null,\n                    \"server_boot_type\": \"classic\",\n                    \"server_allocation_timestamp\": null,\n                    \"instance_label\": [\n                        null\n                    ],\n                    \"instance_id\": [\n                        null\n                    ],\n                    \"instance_array_id\": [\n                        null\n                    ],\n                    \"infrastructure_id\": [\n                        null\n                    ],\n                    \"server_inventory_id\": null,\n                    \"server_rack_name\": null,\n                    \"server_rack_position_lower_unit\": null,\n                    \"server_rack_position_upper_unit\": null,\n                    \"server_ipmi_host\": \"172.18.44.42\",\n                    \"server_ipmi_internal_username\": \"root\",\n                    \"server_processor_name\": null,\n                    \"server_processor_count\": 0,\n                    \"server_processor_core_count\": 0,\n                    \"server_processor_core_mhz\": 0,\n                    \"server_processor_threads\": null,\n                    \"server_processor_cpu_mark\": null,\n                    \"server_disk_type\": \"none\",\n                    \"server_disk_count\": 0,\n                    \"server_disk_size_mbytes\": 0,\n                    \"server_ram_gbytes\": 0,\n                    \"server_network_total_capacity_mbps\": 0,\n                    \"server_dhcp_status\": \"quarantine\",\n                    \"server_dhcp_packet_sniffing_is_enabled\": true,\n                    \"server_dhcp_relay_security_is_enabled\": true,\n                    \"server_disk_wipe\": false,\n                    \"server_power_status\": \"off\",\n                    \"server_power_status_last_update_timestamp\": \"2022-05-23T13:24:41Z\",\n                    \"user_id\": [\n                        [\n                            null\n                        ]\n                    ],\n                    \"user_id_owner\": [\n                        null\n                    ],\n                    \"user_email\": [\n                        [\n                            null\n                        ]\n                    ],\n                    \"infrastructure_user_id\": [\n                        [\n                            null\n                        ]\n                    ]\n                }\n            ]"
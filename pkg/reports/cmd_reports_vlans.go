@@ -0,0 +1,332 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/tableformatter"
+)
+
+// vlanUsage is a VLAN of a network profile together with the instance arrays that use the profile
+type vlanUsage struct {
+	VlanID              *int
+	ProfileID           int
+	ProfileLabel        string
+	NetworkType         string
+	PortMode            string
+	ExternalConnections []string
+	InstanceArrays      []string
+	Issues              []string
+}
+
+// vlanInstanceArray is an instance array together with the ids of the network profiles it uses
+type vlanInstanceArray struct {
+	ID         int
+	Label      string
+	ProfileIDs []int
+}
+
+func vlansReportCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	datacenter, ok := command.GetStringParamOk(c.Arguments["datacenter"])
+	if !ok {
+		return "", fmt.Errorf("-datacenter is required")
+	}
+
+	concurrency, err := getReportConcurrency(c)
+	if err != nil {
+		return "", err
+	}
+
+	npList, err := client.NetworkProfiles(datacenter)
+	if err != nil {
+		return "", err
+	}
+
+	ecList, err := client.ExternalConnections(datacenter)
+	if err != nil {
+		return "", err
+	}
+
+	instanceArrays, err := getVlanInstanceArrays(datacenter, concurrency, client)
+	if err != nil {
+		return "", err
+	}
+
+	profiles := []metalcloud.NetworkProfile{}
+	for _, np := range *npList {
+		profiles = append(profiles, np)
+	}
+
+	vlans := getVlansUsage(profiles, *ecList, instanceArrays)
+
+	if command.GetBoolParam(c.Arguments["issues_only"]) {
+		withIssues := []vlanUsage{}
+		for _, v := range vlans {
+			if len(v.Issues) > 0 {
+				withIssues = append(withIssues, v)
+			}
+		}
+		vlans = withIssues
+	}
+
+	return renderVlansReport(vlans, datacenter, command.GetStringParam(c.Arguments["format"]))
+}
+
+// getVlanInstanceArrays returns the instance arrays of the infrastructures of a datacenter that are not deleted, with the network profiles they use.
+// The instance arrays and their network profiles are retrieved in parallel.
+func getVlanInstanceArrays(datacenter string, concurrency int, client metalcloud.MetalCloudClient) ([]vlanInstanceArray, error) {
+
+	iList, err := client.InfrastructureSearch("datacenter_name:" + datacenter)
+	if err != nil {
+		return nil, err
+	}
+
+	infrastructures := []metalcloud.InfrastructuresSearchResult{}
+	for _, infra := range *iList {
+		if infra.InfrastructureServiceStatus == "deleted" {
+			continue
+		}
+		infrastructures = append(infrastructures, infra)
+	}
+
+	iaLists := make([][]metalcloud.InstanceArray, len(infrastructures))
+	err = command.Prefetch(len(infrastructures), concurrency, func(i int) error {
+		list, err := client.InstanceArrays(infrastructures[i].InfrastructureID)
+		if err != nil {
+			return err
+		}
+
+		for _, ia := range *list {
+			if ia.InstanceArrayServiceStatus == "deleted" {
+				continue
+			}
+			iaLists[i] = append(iaLists[i], ia)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	instanceArrays := []vlanInstanceArray{}
+	for _, list := range iaLists {
+		for _, ia := range list {
+			instanceArrays = append(instanceArrays, vlanInstanceArray{
+				ID:    ia.InstanceArrayID,
+				Label: ia.InstanceArrayLabel,
+			})
+		}
+	}
+
+	sort.Slice(instanceArrays, func(i, j int) bool {
+		return instanceArrays[i].ID < instanceArrays[j].ID
+	})
+
+	err = command.Prefetch(len(instanceArrays), concurrency, func(i int) error {
+		profileIDs, err := client.NetworkProfileListByInstanceArray(instanceArrays[i].ID)
+		if err != nil {
+			return err
+		}
+
+		seen := map[int]bool{}
+		for _, profileID := range *profileIDs {
+			if !seen[profileID] {
+				seen[profileID] = true
+				instanceArrays[i].ProfileIDs = append(instanceArrays[i].ProfileIDs, profileID)
+			}
+		}
+		sort.Ints(instanceArrays[i].ProfileIDs)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return instanceArrays, nil
+}
+
+// getVlansUsage returns the VLANs of the network profiles sorted by VLAN ID with automatically allocated VLANs last.
+// VLAN IDs used more than once, VLANs of profiles not used by any instance array and external connections
+// that do not exist in the datacenter are reported as issues.
+func getVlansUsage(profiles []metalcloud.NetworkProfile, externalConnections map[int]metalcloud.ExternalConnection, instanceArrays []vlanInstanceArray) []vlanUsage {
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].NetworkProfileID < profiles[j].NetworkProfileID
+	})
+
+	usedBy := map[int][]string{}
+	for _, ia := range instanceArrays {
+		for _, profileID := range ia.ProfileIDs {
+			usedBy[profileID] = append(usedBy[profileID], fmt.Sprintf("%s (#%d)", ia.Label, ia.ID))
+		}
+	}
+
+	vlans := []vlanUsage{}
+	owners := map[int][]string{}
+
+	for _, np := range profiles {
+		profile := fmt.Sprintf("%s (#%d)", np.NetworkProfileLabel, np.NetworkProfileID)
+
+		for _, vlan := range np.NetworkProfileVLANs {
+			v := vlanUsage{
+				VlanID:              vlan.VlanID,
+				ProfileID:           np.NetworkProfileID,
+				ProfileLabel:        np.NetworkProfileLabel,
+				NetworkType:         np.NetworkType,
+				PortMode:            vlan.PortMode,
+				ExternalConnections: []string{},
+				InstanceArrays:      usedBy[np.NetworkProfileID],
+				Issues:              []string{},
+			}
+
+			if v.InstanceArrays == nil {
+				v.InstanceArrays = []string{}
+			}
+
+			for _, ecID := range vlan.ExternalConnectionIDs {
+				ec, ok := externalConnections[ecID]
+				if !ok {
+					v.ExternalConnections = append(v.ExternalConnections, fmt.Sprintf("#%d", ecID))
+					v.Issues = append(v.Issues, fmt.Sprintf("missing external connection #%d", ecID))
+					continue
+				}
+				v.ExternalConnections = append(v.ExternalConnections, fmt.Sprintf("%s (#%d)", ec.ExternalConnectionLabel, ecID))
+			}
+
+			if len(v.InstanceArrays) == 0 {
+				v.Issues = append(v.Issues, "unused")
+			}
+
+			if vlan.VlanID != nil {
+				owners[*vlan.VlanID] = append(owners[*vlan.VlanID], profile)
+			}
+
+			vlans = append(vlans, v)
+		}
+	}
+
+	for i, v := range vlans {
+		if v.VlanID == nil || len(owners[*v.VlanID]) < 2 {
+			continue
+		}
+
+		profile := fmt.Sprintf("%s (#%d)", v.ProfileLabel, v.ProfileID)
+		others := []string{}
+		skipped := false
+		for _, owner := range owners[*v.VlanID] {
+			// skip this VLAN itself once, a profile that uses the same VLAN twice collides with itself
+			if owner == profile && !skipped {
+				skipped = true
+				continue
+			}
+			others = append(others, owner)
+		}
+
+		vlans[i].Issues = append([]string{fmt.Sprintf("collision with %s", strings.Join(others, ", "))}, v.Issues...)
+	}
+
+	sort.SliceStable(vlans, func(i, j int) bool {
+		if vlans[i].VlanID == nil || vlans[j].VlanID == nil {
+			return vlans[i].VlanID != nil && vlans[j].VlanID == nil
+		}
+		return *vlans[i].VlanID < *vlans[j].VlanID
+	})
+
+	return vlans
+}
+
+func renderVlansReport(vlans []vlanUsage, datacenter string, format string) (string, error) {
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "VLAN",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "PROFILE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 20,
+		},
+		{
+			FieldName: "NETWORK_TYPE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "PORT_MODE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "EXTERNAL_CONNECTIONS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 20,
+		},
+		{
+			FieldName: "INSTANCE_ARRAYS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 20,
+		},
+		{
+			FieldName: "ISSUES",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 20,
+		},
+	}
+
+	collisions := 0
+	unused := 0
+	missing := 0
+
+	data := [][]interface{}{}
+	for _, v := range vlans {
+		vlanID := "auto"
+		if v.VlanID != nil {
+			vlanID = strconv.Itoa(*v.VlanID)
+		}
+
+		for _, issue := range v.Issues {
+			switch {
+			case strings.HasPrefix(issue, "collision"):
+				collisions++
+			case issue == "unused":
+				unused++
+			case strings.HasPrefix(issue, "missing"):
+				missing++
+			}
+		}
+
+		issues := strings.Join(v.Issues, "; ")
+		if format == "" && issues != "" {
+			issues = colors.Red(issues)
+		}
+
+		data = append(data, []interface{}{
+			vlanID,
+			fmt.Sprintf("%s (#%d)", v.ProfileLabel, v.ProfileID),
+			v.NetworkType,
+			v.PortMode,
+			strings.Join(v.ExternalConnections, ", "),
+			strings.Join(v.InstanceArrays, ", "),
+			issues,
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	title := fmt.Sprintf("VLANs of the network profiles of datacenter %s: %d colliding, %d unused, %d with missing external connections", datacenter, collisions, unused, missing)
+
+	return table.RenderTable("VLANs", title, format)
+}
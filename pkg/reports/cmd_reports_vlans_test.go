@@ -0,0 +1,122 @@
+package reports
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	. "github.com/onsi/gomega"
+
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+)
+
+func mockVlansReportClient(t *testing.T) *mock_metalcloud.MockMetalCloudClient {
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	vlan100 := 100
+	vlan200 := 200
+
+	client.EXPECT().
+		NetworkProfiles("dc1").
+		Return(&map[int]metalcloud.NetworkProfile{
+			1: {
+				NetworkProfileID:    1,
+				NetworkProfileLabel: "wan-a",
+				NetworkType:         "wan",
+				NetworkProfileVLANs: []metalcloud.NetworkProfileVLAN{
+					{VlanID: &vlan100, PortMode: "trunk", ExternalConnectionIDs: []int{5}},
+					{PortMode: "native"},
+				},
+			},
+			2: {
+				NetworkProfileID:    2,
+				NetworkProfileLabel: "wan-b",
+				NetworkType:         "wan",
+				NetworkProfileVLANs: []metalcloud.NetworkProfileVLAN{
+					{VlanID: &vlan100, PortMode: "trunk", ExternalConnectionIDs: []int{5}},
+				},
+			},
+			3: {
+				NetworkProfileID:    3,
+				NetworkProfileLabel: "lan-old",
+				NetworkType:         "lan",
+				NetworkProfileVLANs: []metalcloud.NetworkProfileVLAN{
+					{VlanID: &vlan200, PortMode: "trunk", ExternalConnectionIDs: []int{9}},
+				},
+			},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ExternalConnections("dc1").
+		Return(&map[int]metalcloud.ExternalConnection{
+			5: {ExternalConnectionID: 5, ExternalConnectionLabel: "uplink"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InfrastructureSearch("datacenter_name:dc1").
+		Return(&[]metalcloud.InfrastructuresSearchResult{
+			{InfrastructureID: 10, InfrastructureServiceStatus: "active"},
+			{InfrastructureID: 11, InfrastructureServiceStatus: "deleted"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		InstanceArrays(10).
+		Return(&map[string]metalcloud.InstanceArray{
+			"web": {InstanceArrayID: 20, InstanceArrayLabel: "web", InstanceArrayServiceStatus: "active"},
+			"db":  {InstanceArrayID: 21, InstanceArrayLabel: "db", InstanceArrayServiceStatus: "active"},
+			"old": {InstanceArrayID: 22, InstanceArrayLabel: "old", InstanceArrayServiceStatus: "deleted"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		NetworkProfileListByInstanceArray(20).
+		Return(&map[int]int{100: 1, 101: 2}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		NetworkProfileListByInstanceArray(21).
+		Return(&map[int]int{100: 1}, nil).
+		AnyTimes()
+
+	return client
+}
+
+func TestVlansReportCmd(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockVlansReportClient(t)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+		"format":     "csv",
+	})
+
+	ret, err := vlansReportCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal(`VLAN,PROFILE,NETWORK_TYPE,PORT_MODE,EXTERNAL_CONNECTIONS,INSTANCE_ARRAYS,ISSUES
+100,wan-a (#1),wan,trunk,uplink (#5),"web (#20), db (#21)",collision with wan-b (#2)
+100,wan-b (#2),wan,trunk,uplink (#5),web (#20),collision with wan-a (#1)
+200,lan-old (#3),lan,trunk,#9,,missing external connection #9; unused
+auto,wan-a (#1),wan,native,,"web (#20), db (#21)",
+`))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"datacenter":  "dc1",
+		"issues_only": true,
+		"format":      "csv",
+	})
+
+	ret, err = vlansReportCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).NotTo(ContainSubstring("auto"))
+
+	cmd = command.MakeCommand(map[string]interface{}{})
+
+	_, err = vlansReportCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+}
@@ -0,0 +1,419 @@
+package server
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+)
+
+var RackCmds = []command.Command{
+	{
+		Description:  "Show the elevation of racks.",
+		Subject:      "rack",
+		AltSubject:   "racks",
+		Predicate:    "show",
+		AltPredicate: "elevation",
+		FlagSet:      flag.NewFlagSet("show rack", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"datacenter": c.FlagSet.String("datacenter", command.NilDefaultStr, colors.Red("(Required)")+" The datacenter of the racks."),
+				"rack":       c.FlagSet.String("rack", command.NilDefaultStr, colors.Green("(Optional)")+" Only show this rack."),
+				"height":     c.FlagSet.Int("height", 42, colors.Green("(Optional)")+" The height of the racks in units. Racks holding devices above this unit are extended."),
+				"format":     c.FlagSet.String("format", "text", "The output format. Supported values are 'text','svg','html'."),
+			}
+		},
+		ExecuteFunc:         rackShowCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.SERVERS_READ},
+		Example: `
+The servers and switches are placed using the rack name and the lower and upper units set with server rack-info-set
+and switch edit. Overlapping devices and devices without rack information are reported after the racks.
+
+metalcloud-cli rack show --datacenter dc1
+metalcloud-cli rack show --datacenter dc1 --rack R12 --format html > R12.html
+`,
+	},
+}
+
+// rackDevice is a server or switch placed in a rack
+type rackDevice struct {
+	Kind   string
+	ID     int
+	Label  string
+	Status string
+	Rack   string
+	Lower  int
+	Upper  int
+}
+
+func (d rackDevice) String() string {
+	return fmt.Sprintf("%s %s (#%d)", d.Kind, d.Label, d.ID)
+}
+
+// rackElevation holds the devices of a rack
+type rackElevation struct {
+	Name     string
+	Height   int
+	Devices  []rackDevice
+	Overlaps []string
+}
+
+// devicesAt returns the devices occupying a unit
+func (r rackElevation) devicesAt(unit int) []rackDevice {
+	devices := []rackDevice{}
+	for _, d := range r.Devices {
+		if unit >= d.Lower && unit <= d.Upper {
+			devices = append(devices, d)
+		}
+	}
+	return devices
+}
+
+// isOverlapping returns true if another device of the rack occupies one of the units of a device
+func (r rackElevation) isOverlapping(d rackDevice) bool {
+	for _, o := range r.Devices {
+		if (o.Kind != d.Kind || o.ID != d.ID) && d.Lower <= o.Upper && o.Lower <= d.Upper {
+			return true
+		}
+	}
+	return false
+}
+
+func rackShowCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	datacenter, ok := command.GetStringParamOk(c.Arguments["datacenter"])
+	if !ok {
+		return "", fmt.Errorf("-datacenter is required")
+	}
+
+	format := command.GetStringParam(c.Arguments["format"])
+	if format == "" {
+		format = "text"
+	}
+
+	if format != "text" && format != "svg" && format != "html" {
+		return "", fmt.Errorf("format '%s' not supported. Supported values are 'text','svg','html'", format)
+	}
+
+	height := 42
+	if v, ok := command.GetIntParamOk(c.Arguments["height"]); ok {
+		height = v
+	}
+
+	servers, err := client.ServersSearch(fmt.Sprintf("+datacenter_name:%s", datacenter))
+	if err != nil {
+		return "", err
+	}
+
+	switches, err := client.SwitchDevices(datacenter, "")
+	if err != nil {
+		return "", err
+	}
+
+	devices := []rackDevice{}
+	missing := []string{}
+
+	for _, s := range *servers {
+		if s.ServerStatus == "decommissioned" {
+			continue
+		}
+
+		d := rackDevice{
+			Kind:   "server",
+			ID:     s.ServerID,
+			Label:  strings.TrimSpace(fmt.Sprintf("%s %s", s.ServerSerialNumber, s.ServerTypeName)),
+			Status: s.ServerStatus,
+			Rack:   s.ServerRackName,
+		}
+
+		lower, errLower := strconv.Atoi(strings.TrimSpace(s.ServerRackPositionLowerUnit))
+		upper, errUpper := strconv.Atoi(strings.TrimSpace(s.ServerRackPositionUpperUnit))
+		if errUpper != nil {
+			upper = lower
+		}
+
+		if d.Rack == "" || errLower != nil {
+			missing = append(missing, fmt.Sprintf("%s has no rack information", d))
+			continue
+		}
+
+		d.Lower, d.Upper = lower, upper
+		devices = append(devices, d)
+	}
+
+	for _, s := range *switches {
+		d := rackDevice{
+			Kind:   "switch",
+			ID:     s.NetworkEquipmentID,
+			Label:  s.NetworkEquipmentIdentifierString,
+			Rack:   s.NetworkEquipmentDatacenterRack,
+			Lower:  s.NetworkEquipmentRackPositionLowerUnit,
+			Upper:  s.NetworkEquipmentRackPositionUpperUnit,
+			Status: "switch",
+		}
+
+		if d.Upper == 0 {
+			d.Upper = d.Lower
+		}
+
+		if d.Rack == "" || d.Lower == 0 {
+			missing = append(missing, fmt.Sprintf("%s has no rack information", d))
+			continue
+		}
+
+		devices = append(devices, d)
+	}
+
+	racks := getRackElevations(devices, height, &missing)
+
+	if v, ok := command.GetStringParamOk(c.Arguments["rack"]); ok {
+		filtered := []rackElevation{}
+		for _, r := range racks {
+			if r.Name == v {
+				filtered = append(filtered, r)
+			}
+		}
+
+		if len(filtered) == 0 {
+			return "", fmt.Errorf("no devices were found in rack %s of datacenter %s", v, datacenter)
+		}
+		racks = filtered
+	}
+
+	sort.Strings(missing)
+
+	problems := []string{}
+	for _, r := range racks {
+		problems = append(problems, r.Overlaps...)
+	}
+	problems = append(problems, missing...)
+
+	switch format {
+	case "svg":
+		return renderRacksAsSVG(racks), nil
+	case "html":
+		return renderRacksAsHTML(datacenter, racks, problems), nil
+	}
+
+	return renderRacksAsText(racks, problems), nil
+}
+
+// getRackElevations groups the devices by rack and finds the overlapping ones.
+// Devices with an invalid position are added to the invalid list.
+func getRackElevations(devices []rackDevice, height int, invalid *[]string) []rackElevation {
+
+	byRack := map[string]*rackElevation{}
+	names := []string{}
+
+	for _, d := range devices {
+		if d.Lower < 1 || d.Upper < d.Lower {
+			*invalid = append(*invalid, fmt.Sprintf("%s has an invalid rack position %d-%d in rack %s", d, d.Lower, d.Upper, d.Rack))
+			continue
+		}
+
+		r, ok := byRack[d.Rack]
+		if !ok {
+			r = &rackElevation{Name: d.Rack, Height: height}
+			byRack[d.Rack] = r
+			names = append(names, d.Rack)
+		}
+
+		if d.Upper > r.Height {
+			r.Height = d.Upper
+		}
+
+		r.Devices = append(r.Devices, d)
+	}
+
+	sort.Strings(names)
+
+	racks := []rackElevation{}
+	for _, name := range names {
+		r := byRack[name]
+
+		sort.Slice(r.Devices, func(i, j int) bool {
+			if r.Devices[i].Upper != r.Devices[j].Upper {
+				return r.Devices[i].Upper > r.Devices[j].Upper
+			}
+			return r.Devices[i].ID < r.Devices[j].ID
+		})
+
+		for i := 0; i < len(r.Devices); i++ {
+			for j := i + 1; j < len(r.Devices); j++ {
+				a, b := r.Devices[i], r.Devices[j]
+				if a.Lower <= b.Upper && b.Lower <= a.Upper {
+					r.Overlaps = append(r.Overlaps, fmt.Sprintf("%s (U%d-U%d) overlaps %s (U%d-U%d) in rack %s", a, a.Lower, a.Upper, b, b.Lower, b.Upper, r.Name))
+				}
+			}
+		}
+
+		racks = append(racks, *r)
+	}
+
+	return racks
+}
+
+func colorizeRackDeviceStatus(status string) string {
+	if status == "switch" {
+		return colors.Bold(status)
+	}
+	return colorizeServerStatus(status)
+}
+
+func renderRacksAsText(racks []rackElevation, problems []string) string {
+	const width = 50
+
+	var sb strings.Builder
+	border := fmt.Sprintf("+-----+%s+\n", strings.Repeat("-", width+2))
+
+	for _, r := range racks {
+		sb.WriteString(fmt.Sprintf("Rack %s\n", r.Name))
+		sb.WriteString(border)
+
+		for unit := r.Height; unit >= 1; unit-- {
+			devices := r.devicesAt(unit)
+
+			text := ""
+			status := ""
+			switch {
+			case len(devices) > 1:
+				labels := []string{}
+				for _, d := range devices {
+					labels = append(labels, d.Label)
+				}
+				text = "!! " + strings.Join(labels, ", ")
+				status = "overlap"
+			case len(devices) == 1 && devices[0].Upper == unit:
+				text = fmt.Sprintf("#%d %s", devices[0].ID, devices[0].Label)
+				status = devices[0].Status
+			case len(devices) == 1:
+				text = "  |"
+			}
+
+			//the padding is computed before coloring as the escape codes have no width
+			if len(text)+len(status)+1 > width {
+				text = text[:width-len(status)-1]
+			}
+			padding := width - len(text) - len(status)
+
+			switch status {
+			case "":
+				sb.WriteString(fmt.Sprintf("| %3d | %s%s |\n", unit, text, strings.Repeat(" ", padding)))
+			case "overlap":
+				sb.WriteString(fmt.Sprintf("| %3d | %s%s%s |\n", unit, text, strings.Repeat(" ", padding), colors.Red(status)))
+			default:
+				sb.WriteString(fmt.Sprintf("| %3d | %s%s%s |\n", unit, text, strings.Repeat(" ", padding), colorizeRackDeviceStatus(status)))
+			}
+		}
+
+		sb.WriteString(border)
+		sb.WriteString("\n")
+	}
+
+	if len(problems) > 0 {
+		sb.WriteString(fmt.Sprintf("%s\n", colors.Red(fmt.Sprintf("%d problems found:", len(problems)))))
+		for _, p := range problems {
+			sb.WriteString(fmt.Sprintf("  %s\n", p))
+		}
+	}
+
+	return sb.String()
+}
+
+const (
+	rackSVGUnitHeight = 16
+	rackSVGRackWidth  = 260
+	rackSVGMargin     = 40
+)
+
+func rackSVGColor(status string) string {
+	switch status {
+	case "available":
+		return "#60a5fa"
+	case "used":
+		return "#4ade80"
+	case "unavailable":
+		return "#c084fc"
+	case "switch":
+		return "#94a3b8"
+	}
+	return "#facc15"
+}
+
+func renderRacksAsSVG(racks []rackElevation) string {
+
+	maxHeight := 0
+	for _, r := range racks {
+		if r.Height > maxHeight {
+			maxHeight = r.Height
+		}
+	}
+
+	width := len(racks)*(rackSVGRackWidth+rackSVGMargin) + rackSVGMargin
+	height := maxHeight*rackSVGUnitHeight + 2*rackSVGMargin
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" font-family=\"monospace\" font-size=\"11\">\n", width, height))
+
+	for i, r := range racks {
+		x := rackSVGMargin + i*(rackSVGRackWidth+rackSVGMargin)
+		top := rackSVGMargin + (maxHeight-r.Height)*rackSVGUnitHeight
+
+		sb.WriteString(fmt.Sprintf("  <g id=\"rack-%s\">\n", html.EscapeString(r.Name)))
+		sb.WriteString(fmt.Sprintf("    <text x=\"%d\" y=\"%d\" font-weight=\"bold\">%s</text>\n", x, top-8, html.EscapeString(r.Name)))
+		sb.WriteString(fmt.Sprintf("    <rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"#f8fafc\" stroke=\"#334155\"/>\n", x, top, rackSVGRackWidth, r.Height*rackSVGUnitHeight))
+
+		for unit := r.Height; unit >= 1; unit-- {
+			y := top + (r.Height-unit)*rackSVGUnitHeight
+			sb.WriteString(fmt.Sprintf("    <text x=\"%d\" y=\"%d\" text-anchor=\"end\" fill=\"#64748b\">%d</text>\n", x-4, y+rackSVGUnitHeight-4, unit))
+		}
+
+		for _, d := range r.Devices {
+			y := top + (r.Height-d.Upper)*rackSVGUnitHeight
+			stroke := "#334155"
+			if r.isOverlapping(d) {
+				stroke = "#dc2626"
+			}
+
+			sb.WriteString(fmt.Sprintf("    <rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\" stroke=\"%s\"><title>%s %s</title></rect>\n",
+				x+2, y+1, rackSVGRackWidth-4, (d.Upper-d.Lower+1)*rackSVGUnitHeight-2, rackSVGColor(d.Status), stroke, html.EscapeString(d.String()), html.EscapeString(d.Status)))
+			sb.WriteString(fmt.Sprintf("    <text x=\"%d\" y=\"%d\">#%d %s</text>\n", x+6, y+rackSVGUnitHeight-4, d.ID, html.EscapeString(d.Label)))
+		}
+
+		sb.WriteString("  </g>\n")
+	}
+
+	sb.WriteString("</svg>\n")
+
+	return sb.String()
+}
+
+func renderRacksAsHTML(datacenter string, racks []rackElevation, problems []string) string {
+	var sb strings.Builder
+
+	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n")
+	sb.WriteString(fmt.Sprintf("<meta charset=\"utf-8\">\n<title>Racks of %s</title>\n", html.EscapeString(datacenter)))
+	sb.WriteString("</head>\n<body style=\"font-family: sans-serif\">\n")
+	sb.WriteString(fmt.Sprintf("<h1>Racks of %s</h1>\n", html.EscapeString(datacenter)))
+	sb.WriteString(renderRacksAsSVG(racks))
+
+	if len(problems) > 0 {
+		sb.WriteString(fmt.Sprintf("<h2>%d problems found</h2>\n<ul>\n", len(problems)))
+		for _, p := range problems {
+			sb.WriteString(fmt.Sprintf("<li>%s</li>\n", html.EscapeString(p)))
+		}
+		sb.WriteString("</ul>\n")
+	}
+
+	sb.WriteString("</body>\n</html>\n")
+
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	. "github.com/onsi/gomega"
+)
+
+func TestRackShowCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		ServersSearch("+datacenter_name:dc1").
+		Return(&[]metalcloud.ServerSearchResult{
+			{ServerID: 1, ServerSerialNumber: "SN1", ServerStatus: "available", ServerRackName: "R12", ServerRackPositionLowerUnit: "10", ServerRackPositionUpperUnit: "11"},
+			{ServerID: 2, ServerSerialNumber: "SN2", ServerStatus: "used", ServerRackName: "R12", ServerRackPositionLowerUnit: "11", ServerRackPositionUpperUnit: "11"},
+			{ServerID: 3, ServerSerialNumber: "SN3", ServerStatus: "available"},
+			{ServerID: 4, ServerSerialNumber: "SN4", ServerStatus: "used", ServerRackName: "R13", ServerRackPositionLowerUnit: "44", ServerRackPositionUpperUnit: "45"},
+			{ServerID: 5, ServerSerialNumber: "SN5", ServerStatus: "decommissioned"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SwitchDevices("dc1", "").
+		Return(&map[string]metalcloud.SwitchDevice{
+			"leaf-1": {NetworkEquipmentID: 7, NetworkEquipmentIdentifierString: "leaf-1", NetworkEquipmentDatacenterRack: "R12", NetworkEquipmentRackPositionLowerUnit: 42},
+		}, nil).
+		AnyTimes()
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+	})
+
+	ret, err := rackShowCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("Rack R12\n"))
+	Expect(ret).To(ContainSubstring("Rack R13\n"))
+	Expect(ret).To(ContainSubstring("|  42 | #7 leaf-1"))
+	Expect(ret).To(ContainSubstring("|  11 | !! SN1, SN2"))
+	Expect(ret).To(ContainSubstring("|  10 |   |"))
+	Expect(ret).To(ContainSubstring("|  45 | #4 SN4"))
+	Expect(ret).To(ContainSubstring("server SN1 (#1) (U10-U11) overlaps server SN2 (#2) (U11-U11) in rack R12"))
+	Expect(ret).To(ContainSubstring("server SN3 (#3) has no rack information"))
+	Expect(ret).NotTo(ContainSubstring("SN5"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+		"rack":       "R13",
+		"format":     "svg",
+	})
+
+	ret, err = rackShowCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(HavePrefix("<svg"))
+	Expect(ret).To(ContainSubstring("<g id=\"rack-R13\">"))
+	Expect(ret).NotTo(ContainSubstring("rack-R12"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+		"format":     "html",
+	})
+
+	ret, err = rackShowCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("<h2>2 problems found</h2>"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+		"rack":       "R99",
+	})
+
+	_, err = rackShowCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+}
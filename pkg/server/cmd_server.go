@@ -185,8 +185,12 @@ $ metalcloud-cli server import -format yaml -file ./input.yaml
 		FlagSet:      flag.NewFlagSet("import server", flag.ExitOnError),
 		InitFunc: func(c *command.Command) {
 			c.Arguments = map[string]interface{}{
-				"format":                c.FlagSet.String("format", "json", "The input format. Supported values are 'json','yaml'. The only supported format is yaml."),
+				"format":                c.FlagSet.String("format", "json", "The input format. Supported values are 'yaml','csv'. Files with the .csv extension are read as csv, otherwise yaml is used."),
 				"read_config_from_file": c.FlagSet.String("file", command.NilDefaultStr, colors.Red("(Required)")+" Read raw object from file"),
+				"mapping":               c.FlagSet.String("mapping", command.NilDefaultStr, colors.Green("(Optional)")+" Yaml file mapping the record fields to the columns of the csv file. By default the columns are named like the yaml fields."),
+				"validate_only":         c.FlagSet.Bool("validate-only", false, colors.Green("(Flag)")+" If set the records are validated without importing them."),
+				"new_only":              c.FlagSet.Bool("new-only", false, colors.Green("(Flag)")+" If set the records are rejected if their serial number already exists or if MAC addresses or switch interfaces are repeated in the file. Use it when onboarding new servers. Serial numbers repeated in the file are always rejected."),
+				"report_format":         c.FlagSet.String("report-format", "", "The format of the per record report. Supported values are 'json','csv','yaml'. The default format is human readable."),
 				"add_to_infra":          c.FlagSet.String("add-to-infra", command.NilDefaultStr, colors.Green("(Optional)")+" The infrastructure to use to add this server to. If set to 'auto' will use the settings in the file instead."),
 				"return_id":             c.FlagSet.Bool("return-id", false, "(Optional) Will print the ID of the created object. Useful for automating tasks."),
 			}
@@ -227,7 +231,7 @@ interfaces:
 	switchInterface: Ethernet219
 ---
 datacenter: sonic-qts
-serialNumber: NNAACC3
+serialNumber: NNAACC4
 serverType: M.15.15.1
 label: testserv
 infrastructure: myinfra
@@ -239,6 +243,32 @@ interfaces:
 - mac: aa:bb:cc:dd:02:ff
 	switch: leaf-124
 	switchInterface: Ethernet221
+
+The records can also be read from a csv file with one server per row. By default the columns are named like the yaml fields
+and the interfaces are read from the mac1, switch1, switchInterface1, mac2, switch2, switchInterface2... columns.
+The rack, rackPositionLowerUnit and rackPositionUpperUnit columns set the rack metadata of the imported servers:
+
+serialNumber,datacenter,serverType,rack,rackPositionLowerUnit,rackPositionUpperUnit,mac1,switch1,switchInterface1
+NNAACC2,sonic-qts,M.15.15.1,R12,10,11,00:B0:D0:63:C2:26,leaf-124,Ethernet216
+
+A mapping file can be used for spreadsheets with different column names:
+
+serialNumber: Serial
+datacenter: DC
+serverType: Model
+rack: Rack
+rackPositionLowerUnit: U
+rackPositionUpperUnit: U
+interfaces:
+- mac: NIC1 MAC
+  switch: NIC1 Switch
+  switchInterface: NIC1 Port
+
+Every record is validated against the existing server types and switches before anything is imported. Records of existing
+servers update them, which allows swapping interfaces between servers in one batch. When onboarding new servers use -new-only
+to also reject serial numbers that already exist and serial numbers, MAC addresses or switch interfaces repeated in the file:
+
+$ metalcloud-cli server import-batch -format csv -file ./rack12.csv -mapping ./mapping.yaml -new-only -validate-only
 `,
 	},
 
@@ -272,7 +302,7 @@ interfaces:
 		FlagSet:      flag.NewFlagSet("server credentials", flag.ExitOnError),
 		InitFunc: func(c *command.Command) {
 			c.Arguments = map[string]interface{}{
-				"format":                 c.FlagSet.String("format", "json", "The input format. Supported values are 'json','yaml'. The only supported format is yaml."),
+				"format":                 c.FlagSet.String("format", "json", "The input format. Supported values are 'yaml','csv'. Files with the .csv extension are read as csv, otherwise yaml is used."),
 				"do-not-skip-duplicates": c.FlagSet.Bool("do-not-skip-duplicates", false, colors.Green("(Flag)")+" If set it will not skip the records found as duplicate and will instead throw an error"),
 				"read_config_from_file":  c.FlagSet.String("file", command.NilDefaultStr, colors.Red("(Required)")+" Read raw object from file"),
 			}
@@ -286,17 +316,25 @@ This command supports one or more credentials in a yaml file format. The file fo
 
 datacenter: sonic-qts
 serialNumber: NNAACC2
-BMCMACAddress: aa:vv:cc:dd:ee
+BMCMACAddress: aa:bb:cc:dd:ee:01
 username: root
 password: calvin
 ---
 datacenter: sonic-qts
 serialNumber: NNAACC3
-BMCMACAddress: aa:vv:cc:dd:eb
+BMCMACAddress: aa:bb:cc:dd:ee:02
 username: root
 password: notcalvin
 ---
 
+The csv format uses a header row with the same keys, as produced by ztp-credentials-export:
+
+datacenter,serialNumber,BMCMACAddress,username,password
+sonic-qts,NNAACC2,aa:bb:cc:dd:ee:01,root,calvin
+sonic-qts,NNAACC3,aa:bb:cc:dd:ee:02,root,notcalvin
+
+All records are validated before any of them is uploaded. Missing fields, invalid MAC addresses and serial numbers
+or MAC addresses duplicated within the file are reported and nothing is added.
 `,
 	},
 	{
@@ -317,6 +355,53 @@ password: notcalvin
 		Endpoint:            configuration.DeveloperEndpoint,
 		PermissionsRequired: []string{command.SERVERS_READ},
 	},
+	{
+		Description:  "Exports default BMC server credentials for the ZTP process as csv.",
+		Subject:      "server",
+		AltSubject:   "srv",
+		Predicate:    "ztp-credentials-export",
+		AltPredicate: "ztp-creds-export",
+		FlagSet:      flag.NewFlagSet("export server credentials", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"datacenter":       c.FlagSet.String("datacenter", command.NilDefaultStr, colors.Red("(Required)")+" The datacenter to export server ZTP credentials from"),
+				"file":             c.FlagSet.String("file", command.NilDefaultStr, colors.Green("(Optional)")+" The file to write to. The csv is written to stdout if not set."),
+				"show_credentials": c.FlagSet.Bool("show-credentials", false, colors.Green("(Flag)")+" If set the BMC passwords are exported as well. (Slow for large queries)"),
+			}
+		},
+		ExecuteFunc:         serverDefaultCredentialsExportCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.SERVERS_READ},
+		Example: `
+The output can be used as input for ztp-credentials-add-batch, for example to copy the credentials to another environment:
+
+metalcloud-cli server ztp-credentials-export --datacenter dc1 --show-credentials --file dc1-ztp.csv
+metalcloud-cli server ztp-credentials-add-batch --file dc1-ztp.csv
+`,
+	},
+	{
+		Description:  "Shows which discovered BMCs have no ZTP credentials and which credentials never matched a server.",
+		Subject:      "server",
+		AltSubject:   "srv",
+		Predicate:    "ztp-coverage",
+		AltPredicate: "ztp-credentials-coverage",
+		FlagSet:      flag.NewFlagSet("server ztp coverage", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"datacenter": c.FlagSet.String("datacenter", command.NilDefaultStr, colors.Red("(Required)")+" The datacenter to check"),
+				"show_all":   c.FlagSet.Bool("show-all", false, colors.Green("(Flag)")+" If set the servers and credentials that match are listed as well."),
+				"format":     c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
+			}
+		},
+		ExecuteFunc:         serverZTPCoverageCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.SERVERS_READ},
+		Example: `
+Servers are matched with the credential records by BMC MAC address and then by serial number.
+
+metalcloud-cli server ztp-coverage --datacenter dc1
+`,
+	},
 	{
 		Description:  "Remove default server BMC credentials for zero touch registration",
 		Subject:      "server",
@@ -416,6 +501,62 @@ password: notcalvin
 		Endpoint:            configuration.DeveloperEndpoint,
 		PermissionsRequired: []string{command.SERVERS_WRITE},
 	},
+	{
+		Description:  "Decommission servers.",
+		Subject:      "server",
+		AltSubject:   "srv",
+		Predicate:    "decommission",
+		AltPredicate: "retire",
+		FlagSet:      flag.NewFlagSet("decommission servers", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"server_ids":  c.FlagSet.String("id", command.NilDefaultStr, colors.Green("(Optional)")+" Server id or comma separated list of server ids. One of -id or -filter is required."),
+				"filter":      c.FlagSet.String("filter", command.NilDefaultStr, colors.Green("(Optional)")+" Filter selecting the servers, in the same format as server list. One of -id or -filter is required."),
+				"skip_ipmi":   c.FlagSet.Bool("skip-ipmi", false, colors.Green("(Flag)")+" If set the BMC of the servers is not contacted."),
+				"dry_run":     c.FlagSet.Bool("dry-run", false, colors.Green("(Flag)")+" If set only the safety checks and the impact summary are shown."),
+				"format":      c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
+				"autoconfirm": c.FlagSet.Bool("autoconfirm", false, colors.Green("(Flag)")+" If set it will assume action is confirmed"),
+			}
+		},
+		ExecuteFunc:         serverDecommissionCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.SERVERS_WRITE},
+		Example: `
+Servers are only decommissioned if none of them is allocated to an instance, has a running job or an operation in
+progress (such as cleaning or registering). Otherwise nothing is changed and the failed checks are listed.
+The servers are selected with one of -id or -filter. With the json, csv and yaml formats only the final report is
+printed, or the impact summary if nothing is changed.
+
+metalcloud-cli server decommission --id 100,101,102 --dry-run
+metalcloud-cli server decommission --filter "datacenter_name:dc1 server_status:defective"
+`,
+	},
+	{
+		Description:  "Delete servers.",
+		Subject:      "server",
+		AltSubject:   "srv",
+		Predicate:    "delete",
+		AltPredicate: "rm",
+		FlagSet:      flag.NewFlagSet("delete servers", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"server_ids":  c.FlagSet.String("id", command.NilDefaultStr, colors.Green("(Optional)")+" Server id or comma separated list of server ids. One of -id or -filter is required."),
+				"filter":      c.FlagSet.String("filter", command.NilDefaultStr, colors.Green("(Optional)")+" Filter selecting the servers, in the same format as server list. One of -id or -filter is required."),
+				"skip_ipmi":   c.FlagSet.Bool("skip-ipmi", false, colors.Green("(Flag)")+" If set the BMC of the servers is not contacted."),
+				"dry_run":     c.FlagSet.Bool("dry-run", false, colors.Green("(Flag)")+" If set only the safety checks and the impact summary are shown."),
+				"format":      c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
+				"autoconfirm": c.FlagSet.Bool("autoconfirm", false, colors.Green("(Flag)")+" If set it will assume action is confirmed"),
+			}
+		},
+		ExecuteFunc:         serverDeleteCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.SERVERS_WRITE},
+		Example: `
+The same safety checks as for server decommission are performed before any server is deleted.
+
+metalcloud-cli server delete --filter "server_status:decommissioned datacenter_name:dc1" --dry-run
+`,
+	},
 	{
 		Description:  "Reregister server",
 		Subject:      "server",
@@ -506,6 +647,111 @@ password: notcalvin
 		Endpoint:            configuration.DeveloperEndpoint,
 		PermissionsRequired: []string{command.SERVERS_READ},
 	},
+	{
+		Description:  "Compare the cabling of servers with a cabling plan.",
+		Subject:      "server",
+		AltSubject:   "srv",
+		Predicate:    "cabling-check",
+		AltPredicate: "cabling",
+		FlagSet:      flag.NewFlagSet("check server cabling", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"plan":        c.FlagSet.String("plan", command.NilDefaultStr, colors.Red("(Required)")+" The cabling plan file. Uses the same format as server import-batch."),
+				"plan_format": c.FlagSet.String("plan-format", command.NilDefaultStr, "The format of the plan. Supported values are 'yaml','csv'. Files with the .csv extension are read as csv, otherwise yaml is used."),
+				"mapping":     c.FlagSet.String("mapping", command.NilDefaultStr, colors.Green("(Optional)")+" Yaml file mapping the plan fields to the columns of the csv file. By default the columns are named like the yaml fields."),
+				"format":      c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
+			}
+		},
+		ExecuteFunc:         serverCablingCheckCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.SERVERS_READ},
+		Example: `
+The plan lists the switch and port every server NIC is expected to be cabled to. Both the yaml and csv formats of server import-batch are accepted:
+
+serialNumber,mac1,switch1,switchInterface1,mac2,switch2,switchInterface2
+NNAACC2,00:B0:D0:63:C2:26,leaf-124,Ethernet216,aa:bb:cc:dd:02:ff,leaf-125,Ethernet216
+
+$ metalcloud-cli server cabling-check -plan ./rack12.csv
+
+Every link is compared with the links reported by the servers and by the switches of the plan and reported as:
+  ok              the MAC was seen on the planned switch and port
+  missing         the MAC was not seen on any switch
+  swapped         the MAC was seen on another port of the planned switch and the planned port holds another NIC of the plan
+  wrong-port      the MAC was seen on another port of the planned switch
+  wrong-switch    the MAC was seen on another switch
+  unknown-server  the server is not registered and the MAC was not seen on any of the planned switches
+  unknown-switch  the planned switch does not exist
+  invalid-mac     the MAC of the plan is not a valid MAC address
+
+MAC addresses are compared in any notation, for example 00-B0-D0-63-C2-26 matches 00:b0:d0:63:c2:26.
+
+The command exits with an error if any link does not match the plan.
+`,
+	},
+	{
+		Description:  "Compare the hardware of servers with their server type.",
+		Subject:      "server",
+		AltSubject:   "srv",
+		Predicate:    "hardware-audit",
+		AltPredicate: "hw-audit",
+		FlagSet:      flag.NewFlagSet("audit server hardware", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"datacenter":  c.FlagSet.String("datacenter", command.NilDefaultStr, colors.Green("(Optional)")+" Only audit the servers of this datacenter."),
+				"server_type": c.FlagSet.String("server-type", command.NilDefaultStr, colors.Green("(Optional)")+" Only audit the servers of this server type."),
+				"show_all":    c.FlagSet.Bool("show-all", false, colors.Green("(Flag)")+" If set the servers matching their server type are also listed."),
+				"format":      c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
+			}
+		},
+		ExecuteFunc:         serverHardwareAuditCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.SERVERS_READ},
+		Example: `
+The RAM, CPUs, disks and NIC capacity of every server are compared with its server type. The components of every server
+are also compared with the ones of the other servers of the same type, which catches failed DIMMs, disks or NICs.
+For servers that do not match, a server type matching their actual hardware is suggested.
+
+metalcloud-cli server hardware-audit --datacenter dc1
+metalcloud-cli server hardware-audit --server-type M.40.256.2 --format csv
+`,
+	},
+	{
+		Description:  "Rotate the BMC credentials of servers.",
+		Subject:      "server",
+		AltSubject:   "srv",
+		Predicate:    "bmc-rotate",
+		AltPredicate: "rotate-ipmi",
+		FlagSet:      flag.NewFlagSet("rotate server BMC credentials", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"filter":            c.FlagSet.String("filter", command.NilDefaultStr, colors.Red("(Required)")+" Filter selecting the servers, in the same format as server list. Decommissioned servers are skipped."),
+				"generate_password": c.FlagSet.Bool("generate-password", false, colors.Red("(Required)")+" Generate a different strong password for every server."),
+				"length":            c.FlagSet.Int("length", 20, colors.Green("(Optional)")+" The length of the generated passwords."),
+				"store_as_secret":   c.FlagSet.Bool("store-as-secret", false, colors.Green("(Flag)")+" If set the new passwords are also stored as secrets named bmc-<server id>-<serial number>."),
+				"manifest":          c.FlagSet.String("manifest", command.NilDefaultStr, colors.Red("(Required)")+" The encrypted manifest holding the old and new credentials. Written when rotating, read when rolling back."),
+				"rollback":          c.FlagSet.Bool("rollback", false, colors.Green("(Flag)")+" If set the old credentials from the manifest are restored."),
+				"concurrency":       c.FlagSet.Int("concurrency", 5, colors.Green("(Optional)")+" The number of servers updated in parallel."),
+				"format":            c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
+				"autoconfirm":       c.FlagSet.Bool("autoconfirm", false, colors.Green("(Flag)")+" If set it will assume action is confirmed"),
+			}
+		},
+		ExecuteFunc:         serverBMCRotateCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.SERVERS_WRITE},
+		Example: `
+The new passwords are set on the BMC of every server and then verified by reading the server back. The manifest is
+encrypted with a passphrase read from the METALCLOUD_BMC_MANIFEST_PASSPHRASE environment variable or from the terminal
+and is written before any server is changed and again after every server.
+
+metalcloud-cli server bmc-rotate --filter "datacenter_name:dc1 server_status:available" --generate-password --manifest ./dc1-bmc.manifest
+metalcloud-cli server bmc-rotate --filter "id:10,11" --generate-password --length 24 --store-as-secret --manifest ./bmc.manifest
+
+To restore the old credentials of the servers that were changed and delete the secrets created with --store-as-secret.
+This also works for an interrupted rotation, servers not yet recorded as rotated are checked and restored if needed:
+
+metalcloud-cli server bmc-rotate --rollback --manifest ./dc1-bmc.manifest
+`,
+	},
 }
 
 func serverPowerControlCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
@@ -1464,6 +1710,11 @@ type ServerCreateUnmanagedInternal struct {
 	InfrastructureID    *int    `json:"infrastructure_id,omitempty" yaml:"infrastructureID,omitempty"`
 	UserEmail           *string `json:"user_email,omitempty" yaml:"userEmail,omitempty"`
 	UserID              *int    `json:"user_id,omitempty" yaml:"userID,omitempty"`
+
+	//rack metadata set after the import
+	RackName              *string `json:"server_rack_name,omitempty" yaml:"rack,omitempty"`
+	RackPositionLowerUnit *string `json:"server_rack_position_lower_unit,omitempty" yaml:"rackPositionLowerUnit,omitempty"`
+	RackPositionUpperUnit *string `json:"server_rack_position_upper_unit,omitempty" yaml:"rackPositionUpperUnit,omitempty"`
 }
 
 func serverImportCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
@@ -1737,27 +1988,52 @@ func serverImportBatchCmd(c *command.Command, client metalcloud.MetalCloudClient
 		return "", fmt.Errorf("-file is required")
 	}
 
-	records, err := getMultipleServerCreateUnmanagedInternalFromYamlFile(filePath)
-	if err != nil {
-		return "", err
+	rows := []serverImportRow{}
+
+	if isCSVInputFile(command.GetStringParam(c.Arguments["format"]), filePath) {
+		var err error
+		rows, err = getServerImportRowsFromCSVFile(filePath, command.GetStringParam(c.Arguments["mapping"]))
+		if err != nil {
+			return "", err
+		}
+	} else {
+		records, err := getMultipleServerCreateUnmanagedInternalFromYamlFile(filePath)
+		if err != nil {
+			return "", err
+		}
+
+		for i, r := range records {
+			rows = append(rows, serverImportRow{
+				Row:    i + 1,
+				Record: r,
+			})
+		}
 	}
 
-	//set server type ids if they are set as labels
-	for i, r := range records {
+	//validate all records before importing any of them. This also sets the server type ids if they are set as labels
+	failed := validateServerImportRows(rows, command.GetBoolParam(c.Arguments["new_only"]), client)
 
-		if r.ServerTypeLabel != nil {
-			serverType, err := client.ServerTypeGetByLabel(*r.ServerTypeLabel)
-			if err != nil {
-				return "", err
-			}
-			records[i].ServerCreateUnmanaged.ServerTypeID = serverType.ServerTypeID
+	reportFormat := command.GetStringParam(c.Arguments["report_format"])
+
+	if failed > 0 {
+		report, err := renderServerImportReport(rows, fmt.Sprintf("%d of %d records failed validation", failed, len(rows)), reportFormat)
+		if err != nil {
+			return "", err
 		}
+
+		fmt.Fprint(configuration.GetStdout(), report)
+
+		return "", fmt.Errorf("%d of %d records failed validation. Nothing was imported", failed, len(rows))
+	}
+
+	if command.GetBoolParam(c.Arguments["validate_only"]) {
+		return renderServerImportReport(rows, fmt.Sprintf("All %d records are valid", len(rows)), reportFormat)
 	}
 
 	//perform a batch update. This helps perform interface swaps in one go
 	embeddedObjects := []metalcloud.ServerCreateUnmanaged{}
-	for _, o := range records {
-		embeddedObjects = append(embeddedObjects, o.ServerCreateUnmanaged)
+	for _, r := range rows {
+		embeddedObjects = append(embeddedObjects, r.Record.ServerCreateUnmanaged)
 	}
 
 	createdServerRecords, err := client.ServerUnmanagedImportBatch(embeddedObjects)
@@ -1765,21 +2041,38 @@ func serverImportBatchCmd(c *command.Command, client metalcloud.MetalCloudClient
 		return "", err
 	}
 
-	if v, ok := command.GetStringParamOk(c.Arguments["add_to_infra"]); ok {
-		for _, record := range records {
-
-			//because the order might have changed
-			//find the server creation object in records for the
-			//returned object for the same serial number
-			serverID := 0
-			for _, cr := range *createdServerRecords {
-				if strings.ToLower(cr.ServerSerialNumber) == strings.ToLower(record.ServerCreateUnmanaged.ServerSerialNumber) {
-					serverID = cr.ServerID
-					break
-				}
+	//because the order might have changed
+	//find the server creation object in records for the
+	//returned object for the same serial number
+	for i, row := range rows {
+		for _, cr := range *createdServerRecords {
+			if strings.ToLower(cr.ServerSerialNumber) == strings.ToLower(row.Record.ServerCreateUnmanaged.ServerSerialNumber) {
+				rows[i].ServerID = cr.ServerID
+				break
 			}
-			if serverID != 0 {
-				_, err := addServerToInfrastructure(serverID, &v, &record, client)
+		}
+	}
+
+	for _, row := range rows {
+		if row.ServerID == 0 || (row.Record.RackName == nil && row.Record.RackPositionLowerUnit == nil && row.Record.RackPositionUpperUnit == nil) {
+			continue
+		}
+
+		_, err := client.ServerEditRack(row.ServerID, metalcloud.ServerEditRack{
+			ServerRackName:              row.Record.RackName,
+			ServerRackPositionLowerUnit: row.Record.RackPositionLowerUnit,
+			ServerRackPositionUpperUnit: row.Record.RackPositionUpperUnit,
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if v, ok := command.GetStringParamOk(c.Arguments["add_to_infra"]); ok {
+		for _, row := range rows {
+			if row.ServerID != 0 {
+				record := row.Record
+				_, err := addServerToInfrastructure(row.ServerID, &v, &record, client)
 				if err != nil {
 					return "", err
 				}
@@ -1797,7 +2090,7 @@ func serverImportBatchCmd(c *command.Command, client metalcloud.MetalCloudClient
 		return s.String(), nil
 	}
 
-	return "", err
+	return renderServerImportReport(rows, fmt.Sprintf("%d records imported", len(*createdServerRecords)), reportFormat)
 }
 
 func serverDefaultCredentialsAddBatchCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
@@ -1807,11 +2100,22 @@ func serverDefaultCredentialsAddBatchCmd(c *command.Command, client metalcloud.M
 		return "", fmt.Errorf("-file is required")
 	}
 
-	records, err := getMultipleServerDefaultCredentialsFromYamlFile(filePath)
+	var records []metalcloud.ServerDefaultCredentials
+	var err error
+	if isCSVInputFile(command.GetStringParam(c.Arguments["format"]), filePath) {
+		records, err = getServerDefaultCredentialsFromCSVFile(filePath)
+	} else {
+		records, err = getMultipleServerDefaultCredentialsFromYamlFile(filePath)
+	}
 	if err != nil {
 		return "", err
 	}
 
+	//nothing is uploaded if any of the records is invalid
+	if problems := validateServerDefaultCredentials(records); len(problems) > 0 {
+		return "", fmt.Errorf("%d problems found in %s:\n%s", len(problems), filePath, strings.Join(problems, "\n"))
+	}
+
 	//check for duplicated mac or SNs as the server side
 	//handling throws a very ugly error
 	creds, err := getAllZTPServerRecords(client)
@@ -1837,7 +2141,7 @@ func serverDefaultCredentialsAddBatchCmd(c *command.Command, client metalcloud.M
 				break
 			}
 
-			if cred.ServerBMCMACAddress == record.ServerBMCMACAddress {
+			if sameMACAddress(cred.ServerBMCMACAddress, record.ServerBMCMACAddress) {
 				errorString := fmt.Sprintf("Duplicate mac address %s found", record.ServerBMCMACAddress)
 
 				if command.GetBoolParam(c.Arguments["do-not-skip-duplicates"]) {
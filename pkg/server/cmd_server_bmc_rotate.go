@@ -0,0 +1,661 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	"github.com/metalsoft-io/metalcloud-cli/internal/filtering"
+	"github.com/metalsoft-io/tableformatter"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	bmcManifestPassphraseEnv = "METALCLOUD_BMC_MANIFEST_PASSPHRASE"
+	bmcManifestMagic         = "MCBMC1"
+
+	bmcStatusPending  = "pending"
+	bmcStatusRotated  = "rotated"
+	bmcStatusFailed   = "failed"
+	bmcStatusRestored = "restored"
+)
+
+// bmcPasswordAlphabet leaves out characters that some BMCs reject or that need escaping in ipmitool
+const bmcPasswordAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz23456789-_.+="
+
+// bmcManifestEntry holds the old and new credentials of a server so that a rotation can be rolled back
+type bmcManifestEntry struct {
+	ServerID     int    `json:"server_id"`
+	SerialNumber string `json:"serial_number"`
+	IPMIHost     string `json:"ipmi_host"`
+	Username     string `json:"username"`
+	OldPassword  string `json:"old_password"`
+	NewPassword  string `json:"new_password"`
+	SecretID     int    `json:"secret_id,omitempty"`
+	Status       string `json:"status"`
+	Details      string `json:"details,omitempty"`
+}
+
+// bmcManifest is written encrypted on disk by server bmc-rotate
+type bmcManifest struct {
+	CreatedTimestamp string             `json:"created_timestamp"`
+	Filter           string             `json:"filter"`
+	StoreAsSecret    bool               `json:"store_as_secret,omitempty"`
+	Entries          []bmcManifestEntry `json:"entries"`
+}
+
+// bmcManifestFile writes a manifest again every time one of its entries changes, so that the manifest on disk
+// always reflects the servers changed so far, even if the process is interrupted
+type bmcManifestFile struct {
+	path     string
+	salt     []byte
+	key      []byte
+	manifest *bmcManifest
+	err      error
+	lock     sync.Mutex
+}
+
+func serverBMCRotateCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	manifestPath, ok := command.GetStringParamOk(c.Arguments["manifest"])
+	if !ok {
+		return "", fmt.Errorf("-manifest is required")
+	}
+
+	concurrency := 5
+	if v, ok := command.GetIntParamOk(c.Arguments["concurrency"]); ok {
+		if v < 1 {
+			return "", fmt.Errorf("-concurrency must be at least 1")
+		}
+		concurrency = v
+	}
+
+	if command.GetBoolParam(c.Arguments["rollback"]) {
+		return serverBMCRollback(c, manifestPath, concurrency, client)
+	}
+
+	filter, ok := command.GetStringParamOk(c.Arguments["filter"])
+	if !ok {
+		return "", fmt.Errorf("-filter is required")
+	}
+
+	if !command.GetBoolParam(c.Arguments["generate_password"]) {
+		return "", fmt.Errorf("-generate-password is required, passwords are always generated per server")
+	}
+
+	length := 20
+	if v, ok := command.GetIntParamOk(c.Arguments["length"]); ok {
+		if v < 12 {
+			return "", fmt.Errorf("-length must be at least 12")
+		}
+		length = v
+	}
+
+	if _, err := os.Stat(manifestPath); err == nil {
+		return "", fmt.Errorf("manifest %s already exists, it would be overwritten", manifestPath)
+	}
+
+	list, err := client.ServersSearch(filtering.ConvertToSearchFieldFormat(filter))
+	if err != nil {
+		return "", err
+	}
+
+	servers := []metalcloud.ServerSearchResult{}
+	for _, s := range *list {
+		if s.ServerStatus != "decommissioned" {
+			servers = append(servers, s)
+		}
+	}
+
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].ServerID < servers[j].ServerID
+	})
+
+	if len(servers) == 0 {
+		return "", fmt.Errorf("no servers match the filter %s", filter)
+	}
+
+	confirm, err := command.ConfirmCommand(c, func() string {
+
+		confirmationMessage := fmt.Sprintf("Rotating the BMC credentials of %s servers. Are you sure? Type \"yes\" to continue:",
+			colors.Red(fmt.Sprintf("%d", len(servers))),
+		)
+
+		//this is simply so that we don't output a text on the command line under go test
+		if strings.HasSuffix(os.Args[0], ".test") {
+			confirmationMessage = ""
+		}
+
+		return confirmationMessage
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if !confirm {
+		return "", fmt.Errorf("operation not confirmed. Aborting")
+	}
+
+	passphrase, err := getBMCManifestPassphrase()
+	if err != nil {
+		return "", err
+	}
+
+	storeAsSecret := command.GetBoolParam(c.Arguments["store_as_secret"])
+
+	manifest := bmcManifest{
+		CreatedTimestamp: time.Now().Format(time.RFC3339),
+		Filter:           filter,
+		StoreAsSecret:    storeAsSecret,
+	}
+
+	for _, s := range servers {
+		server, err := client.ServerGet(s.ServerID, true)
+		if err != nil {
+			return "", err
+		}
+
+		password, err := generateBMCPassword(length)
+		if err != nil {
+			return "", err
+		}
+
+		manifest.Entries = append(manifest.Entries, bmcManifestEntry{
+			ServerID:     server.ServerID,
+			SerialNumber: server.ServerSerialNumber,
+			IPMIHost:     server.ServerIPMIHost,
+			Username:     server.ServerIPMInternalUsername,
+			OldPassword:  server.ServerIPMInternalPassword,
+			NewPassword:  password,
+			Status:       bmcStatusPending,
+		})
+	}
+
+	mf, err := newBMCManifestFile(manifestPath, &manifest, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	//the manifest is written before anything is changed and again after every server so that an interrupted rotation can be rolled back
+	if err := mf.update(func() {}); err != nil {
+		return "", err
+	}
+
+	runBMCEntries(manifest.Entries, concurrency, func(entry *bmcManifestEntry) {
+		if mf.failed() {
+			return
+		}
+
+		if err := setBMCPassword(entry.ServerID, entry.NewPassword, client); err != nil {
+			mf.update(func() {
+				entry.Status = bmcStatusFailed
+				entry.Details = err.Error()
+			})
+			return
+		}
+
+		if mf.update(func() { entry.Status = bmcStatusRotated }) != nil || !storeAsSecret {
+			return
+		}
+
+		secret, err := client.SecretCreate(metalcloud.Secret{
+			SecretName:   bmcSecretName(*entry),
+			SecretUsage:  "bmc",
+			SecretBase64: base64.StdEncoding.EncodeToString([]byte(entry.NewPassword)),
+		})
+
+		mf.update(func() {
+			if err != nil {
+				entry.Details = fmt.Sprintf("could not store the password as secret: %v", err)
+				return
+			}
+			entry.SecretID = secret.SecretID
+		})
+	})
+
+	if mf.err != nil {
+		return "", fmt.Errorf("rotation stopped, could not write the manifest %s: %v. Servers already rotated are recorded in the manifest up to the failed write", manifestPath, mf.err)
+	}
+
+	return renderBMCReport(manifest.Entries, bmcStatusRotated, fmt.Sprintf("BMC credentials rotated. Manifest written to %s", manifestPath), c)
+}
+
+// serverBMCRollback restores the old credentials from a manifest and deletes the secrets holding the new passwords.
+// Servers that are still pending or that failed are checked, as an interrupted or failed rotation might have changed them.
+func serverBMCRollback(c *command.Command, manifestPath string, concurrency int, client metalcloud.MetalCloudClient) (string, error) {
+
+	passphrase, err := getBMCManifestPassphrase()
+	if err != nil {
+		return "", err
+	}
+
+	manifest, err := readBMCManifest(manifestPath, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	confirm, err := command.ConfirmCommand(c, func() string {
+
+		confirmationMessage := fmt.Sprintf("Restoring the BMC credentials of %s servers from the rotation of %s. Are you sure? Type \"yes\" to continue:",
+			colors.Red(fmt.Sprintf("%d", len(manifest.Entries))),
+			manifest.CreatedTimestamp,
+		)
+
+		//this is simply so that we don't output a text on the command line under go test
+		if strings.HasSuffix(os.Args[0], ".test") {
+			confirmationMessage = ""
+		}
+
+		return confirmationMessage
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if !confirm {
+		return "", fmt.Errorf("operation not confirmed. Aborting")
+	}
+
+	//the secret id is not in the manifest if the rotation was interrupted right after the secret was created
+	secretIDs := map[string]int{}
+	if manifest.StoreAsSecret {
+		secrets, err := client.Secrets("bmc")
+		if err != nil {
+			return "", err
+		}
+		for _, secret := range *secrets {
+			secretIDs[secret.SecretName] = secret.SecretID
+		}
+	}
+
+	mf, err := newBMCManifestFile(manifestPath, manifest, passphrase)
+	if err != nil {
+		return "", err
+	}
+
+	runBMCEntries(manifest.Entries, concurrency, func(entry *bmcManifestEntry) {
+		if mf.failed() {
+			return
+		}
+
+		if entry.Status != bmcStatusRestored {
+			changed := true
+
+			if entry.Status == bmcStatusPending {
+				server, err := client.ServerGet(entry.ServerID, true)
+				if err != nil {
+					mf.update(func() {
+						entry.Status = bmcStatusFailed
+						entry.Details = err.Error()
+					})
+					return
+				}
+				changed = server.ServerIPMInternalPassword != entry.OldPassword
+			}
+
+			//servers that were never changed are left alone
+			if !changed {
+				mf.update(func() { entry.Details = "the password was never changed" })
+				return
+			}
+
+			if err := setBMCPassword(entry.ServerID, entry.OldPassword, client); err != nil {
+				mf.update(func() {
+					entry.Status = bmcStatusFailed
+					entry.Details = err.Error()
+				})
+				return
+			}
+
+			if mf.update(func() {
+				entry.Status = bmcStatusRestored
+				entry.Details = ""
+			}) != nil {
+				return
+			}
+		}
+
+		secretID := entry.SecretID
+		if secretID == 0 {
+			secretID = secretIDs[bmcSecretName(*entry)]
+		}
+
+		if secretID == 0 {
+			return
+		}
+
+		err := client.SecretDelete(secretID)
+
+		mf.update(func() {
+			if err != nil {
+				entry.SecretID = secretID
+				entry.Details = fmt.Sprintf("could not delete secret #%d: %v", secretID, err)
+				return
+			}
+			entry.SecretID = 0
+		})
+	})
+
+	if mf.err != nil {
+		return "", fmt.Errorf("rollback stopped, could not write the manifest %s: %v", manifestPath, mf.err)
+	}
+
+	return renderBMCReport(manifest.Entries, bmcStatusRestored, "BMC credentials restored", c)
+}
+
+// runBMCEntries calls f for every entry using at most concurrency goroutines
+func runBMCEntries(entries []bmcManifestEntry, concurrency int, f func(entry *bmcManifestEntry)) {
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+
+	for i := range entries {
+		wg.Add(1)
+		slots <- struct{}{}
+
+		go func(entry *bmcManifestEntry) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			f(entry)
+		}(&entries[i])
+	}
+
+	wg.Wait()
+}
+
+// setBMCPassword changes the password on the BMC and verifies that it was recorded
+func setBMCPassword(serverID int, password string, client metalcloud.MetalCloudClient) error {
+	server, err := client.ServerGet(serverID, true)
+	if err != nil {
+		return err
+	}
+
+	newServer := *server
+	newServer.ServerIPMInternalPassword = password
+
+	if _, err := client.ServerEditIPMI(serverID, newServer, true); err != nil {
+		return err
+	}
+
+	server, err = client.ServerGet(serverID, true)
+	if err != nil {
+		return fmt.Errorf("could not verify the new password: %v", err)
+	}
+
+	if server.ServerIPMInternalPassword != password {
+		return fmt.Errorf("verification failed, the server does not report the new password")
+	}
+
+	return nil
+}
+
+func bmcSecretName(entry bmcManifestEntry) string {
+	return fmt.Sprintf("bmc-%d-%s", entry.ServerID, entry.SerialNumber)
+}
+
+func generateBMCPassword(length int) (string, error) {
+	classes := []string{"ABCDEFGHJKLMNPQRSTUVWXYZ", "abcdefghijkmnopqrstuvwxyz", "23456789", "-_.+="}
+
+	for {
+		password := make([]byte, length)
+		for i := range password {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(bmcPasswordAlphabet))))
+			if err != nil {
+				return "", err
+			}
+			password[i] = bmcPasswordAlphabet[n.Int64()]
+		}
+
+		//most BMC password policies require every character class
+		complete := true
+		for _, class := range classes {
+			if !strings.ContainsAny(string(password), class) {
+				complete = false
+			}
+		}
+
+		if complete {
+			return string(password), nil
+		}
+	}
+}
+
+func getBMCManifestPassphrase() (string, error) {
+	if v := os.Getenv(bmcManifestPassphraseEnv); v != "" {
+		return v, nil
+	}
+
+	content, err := command.RequestInputSilent("Manifest passphrase:")
+	if err != nil {
+		return "", fmt.Errorf("could not read the manifest passphrase, set %s instead: %v", bmcManifestPassphraseEnv, err)
+	}
+	fmt.Fprintln(configuration.GetStdout())
+
+	if len(content) == 0 {
+		return "", fmt.Errorf("the manifest passphrase cannot be empty")
+	}
+
+	return string(content), nil
+}
+
+func bmcManifestKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+// newBMCManifestFile derives the key of the manifest once, the key derivation is too slow to repeat on every write
+func newBMCManifestFile(path string, manifest *bmcManifest, passphrase string) (*bmcManifestFile, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	key, err := bmcManifestKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bmcManifestFile{
+		path:     path,
+		salt:     salt,
+		key:      key,
+		manifest: manifest,
+	}, nil
+}
+
+// update applies a change to the entries of the manifest and writes it. After a failed write the change is still applied
+// but nothing is written anymore and the error is returned for every later update.
+func (f *bmcManifestFile) update(change func()) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	change()
+
+	if f.err == nil {
+		f.err = writeBMCManifest(f.path, *f.manifest, f.salt, f.key)
+	}
+
+	return f.err
+}
+
+func (f *bmcManifestFile) failed() bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.err != nil
+}
+
+// writeBMCManifest encrypts the manifest with AES-256-GCM using a key derived from the passphrase.
+// The file is replaced atomically so that an interrupted write does not leave a corrupted manifest.
+func writeBMCManifest(path string, manifest bmcManifest, salt []byte, key []byte) error {
+	plaintext, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	content := []byte(bmcManifestMagic)
+	content = append(content, salt...)
+	content = append(content, nonce...)
+	content = gcm.Seal(content, nonce, plaintext, []byte(bmcManifestMagic))
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, path)
+}
+
+func readBMCManifest(path string, passphrase string) (*bmcManifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(string(content), bmcManifestMagic) || len(content) < len(bmcManifestMagic)+16 {
+		return nil, fmt.Errorf("%s is not a BMC rotation manifest", path)
+	}
+
+	content = content[len(bmcManifestMagic):]
+	salt := content[:16]
+	content = content[16:]
+
+	key, err := bmcManifestKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%s is not a BMC rotation manifest", path)
+	}
+
+	plaintext, err := gcm.Open(nil, content[:gcm.NonceSize()], content[gcm.NonceSize():], []byte(bmcManifestMagic))
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt %s, the passphrase might be wrong", path)
+	}
+
+	var manifest bmcManifest
+	if err := json.Unmarshal(plaintext, &manifest); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+// renderBMCReport renders the result per server. If any server failed the report is printed and an error is returned.
+func renderBMCReport(entries []bmcManifestEntry, expectedStatus string, title string, c *command.Command) (string, error) {
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "ID",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "SERIAL_NUMBER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "IPMI_HOST",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "SECRET_ID",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "STATUS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "DETAILS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 40,
+		},
+	}
+
+	failed := 0
+	data := [][]interface{}{}
+	for _, e := range entries {
+		status := colors.Green(e.Status)
+		if e.Status == bmcStatusFailed {
+			status = colors.Red(e.Status)
+			failed++
+		} else if e.Status != expectedStatus {
+			status = colors.Yellow(e.Status)
+		}
+
+		data = append(data, []interface{}{
+			e.ServerID,
+			e.SerialNumber,
+			e.IPMIHost,
+			e.SecretID,
+			status,
+			e.Details,
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	if failed > 0 {
+		title = fmt.Sprintf("%d of %d servers failed", failed, len(entries))
+	}
+
+	report, err := table.RenderTable("Servers", title, command.GetStringParam(c.Arguments["format"]))
+	if err != nil {
+		return "", err
+	}
+
+	if failed > 0 {
+		fmt.Fprint(configuration.GetStdout(), report)
+		return "", fmt.Errorf("%d of %d servers failed, see the manifest for the credentials of every server", failed, len(entries))
+	}
+
+	return report, nil
+}
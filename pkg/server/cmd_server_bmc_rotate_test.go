@@ -0,0 +1,337 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	. "github.com/onsi/gomega"
+)
+
+func TestServerBMCRotateCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	os.Setenv(bmcManifestPassphraseEnv, "test-passphrase")
+	defer os.Unsetenv(bmcManifestPassphraseEnv)
+
+	manifestPath := filepath.Join(t.TempDir(), "bmc.manifest")
+
+	var lock sync.Mutex
+	passwords := map[int]string{10: "old-10", 11: "old-11"}
+
+	client.EXPECT().
+		ServersSearch("+datacenter_name:dc1").
+		Return(&[]metalcloud.ServerSearchResult{
+			{ServerID: 10, ServerStatus: "available"},
+			{ServerID: 11, ServerStatus: "used"},
+			{ServerID: 12, ServerStatus: "decommissioned"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServerGet(gomock.Any(), true).
+		DoAndReturn(func(serverID int, decryptPasswd bool) (*metalcloud.Server, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			return &metalcloud.Server{
+				ServerID:                  serverID,
+				ServerSerialNumber:        fmt.Sprintf("SN%d", serverID),
+				ServerIPMInternalUsername: "admin",
+				ServerIPMInternalPassword: passwords[serverID],
+			}, nil
+		}).
+		AnyTimes()
+
+	client.EXPECT().
+		ServerEditIPMI(gomock.Any(), gomock.Any(), true).
+		DoAndReturn(func(serverID int, server metalcloud.Server, updateInBMC bool) (*metalcloud.Server, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			passwords[serverID] = server.ServerIPMInternalPassword
+			return &server, nil
+		}).
+		Times(2)
+
+	client.EXPECT().
+		SecretCreate(gomock.Any()).
+		DoAndReturn(func(secret metalcloud.Secret) (*metalcloud.Secret, error) {
+			Expect(secret.SecretName).To(HavePrefix("bmc-1"))
+			secret.SecretID = 100
+			if secret.SecretName == "bmc-11-SN11" {
+				secret.SecretID = 101
+			}
+			return &secret, nil
+		}).
+		Times(2)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"filter":            "datacenter_name:dc1",
+		"generate_password": true,
+		"length":            16,
+		"store_as_secret":   true,
+		"manifest":          manifestPath,
+		"format":            "csv",
+		"autoconfirm":       true,
+	})
+
+	ret, err := serverBMCRotateCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("10,SN10,,100,rotated,"))
+	Expect(ret).To(ContainSubstring("11,SN11,,101,rotated,"))
+
+	Expect(passwords[10]).To(HaveLen(16))
+	Expect(passwords[10]).NotTo(Equal(passwords[11]))
+
+	// the manifest is encrypted
+	content, err := os.ReadFile(manifestPath)
+	Expect(err).To(BeNil())
+	Expect(string(content)).NotTo(ContainSubstring("old-10"))
+
+	manifest, err := readBMCManifest(manifestPath, "test-passphrase")
+	Expect(err).To(BeNil())
+	Expect(manifest.Entries).To(HaveLen(2))
+	Expect(manifest.Entries[0].OldPassword).To(Equal("old-10"))
+	Expect(manifest.Entries[0].NewPassword).To(Equal(passwords[10]))
+
+	_, err = readBMCManifest(manifestPath, "wrong")
+	Expect(err).NotTo(BeNil())
+
+	// an existing manifest is never overwritten
+	_, err = serverBMCRotateCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+
+	client.EXPECT().
+		ServerEditIPMI(gomock.Any(), gomock.Any(), true).
+		DoAndReturn(func(serverID int, server metalcloud.Server, updateInBMC bool) (*metalcloud.Server, error) {
+			lock.Lock()
+			defer lock.Unlock()
+			passwords[serverID] = server.ServerIPMInternalPassword
+			return &server, nil
+		}).
+		Times(2)
+
+	client.EXPECT().
+		Secrets("bmc").
+		Return(&map[string]metalcloud.Secret{}, nil).
+		Times(1)
+
+	// the secrets holding the rotated passwords are removed
+	client.EXPECT().
+		SecretDelete(100).
+		Return(nil).
+		Times(1)
+
+	client.EXPECT().
+		SecretDelete(101).
+		Return(nil).
+		Times(1)
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"manifest":    manifestPath,
+		"rollback":    true,
+		"format":      "csv",
+		"autoconfirm": true,
+	})
+
+	ret, err = serverBMCRotateCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("10,SN10,,0,restored,"))
+	Expect(ret).To(ContainSubstring("11,SN11,,0,restored,"))
+	Expect(passwords).To(Equal(map[int]string{10: "old-10", 11: "old-11"}))
+
+	manifest, err = readBMCManifest(manifestPath, "test-passphrase")
+	Expect(err).To(BeNil())
+	Expect(manifest.Entries[0].SecretID).To(Equal(0))
+}
+
+func TestServerBMCRotateCmdWritesManifestPerServer(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	os.Setenv(bmcManifestPassphraseEnv, "test-passphrase")
+	defer os.Unsetenv(bmcManifestPassphraseEnv)
+
+	manifestPath := filepath.Join(t.TempDir(), "bmc.manifest")
+
+	passwords := map[int]string{10: "old-10", 11: "old-11"}
+
+	client.EXPECT().
+		ServersSearch(gomock.Any()).
+		Return(&[]metalcloud.ServerSearchResult{{ServerID: 10}, {ServerID: 11}}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServerGet(gomock.Any(), true).
+		DoAndReturn(func(serverID int, decryptPasswd bool) (*metalcloud.Server, error) {
+			return &metalcloud.Server{
+				ServerID:                  serverID,
+				ServerSerialNumber:        fmt.Sprintf("SN%d", serverID),
+				ServerIPMInternalPassword: passwords[serverID],
+			}, nil
+		}).
+		AnyTimes()
+
+	client.EXPECT().
+		ServerEditIPMI(gomock.Any(), gomock.Any(), true).
+		DoAndReturn(func(serverID int, server metalcloud.Server, updateInBMC bool) (*metalcloud.Server, error) {
+			// when the second server is changed the manifest already records the first one
+			if serverID == 11 {
+				manifest, err := readBMCManifest(manifestPath, "test-passphrase")
+				Expect(err).To(BeNil())
+				Expect(manifest.Entries[0].Status).To(Equal(bmcStatusRotated))
+				Expect(manifest.Entries[1].Status).To(Equal(bmcStatusPending))
+			}
+			passwords[serverID] = server.ServerIPMInternalPassword
+			return &server, nil
+		}).
+		Times(2)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"filter":            "datacenter_name:dc1",
+		"generate_password": true,
+		"manifest":          manifestPath,
+		"concurrency":       1,
+		"format":            "csv",
+		"autoconfirm":       true,
+	})
+
+	_, err := serverBMCRotateCmd(&cmd, client)
+	Expect(err).To(BeNil())
+}
+
+func TestServerBMCRotateCmdRollbackInterrupted(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	os.Setenv(bmcManifestPassphraseEnv, "test-passphrase")
+	defer os.Unsetenv(bmcManifestPassphraseEnv)
+
+	manifestPath := filepath.Join(t.TempDir(), "bmc.manifest")
+
+	// the rotation was interrupted after the password of server 10 was changed and its secret created,
+	// but before the manifest recorded it. Server 11 was never changed.
+	manifest := bmcManifest{
+		StoreAsSecret: true,
+		Entries: []bmcManifestEntry{
+			{ServerID: 10, SerialNumber: "SN10", OldPassword: "old-10", NewPassword: "new-10", Status: bmcStatusPending},
+			{ServerID: 11, SerialNumber: "SN11", OldPassword: "old-11", NewPassword: "new-11", Status: bmcStatusPending},
+		},
+	}
+
+	mf, err := newBMCManifestFile(manifestPath, &manifest, "test-passphrase")
+	Expect(err).To(BeNil())
+	Expect(mf.update(func() {})).To(BeNil())
+
+	passwords := map[int]string{10: "new-10", 11: "old-11"}
+
+	client.EXPECT().
+		ServerGet(gomock.Any(), true).
+		DoAndReturn(func(serverID int, decryptPasswd bool) (*metalcloud.Server, error) {
+			return &metalcloud.Server{ServerID: serverID, ServerIPMInternalPassword: passwords[serverID]}, nil
+		}).
+		AnyTimes()
+
+	client.EXPECT().
+		ServerEditIPMI(10, gomock.Any(), true).
+		DoAndReturn(func(serverID int, server metalcloud.Server, updateInBMC bool) (*metalcloud.Server, error) {
+			passwords[serverID] = server.ServerIPMInternalPassword
+			return &server, nil
+		}).
+		Times(1)
+
+	client.EXPECT().
+		Secrets("bmc").
+		Return(&map[string]metalcloud.Secret{
+			"bmc-10-SN10": {SecretID: 200, SecretName: "bmc-10-SN10"},
+			"other":       {SecretID: 300, SecretName: "other"},
+		}, nil).
+		Times(1)
+
+	client.EXPECT().
+		SecretDelete(200).
+		Return(nil).
+		Times(1)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"manifest":    manifestPath,
+		"rollback":    true,
+		"concurrency": 1,
+		"format":      "csv",
+		"autoconfirm": true,
+	})
+
+	ret, err := serverBMCRotateCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("10,SN10,,0,restored,"))
+	Expect(ret).To(ContainSubstring("11,SN11,,0,pending,the password was never changed"))
+	Expect(passwords).To(Equal(map[int]string{10: "old-10", 11: "old-11"}))
+}
+
+func TestServerBMCRotateCmdReportsFailures(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	os.Setenv(bmcManifestPassphraseEnv, "test-passphrase")
+	defer os.Unsetenv(bmcManifestPassphraseEnv)
+
+	manifestPath := filepath.Join(t.TempDir(), "bmc.manifest")
+
+	client.EXPECT().
+		ServersSearch(gomock.Any()).
+		Return(&[]metalcloud.ServerSearchResult{{ServerID: 10}}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServerGet(10, true).
+		Return(&metalcloud.Server{ServerID: 10, ServerSerialNumber: "SN10", ServerIPMInternalPassword: "old"}, nil).
+		AnyTimes()
+
+	// the BMC accepts the call but the password is not changed
+	client.EXPECT().
+		ServerEditIPMI(10, gomock.Any(), true).
+		Return(nil, nil).
+		Times(1)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(strings.NewReader(""), &stdout)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"filter":            "id:10",
+		"generate_password": true,
+		"manifest":          manifestPath,
+		"format":            "csv",
+		"autoconfirm":       true,
+	})
+
+	_, err := serverBMCRotateCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("1 of 1 servers failed"))
+	Expect(stdout.String()).To(ContainSubstring("10,SN10,,0,failed,\"verification failed"))
+
+	manifest, err := readBMCManifest(manifestPath, "test-passphrase")
+	Expect(err).To(BeNil())
+	Expect(manifest.Entries[0].Status).To(Equal(bmcStatusFailed))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"filter":      "id:10",
+		"manifest":    manifestPath + ".2",
+		"autoconfirm": true,
+	})
+
+	_, err = serverBMCRotateCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+}
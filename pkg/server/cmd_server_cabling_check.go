@@ -0,0 +1,337 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	"github.com/metalsoft-io/tableformatter"
+)
+
+const (
+	cablingStatusOK            = "ok"
+	cablingStatusMissing       = "missing"
+	cablingStatusSwapped       = "swapped"
+	cablingStatusWrongPort     = "wrong-port"
+	cablingStatusWrongSwitch   = "wrong-switch"
+	cablingStatusUnknownServer = "unknown-server"
+	cablingStatusUnknownSwitch = "unknown-switch"
+	cablingStatusInvalidMAC    = "invalid-mac"
+)
+
+// cablingLink is an expected link from the plan together with the link that was actually found
+type cablingLink struct {
+	Row                   int
+	ServerSerialNumber    string
+	MAC                   string
+	ExpectedSwitch        string
+	ExpectedInterface     string
+	ActualSwitch          string
+	ActualInterface       string
+	ServerID              int
+	Status                string
+	Details               string
+	actualSwitchInterface *metalcloud.SwitchInterfaceSearchResult
+}
+
+func serverCablingCheckCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	planPath, ok := command.GetStringParamOk(c.Arguments["plan"])
+	if !ok {
+		return "", fmt.Errorf("-plan is required")
+	}
+
+	rows := []serverImportRow{}
+
+	if isCSVInputFile(command.GetStringParam(c.Arguments["plan_format"]), planPath) {
+		var err error
+		rows, err = getServerImportRowsFromCSVFile(planPath, command.GetStringParam(c.Arguments["mapping"]))
+		if err != nil {
+			return "", err
+		}
+	} else {
+		records, err := getMultipleServerCreateUnmanagedInternalFromYamlFile(planPath)
+		if err != nil {
+			return "", err
+		}
+
+		for i, r := range records {
+			rows = append(rows, serverImportRow{
+				Row:    i + 1,
+				Record: r,
+			})
+		}
+	}
+
+	links, err := checkCabling(rows, client)
+	if err != nil {
+		return "", err
+	}
+
+	failed := 0
+	for _, l := range links {
+		if l.Status != cablingStatusOK {
+			failed++
+		}
+	}
+
+	format := command.GetStringParam(c.Arguments["format"])
+
+	if failed > 0 {
+		report, err := renderCablingReport(links, fmt.Sprintf("%d of %d links do not match the plan", failed, len(links)), format)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprint(configuration.GetStdout(), report)
+
+		return "", fmt.Errorf("%d of %d links do not match the cabling plan", failed, len(links))
+	}
+
+	return renderCablingReport(links, fmt.Sprintf("All %d links match the plan", len(links)), format)
+}
+
+// checkCabling compares the links of the plan with the links reported by the servers and by the switches of the plan.
+// The links reported by the switches are used to find the MACs of servers that are not registered or are cabled elsewhere.
+func checkCabling(rows []serverImportRow, client metalcloud.MetalCloudClient) ([]cablingLink, error) {
+
+	links := []cablingLink{}
+
+	// the actual links indexed by the server MAC and by switch and port
+	byMAC := map[string]metalcloud.SwitchInterfaceSearchResult{}
+	byPort := map[string]metalcloud.SwitchInterfaceSearchResult{}
+
+	addActual := func(list *[]metalcloud.SwitchInterfaceSearchResult) {
+		for _, si := range *list {
+			if si.ServerInterfaceMACAddress == "" {
+				continue
+			}
+			byMAC[normalizeCablingMAC(si.ServerInterfaceMACAddress)] = si
+			byPort[cablingPortKey(si.NetworkEquipmentIdentifierString, si.NetworkEquipmentInterfaceIdentifierString)] = si
+		}
+	}
+
+	serverIDs := map[string]int{}
+	switches := map[string]*metalcloud.SwitchDevice{}
+	switchErrors := map[string]error{}
+
+	for _, row := range rows {
+		record := row.Record.ServerCreateUnmanaged
+		serial := strings.ToLower(record.ServerSerialNumber)
+
+		if _, ok := serverIDs[serial]; !ok && serial != "" {
+			serverIDs[serial] = 0
+
+			list, err := client.ServersSearch(fmt.Sprintf("+server_serial_number:%s", record.ServerSerialNumber))
+			if err != nil {
+				return nil, err
+			}
+
+			for _, s := range *list {
+				if strings.ToLower(s.ServerSerialNumber) == serial {
+					serverIDs[serial] = s.ServerID
+					break
+				}
+			}
+
+			if serverIDs[serial] != 0 {
+				list, err := client.SwitchInterfaceSearch(fmt.Sprintf("server_id:%d", serverIDs[serial]))
+				if err != nil {
+					return nil, err
+				}
+				addActual(list)
+			}
+		}
+
+		for _, intf := range record.ServerInterfaces {
+			sw := intf.NetworkEquipmentIdentifierString
+			if _, ok := switches[sw]; ok || switchErrors[sw] != nil || sw == "" {
+				continue
+			}
+
+			switchDevice, err := client.SwitchDeviceGetByIdentifierString(sw, false)
+			if err != nil {
+				switchErrors[sw] = err
+				continue
+			}
+			switches[sw] = switchDevice
+
+			list, err := client.SwitchInterfaceSearch(fmt.Sprintf("network_equipment_id:%d", switchDevice.NetworkEquipmentID))
+			if err != nil {
+				return nil, err
+			}
+			addActual(list)
+		}
+	}
+
+	planned := map[string]bool{}
+	for _, row := range rows {
+		for _, intf := range row.Record.ServerCreateUnmanaged.ServerInterfaces {
+			planned[normalizeCablingMAC(intf.ServerInterfaceMACAddress)] = true
+		}
+	}
+
+	for _, row := range rows {
+		record := row.Record.ServerCreateUnmanaged
+
+		for _, intf := range record.ServerInterfaces {
+			link := cablingLink{
+				Row:                row.Row,
+				ServerSerialNumber: record.ServerSerialNumber,
+				MAC:                intf.ServerInterfaceMACAddress,
+				ExpectedSwitch:     intf.NetworkEquipmentIdentifierString,
+				ExpectedInterface:  intf.NetworkEquipmentInterfaceIdentifierString,
+				ServerID:           serverIDs[strings.ToLower(record.ServerSerialNumber)],
+			}
+
+			if _, err := net.ParseMAC(intf.ServerInterfaceMACAddress); err != nil {
+				link.Status = cablingStatusInvalidMAC
+				link.Details = fmt.Sprintf("invalid MAC address %s in the plan", intf.ServerInterfaceMACAddress)
+				links = append(links, link)
+				continue
+			}
+
+			if actual, ok := byMAC[normalizeCablingMAC(intf.ServerInterfaceMACAddress)]; ok {
+				link.ActualSwitch = actual.NetworkEquipmentIdentifierString
+				link.ActualInterface = actual.NetworkEquipmentInterfaceIdentifierString
+				link.actualSwitchInterface = &actual
+			}
+
+			link.Status, link.Details = classifyCablingLink(link, switchErrors[link.ExpectedSwitch], byPort, planned)
+
+			links = append(links, link)
+		}
+	}
+
+	sort.SliceStable(links, func(i, j int) bool {
+		return links[i].Row < links[j].Row
+	})
+
+	return links, nil
+}
+
+func classifyCablingLink(link cablingLink, switchErr error, byPort map[string]metalcloud.SwitchInterfaceSearchResult, planned map[string]bool) (string, string) {
+
+	if switchErr != nil {
+		return cablingStatusUnknownSwitch, fmt.Sprintf("unknown switch %s", link.ExpectedSwitch)
+	}
+
+	if link.actualSwitchInterface == nil {
+		if link.ServerID == 0 {
+			return cablingStatusUnknownServer, fmt.Sprintf("no server with serial number %s is registered and MAC %s was not seen on any switch", link.ServerSerialNumber, link.MAC)
+		}
+		if other, ok := byPort[cablingPortKey(link.ExpectedSwitch, link.ExpectedInterface)]; ok {
+			return cablingStatusMissing, fmt.Sprintf("MAC %s was not seen on any switch, %s %s is cabled to MAC %s", link.MAC, link.ExpectedSwitch, link.ExpectedInterface, other.ServerInterfaceMACAddress)
+		}
+		return cablingStatusMissing, fmt.Sprintf("MAC %s was not seen on any switch", link.MAC)
+	}
+
+	if !strings.EqualFold(link.ActualSwitch, link.ExpectedSwitch) {
+		return cablingStatusWrongSwitch, fmt.Sprintf("MAC %s was seen on %s %s instead of %s", link.MAC, link.ActualSwitch, link.ActualInterface, link.ExpectedSwitch)
+	}
+
+	if !strings.EqualFold(link.ActualInterface, link.ExpectedInterface) {
+		if other, ok := byPort[cablingPortKey(link.ExpectedSwitch, link.ExpectedInterface)]; ok && planned[normalizeCablingMAC(other.ServerInterfaceMACAddress)] {
+			return cablingStatusSwapped, fmt.Sprintf("cabled to %s, %s is cabled to MAC %s", link.ActualInterface, link.ExpectedInterface, other.ServerInterfaceMACAddress)
+		}
+		return cablingStatusWrongPort, fmt.Sprintf("cabled to %s instead of %s", link.ActualInterface, link.ExpectedInterface)
+	}
+
+	return cablingStatusOK, ""
+}
+
+// normalizeCablingMAC returns the MAC in the canonical lower case colon separated form so that
+// 00-B0-D0-63-C2-26 and 00:b0:d0:63:c2:26 match. Invalid MACs are only lower cased.
+func normalizeCablingMAC(mac string) string {
+	if hw, err := net.ParseMAC(mac); err == nil {
+		return hw.String()
+	}
+	return strings.ToLower(mac)
+}
+
+func cablingPortKey(switchIdentifier string, interfaceIdentifier string) string {
+	return strings.ToLower(switchIdentifier) + "/" + strings.ToLower(interfaceIdentifier)
+}
+
+// renderCablingReport renders the result of the check for every link of the plan
+func renderCablingReport(links []cablingLink, title string, format string) (string, error) {
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "ROW",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 4,
+		},
+		{
+			FieldName: "SERIAL_NUMBER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "SERVER_ID",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "MAC",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 17,
+		},
+		{
+			FieldName: "EXPECTED",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 20,
+		},
+		{
+			FieldName: "ACTUAL",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 20,
+		},
+		{
+			FieldName: "STATUS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "DETAILS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 40,
+		},
+	}
+
+	data := [][]interface{}{}
+	for _, l := range links {
+		status := colors.Green(l.Status)
+		if l.Status != cablingStatusOK {
+			status = colors.Red(l.Status)
+		}
+
+		actual := ""
+		if l.ActualSwitch != "" {
+			actual = fmt.Sprintf("%s %s", l.ActualSwitch, l.ActualInterface)
+		}
+
+		data = append(data, []interface{}{
+			l.Row,
+			l.ServerSerialNumber,
+			l.ServerID,
+			l.MAC,
+			fmt.Sprintf("%s %s", l.ExpectedSwitch, l.ExpectedInterface),
+			actual,
+			status,
+			l.Details,
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	return table.RenderTable("Links", title, format)
+}
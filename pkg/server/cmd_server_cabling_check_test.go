@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	. "github.com/onsi/gomega"
+)
+
+func TestServerCablingCheckCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		ServersSearch("+server_serial_number:SN1").
+		Return(&[]metalcloud.ServerSearchResult{{ServerID: 10, ServerSerialNumber: "SN1"}}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServersSearch("+server_serial_number:SN2").
+		Return(&[]metalcloud.ServerSearchResult{}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SwitchDeviceGetByIdentifierString("leaf-1", false).
+		Return(&metalcloud.SwitchDevice{NetworkEquipmentID: 1}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SwitchDeviceGetByIdentifierString("leaf-9", false).
+		Return(nil, fmt.Errorf("not found")).
+		AnyTimes()
+
+	link := func(mac string, sw string, port string) metalcloud.SwitchInterfaceSearchResult {
+		return metalcloud.SwitchInterfaceSearchResult{
+			ServerID:                                  10,
+			ServerSerialNumber:                        "SN1",
+			ServerInterfaceMACAddress:                 mac,
+			NetworkEquipmentIdentifierString:          sw,
+			NetworkEquipmentInterfaceIdentifierString: port,
+		}
+	}
+
+	client.EXPECT().
+		SwitchInterfaceSearch("server_id:10").
+		Return(&[]metalcloud.SwitchInterfaceSearchResult{
+			link("aa:bb:cc:dd:ee:01", "leaf-1", "Ethernet2"),
+			link("aa:bb:cc:dd:ee:02", "leaf-1", "Ethernet1"),
+			link("aa:bb:cc:dd:ee:03", "leaf-2", "Ethernet3"),
+			link("aa:bb:cc:dd:ee:04", "leaf-1", "Ethernet4"),
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SwitchInterfaceSearch("network_equipment_id:1").
+		Return(&[]metalcloud.SwitchInterfaceSearchResult{
+			link("aa:bb:cc:dd:ee:01", "leaf-1", "Ethernet2"),
+			link("aa:bb:cc:dd:ee:02", "leaf-1", "Ethernet1"),
+			link("aa:bb:cc:dd:ee:04", "leaf-1", "Ethernet4"),
+		}, nil).
+		AnyTimes()
+
+	planFile := createTempFileWithContent("test-*.csv", `serialNumber,mac1,switch1,switchInterface1,mac2,switch2,switchInterface2,mac3,switch3,switchInterface3,mac4,switch4,switchInterface4
+SN1,aa:bb:cc:dd:ee:01,leaf-1,Ethernet1,aa:bb:cc:dd:ee:02,leaf-1,Ethernet2,aa:bb:cc:dd:ee:03,leaf-1,Ethernet3,AA-BB-CC-DD-EE-04,leaf-1,ethernet4
+SN2,aa:bb:cc:dd:ee:05,leaf-1,Ethernet5,aa:bb:cc:dd:ee:06,leaf-9,Ethernet1,not-a-mac,leaf-1,Ethernet7,,,
+`)
+	defer os.Remove(planFile)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(strings.NewReader(""), &stdout)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"plan":   planFile,
+		"format": "csv",
+	})
+
+	_, err := serverCablingCheckCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("6 of 7 links do not match the cabling plan"))
+
+	report := stdout.String()
+	Expect(report).To(ContainSubstring("2,SN1,10,aa:bb:cc:dd:ee:01,leaf-1 Ethernet1,leaf-1 Ethernet2,swapped,"))
+	Expect(report).To(ContainSubstring("2,SN1,10,aa:bb:cc:dd:ee:03,leaf-1 Ethernet3,leaf-2 Ethernet3,wrong-switch,"))
+	Expect(report).To(ContainSubstring("2,SN1,10,AA-BB-CC-DD-EE-04,leaf-1 ethernet4,leaf-1 Ethernet4,ok,"))
+	Expect(report).To(ContainSubstring("3,SN2,0,aa:bb:cc:dd:ee:05,leaf-1 Ethernet5,,unknown-server,"))
+	Expect(report).To(ContainSubstring("3,SN2,0,aa:bb:cc:dd:ee:06,leaf-9 Ethernet1,,unknown-switch,"))
+	Expect(report).To(ContainSubstring("3,SN2,0,not-a-mac,leaf-1 Ethernet7,,invalid-mac,invalid MAC address not-a-mac in the plan"))
+
+	// a plan that matches does not return an error
+	planFile2 := createTempFileWithContent("test-*.yaml", `
+serialNumber: SN1
+interfaces:
+- mac: aa:bb:cc:dd:ee:01
+  switch: leaf-1
+  switchInterface: Ethernet2
+`)
+	defer os.Remove(planFile2)
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"plan":   planFile2,
+		"format": "csv",
+	})
+
+	ret, err := serverCablingCheckCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("1,SN1,10,aa:bb:cc:dd:ee:01,leaf-1 Ethernet2,leaf-1 Ethernet2,ok,"))
+}
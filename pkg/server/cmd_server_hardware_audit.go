@@ -0,0 +1,307 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/filtering"
+	"github.com/metalsoft-io/tableformatter"
+)
+
+// serverHardwareAudit is the result of comparing a server with its server type
+type serverHardwareAudit struct {
+	Server         metalcloud.ServerSearchResult
+	Components     map[string]int
+	Mismatches     []string
+	SuggestedTypes []string
+}
+
+func serverHardwareAuditCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	conditions := []string{}
+	if v, ok := command.GetStringParamOk(c.Arguments["datacenter"]); ok {
+		conditions = append(conditions, fmt.Sprintf("datacenter_name:%s", v))
+	}
+	if v, ok := command.GetStringParamOk(c.Arguments["server_type"]); ok {
+		conditions = append(conditions, fmt.Sprintf("server_type_name:%s", v))
+	}
+
+	filter := "*"
+	if len(conditions) > 0 {
+		filter = filtering.ConvertToSearchFieldFormat(strings.Join(conditions, " "))
+	}
+
+	list, err := client.ServersSearch(filter)
+	if err != nil {
+		return "", err
+	}
+
+	serverTypes, err := client.ServerTypes(false)
+	if err != nil {
+		return "", err
+	}
+
+	audits := []serverHardwareAudit{}
+	for _, s := range *list {
+		//servers that were not registered yet have no hardware information
+		if s.ServerStatus == "decommissioned" || (s.ServerProcessorCount == 0 && s.ServerRAMGbytes == 0) {
+			continue
+		}
+
+		components, err := client.ServerComponents(s.ServerID, "")
+		if err != nil {
+			return "", err
+		}
+
+		audit := serverHardwareAudit{
+			Server:     s,
+			Components: map[string]int{},
+		}
+
+		for _, component := range *components {
+			audit.Components[strings.ToLower(component.ServerComponentType)]++
+		}
+
+		if serverType, ok := (*serverTypes)[s.ServerTypeID]; ok {
+			audit.Mismatches = getServerHardwareMismatches(s, serverType)
+		} else {
+			audit.Mismatches = []string{"no server type assigned"}
+		}
+
+		audits = append(audits, audit)
+	}
+
+	sort.Slice(audits, func(i, j int) bool {
+		return audits[i].Server.ServerID < audits[j].Server.ServerID
+	})
+
+	addComponentMismatches(audits)
+
+	mismatched := 0
+	for i := range audits {
+		audit := &audits[i]
+		if len(audit.Mismatches) == 0 {
+			continue
+		}
+
+		mismatched++
+
+		suggested, err := getSuggestedServerTypes(audit.Server, client)
+		if err != nil {
+			return "", err
+		}
+		audit.SuggestedTypes = suggested
+	}
+
+	return renderServerHardwareAudit(audits, command.GetBoolParam(c.Arguments["show_all"]), fmt.Sprintf("%d of %d servers do not match their server type", mismatched, len(audits)), command.GetStringParam(c.Arguments["format"]))
+}
+
+// getServerHardwareMismatches compares the hardware configuration of a server with the one of its server type
+func getServerHardwareMismatches(s metalcloud.ServerSearchResult, serverType metalcloud.ServerType) []string {
+	mismatches := []string{}
+
+	check := func(name string, actual int, expected int, unit string) {
+		if actual != expected {
+			mismatches = append(mismatches, fmt.Sprintf("%s: %d%s instead of %d%s", name, actual, unit, expected, unit))
+		}
+	}
+
+	check("RAM", s.ServerRAMGbytes, serverType.ServerRAMGbytes, " GB")
+	check("CPUs", s.ServerProcessorCount, serverType.ServerProcessorCount, "")
+	check("CPU cores", s.ServerProcessorCoreCount, serverType.ServerProcessorCoreCount, "")
+	check("disks", s.ServerDiskCount, serverType.ServerDiskCount, "")
+
+	if s.ServerDiskCount > 0 && serverType.ServerDiskCount > 0 {
+		check("disk size", s.ServerDiskSizeMbytes, serverType.ServerDiskSizeMBytes, " MB")
+
+		if !strings.EqualFold(s.ServerDiskType, serverType.ServerDiskType) {
+			mismatches = append(mismatches, fmt.Sprintf("disk type: %s instead of %s", s.ServerDiskType, serverType.ServerDiskType))
+		}
+	}
+
+	check("NIC capacity", s.ServerNetworkTotalCapacityMbps, serverType.ServerNetworkTotalCapacityMBps, " Mbps")
+
+	return mismatches
+}
+
+// addComponentMismatches flags servers having a different number of components of a type than most other
+// servers of the same server type. This catches failed DIMMs, disks or NICs before the server is re-registered.
+func addComponentMismatches(audits []serverHardwareAudit) {
+
+	//component type -> count -> number of servers, for every server type
+	counts := map[int]map[string]map[int]int{}
+
+	for _, audit := range audits {
+		byType, ok := counts[audit.Server.ServerTypeID]
+		if !ok {
+			byType = map[string]map[int]int{}
+			counts[audit.Server.ServerTypeID] = byType
+		}
+
+		for componentType, n := range audit.Components {
+			if byType[componentType] == nil {
+				byType[componentType] = map[int]int{}
+			}
+			byType[componentType][n]++
+		}
+	}
+
+	for i := range audits {
+		audit := &audits[i]
+		if audit.Server.ServerTypeID == 0 {
+			continue
+		}
+
+		componentTypes := []string{}
+		for componentType := range counts[audit.Server.ServerTypeID] {
+			componentTypes = append(componentTypes, componentType)
+		}
+		sort.Strings(componentTypes)
+
+		for _, componentType := range componentTypes {
+			usual, servers := 0, 0
+			for n, s := range counts[audit.Server.ServerTypeID][componentType] {
+				if s > servers || (s == servers && n > usual) {
+					usual, servers = n, s
+				}
+			}
+
+			//a baseline needs at least two servers agreeing on it
+			if servers < 2 {
+				continue
+			}
+
+			if n := audit.Components[componentType]; n != usual {
+				audit.Mismatches = append(audit.Mismatches, fmt.Sprintf("%s components: %d instead of %d like most %s servers", componentType, n, usual, audit.Server.ServerTypeName))
+			}
+		}
+	}
+}
+
+// getSuggestedServerTypes returns the names of the server types matching the actual hardware of a server
+func getSuggestedServerTypes(s metalcloud.ServerSearchResult, client metalcloud.MetalCloudClient) ([]string, error) {
+
+	hardwareConfiguration := metalcloud.HardwareConfiguration{
+		InstanceArrayRAMGbytes:          s.ServerRAMGbytes,
+		InstanceArrayProcessorCount:     s.ServerProcessorCount,
+		InstanceArrayProcessorCoreMHZ:   s.ServerProcessorCoreMhz,
+		InstanceArrayProcessorCoreCount: s.ServerProcessorCoreCount,
+		InstanceArrayDiskCount:          s.ServerDiskCount,
+		InstanceArrayDiskSizeMBytes:     s.ServerDiskSizeMbytes,
+		InstanceArrayInstanceCount:      1,
+	}
+
+	if s.ServerDiskType != "" {
+		hardwareConfiguration.InstanceArrayDiskTypes = []string{s.ServerDiskType}
+	}
+
+	matches, err := client.ServerTypesMatchHardwareConfiguration(s.DatacenterName, hardwareConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []int{}
+	for id := range *matches {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	//types with the exact configuration of the server come first
+	exact := []string{}
+	others := []string{}
+	for _, id := range ids {
+		serverType := (*matches)[id]
+		if id == s.ServerTypeID {
+			continue
+		}
+
+		if len(getServerHardwareMismatches(s, serverType)) == 0 {
+			exact = append(exact, serverType.ServerTypeName)
+		} else {
+			others = append(others, serverType.ServerTypeName)
+		}
+	}
+
+	return append(exact, others...), nil
+}
+
+func renderServerHardwareAudit(audits []serverHardwareAudit, showAll bool, title string, format string) (string, error) {
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "ID",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "SERIAL_NUMBER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "DATACENTER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "STATUS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "SERVER_TYPE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "MISMATCHES",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 40,
+		},
+		{
+			FieldName: "SUGGESTED_TYPE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+	}
+
+	data := [][]interface{}{}
+	for _, audit := range audits {
+		if len(audit.Mismatches) == 0 && !showAll {
+			continue
+		}
+
+		mismatches := colors.Green("none")
+		if len(audit.Mismatches) > 0 {
+			mismatches = colors.Red(strings.Join(audit.Mismatches, "; "))
+		}
+
+		suggested := ""
+		if len(audit.SuggestedTypes) > 0 {
+			suggested = audit.SuggestedTypes[0]
+		} else if len(audit.Mismatches) > 0 {
+			suggested = "none"
+		}
+
+		data = append(data, []interface{}{
+			audit.Server.ServerID,
+			audit.Server.ServerSerialNumber,
+			audit.Server.DatacenterName,
+			audit.Server.ServerStatus,
+			audit.Server.ServerTypeName,
+			mismatches,
+			suggested,
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	return table.RenderTable("Servers", title, format)
+}
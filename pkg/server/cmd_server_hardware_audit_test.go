@@ -0,0 +1,100 @@
+package server
+
+import (
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	. "github.com/onsi/gomega"
+)
+
+func TestServerHardwareAuditCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	serverType := metalcloud.ServerType{
+		ServerTypeID:                   5,
+		ServerTypeName:                 "M.20.256.2",
+		ServerRAMGbytes:                256,
+		ServerProcessorCount:           2,
+		ServerProcessorCoreCount:       20,
+		ServerDiskCount:                2,
+		ServerDiskSizeMBytes:           960000,
+		ServerDiskType:                 "SSD",
+		ServerNetworkTotalCapacityMBps: 20000,
+	}
+
+	smallerType := serverType
+	smallerType.ServerTypeID = 6
+	smallerType.ServerTypeName = "M.20.128.2"
+	smallerType.ServerRAMGbytes = 128
+
+	server := func(id int, ram int) metalcloud.ServerSearchResult {
+		return metalcloud.ServerSearchResult{
+			ServerID:                       id,
+			ServerSerialNumber:             "SN",
+			ServerStatus:                   "available",
+			DatacenterName:                 "dc1",
+			ServerTypeID:                   5,
+			ServerTypeName:                 "M.20.256.2",
+			ServerRAMGbytes:                ram,
+			ServerProcessorCount:           2,
+			ServerProcessorCoreCount:       20,
+			ServerDiskCount:                2,
+			ServerDiskSizeMbytes:           960000,
+			ServerDiskType:                 "SSD",
+			ServerNetworkTotalCapacityMbps: 20000,
+		}
+	}
+
+	decommissioned := server(4, 128)
+	decommissioned.ServerStatus = "decommissioned"
+
+	client.EXPECT().
+		ServersSearch("+datacenter_name:dc1").
+		Return(&[]metalcloud.ServerSearchResult{server(1, 256), server(2, 128), server(3, 256), server(5, 256), decommissioned}, nil).
+		Times(1)
+
+	client.EXPECT().
+		ServerTypes(false).
+		Return(&map[int]metalcloud.ServerType{5: serverType, 6: smallerType}, nil).
+		Times(1)
+
+	components := func(dimms int) *[]metalcloud.ServerComponent {
+		list := []metalcloud.ServerComponent{{ServerComponentType: "BIOS"}}
+		for i := 0; i < dimms; i++ {
+			list = append(list, metalcloud.ServerComponent{ServerComponentType: "Memory"})
+		}
+		return &list
+	}
+
+	client.EXPECT().ServerComponents(1, "").Return(components(8), nil).Times(1)
+	client.EXPECT().ServerComponents(2, "").Return(components(4), nil).Times(1)
+	client.EXPECT().ServerComponents(3, "").Return(components(7), nil).Times(1)
+	client.EXPECT().ServerComponents(5, "").Return(components(8), nil).Times(1)
+
+	client.EXPECT().
+		ServerTypesMatchHardwareConfiguration("dc1", gomock.Any()).
+		DoAndReturn(func(datacenterName string, hardwareConfiguration metalcloud.HardwareConfiguration) (*map[int]metalcloud.ServerType, error) {
+			if hardwareConfiguration.InstanceArrayRAMGbytes == 128 {
+				return &map[int]metalcloud.ServerType{6: smallerType}, nil
+			}
+			return &map[int]metalcloud.ServerType{5: serverType}, nil
+		}).
+		Times(2)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+		"format":     "csv",
+	})
+
+	ret, err := serverHardwareAuditCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("2,SN,dc1,available,M.20.256.2,RAM: 128 GB instead of 256 GB; memory components: 4 instead of 8 like most M.20.256.2 servers,M.20.128.2"))
+	Expect(ret).To(ContainSubstring("3,SN,dc1,available,M.20.256.2,memory components: 7 instead of 8 like most M.20.256.2 servers,none"))
+	Expect(ret).NotTo(ContainSubstring("\n1,SN"))
+	Expect(ret).NotTo(ContainSubstring("\n4,SN"))
+}
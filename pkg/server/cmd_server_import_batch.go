@@ -0,0 +1,451 @@
+package server
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/tableformatter"
+	"gopkg.in/yaml.v2"
+)
+
+// serverImportCSVMapping maps the fields of a server record to the columns of a CSV file.
+// The keys are the same as the ones used in the yaml format of server import-batch.
+type serverImportCSVMapping struct {
+	SerialNumber          string                            `yaml:"serialNumber"`
+	Datacenter            string                            `yaml:"datacenter"`
+	ServerType            string                            `yaml:"serverType"`
+	Address               string                            `yaml:"address"`
+	User                  string                            `yaml:"user"`
+	Pass                  string                            `yaml:"pass"`
+	Label                 string                            `yaml:"label"`
+	Infrastructure        string                            `yaml:"infrastructure"`
+	UserEmail             string                            `yaml:"userEmail"`
+	Rack                  string                            `yaml:"rack"`
+	RackPositionLowerUnit string                            `yaml:"rackPositionLowerUnit"`
+	RackPositionUpperUnit string                            `yaml:"rackPositionUpperUnit"`
+	Interfaces            []serverImportCSVInterfaceMapping `yaml:"interfaces"`
+}
+
+type serverImportCSVInterfaceMapping struct {
+	MAC             string `yaml:"mac"`
+	Switch          string `yaml:"switch"`
+	SwitchInterface string `yaml:"switchInterface"`
+}
+
+// serverImportRow is a record of an import batch together with its validation result
+type serverImportRow struct {
+	Row      int
+	Record   ServerCreateUnmanagedInternal
+	Errors   []string
+	ServerID int
+}
+
+// defaultServerImportCSVMapping uses the yaml keys as column names. Interfaces are read from
+// the mac1, switch1, switchInterface1, mac2... columns present in the header.
+func defaultServerImportCSVMapping(header []string) serverImportCSVMapping {
+	mapping := serverImportCSVMapping{
+		SerialNumber:          "serialNumber",
+		Datacenter:            "datacenter",
+		ServerType:            "serverType",
+		Address:               "address",
+		User:                  "user",
+		Pass:                  "pass",
+		Label:                 "label",
+		Infrastructure:        "infrastructure",
+		UserEmail:             "userEmail",
+		Rack:                  "rack",
+		RackPositionLowerUnit: "rackPositionLowerUnit",
+		RackPositionUpperUnit: "rackPositionUpperUnit",
+	}
+
+	columns := map[string]bool{}
+	for _, h := range header {
+		columns[strings.TrimSpace(h)] = true
+	}
+
+	for i := 1; columns[fmt.Sprintf("mac%d", i)]; i++ {
+		mapping.Interfaces = append(mapping.Interfaces, serverImportCSVInterfaceMapping{
+			MAC:             fmt.Sprintf("mac%d", i),
+			Switch:          fmt.Sprintf("switch%d", i),
+			SwitchInterface: fmt.Sprintf("switchInterface%d", i),
+		})
+	}
+
+	return mapping
+}
+
+// isCSVInputFile returns true if a batch file is to be read as csv: when the format is csv or the file has the .csv extension
+func isCSVInputFile(format string, filePath string) bool {
+	return strings.ToLower(format) == "csv" || strings.ToLower(filepath.Ext(filePath)) == ".csv"
+}
+
+// getServerImportRowsFromCSVFile reads the server records from a CSV file with a header row.
+// If mappingFilePath is empty the default mapping is used.
+func getServerImportRowsFromCSVFile(filePath string, mappingFilePath string) ([]serverImportRow, error) {
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading %s: %v", filePath, err)
+	}
+
+	if len(lines) == 0 {
+		return []serverImportRow{}, nil
+	}
+
+	header := lines[0]
+
+	var mapping serverImportCSVMapping
+	if mappingFilePath != "" {
+		content, err := os.ReadFile(mappingFilePath)
+		if err != nil {
+			return nil, err
+		}
+
+		err = yaml.Unmarshal(content, &mapping)
+		if err != nil {
+			return nil, fmt.Errorf("Error while reading mapping file %s: %v", mappingFilePath, err)
+		}
+	} else {
+		mapping = defaultServerImportCSVMapping(header)
+	}
+
+	columnIndex := map[string]int{}
+	for i, h := range header {
+		columnIndex[strings.TrimSpace(h)] = i
+	}
+
+	// columns explicitly set in the mapping file must exist, the default ones are optional
+	for _, column := range mappedServerImportCSVColumns(mapping) {
+		if _, ok := columnIndex[column]; !ok && mappingFilePath != "" {
+			return nil, fmt.Errorf("column '%s' from the mapping file was not found in the header of %s", column, filePath)
+		}
+	}
+
+	rows := []serverImportRow{}
+
+	for lineIdx, line := range lines[1:] {
+		get := func(column string) string {
+			idx, ok := columnIndex[column]
+			if column == "" || !ok || idx >= len(line) {
+				return ""
+			}
+			return strings.TrimSpace(line[idx])
+		}
+
+		getPtr := func(column string) *string {
+			if v := get(column); v != "" {
+				return &v
+			}
+			return nil
+		}
+
+		empty := true
+		for _, v := range line {
+			if strings.TrimSpace(v) != "" {
+				empty = false
+			}
+		}
+		if empty {
+			continue
+		}
+
+		record := ServerCreateUnmanagedInternal{
+			ServerCreateUnmanaged: metalcloud.ServerCreateUnmanaged{
+				DatacenterName:           get(mapping.Datacenter),
+				ServerSerialNumber:       get(mapping.SerialNumber),
+				ServerManagementAddress:  get(mapping.Address),
+				ServerManagementUser:     get(mapping.User),
+				ServerManagementPassword: get(mapping.Pass),
+			},
+			InstanceArrayLabel:    getPtr(mapping.Label),
+			ServerTypeLabel:       getPtr(mapping.ServerType),
+			InfrastructureLabel:   getPtr(mapping.Infrastructure),
+			UserEmail:             getPtr(mapping.UserEmail),
+			RackName:              getPtr(mapping.Rack),
+			RackPositionLowerUnit: getPtr(mapping.RackPositionLowerUnit),
+			RackPositionUpperUnit: getPtr(mapping.RackPositionUpperUnit),
+		}
+
+		for _, intf := range mapping.Interfaces {
+			mac := get(intf.MAC)
+			if mac == "" {
+				continue
+			}
+
+			record.ServerCreateUnmanaged.ServerInterfaces = append(record.ServerCreateUnmanaged.ServerInterfaces, metalcloud.ServerInterfaceCreate{
+				ServerInterfaceMACAddress:                 mac,
+				NetworkEquipmentIdentifierString:          get(intf.Switch),
+				NetworkEquipmentInterfaceIdentifierString: get(intf.SwitchInterface),
+			})
+		}
+
+		// the header is line 1
+		rows = append(rows, serverImportRow{
+			Row:    lineIdx + 2,
+			Record: record,
+		})
+	}
+
+	return rows, nil
+}
+
+func mappedServerImportCSVColumns(mapping serverImportCSVMapping) []string {
+	columns := []string{}
+	for _, c := range []string{
+		mapping.SerialNumber,
+		mapping.Datacenter,
+		mapping.ServerType,
+		mapping.Address,
+		mapping.User,
+		mapping.Pass,
+		mapping.Label,
+		mapping.Infrastructure,
+		mapping.UserEmail,
+		mapping.Rack,
+		mapping.RackPositionLowerUnit,
+		mapping.RackPositionUpperUnit,
+	} {
+		if c != "" {
+			columns = append(columns, c)
+		}
+	}
+
+	for _, intf := range mapping.Interfaces {
+		for _, c := range []string{intf.MAC, intf.Switch, intf.SwitchInterface} {
+			if c != "" {
+				columns = append(columns, c)
+			}
+		}
+	}
+
+	return columns
+}
+
+// validateServerImportRows checks every row against the existing server types and switches. The server type ids are resolved in the process.
+// Serial numbers must be unique within the batch. A batch can update existing servers and swap interfaces between them,
+// so serial numbers are only checked against the existing servers and MAC addresses and switch interfaces are only checked
+// for duplicates if newOnly is set.
+// It returns the number of rows that failed validation.
+func validateServerImportRows(rows []serverImportRow, newOnly bool, client metalcloud.MetalCloudClient) int {
+
+	serverTypes := map[string]*metalcloud.ServerType{}
+	serverTypeErrors := map[string]error{}
+	switches := map[string]*metalcloud.SwitchDevice{}
+	switchErrors := map[string]error{}
+
+	serials := map[string]int{}
+	macs := map[string]int{}
+	ports := map[string]int{}
+
+	for i := range rows {
+		row := &rows[i]
+		record := &row.Record.ServerCreateUnmanaged
+
+		addError := func(format string, a ...interface{}) {
+			row.Errors = append(row.Errors, fmt.Sprintf(format, a...))
+		}
+
+		serial := strings.ToLower(record.ServerSerialNumber)
+		if serial == "" {
+			addError("serial number cannot be empty")
+		} else {
+			if other, ok := serials[serial]; ok {
+				addError("serial number %s is duplicated on row %d", record.ServerSerialNumber, other)
+			} else {
+				serials[serial] = row.Row
+			}
+		}
+
+		if serial != "" && newOnly {
+			existing, err := client.ServersSearch(fmt.Sprintf("+server_serial_number:%s", record.ServerSerialNumber))
+			if err != nil {
+				addError("could not search for serial number %s: %v", record.ServerSerialNumber, err)
+			} else {
+				for _, s := range *existing {
+					if strings.ToLower(s.ServerSerialNumber) == serial {
+						addError("a server with serial number %s already exists (#%d)", record.ServerSerialNumber, s.ServerID)
+						break
+					}
+				}
+			}
+		}
+
+		if label := row.Record.ServerTypeLabel; label != nil {
+			if _, ok := serverTypes[*label]; !ok && serverTypeErrors[*label] == nil {
+				serverType, err := client.ServerTypeGetByLabel(*label)
+				if err != nil {
+					serverTypeErrors[*label] = err
+				} else {
+					serverTypes[*label] = serverType
+				}
+			}
+
+			if serverType, ok := serverTypes[*label]; ok {
+				record.ServerTypeID = serverType.ServerTypeID
+			} else {
+				addError("unknown server type %s", *label)
+			}
+		}
+
+		if row.Record.RackPositionLowerUnit != nil || row.Record.RackPositionUpperUnit != nil {
+			lower, errLower := strconv.Atoi(stringOrEmpty(row.Record.RackPositionLowerUnit))
+			upper, errUpper := strconv.Atoi(stringOrEmpty(row.Record.RackPositionUpperUnit))
+			if errLower != nil || errUpper != nil {
+				addError("rack position units must be numbers")
+			} else if lower > upper {
+				addError("rack position lower unit %d is above the upper unit %d", lower, upper)
+			}
+		}
+
+		for _, intf := range record.ServerInterfaces {
+			mac, err := net.ParseMAC(intf.ServerInterfaceMACAddress)
+			if err != nil {
+				addError("invalid MAC address %s", intf.ServerInterfaceMACAddress)
+			} else if other, ok := macs[mac.String()]; ok && newOnly {
+				addError("MAC address %s is duplicated on row %d", intf.ServerInterfaceMACAddress, other)
+			} else {
+				macs[mac.String()] = row.Row
+			}
+
+			if intf.NetworkEquipmentIdentifierString == "" {
+				addError("MAC address %s has no switch", intf.ServerInterfaceMACAddress)
+				continue
+			}
+
+			switchID := intf.NetworkEquipmentIdentifierString
+			if _, ok := switches[switchID]; !ok && switchErrors[switchID] == nil {
+				sw, err := client.SwitchDeviceGetByIdentifierString(switchID, false)
+				if err != nil {
+					switchErrors[switchID] = err
+				} else {
+					switches[switchID] = sw
+				}
+			}
+
+			sw, ok := switches[switchID]
+			if !ok {
+				addError("unknown switch %s", switchID)
+				continue
+			}
+
+			if record.DatacenterName != "" && sw.DatacenterName != record.DatacenterName {
+				addError("switch %s is in datacenter %s, not %s", switchID, sw.DatacenterName, record.DatacenterName)
+			}
+
+			if intf.NetworkEquipmentInterfaceIdentifierString == "" {
+				addError("MAC address %s has no switch interface", intf.ServerInterfaceMACAddress)
+				continue
+			}
+
+			port := switchID + " " + intf.NetworkEquipmentInterfaceIdentifierString
+			if other, ok := ports[port]; ok && newOnly {
+				addError("switch interface %s is duplicated on row %d", port, other)
+			} else {
+				ports[port] = row.Row
+			}
+		}
+	}
+
+	failed := 0
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+func stringOrEmpty(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// renderServerImportReport renders the per row result of a batch import
+func renderServerImportReport(rows []serverImportRow, title string, format string) (string, error) {
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "ROW",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 4,
+		},
+		{
+			FieldName: "SERIAL_NUMBER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "DATACENTER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "SERVER_TYPE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "SERVER_ID",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "STATUS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "DETAILS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 40,
+		},
+	}
+
+	data := [][]interface{}{}
+	for _, row := range rows {
+		status := colors.Green("ok")
+		if row.ServerID != 0 {
+			status = colors.Green("imported")
+		}
+		if len(row.Errors) > 0 {
+			status = colors.Red("invalid")
+		}
+
+		data = append(data, []interface{}{
+			row.Row,
+			row.Record.ServerCreateUnmanaged.ServerSerialNumber,
+			row.Record.ServerCreateUnmanaged.DatacenterName,
+			stringOrEmpty(row.Record.ServerTypeLabel),
+			row.ServerID,
+			status,
+			strings.Join(row.Errors, "; "),
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	return table.RenderTable("Records", title, format)
+}
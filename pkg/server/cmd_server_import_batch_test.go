@@ -0,0 +1,322 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	. "github.com/onsi/gomega"
+)
+
+func createTempFileWithContent(pattern string, content string) string {
+	f, err := os.CreateTemp(os.TempDir(), pattern)
+	if err != nil {
+		panic(err)
+	}
+
+	f.WriteString(content)
+	f.Close()
+
+	return f.Name()
+}
+
+func TestGetServerImportRowsFromCSVFile(t *testing.T) {
+	RegisterTestingT(t)
+
+	csvFile := createTempFileWithContent("test-*.csv", _serverImportCSVFixture)
+	defer os.Remove(csvFile)
+
+	mappingFile := createTempFileWithContent("test-*.yaml", _serverImportCSVMappingFixture)
+	defer os.Remove(mappingFile)
+
+	rows, err := getServerImportRowsFromCSVFile(csvFile, mappingFile)
+	Expect(err).To(BeNil())
+	Expect(rows).To(HaveLen(2))
+	Expect(rows[0].Row).To(Equal(2))
+	Expect(rows[0].Record.ServerCreateUnmanaged.ServerSerialNumber).To(Equal("SN1"))
+	Expect(*rows[0].Record.ServerTypeLabel).To(Equal("M.15.15.1"))
+	Expect(*rows[0].Record.RackName).To(Equal("R12"))
+	Expect(rows[0].Record.ServerCreateUnmanaged.ServerInterfaces).To(HaveLen(2))
+	Expect(rows[0].Record.ServerCreateUnmanaged.ServerInterfaces[1].NetworkEquipmentInterfaceIdentifierString).To(Equal("Ethernet2"))
+	Expect(rows[1].Record.ServerCreateUnmanaged.ServerInterfaces).To(HaveLen(1))
+
+	// with the default mapping the columns are named like the yaml fields
+	csvFile2 := createTempFileWithContent("test-*.csv", "serialNumber,datacenter,mac1,switch1,switchInterface1\nSN3,dc1,aa:bb:cc:dd:ee:01,leaf-1,Ethernet1\n")
+	defer os.Remove(csvFile2)
+
+	rows, err = getServerImportRowsFromCSVFile(csvFile2, "")
+	Expect(err).To(BeNil())
+	Expect(rows).To(HaveLen(1))
+	Expect(rows[0].Record.ServerCreateUnmanaged.ServerInterfaces[0].NetworkEquipmentIdentifierString).To(Equal("leaf-1"))
+
+	// columns from the mapping file must exist
+	mappingFile2 := createTempFileWithContent("test-*.yaml", "serialNumber: Serial Number\n")
+	defer os.Remove(mappingFile2)
+
+	_, err = getServerImportRowsFromCSVFile(csvFile, mappingFile2)
+	Expect(err).NotTo(BeNil())
+}
+
+func TestImportServersBatchCSV(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		ServersSearch(gomock.Any()).
+		Return(&[]metalcloud.ServerSearchResult{}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServerTypeGetByLabel("M.15.15.1").
+		Return(&metalcloud.ServerType{ServerTypeID: 5, ServerTypeName: "M.15.15.1"}, nil).
+		Times(2) //once for every run, the rows share the lookup
+
+	client.EXPECT().
+		SwitchDeviceGetByIdentifierString("leaf-1", false).
+		Return(&metalcloud.SwitchDevice{NetworkEquipmentID: 1, DatacenterName: "dc1"}, nil).
+		Times(2)
+
+	csvFile := createTempFileWithContent("test-*.csv", _serverImportCSVFixture)
+	defer os.Remove(csvFile)
+
+	mappingFile := createTempFileWithContent("test-*.yaml", _serverImportCSVMappingFixture)
+	defer os.Remove(mappingFile)
+
+	c := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": csvFile,
+		"format":                "csv",
+		"mapping":               mappingFile,
+		"validate_only":         true,
+		"report_format":         "csv",
+	})
+
+	ret, err := serverImportBatchCmd(&c, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("2,SN1,dc1,M.15.15.1,0,ok,"))
+	Expect(ret).To(ContainSubstring("3,SN2,dc1,M.15.15.1,0,ok,"))
+
+	servers := map[string]metalcloud.Server{
+		"10": {ServerID: 10, ServerSerialNumber: "SN1"},
+		"11": {ServerID: 11, ServerSerialNumber: "SN2"},
+	}
+
+	client.EXPECT().
+		ServerUnmanagedImportBatch(gomock.Any()).
+		DoAndReturn(func(records []metalcloud.ServerCreateUnmanaged) (*map[string]metalcloud.Server, error) {
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].ServerTypeID).To(Equal(5))
+			return &servers, nil
+		}).
+		Times(1)
+
+	client.EXPECT().
+		ServerEditRack(10, gomock.Any()).
+		DoAndReturn(func(serverID int, rack metalcloud.ServerEditRack) (*metalcloud.Server, error) {
+			Expect(*rack.ServerRackName).To(Equal("R12"))
+			Expect(*rack.ServerRackPositionLowerUnit).To(Equal("10"))
+			s := servers["10"]
+			return &s, nil
+		}).
+		Times(1)
+
+	client.EXPECT().
+		ServerEditRack(11, gomock.Any()).
+		Return(nil, nil).
+		Times(1)
+
+	c = command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": csvFile,
+		"format":                "csv",
+		"mapping":               mappingFile,
+		"report_format":         "csv",
+	})
+
+	ret, err = serverImportBatchCmd(&c, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("2,SN1,dc1,M.15.15.1,10,imported,"))
+}
+
+func TestImportServersBatchValidationReport(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		ServersSearch("+server_serial_number:SN1").
+		Return(&[]metalcloud.ServerSearchResult{{ServerID: 99, ServerSerialNumber: "SN1"}}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServersSearch(gomock.Any()).
+		Return(&[]metalcloud.ServerSearchResult{}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServerTypeGetByLabel("unknown").
+		Return(nil, fmt.Errorf("not found")).
+		Times(1)
+
+	client.EXPECT().
+		SwitchDeviceGetByIdentifierString("leaf-1", false).
+		Return(&metalcloud.SwitchDevice{NetworkEquipmentID: 1, DatacenterName: "dc1"}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SwitchDeviceGetByIdentifierString("leaf-9", false).
+		Return(nil, fmt.Errorf("not found")).
+		Times(1)
+
+	// nothing must be imported when a record is invalid
+	client.EXPECT().
+		ServerUnmanagedImportBatch(gomock.Any()).
+		Times(0)
+
+	csvFile := createTempFileWithContent("test-*.csv", `serialNumber,datacenter,serverType,rackPositionLowerUnit,rackPositionUpperUnit,mac1,switch1,switchInterface1
+SN1,dc1,,,,aa:bb:cc:dd:ee:01,leaf-1,Ethernet1
+SN2,dc1,unknown,,,aa:bb:cc:dd:ee:01,leaf-1,Ethernet1
+SN2,dc1,unknown,12,10,not-a-mac,leaf-9,Ethernet3
+SN4,dc1,,,,aa:bb:cc:dd:ee:04,leaf-1,Ethernet4
+`)
+	defer os.Remove(csvFile)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(strings.NewReader(""), &stdout)
+
+	c := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": csvFile,
+		"format":                "csv",
+		"report_format":         "json",
+		"new_only":              true,
+	})
+
+	_, err := serverImportBatchCmd(&c, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("3 of 4 records failed validation"))
+
+	report := stdout.String()
+	Expect(report).To(ContainSubstring("a server with serial number SN1 already exists (#99)"))
+	Expect(report).To(ContainSubstring("unknown server type unknown"))
+	Expect(report).To(ContainSubstring("MAC address aa:bb:cc:dd:ee:01 is duplicated on row 2"))
+	Expect(report).To(ContainSubstring("switch interface leaf-1 Ethernet1 is duplicated on row 2"))
+	Expect(report).To(ContainSubstring("serial number SN2 is duplicated on row 3"))
+	Expect(report).To(ContainSubstring("rack position lower unit 12 is above the upper unit 10"))
+	Expect(report).To(ContainSubstring("invalid MAC address not-a-mac"))
+	Expect(report).To(ContainSubstring("unknown switch leaf-9"))
+}
+
+func TestImportServersBatchSwap(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	// existing servers are not looked up unless -new-only is set
+	client.EXPECT().
+		ServersSearch(gomock.Any()).
+		Times(0)
+
+	client.EXPECT().
+		SwitchDeviceGetByIdentifierString("leaf-1", false).
+		Return(&metalcloud.SwitchDevice{NetworkEquipmentID: 1, DatacenterName: "dc1"}, nil).
+		AnyTimes()
+
+	// the MAC addresses of SN1 and SN2 are swapped, SN3 takes over the switch interface of SN2
+	yamlFile := createTempFileWithContent("test-*.yaml", `datacenter: dc1
+serialNumber: SN1
+interfaces:
+- mac: aa:bb:cc:dd:ee:02
+  switch: leaf-1
+  switchInterface: Ethernet1
+---
+datacenter: dc1
+serialNumber: SN2
+interfaces:
+- mac: aa:bb:cc:dd:ee:01
+  switch: leaf-1
+  switchInterface: Ethernet2
+---
+datacenter: dc1
+serialNumber: SN3
+interfaces:
+- mac: aa:bb:cc:dd:ee:02
+  switch: leaf-1
+  switchInterface: Ethernet2
+`)
+	defer os.Remove(yamlFile)
+
+	c := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": yamlFile,
+		"format":                "yaml",
+		"validate_only":         true,
+		"report_format":         "csv",
+	})
+
+	ret, err := serverImportBatchCmd(&c, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("3,SN3,dc1,"))
+	Expect(ret).NotTo(ContainSubstring("duplicated"))
+
+	// a serial number repeated in the file is always rejected
+	yamlFile = createTempFileWithContent("test-*.yaml", `datacenter: dc1
+serialNumber: SN1
+---
+datacenter: dc1
+serialNumber: sn1
+`)
+	defer os.Remove(yamlFile)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(os.Stdin, &stdout)
+	defer configuration.SetConsoleIOChannel(os.Stdin, os.Stdout)
+
+	c = command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": yamlFile,
+		"format":                "yaml",
+		"validate_only":         true,
+		"report_format":         "csv",
+	})
+
+	_, err = serverImportBatchCmd(&c, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("1 of 2 records failed validation"))
+	Expect(stdout.String()).To(ContainSubstring("serial number sn1 is duplicated on row 1"))
+}
+
+func TestIsCSVInputFile(t *testing.T) {
+	RegisterTestingT(t)
+
+	Expect(isCSVInputFile("csv", "servers.yaml")).To(BeTrue())
+	Expect(isCSVInputFile("CSV", "servers")).To(BeTrue())
+	Expect(isCSVInputFile("json", "servers.CSV")).To(BeTrue())
+	Expect(isCSVInputFile("", "servers.csv")).To(BeTrue())
+	Expect(isCSVInputFile("yaml", "servers.yaml")).To(BeFalse())
+	Expect(isCSVInputFile("", "servers")).To(BeFalse())
+}
+
+const _serverImportCSVFixture = `Serial,DC,Model,Rack,U,NIC1 MAC,NIC1 Switch,NIC1 Port,NIC2 MAC,NIC2 Switch,NIC2 Port
+SN1,dc1,M.15.15.1,R12,10,aa:bb:cc:dd:ee:01,leaf-1,Ethernet1,aa:bb:cc:dd:ee:02,leaf-1,Ethernet2
+SN2,dc1,M.15.15.1,R12,11,aa:bb:cc:dd:ee:03,leaf-1,Ethernet3,,,
+`
+
+const _serverImportCSVMappingFixture = `
+serialNumber: Serial
+datacenter: DC
+serverType: Model
+rack: Rack
+rackPositionLowerUnit: U
+rackPositionUpperUnit: U
+interfaces:
+- mac: NIC1 MAC
+  switch: NIC1 Switch
+  switchInterface: NIC1 Port
+- mac: NIC2 MAC
+  switch: NIC2 Switch
+  switchInterface: NIC2 Port
+`
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	"github.com/metalsoft-io/metalcloud-cli/internal/filtering"
+	"github.com/metalsoft-io/tableformatter"
+)
+
+// serverLifecycleStatuses are the statuses in which no operation is in progress on a server
+var serverLifecycleStatuses = map[string]bool{
+	"available":         true,
+	"unavailable":       true,
+	"defective":         true,
+	"removed_from_rack": true,
+	"decommissioned":    true,
+}
+
+// serverLifecycleTarget is a server selected for decommissioning or deletion together with the result of the safety checks
+type serverLifecycleTarget struct {
+	Server   metalcloud.ServerSearchResult
+	Problems []string
+	Result   string
+}
+
+func serverDecommissionCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+	return serverLifecycleCmd(c, client, "decommission", "decommissioned", func(serverID int, skipIPMI bool) error {
+		//same as status-set decommissioned
+		if err := client.ServerStatusUpdate(serverID, "unavailable"); err != nil {
+			return err
+		}
+		return client.ServerDecomission(serverID, skipIPMI)
+	})
+}
+
+func serverDeleteCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+	return serverLifecycleCmd(c, client, "delete", "deleted", func(serverID int, skipIPMI bool) error {
+		return client.ServerDelete(serverID, skipIPMI)
+	})
+}
+
+func serverLifecycleCmd(c *command.Command, client metalcloud.MetalCloudClient, operation string, done string, apply func(serverID int, skipIPMI bool) error) (string, error) {
+
+	targets, err := getServerLifecycleTargets(c, client)
+	if err != nil {
+		return "", err
+	}
+
+	blocked := 0
+	for i := range targets {
+		t := &targets[i]
+
+		if operation == "decommission" && t.Server.ServerStatus == "decommissioned" {
+			t.Problems = append(t.Problems, "already decommissioned")
+		}
+
+		problems, err := getServerLifecycleProblems(t.Server, client)
+		if err != nil {
+			return "", err
+		}
+		t.Problems = append(t.Problems, problems...)
+
+		if len(t.Problems) > 0 {
+			blocked++
+		}
+	}
+
+	format := command.GetStringParam(c.Arguments["format"])
+
+	summary, err := renderServerLifecycleSummary(targets, getServerLifecycleImpact(operation, targets), format)
+	if err != nil {
+		return "", err
+	}
+
+	//the impact summary is shown before asking for confirmation. The json, csv and yaml formats output a single
+	//document: the summary if nothing is changed, the final report otherwise.
+	if format == "" {
+		fmt.Fprint(configuration.GetStdout(), summary)
+	}
+
+	if blocked > 0 {
+		if format != "" {
+			fmt.Fprint(configuration.GetStdout(), summary)
+		}
+		return "", fmt.Errorf("%d of %d servers failed the safety checks. Nothing was changed", blocked, len(targets))
+	}
+
+	if command.GetBoolParam(c.Arguments["dry_run"]) {
+		if format != "" {
+			return summary, nil
+		}
+		return "", nil
+	}
+
+	confirm, err := command.ConfirmCommand(c, func() string {
+
+		confirmationMessage := fmt.Sprintf("This will %s %s servers. Are you sure? Type \"yes\" to continue:",
+			operation,
+			colors.Red(fmt.Sprintf("%d", len(targets))),
+		)
+
+		//this is simply so that we don't output a text on the command line under go test
+		if strings.HasSuffix(os.Args[0], ".test") {
+			confirmationMessage = ""
+		}
+
+		return confirmationMessage
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if !confirm {
+		return "", fmt.Errorf("operation not confirmed. Aborting")
+	}
+
+	skipIPMI := command.GetBoolParam(c.Arguments["skip_ipmi"])
+
+	failed := 0
+	for i := range targets {
+		t := &targets[i]
+
+		if err := apply(t.Server.ServerID, skipIPMI); err != nil {
+			t.Problems = append(t.Problems, err.Error())
+			failed++
+			continue
+		}
+
+		t.Result = done
+	}
+
+	report, err := renderServerLifecycleSummary(targets, fmt.Sprintf("%d of %d servers %s", len(targets)-failed, len(targets), done), format)
+	if err != nil {
+		return "", err
+	}
+
+	if failed > 0 {
+		fmt.Fprint(configuration.GetStdout(), report)
+		return "", fmt.Errorf("could not %s %d of %d servers", operation, failed, len(targets))
+	}
+
+	return report, nil
+}
+
+// getServerLifecycleTargets returns the servers selected with -id or -filter
+func getServerLifecycleTargets(c *command.Command, client metalcloud.MetalCloudClient) ([]serverLifecycleTarget, error) {
+
+	ids, hasIDs := command.GetStringParamOk(c.Arguments["server_ids"])
+	filter, hasFilter := command.GetStringParamOk(c.Arguments["filter"])
+
+	if hasIDs == hasFilter {
+		return nil, fmt.Errorf("exactly one of -id or -filter is required")
+	}
+
+	requested := []int{}
+	if hasIDs {
+		for _, s := range strings.Split(ids, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return nil, fmt.Errorf("-id must be a comma separated list of server ids: %v", err)
+			}
+			requested = append(requested, id)
+		}
+
+		filter = fmt.Sprintf("server_id:%s", strings.ReplaceAll(ids, " ", ""))
+	}
+
+	list, err := client.ServersSearch(filtering.ConvertToSearchFieldFormat(filter))
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[int]bool{}
+	targets := []serverLifecycleTarget{}
+	for _, s := range *list {
+		found[s.ServerID] = true
+		targets = append(targets, serverLifecycleTarget{Server: s})
+	}
+
+	for _, id := range requested {
+		if !found[id] {
+			return nil, fmt.Errorf("server #%d was not found", id)
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no servers match the filter %s", filter)
+	}
+
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Server.ServerID < targets[j].Server.ServerID
+	})
+
+	return targets, nil
+}
+
+// getServerLifecycleProblems checks that a server is not allocated, has no running jobs and no pending operations
+func getServerLifecycleProblems(s metalcloud.ServerSearchResult, client metalcloud.MetalCloudClient) ([]string, error) {
+	problems := []string{}
+
+	if len(s.InstanceID) > 0 && s.InstanceID[0] != 0 {
+		label := ""
+		if len(s.InstanceLabel) > 0 {
+			label = s.InstanceLabel[0] + " "
+		}
+		infrastructureID := 0
+		if len(s.InfrastructureID) > 0 {
+			infrastructureID = s.InfrastructureID[0]
+		}
+		problems = append(problems, fmt.Sprintf("allocated to instance %s(#%d) of infrastructure #%d", label, s.InstanceID[0], infrastructureID))
+	} else if s.ServerStatus == "used" {
+		problems = append(problems, "allocated to an instance")
+	}
+
+	if !serverLifecycleStatuses[s.ServerStatus] && s.ServerStatus != "used" {
+		problems = append(problems, fmt.Sprintf("status %s indicates an operation in progress", s.ServerStatus))
+	}
+
+	jobs, err := client.AFCSearch(fmt.Sprintf("+server_id:%d", s.ServerID), 0, 100)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, j := range *jobs {
+		if j.AFCStatus == "running" || j.AFCStatus == "thrown_error_while_retrying" {
+			problems = append(problems, fmt.Sprintf("job #%d %s is %s", j.AFCID, j.AFCFunctionName, strings.ReplaceAll(j.AFCStatus, "_", " ")))
+		}
+	}
+
+	return problems, nil
+}
+
+// getServerLifecycleImpact summarizes the servers affected by an operation
+func getServerLifecycleImpact(operation string, targets []serverLifecycleTarget) string {
+	datacenters := map[string]int{}
+	statuses := map[string]int{}
+	for _, t := range targets {
+		datacenters[t.Server.DatacenterName]++
+		statuses[t.Server.ServerStatus]++
+	}
+
+	describe := func(m map[string]int) string {
+		keys := []string{}
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		parts := []string{}
+		for _, k := range keys {
+			parts = append(parts, fmt.Sprintf("%d %s", m[k], k))
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	impact := "their interfaces and switch links are removed"
+	if operation == "delete" {
+		impact = "they are removed permanently"
+	}
+
+	return fmt.Sprintf("Servers to %s: %d (datacenters: %s; statuses: %s), %s",
+		operation,
+		len(targets),
+		describe(datacenters),
+		describe(statuses),
+		impact,
+	)
+}
+
+func renderServerLifecycleSummary(targets []serverLifecycleTarget, title string, format string) (string, error) {
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "ID",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "SERIAL_NUMBER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "DATACENTER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "SERVER_TYPE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "STATUS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "RACK",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "CHECKS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 40,
+		},
+	}
+
+	data := [][]interface{}{}
+	for _, t := range targets {
+		checks := colors.Green("ok")
+		if t.Result != "" {
+			checks = colors.Green(t.Result)
+		}
+		if len(t.Problems) > 0 {
+			checks = colors.Red(strings.Join(t.Problems, "; "))
+		}
+
+		rack := t.Server.ServerRackName
+		if t.Server.ServerRackPositionLowerUnit != "" {
+			rack = fmt.Sprintf("%s U%s", rack, t.Server.ServerRackPositionLowerUnit)
+		}
+
+		data = append(data, []interface{}{
+			t.Server.ServerID,
+			t.Server.ServerSerialNumber,
+			t.Server.DatacenterName,
+			t.Server.ServerTypeName,
+			colorizeServerStatus(t.Server.ServerStatus),
+			strings.TrimSpace(rack),
+			checks,
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	return table.RenderTable("Servers", title, format)
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	. "github.com/onsi/gomega"
+)
+
+func TestServerDecommissionCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		ServersSearch(gomock.Any()).
+		Return(&[]metalcloud.ServerSearchResult{
+			{ServerID: 11, ServerSerialNumber: "SN11", DatacenterName: "dc1", ServerStatus: "defective"},
+			{ServerID: 10, ServerSerialNumber: "SN10", DatacenterName: "dc1", ServerStatus: "available"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		AFCSearch(gomock.Any(), 0, 100).
+		Return(&[]metalcloud.AFCSearchResult{{AFCID: 5, AFCStatus: "finished"}}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServerStatusUpdate(gomock.Any(), "unavailable").
+		Return(nil).
+		Times(2)
+
+	client.EXPECT().
+		ServerDecomission(gomock.Any(), false).
+		Return(nil).
+		Times(2)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(strings.NewReader(""), &stdout)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"server_ids": "10,11",
+		"format":     "csv",
+		"dry_run":    true,
+	})
+
+	// the structured formats output a single document
+	ret, err := serverDecommissionCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("10,SN10,dc1,,available,,ok"))
+	Expect(stdout.String()).To(Equal(""))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"server_ids":  "10,11",
+		"format":      "csv",
+		"autoconfirm": true,
+	})
+
+	ret, err = serverDecommissionCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("11,SN11,dc1,,defective,,decommissioned"))
+	Expect(stdout.String()).To(Equal(""))
+
+	// the human readable format shows the impact summary before the report
+	cmd = command.MakeCommand(map[string]interface{}{
+		"server_ids": "10,11",
+		"dry_run":    true,
+	})
+
+	ret, err = serverDecommissionCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal(""))
+	Expect(stdout.String()).To(ContainSubstring("SN10"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"server_ids": "10,12",
+	})
+
+	_, err = serverDecommissionCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("server #12 was not found"))
+}
+
+func TestServerDeleteCmdSafetyChecks(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		ServersSearch("+datacenter_name:dc1").
+		Return(&[]metalcloud.ServerSearchResult{
+			{ServerID: 10, ServerStatus: "decommissioned"},
+			{ServerID: 11, ServerStatus: "used", InstanceID: []int{100}, InstanceLabel: []string{"instance-100"}, InfrastructureID: []int{7}},
+			{ServerID: 12, ServerStatus: "cleaning"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		AFCSearch("+server_id:10", 0, 100).
+		Return(&[]metalcloud.AFCSearchResult{{AFCID: 5, AFCFunctionName: "server_firmware_upgrade", AFCStatus: "running"}}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		AFCSearch(gomock.Any(), 0, 100).
+		Return(&[]metalcloud.AFCSearchResult{}, nil).
+		AnyTimes()
+
+	// nothing is deleted when any of the servers fails the checks
+	client.EXPECT().
+		ServerDelete(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(strings.NewReader(""), &stdout)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"filter":      "datacenter_name:dc1",
+		"format":      "csv",
+		"autoconfirm": true,
+	})
+
+	_, err := serverDeleteCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("3 of 3 servers failed the safety checks"))
+	Expect(stdout.String()).To(ContainSubstring("job #5 server_firmware_upgrade is running"))
+	Expect(stdout.String()).To(ContainSubstring("allocated to instance instance-100 (#100) of infrastructure #7"))
+	Expect(stdout.String()).To(ContainSubstring("status cleaning indicates an operation in progress"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"filter":     "datacenter_name:dc1",
+		"server_ids": "10",
+	})
+
+	_, err = serverDeleteCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+}
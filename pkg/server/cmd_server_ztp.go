@@ -0,0 +1,386 @@
+package server
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/tableformatter"
+)
+
+// serverDefaultCredentialsCSVColumns are the columns of the ZTP credentials CSV files.
+// They are the same as the keys of the yaml format of ztp-credentials-add-batch.
+var serverDefaultCredentialsCSVColumns = []string{"datacenter", "serialNumber", "BMCMACAddress", "username", "password"}
+
+// getServerDefaultCredentialsFromCSVFile reads ZTP credentials from a CSV file with a header row
+func getServerDefaultCredentialsFromCSVFile(filePath string) ([]metalcloud.ServerDefaultCredentials, error) {
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading %s: %v", filePath, err)
+	}
+
+	if len(lines) == 0 {
+		return []metalcloud.ServerDefaultCredentials{}, nil
+	}
+
+	columnIndex := map[string]int{}
+	for i, h := range lines[0] {
+		columnIndex[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	for _, column := range serverDefaultCredentialsCSVColumns {
+		if _, ok := columnIndex[strings.ToLower(column)]; !ok {
+			return nil, fmt.Errorf("column '%s' was not found in the header of %s", column, filePath)
+		}
+	}
+
+	records := []metalcloud.ServerDefaultCredentials{}
+
+	for _, line := range lines[1:] {
+		get := func(column string) string {
+			idx := columnIndex[strings.ToLower(column)]
+			if idx >= len(line) {
+				return ""
+			}
+			return strings.TrimSpace(line[idx])
+		}
+
+		records = append(records, metalcloud.ServerDefaultCredentials{
+			DatacenterName:                   get("datacenter"),
+			ServerSerialNumber:               get("serialNumber"),
+			ServerBMCMACAddress:              get("BMCMACAddress"),
+			ServerDefaultCredentialsUsername: get("username"),
+			ServerDefaultCredentialsPassword: get("password"),
+		})
+	}
+
+	return records, nil
+}
+
+// validateServerDefaultCredentials checks the records of a batch before anything is uploaded.
+// Problems are prefixed with the position of the record in the file.
+func validateServerDefaultCredentials(records []metalcloud.ServerDefaultCredentials) []string {
+	problems := []string{}
+
+	serialNumbers := map[string]int{}
+	macs := map[string]int{}
+
+	for i, record := range records {
+		addProblem := func(format string, a ...interface{}) {
+			problems = append(problems, fmt.Sprintf("record %d: %s", i+1, fmt.Sprintf(format, a...)))
+		}
+
+		if record.DatacenterName == "" {
+			addProblem("datacenter is required")
+		}
+
+		if record.ServerDefaultCredentialsUsername == "" || record.ServerDefaultCredentialsPassword == "" {
+			addProblem("username and password are required")
+		}
+
+		if record.ServerSerialNumber == "" {
+			addProblem("serial number is required")
+		} else if other, ok := serialNumbers[record.ServerSerialNumber]; ok {
+			addProblem("serial number %s is duplicated on record %d", record.ServerSerialNumber, other)
+		} else {
+			serialNumbers[record.ServerSerialNumber] = i + 1
+		}
+
+		mac, err := normalizeMACAddress(record.ServerBMCMACAddress)
+		if err != nil {
+			addProblem("invalid BMC MAC address '%s'", record.ServerBMCMACAddress)
+		} else if other, ok := macs[mac]; ok {
+			addProblem("BMC MAC address %s is duplicated on record %d", record.ServerBMCMACAddress, other)
+		} else {
+			macs[mac] = i + 1
+		}
+	}
+
+	return problems
+}
+
+// normalizeMACAddress returns the lowercase, colon separated form of a 6 byte MAC address
+func normalizeMACAddress(s string) (string, error) {
+	mac, err := net.ParseMAC(strings.TrimSpace(s))
+	if err != nil {
+		return "", err
+	}
+	if len(mac) != 6 {
+		return "", fmt.Errorf("%s is not a 6 byte MAC address", s)
+	}
+	return mac.String(), nil
+}
+
+// sameMACAddress compares two MAC addresses regardless of case and separators
+func sameMACAddress(a string, b string) bool {
+	macA, errA := normalizeMACAddress(a)
+	macB, errB := normalizeMACAddress(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return macA == macB
+}
+
+func serverDefaultCredentialsExportCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	datacenter, ok := command.GetStringParamOk(c.Arguments["datacenter"])
+	if !ok {
+		return "", fmt.Errorf("-datacenter is required")
+	}
+
+	showCredentials := command.GetBoolParam(c.Arguments["show_credentials"])
+
+	list, err := client.ServerDefaultCredentials(datacenter, showCredentials)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	w.Write(serverDefaultCredentialsCSVColumns)
+	for _, cred := range *list {
+		password := ""
+		if showCredentials {
+			password = cred.ServerDefaultCredentialsPassword
+		}
+
+		w.Write([]string{
+			cred.DatacenterName,
+			cred.ServerSerialNumber,
+			cred.ServerBMCMACAddress,
+			cred.ServerDefaultCredentialsUsername,
+			password,
+		})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+
+	if filePath, ok := command.GetStringParamOk(c.Arguments["file"]); ok {
+		// the file can contain BMC passwords
+		err := os.WriteFile(filePath, buf.Bytes(), 0600)
+		if err != nil {
+			return "", err
+		}
+
+		return fmt.Sprintf("%d records exported to %s\n", len(*list), filePath), nil
+	}
+
+	return buf.String(), nil
+}
+
+// serverZTPCoverageRow is a server or a credential record of the ztp-coverage report
+type serverZTPCoverageRow struct {
+	Kind         string
+	ID           int
+	SerialNumber string
+	MAC          string
+	Status       string
+	Details      string
+}
+
+const (
+	ztpCoverageCovered      = "covered"
+	ztpCoverageMissing      = "no-credentials"
+	ztpCoverageUsed         = "matched"
+	ztpCoverageNeverMatched = "never-matched"
+)
+
+func serverZTPCoverageCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	datacenter, ok := command.GetStringParamOk(c.Arguments["datacenter"])
+	if !ok {
+		return "", fmt.Errorf("-datacenter is required")
+	}
+
+	creds, err := client.ServerDefaultCredentials(datacenter, false)
+	if err != nil {
+		return "", err
+	}
+
+	list, err := client.ServersSearch(fmt.Sprintf("+datacenter_name:%s", datacenter))
+	if err != nil {
+		return "", err
+	}
+
+	credsByMAC := map[string]int{}
+	credsBySerial := map[string]int{}
+	for i, cred := range *creds {
+		if mac, err := normalizeMACAddress(cred.ServerBMCMACAddress); err == nil {
+			credsByMAC[mac] = i
+		}
+		if cred.ServerSerialNumber != "" {
+			credsBySerial[cred.ServerSerialNumber] = i
+		}
+	}
+
+	matched := map[int]bool{}
+	serverRows := []serverZTPCoverageRow{}
+
+	for _, s := range *list {
+		// the BMC MAC address is not returned by the search
+		server, err := client.ServerGet(s.ServerID, false)
+		if err != nil {
+			return "", err
+		}
+
+		mac, err := normalizeMACAddress(server.ServerBMCMACAddress)
+		if err != nil {
+			// only servers with a discovered BMC are relevant
+			continue
+		}
+
+		row := serverZTPCoverageRow{
+			Kind:         "server",
+			ID:           s.ServerID,
+			SerialNumber: s.ServerSerialNumber,
+			MAC:          mac,
+			Status:       ztpCoverageMissing,
+		}
+
+		if i, ok := credsByMAC[mac]; ok {
+			matched[i] = true
+			row.Status = ztpCoverageCovered
+			row.Details = fmt.Sprintf("credentials #%d", (*creds)[i].ServerDefaultCredentialsID)
+		} else if i, ok := credsBySerial[s.ServerSerialNumber]; ok && s.ServerSerialNumber != "" {
+			matched[i] = true
+			row.Status = ztpCoverageCovered
+			row.Details = fmt.Sprintf("credentials #%d matched by serial number, BMC MAC is %s", (*creds)[i].ServerDefaultCredentialsID, (*creds)[i].ServerBMCMACAddress)
+		}
+
+		serverRows = append(serverRows, row)
+	}
+
+	credentialRows := []serverZTPCoverageRow{}
+	for i, cred := range *creds {
+		row := serverZTPCoverageRow{
+			Kind:         "credentials",
+			ID:           cred.ServerDefaultCredentialsID,
+			SerialNumber: cred.ServerSerialNumber,
+			MAC:          cred.ServerBMCMACAddress,
+			Status:       ztpCoverageUsed,
+		}
+
+		if !matched[i] {
+			row.Status = ztpCoverageNeverMatched
+			if _, err := normalizeMACAddress(cred.ServerBMCMACAddress); err != nil {
+				row.Details = "invalid BMC MAC address"
+			}
+		}
+
+		credentialRows = append(credentialRows, row)
+	}
+
+	missing := 0
+	for _, row := range serverRows {
+		if row.Status == ztpCoverageMissing {
+			missing++
+		}
+	}
+
+	neverMatched := 0
+	for _, row := range credentialRows {
+		if row.Status == ztpCoverageNeverMatched {
+			neverMatched++
+		}
+	}
+
+	title := fmt.Sprintf("ZTP credentials coverage for the %s datacenter: %d of %d discovered BMCs have no credentials, %d of %d credential records never matched a server",
+		datacenter,
+		missing,
+		len(serverRows),
+		neverMatched,
+		len(credentialRows),
+	)
+
+	showAll := command.GetBoolParam(c.Arguments["show_all"])
+
+	rows := []serverZTPCoverageRow{}
+	for _, row := range append(serverRows, credentialRows...) {
+		if showAll || row.Status == ztpCoverageMissing || row.Status == ztpCoverageNeverMatched {
+			rows = append(rows, row)
+		}
+	}
+
+	return renderServerZTPCoverage(rows, title, command.GetStringParam(c.Arguments["format"]))
+}
+
+func renderServerZTPCoverage(rows []serverZTPCoverageRow, title string, format string) (string, error) {
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "TYPE",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "ID",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "SERIAL_NUMBER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "BMC_MAC",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 17,
+		},
+		{
+			FieldName: "STATUS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "DETAILS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 40,
+		},
+	}
+
+	data := [][]interface{}{}
+	for _, row := range rows {
+		status := colors.Green(row.Status)
+		if row.Status == ztpCoverageMissing || row.Status == ztpCoverageNeverMatched {
+			status = colors.Red(row.Status)
+		}
+
+		data = append(data, []interface{}{
+			row.Kind,
+			row.ID,
+			row.SerialNumber,
+			row.MAC,
+			status,
+			row.Details,
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	return table.RenderTable("Records", title, format)
+}
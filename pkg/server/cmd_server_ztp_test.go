@@ -0,0 +1,160 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	. "github.com/onsi/gomega"
+)
+
+func TestServerDefaultCredentialsAddBatchFromCSV(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		Datacenters(true).
+		Return(&map[string]metalcloud.Datacenter{"dc1": {DatacenterName: "dc1"}}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServerDefaultCredentials("dc1", false).
+		Return(&[]metalcloud.ServerDefaultCredentials{
+			{ServerDefaultCredentialsID: 1, DatacenterName: "dc1", ServerSerialNumber: "SN1", ServerBMCMACAddress: "AA:BB:CC:DD:EE:01"},
+		}, nil).
+		AnyTimes()
+
+	// the record with the already known MAC address is skipped
+	client.EXPECT().
+		ServerDefaultCredentialsAdd([]metalcloud.ServerDefaultCredentials{
+			{DatacenterName: "dc1", ServerSerialNumber: "SN3", ServerBMCMACAddress: "aa:bb:cc:dd:ee:03", ServerDefaultCredentialsUsername: "root", ServerDefaultCredentialsPassword: "calvin"},
+		}).
+		Return(nil).
+		Times(1)
+
+	f := createTempFileWithContent("ztp*.csv", `datacenter,serialNumber,BMCMACAddress,username,password
+dc1,SN2,aa:bb:cc:dd:ee:01,root,calvin
+dc1,SN3,aa:bb:cc:dd:ee:03,root,calvin
+`)
+	defer os.Remove(f)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": f,
+	})
+
+	_, err := serverDefaultCredentialsAddBatchCmd(&cmd, client)
+	Expect(err).To(BeNil())
+
+	// nothing is uploaded if any record is invalid
+	f2 := createTempFileWithContent("ztp*.csv", `datacenter,serialNumber,BMCMACAddress,username,password
+dc1,SN4,aa:bb:cc:dd:ee:04,root,calvin
+dc1,SN4,aa:bb:cc:dd:ee,root,calvin
+dc1,SN5,AA-BB-CC-DD-EE-04,root,
+`)
+	defer os.Remove(f2)
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": f2,
+	})
+
+	_, err = serverDefaultCredentialsAddBatchCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("record 2: serial number SN4 is duplicated on record 1"))
+	Expect(err.Error()).To(ContainSubstring("record 2: invalid BMC MAC address 'aa:bb:cc:dd:ee'"))
+	Expect(err.Error()).To(ContainSubstring("record 3: username and password are required"))
+	Expect(err.Error()).To(ContainSubstring("record 3: BMC MAC address AA-BB-CC-DD-EE-04 is duplicated on record 1"))
+}
+
+func TestServerDefaultCredentialsExportCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		ServerDefaultCredentials("dc1", true).
+		Return(&[]metalcloud.ServerDefaultCredentials{
+			{ServerDefaultCredentialsID: 1, DatacenterName: "dc1", ServerSerialNumber: "SN1", ServerBMCMACAddress: "aa:bb:cc:dd:ee:01", ServerDefaultCredentialsUsername: "root", ServerDefaultCredentialsPassword: "calvin"},
+		}, nil).
+		AnyTimes()
+
+	filePath := filepath.Join(t.TempDir(), "ztp.csv")
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"datacenter":       "dc1",
+		"show_credentials": true,
+		"file":             filePath,
+	})
+
+	_, err := serverDefaultCredentialsExportCmd(&cmd, client)
+	Expect(err).To(BeNil())
+
+	// the exported file can be imported again
+	records, err := getServerDefaultCredentialsFromCSVFile(filePath)
+	Expect(err).To(BeNil())
+	Expect(records).To(Equal([]metalcloud.ServerDefaultCredentials{
+		{DatacenterName: "dc1", ServerSerialNumber: "SN1", ServerBMCMACAddress: "aa:bb:cc:dd:ee:01", ServerDefaultCredentialsUsername: "root", ServerDefaultCredentialsPassword: "calvin"},
+	}))
+}
+
+func TestServerZTPCoverageCmd(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		ServerDefaultCredentials("dc1", false).
+		Return(&[]metalcloud.ServerDefaultCredentials{
+			{ServerDefaultCredentialsID: 1, ServerSerialNumber: "SN1", ServerBMCMACAddress: "AA:BB:CC:DD:EE:01"},
+			{ServerDefaultCredentialsID: 2, ServerSerialNumber: "SN2", ServerBMCMACAddress: "aa:bb:cc:dd:ee:99"},
+			{ServerDefaultCredentialsID: 3, ServerSerialNumber: "SN9", ServerBMCMACAddress: "aa:bb:cc:dd:ee:09"},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		ServersSearch("+datacenter_name:dc1").
+		Return(&[]metalcloud.ServerSearchResult{
+			{ServerID: 10, ServerSerialNumber: "SN1"},
+			{ServerID: 11, ServerSerialNumber: "SN2"},
+			{ServerID: 12, ServerSerialNumber: "SN3"},
+			{ServerID: 13, ServerSerialNumber: "SN4"},
+		}, nil).
+		AnyTimes()
+
+	macs := map[int]string{10: "aa:bb:cc:dd:ee:01", 11: "aa:bb:cc:dd:ee:02", 12: "aa:bb:cc:dd:ee:03"}
+
+	client.EXPECT().
+		ServerGet(gomock.Any(), false).
+		DoAndReturn(func(serverID int, decryptPasswd bool) (*metalcloud.Server, error) {
+			return &metalcloud.Server{ServerID: serverID, ServerBMCMACAddress: macs[serverID]}, nil
+		}).
+		AnyTimes()
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+		"format":     "csv",
+	})
+
+	ret, err := serverZTPCoverageCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("server,12,SN3,aa:bb:cc:dd:ee:03,no-credentials,"))
+	Expect(ret).To(ContainSubstring("credentials,3,SN9,aa:bb:cc:dd:ee:09,never-matched,"))
+	Expect(ret).NotTo(ContainSubstring("server,10,"))
+	Expect(ret).NotTo(ContainSubstring("SN4"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+		"show_all":   true,
+		"format":     "csv",
+	})
+
+	ret, err = serverZTPCoverageCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(ContainSubstring("server,10,SN1,aa:bb:cc:dd:ee:01,covered,credentials #1"))
+	Expect(ret).To(ContainSubstring("server,11,SN2,aa:bb:cc:dd:ee:02,covered,\"credentials #2 matched by serial number"))
+	Expect(ret).To(ContainSubstring("credentials,2,SN2,aa:bb:cc:dd:ee:99,matched,"))
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
 	"github.com/metalsoft-io/metalcloud-cli/internal/command"
 	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	"github.com/metalsoft-io/metalcloud-cli/internal/ipam"
 	"github.com/metalsoft-io/tableformatter"
 )
 
@@ -63,6 +64,7 @@ var SubnetOOBCmds = []command.Command{
 				"read_config_from_file": c.FlagSet.String("raw-config", command.NilDefaultStr, colors.Red("(Required)")+" Read configuration from file"),
 				"read_config_from_pipe": c.FlagSet.Bool("pipe", false, colors.Green("(Flag)")+" If set, read configuration from pipe instead of from a file. Either this flag or the -raw-config option must be used."),
 				"return_id":             c.FlagSet.Bool("return-id", false, "Will print the ID of the created object. Useful for automating tasks."),
+				"force":                 c.FlagSet.Bool("force", false, colors.Green("(Flag)")+" If set the OOB subnet is created even if it overlaps subnet pools or other OOB subnets of the datacenter."),
 			}
 		},
 		ExecuteFunc:         subnetOOBCreateCmd,
@@ -273,6 +275,18 @@ func subnetOOBCreateCmd(c *command.Command, client metalcloud.MetalCloudClient)
 		return "", err
 	}
 
+	if !command.GetBoolParam(c.Arguments["force"]) && sn.SubnetOOBRangeStartHumanReadable != "" {
+		r, err := ipam.AddressRange(sn.SubnetOOBRangeStartHumanReadable, sn.SubnetOOBRangeEndHumanReadable)
+		if err != nil {
+			return "", err
+		}
+
+		err = ipam.CheckOverlaps(r, sn.DatacenterName, client)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	ret, err := client.SubnetOOBCreate(sn)
 	if err != nil {
 		return "", err
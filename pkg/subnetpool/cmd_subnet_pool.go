@@ -3,6 +3,7 @@ package subnetpool
 import (
 	"flag"
 	"fmt"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
@@ -11,6 +12,7 @@ import (
 	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
 	"github.com/metalsoft-io/metalcloud-cli/internal/command"
 	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	"github.com/metalsoft-io/metalcloud-cli/internal/ipam"
 	"github.com/metalsoft-io/tableformatter"
 )
 
@@ -66,6 +68,7 @@ var SubnetPoolCmds = []command.Command{
 				"read_config_from_file": c.FlagSet.String("raw-config", command.NilDefaultStr, colors.Red("(Required)")+" Read configuration from file"),
 				"read_config_from_pipe": c.FlagSet.Bool("pipe", false, colors.Green("(Flag)")+" If set, read configuration from pipe instead of from a file. Either this flag or the -raw-config option must be used."),
 				"return_id":             c.FlagSet.Bool("return-id", false, "Will print the ID of the created Useful for automating tasks."),
+				"force":                 c.FlagSet.Bool("force", false, colors.Green("(Flag)")+" If set the subnet pool is created even if it overlaps other subnet pools or OOB subnets of the datacenter."),
 			}
 		},
 		ExecuteFunc:         subnetPoolCreateCmd,
@@ -91,6 +94,31 @@ var SubnetPoolCmds = []command.Command{
 		Endpoint:            configuration.ExtendedEndpoint,
 		PermissionsRequired: []string{command.SUBNETS_WRITE},
 	},
+	{
+		Description:  "Find a free prefix for a new subnet pool or OOB subnet.",
+		Subject:      "subnet-pool",
+		AltSubject:   "subnet",
+		Predicate:    "find-free",
+		AltPredicate: "free",
+		FlagSet:      flag.NewFlagSet("find free prefix", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"datacenter":  c.FlagSet.String("datacenter", command.NilDefaultStr, colors.Red("(Required)")+" The datacenter in which the prefix is used."),
+				"prefix_size": c.FlagSet.String("prefix-size", command.NilDefaultStr, colors.Red("(Required)")+" The size of the prefix, such as /24."),
+				"within":      c.FlagSet.String("within", "10.0.0.0/8", colors.Green("(Optional)")+" The prefix in which to search."),
+			}
+		},
+		ExecuteFunc:         subnetFindFreeCmd,
+		AdminEndpoint:       configuration.DeveloperEndpoint,
+		Endpoint:            configuration.ExtendedEndpoint,
+		PermissionsRequired: []string{command.SUBNETS_READ},
+		Example: `
+The subnet pools and the OOB subnets of the datacenter are considered used.
+
+metalcloud-cli subnet find-free --datacenter dc1 --prefix-size /24
+metalcloud-cli subnet find-free --datacenter dc1 --prefix-size /26 --within 172.16.0.0/12
+`,
+	},
 }
 
 func subnetPoolListCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
@@ -155,43 +183,58 @@ func subnetPoolListCmd(c *command.Command, client metalcloud.MetalCloudClient) (
 		},
 	}
 
-	data := [][]interface{}{}
-	for _, s := range *list {
+	pools := *list
 
-		prefixStr := fmt.Sprintf("%s/%d", s.SubnetPoolPrefixHumanReadable, s.SubnetPoolPrefixSize)
+	// the owner, utilization and switch of each pool are retrieved in parallel
+	// and users and switches shared by several pools are retrieved only once
+	resolver := command.NewResolver(client)
+	userEmails := make([]string, len(pools))
+	utilizations := make([]string, len(pools))
+	networkEquipmentIdentifiers := make([]string, len(pools))
+
+	err = command.Prefetch(len(pools), command.DefaultResolverConcurrency, func(i int) error {
+		s := pools[i]
 
-		userEmail := ""
 		if s.UserID != 0 {
-			u, err := client.UserGet(s.UserID)
+			u, err := resolver.User(s.UserID)
 			if err != nil {
-				return "", err
+				return err
 			}
-			userEmail = u.UserEmail
+			userEmails[i] = u.UserEmail
 		}
 
 		utilization, err := client.SubnetPoolPrefixSizesStats(s.SubnetPoolID)
-
 		if err != nil {
-			return "", err
+			return err
 		}
 
 		IPAddressesUsableFreePercentOptimistic, err := strconv.ParseFloat(strings.Trim(utilization.IPAddressesUsableFreePercentOptimistic, "%"), 32)
 		if err != nil {
-			return "", err
+			return err
 		}
 
-		utilizationStr := fmt.Sprintf("%d%% used (%s addresses available)", 100-int(IPAddressesUsableFreePercentOptimistic), utilization.IPAddressesUsableCountFree)
+		utilizations[i] = fmt.Sprintf("%d%% used (%s addresses available)", 100-int(IPAddressesUsableFreePercentOptimistic), utilization.IPAddressesUsableCountFree)
 
-		networkEquipmentIdentifier := ""
 		if s.NetworkEquipmentID != 0 {
-			sw, err := client.SwitchDeviceGet(s.NetworkEquipmentID, false)
+			sw, err := resolver.Switch(s.NetworkEquipmentID, false)
 			if err == nil {
 				//sometimes the switch could be deleted without the associated subnets to be present
 				//so we do it this way and silently fail to retrieve the switch here
-				networkEquipmentIdentifier = sw.NetworkEquipmentIdentifierString
+				networkEquipmentIdentifiers[i] = sw.NetworkEquipmentIdentifierString
 			}
 		}
 
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	data := [][]interface{}{}
+	for i, s := range pools {
+
+		prefixStr := fmt.Sprintf("%s/%d", s.SubnetPoolPrefixHumanReadable, s.SubnetPoolPrefixSize)
+
 		data = append(data, []interface{}{
 
 			s.SubnetPoolID,
@@ -199,10 +242,10 @@ func subnetPoolListCmd(c *command.Command, client metalcloud.MetalCloudClient) (
 			s.DatacenterName,
 			s.SubnetPoolDestination,
 			prefixStr,
-			networkEquipmentIdentifier,
-			userEmail,
+			networkEquipmentIdentifiers[i],
+			userEmails[i],
 			s.SubnetPoolIsOnlyForManualAllocation,
-			utilizationStr,
+			utilizations[i],
 		})
 
 	}
@@ -350,6 +393,18 @@ func subnetPoolCreateCmd(c *command.Command, client metalcloud.MetalCloudClient)
 		return "", err
 	}
 
+	if !command.GetBoolParam(c.Arguments["force"]) {
+		r, err := ipam.PrefixRange(sn.SubnetPoolPrefixHumanReadable, sn.SubnetPoolPrefixSize)
+		if err != nil {
+			return "", err
+		}
+
+		err = ipam.CheckOverlaps(r, sn.DatacenterName, client)
+		if err != nil {
+			return "", err
+		}
+	}
+
 	ret, err := client.SubnetPoolCreate(sn)
 	if err != nil {
 		return "", err
@@ -404,3 +459,38 @@ func subnetPoolDeleteCmd(c *command.Command, client metalcloud.MetalCloudClient)
 
 	return "", err
 }
+
+func subnetFindFreeCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	datacenter, ok := command.GetStringParamOk(c.Arguments["datacenter"])
+	if !ok {
+		return "", fmt.Errorf("-datacenter is required")
+	}
+
+	prefixSize, ok := command.GetStringParamOk(c.Arguments["prefix_size"])
+	if !ok {
+		return "", fmt.Errorf("-prefix-size is required")
+	}
+
+	size, err := strconv.Atoi(strings.TrimPrefix(prefixSize, "/"))
+	if err != nil {
+		return "", fmt.Errorf("invalid prefix size %s", prefixSize)
+	}
+
+	within, err := netip.ParsePrefix(command.GetStringParam(c.Arguments["within"]))
+	if err != nil {
+		return "", fmt.Errorf("invalid -within prefix: %v", err)
+	}
+
+	used, err := ipam.GetDatacenterRanges(datacenter, client)
+	if err != nil {
+		return "", err
+	}
+
+	free, err := ipam.FindFree(within, size, used)
+	if err != nil {
+		return "", err
+	}
+
+	return free.String() + "\n", nil
+}
@@ -50,6 +50,66 @@ func TestSubnetPoolList(t *testing.T) {
 
 }
 
+func TestSubnetPoolListResolvesSharedObjectsOnce(t *testing.T) {
+	RegisterTestingT(t)
+
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	list := []metalcloud.SubnetPool{}
+	for i := 1; i <= 20; i++ {
+		list = append(list, metalcloud.SubnetPool{
+			SubnetPoolID:                  i,
+			SubnetPoolPrefixHumanReadable: "10.0.0.0",
+			SubnetPoolPrefixSize:          24,
+			UserID:                        100,
+			NetworkEquipmentID:            200,
+		})
+	}
+
+	client.EXPECT().
+		SubnetPoolSearch("").
+		Return(&list, nil).
+		Times(1)
+
+	client.EXPECT().
+		SubnetPoolPrefixSizesStats(gomock.Any()).
+		Return(&metalcloud.SubnetPoolUtilization{
+			IPAddressesUsableFreePercentOptimistic: "40",
+			IPAddressesUsableCountFree:             "100",
+		}, nil).
+		Times(len(list))
+
+	client.EXPECT().
+		UserGet(100).
+		Return(&metalcloud.User{UserID: 100, UserEmail: "owner@test.com"}, nil).
+		Times(1)
+
+	client.EXPECT().
+		SwitchDeviceGet(200, false).
+		Return(&metalcloud.SwitchDevice{NetworkEquipmentID: 200, NetworkEquipmentIdentifierString: "leaf-1"}, nil).
+		Times(1)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"format": "json",
+	})
+
+	ret, err := subnetPoolListCmd(&cmd, client)
+	Expect(err).To(BeNil())
+
+	var rows []map[string]interface{}
+	err = json.Unmarshal([]byte(ret), &rows)
+	Expect(err).To(BeNil())
+	Expect(rows).To(HaveLen(len(list)))
+
+	for _, row := range rows {
+		Expect(row["USER"]).To(Equal("owner@test.com"))
+		Expect(row["NETWORK_EQUIPMENT"]).To(Equal("leaf-1"))
+		Expect(row["AVAILABLE_IPS"]).To(Equal("60% used (100 addresses available)"))
+	}
+}
+
 func TestSubnetCreate(t *testing.T) {
 	RegisterTestingT(t)
 	ctrl := gomock.NewController(t)
@@ -68,6 +128,16 @@ func TestSubnetCreate(t *testing.T) {
 		Return(&sw, nil).
 		AnyTimes()
 
+	client.EXPECT().
+		SubnetPoolSearch("datacenter_name:es-madrid").
+		Return(&[]metalcloud.SubnetPool{}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SubnetOOBSearch("datacenter_name:es-madrid").
+		Return(&[]metalcloud.SubnetOOB{}, nil).
+		AnyTimes()
+
 	f, err := os.CreateTemp(os.TempDir(), "testconf-*.json")
 	if err != nil {
 		t.Error(err)
@@ -116,6 +186,120 @@ func TestSubnetCreate(t *testing.T) {
 
 }
 
+func TestSubnetCreateOverlap(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	var sw metalcloud.SubnetPool
+
+	err := json.Unmarshal([]byte(_subnetPoolFixture1), &sw)
+	Expect(err).To(BeNil())
+
+	client.EXPECT().
+		SubnetPoolSearch("datacenter_name:es-madrid").
+		Return(&[]metalcloud.SubnetPool{
+			{
+				SubnetPoolID:                  10,
+				SubnetPoolPrefixHumanReadable: "165.60.14.0",
+				SubnetPoolPrefixSize:          24,
+			},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SubnetOOBSearch("datacenter_name:es-madrid").
+		Return(&[]metalcloud.SubnetOOB{}, nil).
+		AnyTimes()
+
+	f, err := os.CreateTemp(os.TempDir(), "testconf-*.json")
+	Expect(err).To(BeNil())
+
+	f.WriteString(_subnetPoolFixture1)
+	f.Close()
+	defer syscall.Unlink(f.Name())
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": f.Name(),
+		"format":                "json",
+	})
+
+	_, err = subnetPoolCreateCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(ContainSubstring("165.60.14.128/25 overlaps subnet pool #10 165.60.14.0/24"))
+
+	client.EXPECT().
+		SubnetPoolCreate(gomock.Any()).
+		Return(&sw, nil).
+		Times(1)
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": f.Name(),
+		"format":                "json",
+		"force":                 true,
+	})
+
+	_, err = subnetPoolCreateCmd(&cmd, client)
+	Expect(err).To(BeNil())
+}
+
+func TestSubnetFindFree(t *testing.T) {
+	RegisterTestingT(t)
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		SubnetPoolSearch("datacenter_name:dc1").
+		Return(&[]metalcloud.SubnetPool{
+			{
+				SubnetPoolID:                  10,
+				SubnetPoolPrefixHumanReadable: "10.0.0.0",
+				SubnetPoolPrefixSize:          24,
+			},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SubnetOOBSearch("datacenter_name:dc1").
+		Return(&[]metalcloud.SubnetOOB{
+			{
+				SubnetOOBID:                      2,
+				SubnetOOBRangeStartHumanReadable: "10.0.1.10",
+				SubnetOOBRangeEndHumanReadable:   "10.0.1.20",
+			},
+		}, nil).
+		AnyTimes()
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"datacenter":  "dc1",
+		"prefix_size": "/24",
+		"within":      "10.0.0.0/16",
+	})
+
+	ret, err := subnetFindFreeCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal("10.0.2.0/24\n"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"datacenter":  "dc1",
+		"prefix_size": "/24",
+		"within":      "10.0.0.0/23",
+	})
+
+	_, err = subnetFindFreeCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"datacenter": "dc1",
+		"within":     "10.0.0.0/16",
+	})
+
+	_, err = subnetFindFreeCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+}
+
 func TestSubnetGet(t *testing.T) {
 	RegisterTestingT(t)
 	ctrl := gomock.NewController(t)
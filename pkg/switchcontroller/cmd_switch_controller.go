@@ -4,6 +4,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"reflect"
+	"sort"
 	"strings"
 
 	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
@@ -135,11 +137,30 @@ fabricConfiguration:
 		InitFunc: func(c *command.Command) {
 			c.Arguments = map[string]interface{}{
 				"network_controller_id_or_identifier_string": c.FlagSet.String("id", command.NilDefaultStr, colors.Red("(Required)")+" Switch controller id or identifier string. "),
+				"dry_run": c.FlagSet.Bool("dry-run", false, colors.Green("(Flag)")+" If set, the switches managed by the controller are shown and the sync is not run."),
+				"format":  c.FlagSet.String("format", command.NilDefaultStr, "The output format. Supported values are 'json','csv','yaml'. The default format is human readable."),
 			}
 		},
 		ExecuteFunc:         switchControllerSyncCmd,
 		Endpoint:            configuration.DeveloperEndpoint,
 		PermissionsRequired: []string{command.SWITCHES_WRITE},
+		Example: `
+The sync registers the switches reported by the controller. The switches of the datacenter are read before and after
+the sync and every switch of the controller is listed with what changed:
+  created    the switch was not registered before the sync
+  updated    the switch was already registered and the sync changed it or made it managed by the controller
+  unchanged  the switch is managed by the controller and the sync did not change it
+  orphaned   the switch was managed by the controller before the sync and no longer is
+  removed    the switch was managed by the controller before the sync and is no longer registered
+
+The switches reported by the controller are only known once the sync runs, so --dry-run compares the switches
+registered to the controller with the switches of its datacenter:
+  managed    the switch is registered to the controller, the details tell if the datacenter lists it differently
+  unmanaged  the switch is not managed by a controller and has the management address of a managed switch
+
+metalcloud-cli switch-controller sync --id 3 --dry-run
+metalcloud-cli switch-controller sync --id 3
+`,
 	},
 	{
 		Description:  "Delete a switch controller.",
@@ -262,22 +283,38 @@ func switchControllersListCmd(c *command.Command, client metalcloud.MetalCloudCl
 
 	}
 
-	data := [][]interface{}{}
+	controllers := []metalcloud.SwitchDeviceController{}
 	for _, switchController := range *list {
-		credentialsUser := ""
-		credentialsPass := ""
+		controllers = append(controllers, switchController)
+	}
+
+	credentialsUsers := make([]string, len(controllers))
+	credentialsPasses := make([]string, len(controllers))
 
-		if showCredentials {
-			swCtrl, err := client.SwitchDeviceControllerGet(switchController.NetworkEquipmentControllerID, showCredentials)
+	if showCredentials {
+		resolver := command.NewResolver(client)
 
+		err = command.Prefetch(len(controllers), command.DefaultResolverConcurrency, func(i int) error {
+			swCtrl, err := resolver.SwitchController(controllers[i].NetworkEquipmentControllerID, showCredentials)
 			if err != nil {
-				return "", err
+				return err
 			}
 
-			credentialsUser = fmt.Sprintf("%s", swCtrl.NetworkEquipmentControllerManagementUsername)
-			credentialsPass = fmt.Sprintf("%s", swCtrl.NetworkEquipmentControllerManagementPassword)
+			credentialsUsers[i] = fmt.Sprintf("%s", swCtrl.NetworkEquipmentControllerManagementUsername)
+			credentialsPasses[i] = fmt.Sprintf("%s", swCtrl.NetworkEquipmentControllerManagementPassword)
 
+			return nil
+		})
+		if err != nil {
+			return "", err
 		}
+	}
+
+	data := [][]interface{}{}
+	for i, switchController := range controllers {
+		credentialsUser := credentialsUsers[i]
+		credentialsPass := credentialsPasses[i]
+
 		data = append(data, []interface{}{
 			switchController.NetworkEquipmentControllerID,
 			switchController.NetworkEquipmentControllerIdentifierString,
@@ -467,8 +504,291 @@ func switchControllerSyncCmd(c *command.Command, client metalcloud.MetalCloudCli
 		return "", err
 	}
 
-	_, err = client.SwitchDeviceControllerSync(retSWCtrl.NetworkEquipmentControllerID)
-	return "", err
+	switches, err := client.SwitchDevices(retSWCtrl.DatacenterName, "")
+	if err != nil {
+		return "", err
+	}
+
+	format := command.GetStringParam(c.Arguments["format"])
+
+	if command.GetBoolParam(c.Arguments["dry_run"]) {
+		controllerSwitches, err := client.SwitchDeviceControllerSwitches(retSWCtrl.NetworkEquipmentControllerIdentifierString)
+		if err != nil {
+			return "", err
+		}
+
+		actions := getSwitchSyncDryRunActions(retSWCtrl.NetworkEquipmentControllerID, retSWCtrl.DatacenterName, controllerSwitches, *switches)
+
+		counts := map[string]int{}
+		for _, a := range actions {
+			counts[a.Action]++
+		}
+
+		notManaged := 0
+		for _, sw := range *switches {
+			if sw.NetworkEquipmentControllerID == 0 {
+				notManaged++
+			}
+		}
+
+		title := fmt.Sprintf("Dry run of the sync of switch controller %s (#%d): %d managed switches, %d switches of datacenter %s not managed by a controller of which %d share a management address with a managed switch. Switches reported by the controller that are not registered are created by the sync.",
+			retSWCtrl.NetworkEquipmentControllerIdentifierString,
+			retSWCtrl.NetworkEquipmentControllerID,
+			counts["managed"],
+			notManaged,
+			retSWCtrl.DatacenterName,
+			counts["unmanaged"])
+
+		return renderSwitchSyncActions(actions, title, format)
+	}
+
+	// only the switches created by the sync are returned, the switches are read again to find the other changes
+	created, err := client.SwitchDeviceControllerSync(retSWCtrl.NetworkEquipmentControllerID)
+	if err != nil {
+		return "", err
+	}
+
+	switchesAfter, err := client.SwitchDevices(retSWCtrl.DatacenterName, "")
+	if err != nil {
+		return "", err
+	}
+
+	actions := getSwitchSyncActions(retSWCtrl.NetworkEquipmentControllerID, *switches, *switchesAfter, *created)
+
+	counts := map[string]int{}
+	for _, a := range actions {
+		counts[a.Action]++
+	}
+
+	title := fmt.Sprintf("Sync of switch controller %s (#%d): %d created, %d updated, %d unchanged, %d orphaned, %d removed",
+		retSWCtrl.NetworkEquipmentControllerIdentifierString,
+		retSWCtrl.NetworkEquipmentControllerID,
+		counts["created"],
+		counts["updated"],
+		counts["unchanged"],
+		counts["orphaned"],
+		counts["removed"])
+
+	return renderSwitchSyncActions(actions, title, format)
+}
+
+// switchSyncAction is what a controller sync does to a switch
+type switchSyncAction struct {
+	Switch  metalcloud.SwitchDevice
+	Action  string
+	Details string
+}
+
+// getSwitchSyncActions compares the switches of the datacenter before and after the sync of a controller.
+// Switches that appeared are created, switches of the controller that changed are updated, switches that are no longer
+// managed by the controller are orphaned and the ones that disappeared are removed. The switches returned by the sync,
+// which are the ones it created, are added to the switches after the sync in case they are not listed yet.
+func getSwitchSyncActions(controllerID int, before map[string]metalcloud.SwitchDevice, after map[string]metalcloud.SwitchDevice, created map[int]metalcloud.SwitchDevice) []switchSyncAction {
+
+	beforeByID := map[int]metalcloud.SwitchDevice{}
+	for _, sw := range before {
+		beforeByID[sw.NetworkEquipmentID] = sw
+	}
+
+	afterByID := map[int]metalcloud.SwitchDevice{}
+	for _, sw := range after {
+		afterByID[sw.NetworkEquipmentID] = sw
+	}
+	for _, sw := range created {
+		if _, ok := afterByID[sw.NetworkEquipmentID]; !ok {
+			afterByID[sw.NetworkEquipmentID] = sw
+		}
+	}
+
+	actions := []switchSyncAction{}
+
+	for id, sw := range afterByID {
+		existing, ok := beforeByID[id]
+
+		if !ok {
+			// switches added to the datacenter by someone else during the sync are not reported
+			if _, isCreated := created[id]; !isCreated && sw.NetworkEquipmentControllerID != controllerID {
+				continue
+			}
+
+			details := ""
+			for _, other := range before {
+				if other.NetworkEquipmentManagementAddress != "" && other.NetworkEquipmentManagementAddress == sw.NetworkEquipmentManagementAddress {
+					details = fmt.Sprintf("same management address as switch %s (#%d)", other.NetworkEquipmentIdentifierString, other.NetworkEquipmentID)
+					break
+				}
+			}
+			actions = append(actions, switchSyncAction{Switch: sw, Action: "created", Details: details})
+			continue
+		}
+
+		switch {
+		case sw.NetworkEquipmentControllerID == controllerID && existing.NetworkEquipmentControllerID != controllerID:
+			actions = append(actions, switchSyncAction{Switch: sw, Action: "updated", Details: "now managed by the controller"})
+		case sw.NetworkEquipmentControllerID == controllerID:
+			changed := getSwitchChangedFields(existing, sw)
+			if len(changed) == 0 {
+				actions = append(actions, switchSyncAction{Switch: sw, Action: "unchanged"})
+			} else {
+				actions = append(actions, switchSyncAction{Switch: sw, Action: "updated", Details: "changed " + strings.Join(changed, ", ")})
+			}
+		case existing.NetworkEquipmentControllerID == controllerID:
+			actions = append(actions, switchSyncAction{Switch: sw, Action: "orphaned", Details: "no longer managed by the controller"})
+		}
+	}
+
+	for id, sw := range beforeByID {
+		if _, ok := afterByID[id]; !ok && sw.NetworkEquipmentControllerID == controllerID {
+			actions = append(actions, switchSyncAction{Switch: sw, Action: "removed", Details: "no longer registered"})
+		}
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].Switch.NetworkEquipmentIdentifierString < actions[j].Switch.NetworkEquipmentIdentifierString
+	})
+
+	return actions
+}
+
+// getSwitchSyncDryRunActions compares the switches registered to a controller with the switches of its datacenter.
+// The switches registered to the controller are managed, along with the switches of the datacenter set to the controller
+// that it does not list. The switches of the datacenter that are not managed by a controller and share a management
+// address with a managed switch are listed as unmanaged.
+func getSwitchSyncDryRunActions(controllerID int, datacenterName string, controllerSwitches map[int]metalcloud.SwitchDevice, switches map[string]metalcloud.SwitchDevice) []switchSyncAction {
+
+	byID := map[int]metalcloud.SwitchDevice{}
+	for _, sw := range switches {
+		byID[sw.NetworkEquipmentID] = sw
+	}
+
+	actions := []switchSyncAction{}
+	managed := map[int]metalcloud.SwitchDevice{}
+
+	for _, sw := range controllerSwitches {
+		managed[sw.NetworkEquipmentID] = sw
+
+		details := "registered to this controller"
+		if existing, ok := byID[sw.NetworkEquipmentID]; !ok {
+			details = fmt.Sprintf("registered to this controller, not listed in datacenter %s", datacenterName)
+		} else if existing.NetworkEquipmentControllerID != controllerID {
+			details = fmt.Sprintf("registered to this controller, listed with controller #%d in datacenter %s", existing.NetworkEquipmentControllerID, datacenterName)
+		}
+
+		actions = append(actions, switchSyncAction{Switch: sw, Action: "managed", Details: details})
+	}
+
+	for _, sw := range switches {
+		if _, ok := managed[sw.NetworkEquipmentID]; !ok && sw.NetworkEquipmentControllerID == controllerID {
+			managed[sw.NetworkEquipmentID] = sw
+			actions = append(actions, switchSyncAction{Switch: sw, Action: "managed", Details: "set to this controller, not listed by the controller"})
+		}
+	}
+
+	for _, sw := range switches {
+		if sw.NetworkEquipmentControllerID != 0 || sw.NetworkEquipmentManagementAddress == "" {
+			continue
+		}
+
+		for _, other := range managed {
+			if other.NetworkEquipmentManagementAddress == sw.NetworkEquipmentManagementAddress {
+				actions = append(actions, switchSyncAction{
+					Switch:  sw,
+					Action:  "unmanaged",
+					Details: fmt.Sprintf("same management address as switch %s (#%d)", other.NetworkEquipmentIdentifierString, other.NetworkEquipmentID),
+				})
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].Switch.NetworkEquipmentIdentifierString < actions[j].Switch.NetworkEquipmentIdentifierString
+	})
+
+	return actions
+}
+
+// getSwitchChangedFields returns the yaml names of the fields that differ between two versions of a switch.
+// The management password is left out as it is not always returned.
+func getSwitchChangedFields(a metalcloud.SwitchDevice, b metalcloud.SwitchDevice) []string {
+	changed := []string{}
+
+	va := reflect.ValueOf(a)
+	vb := reflect.ValueOf(b)
+	t := va.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Name == "NetworkEquipmentManagementPassword" {
+			continue
+		}
+
+		if !reflect.DeepEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			changed = append(changed, strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0])
+		}
+	}
+
+	return changed
+}
+
+func renderSwitchSyncActions(actions []switchSyncAction, title string, format string) (string, error) {
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "ID",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "IDENTIFIER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "MGMT IP",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "ACTION",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 8,
+		},
+		{
+			FieldName: "DETAILS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 30,
+		},
+	}
+
+	data := [][]interface{}{}
+	for _, a := range actions {
+		action := a.Action
+		if format == "" {
+			switch action {
+			case "created":
+				action = colors.Green(action)
+			case "orphaned", "removed":
+				action = colors.Red(action)
+			case "updated", "unmanaged":
+				action = colors.Yellow(action)
+			}
+		}
+
+		data = append(data, []interface{}{
+			a.Switch.NetworkEquipmentID,
+			a.Switch.NetworkEquipmentIdentifierString,
+			a.Switch.NetworkEquipmentManagementAddress,
+			action,
+			a.Details,
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	return table.RenderTable("Switches", title, format)
 }
 
 func switchControllerDeleteCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
@@ -570,24 +890,39 @@ func switchControllerSwitchesListCmd(c *command.Command, client metalcloud.Metal
 
 	}
 
-	data := [][]interface{}{}
+	switches := []metalcloud.SwitchDevice{}
 	for _, s := range controllerSwitchDevices {
+		switches = append(switches, s)
+	}
 
-		credentialsUser := ""
-		credentialsPass := ""
-
-		if showCredentials {
+	credentialsUsers := make([]string, len(switches))
+	credentialsPasses := make([]string, len(switches))
 
-			sw, err := client.SwitchDeviceGet(s.NetworkEquipmentID, showCredentials)
+	if showCredentials {
+		resolver := command.NewResolver(client)
 
+		err := command.Prefetch(len(switches), command.DefaultResolverConcurrency, func(i int) error {
+			sw, err := resolver.Switch(switches[i].NetworkEquipmentID, showCredentials)
 			if err != nil {
-				return "", err
+				return err
 			}
 
-			credentialsUser = fmt.Sprintf("%s", sw.NetworkEquipmentManagementUsername)
-			credentialsPass = fmt.Sprintf("%s", sw.NetworkEquipmentManagementPassword)
+			credentialsUsers[i] = fmt.Sprintf("%s", sw.NetworkEquipmentManagementUsername)
+			credentialsPasses[i] = fmt.Sprintf("%s", sw.NetworkEquipmentManagementPassword)
 
+			return nil
+		})
+		if err != nil {
+			return "", err
 		}
+	}
+
+	data := [][]interface{}{}
+	for i, s := range switches {
+
+		credentialsUser := credentialsUsers[i]
+		credentialsPass := credentialsPasses[i]
+
 		data = append(data, []interface{}{
 			s.NetworkEquipmentID,
 			s.NetworkEquipmentIdentifierString,
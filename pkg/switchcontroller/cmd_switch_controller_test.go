@@ -300,6 +300,11 @@ func TestSwitchControllerSync(t *testing.T) {
 		Return(&swCtrl, nil).
 		AnyTimes()
 
+	client.EXPECT().
+		SwitchDevices(gomock.Any(), "").
+		Return(&map[string]metalcloud.SwitchDevice{}, nil).
+		AnyTimes()
+
 	client.EXPECT().
 		SwitchDeviceControllerSync(100).
 		Return(&result, nil).
@@ -336,3 +341,161 @@ func TestSwitchObjectYamlUnmarshal(t *testing.T) {
 	Expect(string(j)).To(ContainSubstring("leaf"))
 
 }
+
+func mockSwitchControllerSyncClient(t *testing.T) *mock_metalcloud.MockMetalCloudClient {
+	ctrl := gomock.NewController(t)
+
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		SwitchDeviceControllerGet(100, false).
+		Return(&metalcloud.SwitchDeviceController{
+			NetworkEquipmentControllerID:               100,
+			NetworkEquipmentControllerIdentifierString: "sdn1",
+			DatacenterName:                             "dc1",
+		}, nil).
+		AnyTimes()
+
+	return client
+}
+
+// getSwitchControllerSyncSwitches returns the switches of datacenter dc1 before the sync
+func getSwitchControllerSyncSwitches() map[string]metalcloud.SwitchDevice {
+	return map[string]metalcloud.SwitchDevice{
+		"leaf-1": {NetworkEquipmentID: 1, NetworkEquipmentIdentifierString: "leaf-1", NetworkEquipmentManagementAddress: "10.0.0.1", NetworkEquipmentControllerID: 100},
+		"leaf-2": {NetworkEquipmentID: 2, NetworkEquipmentIdentifierString: "leaf-2", NetworkEquipmentManagementAddress: "10.0.0.2", NetworkEquipmentControllerID: 100},
+		"tor-1":  {NetworkEquipmentID: 3, NetworkEquipmentIdentifierString: "tor-1", NetworkEquipmentManagementAddress: "10.0.0.3"},
+		"leaf-9": {NetworkEquipmentID: 9, NetworkEquipmentIdentifierString: "leaf-9", NetworkEquipmentManagementAddress: "10.0.0.9", NetworkEquipmentControllerID: 200},
+	}
+}
+
+// expectSwitchControllerSyncSwitches sets the switches returned before and after the sync
+func expectSwitchControllerSyncSwitches(client *mock_metalcloud.MockMetalCloudClient, before map[string]metalcloud.SwitchDevice, after map[string]metalcloud.SwitchDevice) {
+	gomock.InOrder(
+		client.EXPECT().
+			SwitchDevices("dc1", "").
+			Return(&before, nil).
+			Times(1),
+		client.EXPECT().
+			SwitchDevices("dc1", "").
+			Return(&after, nil).
+			Times(1),
+	)
+}
+
+func TestSwitchControllerSyncDryRun(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSwitchControllerSyncClient(t)
+
+	switches := getSwitchControllerSyncSwitches()
+	// leaf-2 is set to the controller but not listed by it, leaf-3 is listed by the controller but not in dc1
+	// and tor-2 is not managed and has the management address of leaf-1
+	switches["tor-2"] = metalcloud.SwitchDevice{NetworkEquipmentID: 4, NetworkEquipmentIdentifierString: "tor-2", NetworkEquipmentManagementAddress: "10.0.0.1"}
+
+	client.EXPECT().
+		SwitchDevices("dc1", "").
+		Return(&switches, nil).
+		Times(1)
+
+	client.EXPECT().
+		SwitchDeviceControllerSwitches("sdn1").
+		Return(map[int]metalcloud.SwitchDevice{
+			1: switches["leaf-1"],
+			5: {NetworkEquipmentID: 5, NetworkEquipmentIdentifierString: "leaf-3", NetworkEquipmentManagementAddress: "10.0.0.5", NetworkEquipmentControllerID: 100},
+			9: switches["leaf-9"],
+		}, nil).
+		Times(1)
+
+	client.EXPECT().
+		SwitchDeviceControllerSync(gomock.Any()).
+		Times(0)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"network_controller_id_or_identifier_string": 100,
+		"dry_run": true,
+		"format":  "csv",
+	})
+
+	ret, err := switchControllerSyncCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal("ID,IDENTIFIER,MGMT IP,ACTION,DETAILS\n" +
+		"1,leaf-1,10.0.0.1,managed,registered to this controller\n" +
+		"2,leaf-2,10.0.0.2,managed,\"set to this controller, not listed by the controller\"\n" +
+		"5,leaf-3,10.0.0.5,managed,\"registered to this controller, not listed in datacenter dc1\"\n" +
+		"9,leaf-9,10.0.0.9,managed,\"registered to this controller, listed with controller #200 in datacenter dc1\"\n" +
+		"4,tor-2,10.0.0.1,unmanaged,same management address as switch leaf-1 (#1)\n"))
+}
+
+func TestSwitchControllerSyncActions(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSwitchControllerSyncClient(t)
+
+	before := getSwitchControllerSyncSwitches()
+	before["leaf-3"] = metalcloud.SwitchDevice{NetworkEquipmentID: 4, NetworkEquipmentIdentifierString: "leaf-3", NetworkEquipmentManagementAddress: "10.0.0.4", NetworkEquipmentControllerID: 100}
+	before["leaf-4"] = metalcloud.SwitchDevice{NetworkEquipmentID: 5, NetworkEquipmentIdentifierString: "leaf-4", NetworkEquipmentManagementAddress: "10.0.0.5", NetworkEquipmentControllerID: 100, NetworkEquipmentASN: 65001}
+
+	after := getSwitchControllerSyncSwitches()
+	// leaf-2 is no longer managed by the controller, tor-1 now is, leaf-3 was removed and the ASN of leaf-4 changed
+	after["leaf-2"] = metalcloud.SwitchDevice{NetworkEquipmentID: 2, NetworkEquipmentIdentifierString: "leaf-2", NetworkEquipmentManagementAddress: "10.0.0.2"}
+	after["tor-1"] = metalcloud.SwitchDevice{NetworkEquipmentID: 3, NetworkEquipmentIdentifierString: "tor-1", NetworkEquipmentManagementAddress: "10.0.0.3", NetworkEquipmentControllerID: 100}
+	after["leaf-4"] = metalcloud.SwitchDevice{NetworkEquipmentID: 5, NetworkEquipmentIdentifierString: "leaf-4", NetworkEquipmentManagementAddress: "10.0.0.5", NetworkEquipmentControllerID: 100, NetworkEquipmentASN: 65002}
+	after["leaf-10"] = metalcloud.SwitchDevice{NetworkEquipmentID: 10, NetworkEquipmentIdentifierString: "leaf-10", NetworkEquipmentManagementAddress: "10.0.0.9", NetworkEquipmentControllerID: 100}
+	after["leaf-11"] = metalcloud.SwitchDevice{NetworkEquipmentID: 11, NetworkEquipmentIdentifierString: "leaf-11", NetworkEquipmentManagementAddress: "10.0.0.11", NetworkEquipmentControllerID: 100}
+
+	expectSwitchControllerSyncSwitches(client, before, after)
+
+	// the sync only returns the switches it created
+	client.EXPECT().
+		SwitchDeviceControllerSync(100).
+		Return(&map[int]metalcloud.SwitchDevice{
+			10: after["leaf-10"],
+			11: after["leaf-11"],
+		}, nil).
+		Times(1)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"network_controller_id_or_identifier_string": 100,
+		"format": "csv",
+	})
+
+	ret, err := switchControllerSyncCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal("ID,IDENTIFIER,MGMT IP,ACTION,DETAILS\n" +
+		"1,leaf-1,10.0.0.1,unchanged,\n" +
+		"10,leaf-10,10.0.0.9,created,same management address as switch leaf-9 (#9)\n" +
+		"11,leaf-11,10.0.0.11,created,\n" +
+		"2,leaf-2,10.0.0.2,orphaned,no longer managed by the controller\n" +
+		"4,leaf-3,10.0.0.4,removed,no longer registered\n" +
+		"5,leaf-4,10.0.0.5,updated,changed ASN\n" +
+		"3,tor-1,10.0.0.3,updated,now managed by the controller\n"))
+}
+
+func TestSwitchControllerSyncOnlyNewSwitches(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSwitchControllerSyncClient(t)
+
+	// the new switch is returned by the sync but is not listed yet, the other switches are untouched
+	expectSwitchControllerSyncSwitches(client, getSwitchControllerSyncSwitches(), getSwitchControllerSyncSwitches())
+
+	client.EXPECT().
+		SwitchDeviceControllerSync(100).
+		Return(&map[int]metalcloud.SwitchDevice{
+			12: {NetworkEquipmentID: 12, NetworkEquipmentIdentifierString: "leaf-12", NetworkEquipmentManagementAddress: "10.0.0.12", NetworkEquipmentControllerID: 100},
+		}, nil).
+		Times(1)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"network_controller_id_or_identifier_string": 100,
+		"format": "csv",
+	})
+
+	ret, err := switchControllerSyncCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal("ID,IDENTIFIER,MGMT IP,ACTION,DETAILS\n" +
+		"1,leaf-1,10.0.0.1,unchanged,\n" +
+		"12,leaf-12,10.0.0.12,created,\n" +
+		"2,leaf-2,10.0.0.2,unchanged,\n"))
+}
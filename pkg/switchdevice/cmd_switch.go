@@ -207,6 +207,74 @@ volumeTemplateID: 0
 ===========================================================================
 		`,
 	},
+	{
+		Description:  "Create switches and their MLAG pairs from an inventory file.",
+		Subject:      "switch",
+		AltSubject:   "sw",
+		Predicate:    "import-batch",
+		AltPredicate: "import-batch",
+		FlagSet:      flag.NewFlagSet("import switch batch", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"format":                c.FlagSet.String("format", "yaml", "The input format. Supported values are 'yaml','csv'. The default format is yaml."),
+				"read_config_from_file": c.FlagSet.String("file", command.NilDefaultStr, colors.Red("(Required)")+" Read the switches from this file."),
+				"validate_only":         c.FlagSet.Bool("validate-only", false, colors.Green("(Flag)")+" If set the records are validated without creating the switches."),
+				"sync_controllers":      c.FlagSet.Bool("sync-controllers", false, colors.Green("(Flag)")+" If set, the switch controllers of the created switches are synced after the import."),
+				"rollback_file":         c.FlagSet.String("rollback-file", command.NilDefaultStr, colors.Green("(Optional)")+" Write the commands that remove the created switches and pairs to this file."),
+				"report_format":         c.FlagSet.String("report-format", "", "The format of the per record report. Supported values are 'json','csv','yaml'. The default format is human readable."),
+				"return_id":             c.FlagSet.Bool("return-id", false, "Will print the IDs of the created switches. Useful for automating tasks."),
+			}
+		},
+		ExecuteFunc:         switchImportBatchCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.SWITCHES_WRITE},
+		Example: `
+This command is the batch version of switch create. The records use the same fields as switch create with "---" between them.
+The optional mlagPeer field is the identifier of the other member of an MLAG pair, either a switch of the same file or an
+existing one. A switch pair is created for each MLAG pair after all the switches are created.
+
+identifierString: leaf-1a
+datacenterName: dc-prod
+provisionerType: evpnvxlanl2
+provisionerPosition: leaf
+driver: os_10
+managementUsername: admin
+managementPassword: XXXXX
+managementAddress: 172.16.2.31
+managementPort: 22
+managementProtocol: ssh
+ASN: 65101
+controllerID: 3
+mlagPeer: leaf-1b
+---
+identifierString: leaf-1b
+datacenterName: dc-prod
+provisionerType: evpnvxlanl2
+provisionerPosition: leaf
+driver: os_10
+managementUsername: admin
+managementPassword: XXXXX
+managementAddress: 172.16.2.32
+managementPort: 22
+managementProtocol: ssh
+ASN: 65101
+controllerID: 3
+mlagPeer: leaf-1a
+
+The records can also be read from a csv file with one switch per row and the columns named like the yaml fields.
+List fields such as networkTypesAllowed are separated with ";":
+
+identifierString,datacenterName,driver,provisionerType,provisionerPosition,managementAddress,managementUsername,managementPassword,ASN,networkTypesAllowed,mlagPeer
+leaf-1a,dc-prod,os_10,evpnvxlanl2,leaf,172.16.2.31,admin,XXXXX,65101,wan;quarantine,leaf-1b
+leaf-1b,dc-prod,os_10,evpnvxlanl2,leaf,172.16.2.32,admin,XXXXX,65101,wan;quarantine,leaf-1a
+
+All the records are validated before any switch is created. If the import stops because of an error the switches already
+created are kept and the commands that remove them are printed and written to the --rollback-file if set.
+
+metalcloud-cli switch import-batch --file inventory.yaml --validate-only
+metalcloud-cli switch import-batch --file inventory.csv --format csv --sync-controllers --rollback-file rollback.sh
+`,
+	},
 	{
 		Description:  "Edit switch device.",
 		Subject:      "switch",
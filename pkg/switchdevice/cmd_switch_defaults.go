@@ -124,6 +124,63 @@ customVariables:
 		PermissionsRequired: []string{command.SWITCHES_WRITE},
 		Example: `
 metalcloud-cli switch-defaults delete -ids "834, 835, 836"
+`,
+	},
+	{
+		Description:  "Export switch defaults of a datacenter.",
+		Subject:      "switch-defaults",
+		AltSubject:   "sw-defaults",
+		Predicate:    "export",
+		AltPredicate: "dump",
+		FlagSet:      flag.NewFlagSet("export switch defaults", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"datacenter_name": c.FlagSet.String("datacenter", command.NilDefaultStr, colors.Red("(Required)")+" The datacenter name for which to export the switch defaults."),
+				"format":          c.FlagSet.String("format", "yaml", colors.Green("(Optional)")+" The output format. Supported values are 'yaml','csv'."),
+			}
+		},
+		ExecuteFunc:         switchDefaultsExportCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.SWITCHES_READ},
+		Example: `
+The output can be edited and applied with switch-defaults sync.
+
+metalcloud-cli switch-defaults export --datacenter dc-prod > defaults.yaml
+metalcloud-cli switch-defaults export --datacenter dc-prod --format csv > defaults.csv
+`,
+	},
+	{
+		Description:  "Create, update and delete switch defaults to match a file.",
+		Subject:      "switch-defaults",
+		AltSubject:   "sw-defaults",
+		Predicate:    "sync",
+		AltPredicate: "apply",
+		FlagSet:      flag.NewFlagSet("sync switch defaults", flag.ExitOnError),
+		InitFunc: func(c *command.Command) {
+			c.Arguments = map[string]interface{}{
+				"read_config_from_file": c.FlagSet.String("file", command.NilDefaultStr, colors.Red("(Required)")+" Read the switch defaults from this file."),
+				"format":                c.FlagSet.String("format", "yaml", colors.Green("(Optional)")+" The input format. Supported values are 'yaml','csv'."),
+				"datacenter_name":       c.FlagSet.String("datacenter", command.NilDefaultStr, colors.Green("(Optional)")+" The datacenter of the records of the file that do not have a datacenterName. Records of other datacenters are rejected."),
+				"delete_extra":          c.FlagSet.Bool("delete-extra", false, colors.Green("(Flag)")+" If set the switch defaults of the datacenters of the file that are not in the file are deleted."),
+				"dry_run":               c.FlagSet.Bool("dry-run", false, colors.Green("(Flag)")+" If set only the changes are shown."),
+				"report_format":         c.FlagSet.String("report-format", "", "The format of the changes report. Supported values are 'json','csv','yaml'. The default format is human readable."),
+				"autoconfirm":           c.FlagSet.Bool("autoconfirm", false, colors.Green("(Flag)")+" If set it will assume action is confirmed"),
+			}
+		},
+		ExecuteFunc:         switchDefaultsSyncCmd,
+		Endpoint:            configuration.DeveloperEndpoint,
+		PermissionsRequired: []string{command.SWITCHES_WRITE},
+		Example: `
+The file has the format of switch-defaults export. The switch defaults are matched by serial number within their datacenter:
+the records that do not exist are created and the ones with different values are updated. The switch defaults of the
+datacenters of the file that are not in the file are only shown, unless --delete-extra is set. The changes are shown
+before they are applied.
+
+Switch defaults cannot be edited in place, an update deletes the switch defaults and creates them again with a new id.
+
+metalcloud-cli switch-defaults export --datacenter dc-prod --format csv > defaults.csv
+metalcloud-cli switch-defaults sync --file defaults.csv --format csv --dry-run
+metalcloud-cli switch-defaults sync --file defaults.csv --format csv --delete-extra --autoconfirm
 `,
 	},
 }
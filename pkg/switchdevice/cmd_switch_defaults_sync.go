@@ -0,0 +1,579 @@
+package switchdevice
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	"github.com/metalsoft-io/tableformatter"
+	"gopkg.in/yaml.v2"
+)
+
+// switchDefaultsChange is a difference between the switch defaults of a datacenter and the records of a sync file
+type switchDefaultsChange struct {
+	Action  string
+	Current *metalcloud.SwitchDeviceDefaults
+	Desired *metalcloud.SwitchDeviceDefaults
+	Details []string
+}
+
+const (
+	switchDefaultsCreate = "create"
+	switchDefaultsUpdate = "update"
+	switchDefaultsDelete = "delete"
+	switchDefaultsExtra  = "extra"
+)
+
+func switchDefaultsExportCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	datacenterName, ok := command.GetStringParamOk(c.Arguments["datacenter_name"])
+	if !ok {
+		return "", fmt.Errorf("-datacenter is required")
+	}
+
+	list, err := client.SwitchDeviceDefaults(datacenterName)
+	if err != nil {
+		return "", err
+	}
+
+	defaults := []metalcloud.SwitchDeviceDefaults{}
+	for _, obj := range *list {
+		obj = normalizeSwitchDefaults(obj)
+		obj.DatacenterName = datacenterName
+		defaults = append(defaults, obj)
+	}
+
+	sort.SliceStable(defaults, func(i, j int) bool {
+		return switchDefaultsSerialNumber(defaults[i]) < switchDefaultsSerialNumber(defaults[j])
+	})
+
+	switch command.GetStringParam(c.Arguments["format"]) {
+	case "csv", "CSV":
+		return switchDefaultsToCSV(defaults)
+	case "", "yaml", "YAML":
+		docs := []string{}
+		for _, obj := range defaults {
+			b, err := yaml.Marshal(obj)
+			if err != nil {
+				return "", err
+			}
+			docs = append(docs, string(b))
+		}
+		return strings.Join(docs, "---\n"), nil
+	}
+
+	return "", fmt.Errorf("invalid format '%s'. Supported values are 'yaml','csv'", command.GetStringParam(c.Arguments["format"]))
+}
+
+func switchDefaultsSyncCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	filePath, ok := command.GetStringParamOk(c.Arguments["read_config_from_file"])
+	if !ok {
+		return "", fmt.Errorf("-file is required")
+	}
+
+	var desired []metalcloud.SwitchDeviceDefaults
+	var err error
+
+	if command.GetStringParam(c.Arguments["format"]) == "csv" {
+		desired, err = getSwitchDefaultsFromCSVFile(filePath)
+	} else {
+		desired, err = getSwitchDefaultsFromYamlFile(filePath)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if datacenterName, ok := command.GetStringParamOk(c.Arguments["datacenter_name"]); ok {
+		for i := range desired {
+			if desired[i].DatacenterName == "" {
+				desired[i].DatacenterName = datacenterName
+			} else if desired[i].DatacenterName != datacenterName {
+				return "", fmt.Errorf("switch defaults #%d of the file is for datacenter %s, not %s", i+1, desired[i].DatacenterName, datacenterName)
+			}
+		}
+	}
+
+	datacenters := []string{}
+	serialNumbers := map[string]int{}
+
+	for i, obj := range desired {
+		if obj.DatacenterName == "" {
+			return "", fmt.Errorf("datacenter name is required for switch defaults #%d. Use -datacenter to set it for all the records of the file", i+1)
+		}
+
+		serialNumber := switchDefaultsSerialNumber(obj)
+		if serialNumber == "" {
+			return "", fmt.Errorf("serial number is required for switch defaults #%d, the switch defaults are matched by serial number", i+1)
+		}
+
+		key := obj.DatacenterName + "/" + serialNumber
+		if other, ok := serialNumbers[key]; ok {
+			return "", fmt.Errorf("serial number %s of switch defaults #%d is also used by switch defaults #%d", serialNumber, i+1, other)
+		}
+		serialNumbers[key] = i + 1
+
+		if !slices.Contains(datacenters, obj.DatacenterName) {
+			datacenters = append(datacenters, obj.DatacenterName)
+		}
+	}
+
+	if len(desired) == 0 {
+		return "", fmt.Errorf("no switch defaults found in %s", filePath)
+	}
+
+	deleteExtra := command.GetBoolParam(c.Arguments["delete_extra"])
+
+	changes := []switchDefaultsChange{}
+
+	for _, datacenterName := range datacenters {
+		list, err := client.SwitchDeviceDefaults(datacenterName)
+		if err != nil {
+			return "", err
+		}
+
+		current := []metalcloud.SwitchDeviceDefaults{}
+		for _, obj := range *list {
+			obj.DatacenterName = datacenterName
+			current = append(current, obj)
+		}
+
+		records := []metalcloud.SwitchDeviceDefaults{}
+		for _, obj := range desired {
+			if obj.DatacenterName == datacenterName {
+				records = append(records, obj)
+			}
+		}
+
+		changes = append(changes, diffSwitchDefaults(current, records, deleteExtra)...)
+	}
+
+	reportFormat := command.GetStringParam(c.Arguments["report_format"])
+
+	report, err := renderSwitchDefaultsChanges(changes, reportFormat)
+	if err != nil {
+		return "", err
+	}
+
+	toDelete := []int{}
+	toCreate := []metalcloud.SwitchDeviceDefaults{}
+	counts := map[string]int{}
+
+	for _, ch := range changes {
+		counts[ch.Action]++
+
+		switch ch.Action {
+		case switchDefaultsCreate:
+			toCreate = append(toCreate, *ch.Desired)
+		case switchDefaultsUpdate:
+			toDelete = append(toDelete, ch.Current.NetworkEquipmentDefaultsID)
+			toCreate = append(toCreate, *ch.Desired)
+		case switchDefaultsDelete:
+			toDelete = append(toDelete, ch.Current.NetworkEquipmentDefaultsID)
+		}
+	}
+
+	if command.GetBoolParam(c.Arguments["dry_run"]) {
+		return report, nil
+	}
+
+	fmt.Fprint(configuration.GetStdout(), report)
+
+	if len(toCreate) == 0 && len(toDelete) == 0 {
+		return "", nil
+	}
+
+	confirm, err := command.ConfirmCommand(c, func() string {
+
+		confirmationMessage := fmt.Sprintf("Creating %d, updating %d and deleting %d switch defaults.  Are you sure? Type \"yes\" to continue:",
+			counts[switchDefaultsCreate],
+			counts[switchDefaultsUpdate],
+			counts[switchDefaultsDelete],
+		)
+
+		if strings.HasSuffix(os.Args[0], ".test") {
+			confirmationMessage = ""
+		}
+
+		return confirmationMessage
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if !confirm {
+		return "", fmt.Errorf("operation not confirmed. Aborting")
+	}
+
+	// there is no update call, changed switch defaults are deleted and created again with the values from the file
+	if len(toDelete) > 0 {
+		err = client.SwitchDeviceDefaultsDelete(toDelete)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if len(toCreate) > 0 {
+		err = client.SwitchDeviceDefaultsCreate(toCreate)
+		if err != nil {
+			if counts[switchDefaultsUpdate] > 0 {
+				return "", fmt.Errorf("could not create the switch defaults: %v. The %d switch defaults being updated were deleted, run the command again to create them", err, counts[switchDefaultsUpdate])
+			}
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("Switch defaults synced: %d created, %d updated, %d deleted.\n",
+		counts[switchDefaultsCreate],
+		counts[switchDefaultsUpdate],
+		counts[switchDefaultsDelete],
+	), nil
+}
+
+// diffSwitchDefaults returns the changes needed to get from the current switch defaults of a datacenter to the desired ones.
+// The switch defaults are matched by serial number. Current switch defaults missing from desired are deleted if deleteExtra is set
+// and reported as extra otherwise. Unchanged switch defaults are not returned.
+func diffSwitchDefaults(current []metalcloud.SwitchDeviceDefaults, desired []metalcloud.SwitchDeviceDefaults, deleteExtra bool) []switchDefaultsChange {
+
+	bySerialNumber := map[string]metalcloud.SwitchDeviceDefaults{}
+	for _, obj := range current {
+		bySerialNumber[switchDefaultsSerialNumber(obj)] = obj
+	}
+
+	changes := []switchDefaultsChange{}
+	matched := map[string]bool{}
+
+	for i := range desired {
+		d := normalizeSwitchDefaults(desired[i])
+		serialNumber := switchDefaultsSerialNumber(d)
+
+		cur, ok := bySerialNumber[serialNumber]
+		if !ok {
+			changes = append(changes, switchDefaultsChange{Action: switchDefaultsCreate, Desired: &d})
+			continue
+		}
+
+		matched[serialNumber] = true
+
+		details := diffSwitchDefaultsFields(normalizeSwitchDefaults(cur), d)
+		if len(details) > 0 {
+			cur := cur
+			changes = append(changes, switchDefaultsChange{Action: switchDefaultsUpdate, Current: &cur, Desired: &d, Details: details})
+		}
+	}
+
+	for i := range current {
+		if matched[switchDefaultsSerialNumber(current[i])] {
+			continue
+		}
+
+		ch := switchDefaultsChange{Action: switchDefaultsDelete, Current: &current[i]}
+		if !deleteExtra {
+			ch.Action = switchDefaultsExtra
+			ch.Details = []string{"not in the file, kept"}
+		}
+		changes = append(changes, ch)
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return switchDefaultsSerialNumber(changes[i].record()) < switchDefaultsSerialNumber(changes[j].record())
+	})
+
+	return changes
+}
+
+// record returns the switch defaults the change applies to
+func (ch switchDefaultsChange) record() metalcloud.SwitchDeviceDefaults {
+	if ch.Desired != nil {
+		return *ch.Desired
+	}
+	return *ch.Current
+}
+
+// diffSwitchDefaultsFields returns the fields of two switch defaults that differ as "key old -> new"
+func diffSwitchDefaultsFields(a metalcloud.SwitchDeviceDefaults, b metalcloud.SwitchDeviceDefaults) []string {
+
+	details := []string{}
+
+	va := reflect.ValueOf(a)
+	vb := reflect.ValueOf(b)
+	t := va.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "id" || key == "datacenterName" {
+			continue
+		}
+
+		fa := va.Field(i).Interface()
+		fb := vb.Field(i).Interface()
+
+		if !reflect.DeepEqual(fa, fb) {
+			details = append(details, fmt.Sprintf("%s %s -> %s", key, formatSwitchDefaultsValue(fa), formatSwitchDefaultsValue(fb)))
+		}
+	}
+
+	return details
+}
+
+// normalizeSwitchDefaults returns a copy of the switch defaults without the id and with empty values unset,
+// so that records read from a file compare equal to the ones returned by the server
+func normalizeSwitchDefaults(obj metalcloud.SwitchDeviceDefaults) metalcloud.SwitchDeviceDefaults {
+
+	obj.NetworkEquipmentDefaultsID = 0
+
+	v := reflect.ValueOf(&obj).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Ptr:
+			if !f.IsNil() && f.Elem().Kind() == reflect.String && f.Elem().String() == "" {
+				f.Set(reflect.Zero(f.Type()))
+			}
+		case reflect.Map:
+			if f.Len() == 0 {
+				f.Set(reflect.Zero(f.Type()))
+			}
+		}
+	}
+
+	return obj
+}
+
+// formatSwitchDefaultsValue formats a field of switch defaults for the diff and for CSV, unset values are shown as "-"
+func formatSwitchDefaultsValue(value interface{}) string {
+	s := switchDefaultsValueToString(value)
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func switchDefaultsValueToString(value interface{}) string {
+	v := reflect.ValueOf(value)
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return ""
+		}
+		return fmt.Sprint(v.Elem().Interface())
+	case reflect.Map:
+		pairs := []string{}
+		for _, k := range v.MapKeys() {
+			pairs = append(pairs, fmt.Sprintf("%v=%v", k.Interface(), v.MapIndex(k).Interface()))
+		}
+		sort.Strings(pairs)
+		return strings.Join(pairs, ";")
+	}
+
+	if v.IsZero() {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+func switchDefaultsSerialNumber(obj metalcloud.SwitchDeviceDefaults) string {
+	if obj.NetworkEquipmentSerialNumber == nil {
+		return ""
+	}
+	return strings.TrimSpace(*obj.NetworkEquipmentSerialNumber)
+}
+
+// switchDefaultsToCSV writes switch defaults as CSV with the columns named like the yaml fields, in the format read by switch-defaults sync
+func switchDefaultsToCSV(defaults []metalcloud.SwitchDeviceDefaults) (string, error) {
+
+	t := reflect.TypeOf(metalcloud.SwitchDeviceDefaults{})
+
+	header := []string{}
+	fields := []int{}
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "id" {
+			continue
+		}
+		header = append(header, key)
+		fields = append(fields, i)
+	}
+
+	var sb strings.Builder
+	w := csv.NewWriter(&sb)
+
+	err := w.Write(header)
+	if err != nil {
+		return "", err
+	}
+
+	for _, obj := range defaults {
+		v := reflect.ValueOf(obj)
+
+		line := []string{}
+		for _, i := range fields {
+			line = append(line, switchDefaultsValueToString(v.Field(i).Interface()))
+		}
+
+		err = w.Write(line)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	w.Flush()
+
+	return sb.String(), w.Error()
+}
+
+// getSwitchDefaultsFromYamlFile reads switch defaults from a yaml file with "---" between the records.
+// Unlike getMultipleSwitchDefaultsFromYamlFile a missing file is an error.
+func getSwitchDefaultsFromYamlFile(filePath string) ([]metalcloud.SwitchDeviceDefaults, error) {
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+
+	defaults := []metalcloud.SwitchDeviceDefaults{}
+
+	for {
+		var obj metalcloud.SwitchDeviceDefaults
+
+		err = decoder.Decode(&obj)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error while reading %s: %v", filePath, err)
+		}
+
+		defaults = append(defaults, obj)
+	}
+
+	return defaults, nil
+}
+
+// getSwitchDefaultsFromCSVFile reads switch defaults from a CSV file with a header row, as written by switch-defaults export
+func getSwitchDefaultsFromCSVFile(filePath string) ([]metalcloud.SwitchDeviceDefaults, error) {
+
+	records, err := getCSVRecords(filePath, getYamlFieldKinds(reflect.TypeOf(metalcloud.SwitchDeviceDefaults{})))
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := []metalcloud.SwitchDeviceDefaults{}
+
+	for _, r := range records {
+		if len(r.Errors) > 0 {
+			return nil, fmt.Errorf("line %d of %s: %s", r.Row, filePath, strings.Join(r.Errors, "; "))
+		}
+
+		var obj metalcloud.SwitchDeviceDefaults
+
+		err = yaml.Unmarshal(r.Content, &obj)
+		if err != nil {
+			return nil, fmt.Errorf("Error while reading line %d of %s: %v", r.Row, filePath, err)
+		}
+
+		defaults = append(defaults, obj)
+	}
+
+	return defaults, nil
+}
+
+// renderSwitchDefaultsChanges renders the changes of a switch defaults sync
+func renderSwitchDefaultsChanges(changes []switchDefaultsChange, format string) (string, error) {
+
+	counts := map[string]int{}
+	for _, ch := range changes {
+		counts[ch.Action]++
+	}
+
+	title := fmt.Sprintf("Switch defaults changes: %d to create, %d to update, %d to delete, %d not in the file and kept",
+		counts[switchDefaultsCreate],
+		counts[switchDefaultsUpdate],
+		counts[switchDefaultsDelete],
+		counts[switchDefaultsExtra],
+	)
+
+	if len(changes) == 0 && format == "" {
+		return "The switch defaults are already in sync.\n", nil
+	}
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "ID",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "SERIAL_NUMBER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "DATACENTER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "ACTION",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 8,
+		},
+		{
+			FieldName: "DETAILS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 40,
+		},
+	}
+
+	data := [][]interface{}{}
+	for _, ch := range changes {
+		id := 0
+		if ch.Current != nil {
+			id = ch.Current.NetworkEquipmentDefaultsID
+		}
+
+		action := ch.Action
+		if format == "" {
+			switch action {
+			case switchDefaultsCreate:
+				action = colors.Green(action)
+			case switchDefaultsUpdate:
+				action = colors.Yellow(action)
+			case switchDefaultsDelete:
+				action = colors.Red(action)
+			}
+		}
+
+		record := ch.record()
+
+		data = append(data, []interface{}{
+			id,
+			switchDefaultsSerialNumber(record),
+			record.DatacenterName,
+			action,
+			strings.Join(ch.Details, "; "),
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	return table.RenderTable("Switch defaults", title, format)
+}
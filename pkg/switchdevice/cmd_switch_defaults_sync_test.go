@@ -0,0 +1,251 @@
+package switchdevice
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	. "github.com/onsi/gomega"
+
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+)
+
+func getSwitchDefaultsSyncFixture() []metalcloud.SwitchDeviceDefaults {
+	sn := func(s string) *string { return &s }
+	asn := func(i int) *int { return &i }
+	empty := ""
+
+	return []metalcloud.SwitchDeviceDefaults{
+		{
+			NetworkEquipmentDefaultsID:           11,
+			NetworkEquipmentSerialNumber:         sn("SN-B"),
+			NetworkEquipmentManagementMacAddress: sn("00:00:00:00:00:0b"),
+			NetworkEquipmentIdentifierString:     sn("leaf-b"),
+			NetworkEquipmentAsn:                  asn(65102),
+			NetworkEquipmentLoopbackAddressIpv4:  &empty,
+			NetworkEquipmentCustomVariables:      map[string]string{"rack": "r2", "row": "1"},
+		},
+		{
+			NetworkEquipmentDefaultsID:           10,
+			NetworkEquipmentSerialNumber:         sn("SN-A"),
+			NetworkEquipmentManagementMacAddress: sn("00:00:00:00:00:0a"),
+			NetworkEquipmentIdentifierString:     sn("leaf-a"),
+			NetworkEquipmentAsn:                  asn(65101),
+		},
+		{
+			NetworkEquipmentDefaultsID:           12,
+			NetworkEquipmentSerialNumber:         sn("SN-C"),
+			NetworkEquipmentManagementMacAddress: sn("00:00:00:00:00:0c"),
+		},
+	}
+}
+
+func mockSwitchDefaultsSyncClient(t *testing.T) *mock_metalcloud.MockMetalCloudClient {
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	list := getSwitchDefaultsSyncFixture()
+
+	client.EXPECT().
+		SwitchDeviceDefaults("dc1").
+		Return(&list, nil).
+		AnyTimes()
+
+	return client
+}
+
+func TestSwitchDefaultsExportCmd(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSwitchDefaultsSyncClient(t)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"datacenter_name": "dc1",
+		"format":          "csv",
+	})
+
+	ret, err := switchDefaultsExportCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(HavePrefix("datacenterName,serialNumber,managementMacAddress,position,identifierString,asn,"))
+	Expect(ret).To(ContainSubstring("\ndc1,SN-A,00:00:00:00:00:0a,,leaf-a,65101,"))
+	Expect(ret).To(ContainSubstring(",rack=r2;row=1\n"))
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"datacenter_name": "dc1",
+	})
+
+	ret, err = switchDefaultsExportCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(HavePrefix("datacenterName: dc1\nserialNumber: SN-A\n"))
+	Expect(ret).NotTo(ContainSubstring("id:"))
+	Expect(ret).NotTo(ContainSubstring("loopbackAddressIpv4"))
+	Expect(ret).To(ContainSubstring("---\ndatacenterName: dc1\nserialNumber: SN-B\n"))
+
+	cmd = command.MakeCommand(map[string]interface{}{})
+
+	_, err = switchDefaultsExportCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+}
+
+func TestSwitchDefaultsSyncCmdRoundTrip(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSwitchDefaultsSyncClient(t)
+
+	client.EXPECT().
+		SwitchDeviceDefaultsCreate(gomock.Any()).
+		Times(0)
+
+	client.EXPECT().
+		SwitchDeviceDefaultsDelete(gomock.Any()).
+		Times(0)
+
+	for _, format := range []string{"csv", "yaml"} {
+		cmd := command.MakeCommand(map[string]interface{}{
+			"datacenter_name": "dc1",
+			"format":          format,
+		})
+
+		exported, err := switchDefaultsExportCmd(&cmd, client)
+		Expect(err).To(BeNil())
+
+		file := writeSwitchImportFile(t, "defaults."+format, exported)
+
+		cmd = command.MakeCommand(map[string]interface{}{
+			"read_config_from_file": file,
+			"format":                format,
+			"delete_extra":          true,
+			"dry_run":               true,
+		})
+
+		ret, err := switchDefaultsSyncCmd(&cmd, client)
+		Expect(err).To(BeNil())
+		Expect(ret).To(Equal("The switch defaults are already in sync.\n"))
+	}
+}
+
+func TestSwitchDefaultsSyncCmd(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSwitchDefaultsSyncClient(t)
+
+	file := writeSwitchImportFile(t, "defaults.csv", `serialNumber,managementMacAddress,identifierString,asn,customVariables
+SN-A,00:00:00:00:00:0a,leaf-a,65109,
+SN-B,00:00:00:00:00:0b,leaf-b,65102,row=1;rack=r2
+SN-D,00:00:00:00:00:0d,leaf-d,,
+`)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": file,
+		"format":                "csv",
+		"datacenter_name":       "dc1",
+		"dry_run":               true,
+		"report_format":         "csv",
+	})
+
+	ret, err := switchDefaultsSyncCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal(`ID,SERIAL_NUMBER,DATACENTER,ACTION,DETAILS
+10,SN-A,dc1,update,asn 65101 -> 65109
+12,SN-C,dc1,extra,"not in the file, kept"
+0,SN-D,dc1,create,
+`))
+
+	var created []metalcloud.SwitchDeviceDefaults
+
+	client.EXPECT().
+		SwitchDeviceDefaultsDelete([]int{10, 12}).
+		Return(nil).
+		Times(1)
+
+	client.EXPECT().
+		SwitchDeviceDefaultsCreate(gomock.Any()).
+		DoAndReturn(func(defaults []metalcloud.SwitchDeviceDefaults) error {
+			created = defaults
+			return nil
+		}).
+		Times(1)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(os.Stdin, &stdout)
+	defer configuration.SetConsoleIOChannel(os.Stdin, os.Stdout)
+
+	cmd = command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": file,
+		"format":                "csv",
+		"datacenter_name":       "dc1",
+		"delete_extra":          true,
+		"autoconfirm":           true,
+	})
+
+	ret, err = switchDefaultsSyncCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal("Switch defaults synced: 1 created, 1 updated, 1 deleted.\n"))
+	Expect(stdout.String()).To(ContainSubstring("1 to create, 1 to update, 1 to delete"))
+
+	Expect(created).To(HaveLen(2))
+	Expect(created[0].NetworkEquipmentDefaultsID).To(Equal(0))
+	Expect(*created[0].NetworkEquipmentSerialNumber).To(Equal("SN-A"))
+	Expect(*created[0].NetworkEquipmentAsn).To(Equal(65109))
+	Expect(created[0].DatacenterName).To(Equal("dc1"))
+	Expect(*created[1].NetworkEquipmentSerialNumber).To(Equal("SN-D"))
+	Expect(created[1].NetworkEquipmentAsn).To(BeNil())
+}
+
+func TestSwitchDefaultsSyncCmdValidation(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSwitchDefaultsSyncClient(t)
+
+	cases := []struct {
+		content  string
+		args     map[string]interface{}
+		expected string
+	}{
+		{
+			content:  "serialNumber,managementMacAddress\nSN-A,00:00:00:00:00:0a\n",
+			expected: "datacenter name is required for switch defaults #1",
+		},
+		{
+			content:  "datacenterName,managementMacAddress\ndc1,00:00:00:00:00:0a\n",
+			expected: "serial number is required for switch defaults #1",
+		},
+		{
+			content:  "datacenterName,serialNumber\ndc1,SN-A\ndc1,SN-A\n",
+			expected: "serial number SN-A of switch defaults #2 is also used by switch defaults #1",
+		},
+		{
+			content:  "datacenterName,serialNumber\ndc2,SN-A\n",
+			args:     map[string]interface{}{"datacenter_name": "dc1"},
+			expected: "switch defaults #1 of the file is for datacenter dc2, not dc1",
+		},
+		{
+			content:  "datacenterName,serialNumber,asn\ndc1,SN-A,x\n",
+			expected: "asn must be a number",
+		},
+		{
+			content:  "datacenterName,serialNumber,customVariables\ndc1,SN-A,rack\n",
+			expected: "customVariables must be a list of key=value pairs",
+		},
+	}
+
+	for _, c := range cases {
+		args := map[string]interface{}{
+			"read_config_from_file": writeSwitchImportFile(t, "defaults.csv", c.content),
+			"format":                "csv",
+		}
+		for k, v := range c.args {
+			args[k] = v
+		}
+
+		cmd := command.MakeCommand(args)
+
+		_, err := switchDefaultsSyncCmd(&cmd, client)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring(c.expected))
+	}
+}
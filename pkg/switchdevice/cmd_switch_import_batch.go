@@ -0,0 +1,658 @@
+package switchdevice
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+	"github.com/metalsoft-io/tableformatter"
+	"gopkg.in/yaml.v2"
+)
+
+// switchImportRecord is a switch of an import batch. MLAGPeer is the identifier string of the switch
+// with which it forms an MLAG pair, either another switch of the batch or an existing one.
+type switchImportRecord struct {
+	metalcloud.SwitchDevice `yaml:",inline"`
+	MLAGPeer                string `yaml:"mlagPeer,omitempty"`
+}
+
+// switchImportRow is a record of an import batch together with its validation and import result
+type switchImportRow struct {
+	Row      int
+	Record   switchImportRecord
+	Errors   []string
+	SwitchID int
+	LinkID   int
+	Failed   bool
+}
+
+func switchImportBatchCmd(c *command.Command, client metalcloud.MetalCloudClient) (string, error) {
+
+	filePath, ok := command.GetStringParamOk(c.Arguments["read_config_from_file"])
+	if !ok {
+		return "", fmt.Errorf("-file is required")
+	}
+
+	var rows []switchImportRow
+	var err error
+
+	if command.GetStringParam(c.Arguments["format"]) == "csv" {
+		rows, err = getSwitchImportRowsFromCSVFile(filePath)
+	} else {
+		rows, err = getSwitchImportRowsFromYamlFile(filePath)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if len(rows) == 0 {
+		return "", fmt.Errorf("no switches found in %s", filePath)
+	}
+
+	//validate all records before creating any of them
+	failed := validateSwitchImportRows(rows, client)
+
+	reportFormat := command.GetStringParam(c.Arguments["report_format"])
+
+	if failed > 0 {
+		report, err := renderSwitchImportReport(rows, fmt.Sprintf("%d of %d records failed validation", failed, len(rows)), reportFormat)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprint(configuration.GetStdout(), report)
+
+		return "", fmt.Errorf("%d of %d records failed validation. Nothing was imported", failed, len(rows))
+	}
+
+	if command.GetBoolParam(c.Arguments["validate_only"]) {
+		return renderSwitchImportReport(rows, fmt.Sprintf("All %d records are valid", len(rows)), reportFormat)
+	}
+
+	err = importSwitchRows(rows, command.GetBoolParam(c.Arguments["sync_controllers"]), client)
+
+	rollback := getSwitchImportRollback(rows)
+
+	if rollbackFile, ok := command.GetStringParamOk(c.Arguments["rollback_file"]); ok && len(rollback) > 0 {
+		writeErr := os.WriteFile(rollbackFile, []byte(strings.Join(rollback, "\n")+"\n"), 0644)
+		if writeErr != nil && err == nil {
+			err = writeErr
+		}
+	}
+
+	created := 0
+	for _, row := range rows {
+		if row.SwitchID != 0 {
+			created++
+		}
+	}
+
+	if command.GetBoolParam(c.Arguments["return_id"]) && err == nil {
+		var s strings.Builder
+		for _, row := range rows {
+			s.WriteString(fmt.Sprintf("%d\n", row.SwitchID))
+		}
+		return s.String(), nil
+	}
+
+	report, renderErr := renderSwitchImportReport(rows, fmt.Sprintf("%d of %d switches created", created, len(rows)), reportFormat)
+	if renderErr != nil {
+		return "", renderErr
+	}
+
+	if reportFormat == "" && len(rollback) > 0 {
+		report = report + "To roll back the import run:\n" + strings.Join(rollback, "\n") + "\n"
+	}
+
+	if err != nil {
+		fmt.Fprint(configuration.GetStdout(), report)
+		return "", fmt.Errorf("import stopped: %v. The switches already created were not removed", err)
+	}
+
+	return report, nil
+}
+
+// importSwitchRows creates the switches in the order of the rows, then the MLAG pairs and finally syncs the controllers
+// of the new switches if requested. It stops at the first error, the ids of the created objects are kept in the rows.
+func importSwitchRows(rows []switchImportRow, syncControllers bool, client metalcloud.MetalCloudClient) error {
+
+	for i := range rows {
+		ret, err := client.SwitchDeviceCreate(rows[i].Record.SwitchDevice, false)
+		if err != nil {
+			rows[i].Errors = append(rows[i].Errors, err.Error())
+			rows[i].Failed = true
+			return fmt.Errorf("could not create switch %s: %v", rows[i].Record.NetworkEquipmentIdentifierString, err)
+		}
+		rows[i].SwitchID = ret.NetworkEquipmentID
+	}
+
+	created := map[string]int{}
+	for _, row := range rows {
+		created[strings.ToLower(row.Record.NetworkEquipmentIdentifierString)] = row.SwitchID
+	}
+
+	paired := map[string]bool{}
+
+	for i := range rows {
+		peer := strings.ToLower(rows[i].Record.MLAGPeer)
+		if peer == "" || paired[strings.ToLower(rows[i].Record.NetworkEquipmentIdentifierString)] {
+			continue
+		}
+
+		peerID, ok := created[peer]
+		if !ok {
+			sw, err := client.SwitchDeviceGetByIdentifierString(rows[i].Record.MLAGPeer, false)
+			if err != nil {
+				rows[i].Errors = append(rows[i].Errors, err.Error())
+				rows[i].Failed = true
+				return fmt.Errorf("could not find MLAG peer %s: %v", rows[i].Record.MLAGPeer, err)
+			}
+			peerID = sw.NetworkEquipmentID
+		}
+
+		link, err := client.SwitchDeviceLinkCreate(rows[i].SwitchID, peerID, "mlag")
+		if err != nil {
+			rows[i].Errors = append(rows[i].Errors, err.Error())
+			rows[i].Failed = true
+			return fmt.Errorf("could not pair switch %s with %s: %v", rows[i].Record.NetworkEquipmentIdentifierString, rows[i].Record.MLAGPeer, err)
+		}
+
+		rows[i].LinkID = link.NetworkEquipmentLinkID
+		paired[peer] = true
+	}
+
+	if !syncControllers {
+		return nil
+	}
+
+	controllerIDs := []int{}
+	seen := map[int]bool{}
+	for _, row := range rows {
+		id := row.Record.NetworkEquipmentControllerID
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			controllerIDs = append(controllerIDs, id)
+		}
+	}
+	sort.Ints(controllerIDs)
+
+	for _, id := range controllerIDs {
+		_, err := client.SwitchDeviceControllerSync(id)
+		if err != nil {
+			return fmt.Errorf("could not sync switch controller #%d: %v", id, err)
+		}
+	}
+
+	return nil
+}
+
+// getSwitchImportRollback returns the commands that remove the objects created by an import, in reverse order
+func getSwitchImportRollback(rows []switchImportRow) []string {
+
+	commands := []string{}
+
+	for i := len(rows) - 1; i >= 0; i-- {
+		if rows[i].LinkID != 0 {
+			commands = append(commands, fmt.Sprintf("metalcloud-cli switch-pair delete --switch1 %s --switch2 %s --autoconfirm",
+				rows[i].Record.NetworkEquipmentIdentifierString,
+				rows[i].Record.MLAGPeer))
+		}
+	}
+
+	for i := len(rows) - 1; i >= 0; i-- {
+		if rows[i].SwitchID != 0 {
+			commands = append(commands, fmt.Sprintf("metalcloud-cli switch delete --id %d --autoconfirm", rows[i].SwitchID))
+		}
+	}
+
+	return commands
+}
+
+// getSwitchImportRowsFromYamlFile reads the switch records from a yaml file with "---" between the records
+func getSwitchImportRowsFromYamlFile(filePath string) ([]switchImportRow, error) {
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+
+	rows := []switchImportRow{}
+
+	for {
+		var record switchImportRecord
+
+		err = decoder.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error while reading %s: %v", filePath, err)
+		}
+
+		rows = append(rows, switchImportRow{
+			Row:    len(rows) + 1,
+			Record: record,
+		})
+	}
+
+	return rows, nil
+}
+
+// getSwitchImportRowsFromCSVFile reads the switch records from a CSV file with a header row.
+// The columns are named like the yaml fields. List fields such as networkTypesAllowed are separated with ";".
+func getSwitchImportRowsFromCSVFile(filePath string) ([]switchImportRow, error) {
+
+	kinds := getYamlFieldKinds(reflect.TypeOf(metalcloud.SwitchDevice{}))
+	kinds["mlagPeer"] = reflect.String
+
+	records, err := getCSVRecords(filePath, kinds)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []switchImportRow{}
+
+	for _, r := range records {
+		row := switchImportRow{
+			Row:    r.Row,
+			Errors: r.Errors,
+		}
+
+		err = yaml.Unmarshal(r.Content, &row.Record)
+		if err != nil {
+			return nil, fmt.Errorf("Error while reading line %d of %s: %v", r.Row, filePath, err)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// csvRecord is a line of a CSV file converted to yaml so that it can be decoded like a yaml record
+type csvRecord struct {
+	Row     int
+	Content []byte
+	Errors  []string
+}
+
+// getCSVRecords reads a CSV file with a header row whose columns are the yaml keys of kinds.
+// Empty cells are skipped, lists are separated with ";" and maps are written as key=value pairs separated with ";".
+// Values that do not match the kind of their column are reported in the Errors of the record.
+func getCSVRecords(filePath string, kinds map[string]reflect.Kind) ([]csvRecord, error) {
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Error while reading %s: %v", filePath, err)
+	}
+
+	if len(lines) == 0 {
+		return []csvRecord{}, nil
+	}
+
+	header := []string{}
+	for _, h := range lines[0] {
+		h = strings.TrimSpace(h)
+		if _, ok := kinds[h]; !ok {
+			return nil, fmt.Errorf("unknown column '%s' in the header of %s", h, filePath)
+		}
+		header = append(header, h)
+	}
+
+	records := []csvRecord{}
+
+	for lineIdx, line := range lines[1:] {
+		// the header is line 1
+		record := csvRecord{Row: lineIdx + 2}
+
+		fields := map[string]interface{}{}
+		for i, v := range line {
+			v = strings.TrimSpace(v)
+			if i >= len(header) || v == "" {
+				continue
+			}
+
+			column := header[i]
+
+			switch kinds[column] {
+			case reflect.Int:
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					record.Errors = append(record.Errors, fmt.Sprintf("%s must be a number", column))
+					continue
+				}
+				fields[column] = n
+			case reflect.Bool:
+				b, err := strconv.ParseBool(v)
+				if err != nil {
+					record.Errors = append(record.Errors, fmt.Sprintf("%s must be true or false", column))
+					continue
+				}
+				fields[column] = b
+			case reflect.Slice:
+				items := []string{}
+				for _, item := range strings.Split(v, ";") {
+					if item = strings.TrimSpace(item); item != "" {
+						items = append(items, item)
+					}
+				}
+				fields[column] = items
+			case reflect.Map:
+				items := map[string]string{}
+				for _, item := range strings.Split(v, ";") {
+					if item = strings.TrimSpace(item); item == "" {
+						continue
+					}
+					kv := strings.SplitN(item, "=", 2)
+					if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+						record.Errors = append(record.Errors, fmt.Sprintf("%s must be a list of key=value pairs", column))
+						break
+					}
+					items[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+				}
+				fields[column] = items
+			default:
+				fields[column] = v
+			}
+		}
+
+		if len(fields) == 0 && len(record.Errors) == 0 {
+			continue
+		}
+
+		record.Content, err = yaml.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// getYamlFieldKinds returns the kind of every field of a struct indexed by its yaml key, pointers are replaced by the kind they point to.
+// The id field is left out as it is set by the server.
+func getYamlFieldKinds(t reflect.Type) map[string]reflect.Kind {
+	kinds := map[string]reflect.Kind{}
+
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "" || key == "id" {
+			continue
+		}
+
+		fieldType := t.Field(i).Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		kinds[key] = fieldType.Kind()
+	}
+
+	return kinds
+}
+
+// validateSwitchImportRows checks every row against the existing switches and controllers and against the other rows of the batch.
+// It returns the number of rows that failed validation.
+func validateSwitchImportRows(rows []switchImportRow, client metalcloud.MetalCloudClient) int {
+
+	resolver := command.NewResolver(client)
+
+	existing := map[string]map[string]metalcloud.SwitchDevice{}
+	existingErrors := map[string]error{}
+
+	getExisting := func(datacenter string) (map[string]metalcloud.SwitchDevice, error) {
+		if _, ok := existing[datacenter]; !ok && existingErrors[datacenter] == nil {
+			list, err := client.SwitchDevices(datacenter, "")
+			if err != nil {
+				existingErrors[datacenter] = err
+			} else {
+				switches := map[string]metalcloud.SwitchDevice{}
+				for _, sw := range *list {
+					switches[strings.ToLower(sw.NetworkEquipmentIdentifierString)] = sw
+				}
+				existing[datacenter] = switches
+			}
+		}
+		return existing[datacenter], existingErrors[datacenter]
+	}
+
+	identifiers := map[string]int{}
+	addresses := map[string]int{}
+
+	for i := range rows {
+		id := strings.ToLower(rows[i].Record.NetworkEquipmentIdentifierString)
+		if id != "" {
+			if _, ok := identifiers[id]; !ok {
+				identifiers[id] = i
+			}
+		}
+	}
+
+	for i := range rows {
+		row := &rows[i]
+		record := &row.Record
+
+		addError := func(format string, a ...interface{}) {
+			row.Errors = append(row.Errors, fmt.Sprintf(format, a...))
+		}
+
+		identifier := record.NetworkEquipmentIdentifierString
+		id := strings.ToLower(identifier)
+
+		if identifier == "" {
+			addError("identifierString cannot be empty")
+		} else if first := identifiers[id]; first != i {
+			addError("identifier %s is duplicated on row %d", identifier, rows[first].Row)
+		}
+
+		if record.DatacenterName == "" {
+			addError("datacenterName cannot be empty")
+			continue
+		}
+
+		switches, err := getExisting(record.DatacenterName)
+		if err != nil {
+			addError("could not list the switches of datacenter %s: %v", record.DatacenterName, err)
+			continue
+		}
+
+		if sw, ok := switches[id]; ok && identifier != "" {
+			addError("a switch with identifier %s already exists (#%d)", identifier, sw.NetworkEquipmentID)
+		}
+
+		address := record.NetworkEquipmentManagementAddress
+		if address == "" {
+			addError("managementAddress cannot be empty")
+		} else if net.ParseIP(address) == nil {
+			addError("invalid management address %s", address)
+		} else {
+			if other, ok := addresses[address]; ok {
+				addError("management address %s is duplicated on row %d", address, other)
+			} else {
+				addresses[address] = row.Row
+			}
+
+			for _, sw := range switches {
+				if sw.NetworkEquipmentManagementAddress == address {
+					addError("management address %s is used by switch %s (#%d)", address, sw.NetworkEquipmentIdentifierString, sw.NetworkEquipmentID)
+					break
+				}
+			}
+		}
+
+		if record.NetworkEquipmentASN < 0 || int64(record.NetworkEquipmentASN) > 4294967295 {
+			addError("invalid ASN %d", record.NetworkEquipmentASN)
+		}
+
+		if controllerID := record.NetworkEquipmentControllerID; controllerID != 0 {
+			controller, err := resolver.SwitchController(controllerID, false)
+			if err != nil {
+				addError("unknown switch controller #%d", controllerID)
+			} else if controller.DatacenterName != record.DatacenterName {
+				addError("switch controller #%d is in datacenter %s, not %s", controllerID, controller.DatacenterName, record.DatacenterName)
+			}
+		}
+
+		if record.MLAGPeer == "" {
+			continue
+		}
+
+		peer := strings.ToLower(record.MLAGPeer)
+
+		if peer == id {
+			addError("a switch cannot be its own MLAG peer")
+			continue
+		}
+
+		if j, ok := identifiers[peer]; ok {
+			other := rows[j].Record
+			if other.MLAGPeer != "" && strings.ToLower(other.MLAGPeer) != id {
+				addError("MLAG peer %s has %s as its own peer", record.MLAGPeer, other.MLAGPeer)
+			}
+			if other.DatacenterName != record.DatacenterName {
+				addError("MLAG peer %s is in datacenter %s, not %s", record.MLAGPeer, other.DatacenterName, record.DatacenterName)
+			}
+			continue
+		}
+
+		if _, ok := switches[peer]; !ok {
+			addError("unknown MLAG peer %s", record.MLAGPeer)
+		}
+	}
+
+	failed := 0
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			failed++
+		}
+	}
+
+	return failed
+}
+
+// renderSwitchImportReport renders the per row result of a batch import
+func renderSwitchImportReport(rows []switchImportRow, title string, format string) (string, error) {
+
+	schema := []tableformatter.SchemaField{
+		{
+			FieldName: "ROW",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 4,
+		},
+		{
+			FieldName: "IDENTIFIER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "DATACENTER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "MGMT_ADDRESS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "POSITION",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "ASN",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "MLAG_PEER",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 15,
+		},
+		{
+			FieldName: "SWITCH_ID",
+			FieldType: tableformatter.TypeInt,
+			FieldSize: 6,
+		},
+		{
+			FieldName: "STATUS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 10,
+		},
+		{
+			FieldName: "DETAILS",
+			FieldType: tableformatter.TypeString,
+			FieldSize: 40,
+		},
+	}
+
+	data := [][]interface{}{}
+	for _, row := range rows {
+		status := "ok"
+		if row.SwitchID != 0 {
+			status = "created"
+		}
+		if row.LinkID != 0 {
+			status = "paired"
+		}
+		if len(row.Errors) > 0 {
+			status = "invalid"
+		}
+		if row.Failed {
+			status = "failed"
+		}
+
+		if format == "" {
+			if len(row.Errors) > 0 {
+				status = colors.Red(status)
+			} else {
+				status = colors.Green(status)
+			}
+		}
+
+		data = append(data, []interface{}{
+			row.Row,
+			row.Record.NetworkEquipmentIdentifierString,
+			row.Record.DatacenterName,
+			row.Record.NetworkEquipmentManagementAddress,
+			row.Record.NetworkEquipmentProvisionerPosition,
+			row.Record.NetworkEquipmentASN,
+			row.Record.MLAGPeer,
+			row.SwitchID,
+			status,
+			strings.Join(row.Errors, "; "),
+		})
+	}
+
+	table := tableformatter.Table{
+		Data:   data,
+		Schema: schema,
+	}
+
+	return table.RenderTable("Records", title, format)
+}
@@ -0,0 +1,253 @@
+package switchdevice
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gomock "github.com/golang/mock/gomock"
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
+	mock_metalcloud "github.com/metalsoft-io/metalcloud-cli/helpers"
+	. "github.com/onsi/gomega"
+
+	"github.com/metalsoft-io/metalcloud-cli/internal/command"
+	"github.com/metalsoft-io/metalcloud-cli/internal/configuration"
+)
+
+const _switchImportYamlFixture = `identifierString: leaf-1a
+datacenterName: dc1
+driver: os_10
+provisionerPosition: leaf
+managementAddress: 10.0.0.1
+ASN: 65101
+controllerID: 3
+mlagPeer: leaf-1b
+---
+identifierString: leaf-1b
+datacenterName: dc1
+driver: os_10
+provisionerPosition: leaf
+managementAddress: 10.0.0.2
+ASN: 65101
+controllerID: 3
+mlagPeer: leaf-1a
+---
+identifierString: leaf-2a
+datacenterName: dc1
+driver: os_10
+provisionerPosition: leaf
+managementAddress: 10.0.0.3
+mlagPeer: leaf-2b
+`
+
+func writeSwitchImportFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func mockSwitchImportClient(t *testing.T) *mock_metalcloud.MockMetalCloudClient {
+	ctrl := gomock.NewController(t)
+	client := mock_metalcloud.NewMockMetalCloudClient(ctrl)
+
+	client.EXPECT().
+		SwitchDevices("dc1", "").
+		Return(&map[string]metalcloud.SwitchDevice{
+			"leaf-2b": {
+				NetworkEquipmentID:                20,
+				NetworkEquipmentIdentifierString:  "leaf-2b",
+				NetworkEquipmentManagementAddress: "10.0.0.20",
+				DatacenterName:                    "dc1",
+			},
+		}, nil).
+		AnyTimes()
+
+	client.EXPECT().
+		SwitchDeviceControllerGet(3, false).
+		Return(&metalcloud.SwitchDeviceController{
+			NetworkEquipmentControllerID: 3,
+			DatacenterName:               "dc1",
+		}, nil).
+		AnyTimes()
+
+	return client
+}
+
+func TestSwitchImportBatchCmd(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSwitchImportClient(t)
+
+	ids := map[string]int{"leaf-1a": 101, "leaf-1b": 102, "leaf-2a": 103}
+	created := []string{}
+
+	client.EXPECT().
+		SwitchDeviceCreate(gomock.Any(), false).
+		DoAndReturn(func(sw metalcloud.SwitchDevice, overwrite bool) (*metalcloud.SwitchDevice, error) {
+			created = append(created, sw.NetworkEquipmentIdentifierString)
+			sw.NetworkEquipmentID = ids[sw.NetworkEquipmentIdentifierString]
+			return &sw, nil
+		}).
+		Times(3)
+
+	client.EXPECT().
+		SwitchDeviceGetByIdentifierString("leaf-2b", false).
+		Return(&metalcloud.SwitchDevice{NetworkEquipmentID: 20}, nil).
+		Times(1)
+
+	client.EXPECT().
+		SwitchDeviceLinkCreate(101, 102, "mlag").
+		Return(&metalcloud.SwitchDeviceLink{NetworkEquipmentLinkID: 1}, nil).
+		Times(1)
+
+	client.EXPECT().
+		SwitchDeviceLinkCreate(103, 20, "mlag").
+		Return(&metalcloud.SwitchDeviceLink{NetworkEquipmentLinkID: 2}, nil).
+		Times(1)
+
+	client.EXPECT().
+		SwitchDeviceControllerSync(3).
+		Return(&map[int]metalcloud.SwitchDevice{}, nil).
+		Times(1)
+
+	file := writeSwitchImportFile(t, "inventory.yaml", _switchImportYamlFixture)
+	rollbackFile := filepath.Join(t.TempDir(), "rollback.sh")
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": file,
+		"format":                "yaml",
+		"sync_controllers":      true,
+		"rollback_file":         rollbackFile,
+		"report_format":         "csv",
+	})
+
+	ret, err := switchImportBatchCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(created).To(Equal([]string{"leaf-1a", "leaf-1b", "leaf-2a"}))
+	Expect(ret).To(Equal(`ROW,IDENTIFIER,DATACENTER,MGMT_ADDRESS,POSITION,ASN,MLAG_PEER,SWITCH_ID,STATUS,DETAILS
+1,leaf-1a,dc1,10.0.0.1,leaf,65101,leaf-1b,101,paired,
+2,leaf-1b,dc1,10.0.0.2,leaf,65101,leaf-1a,102,created,
+3,leaf-2a,dc1,10.0.0.3,leaf,0,leaf-2b,103,paired,
+`))
+
+	rollback, err := os.ReadFile(rollbackFile)
+	Expect(err).To(BeNil())
+	Expect(string(rollback)).To(Equal(`metalcloud-cli switch-pair delete --switch1 leaf-2a --switch2 leaf-2b --autoconfirm
+metalcloud-cli switch-pair delete --switch1 leaf-1a --switch2 leaf-1b --autoconfirm
+metalcloud-cli switch delete --id 103 --autoconfirm
+metalcloud-cli switch delete --id 102 --autoconfirm
+metalcloud-cli switch delete --id 101 --autoconfirm
+`))
+}
+
+func TestSwitchImportBatchCmdCSV(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSwitchImportClient(t)
+
+	file := writeSwitchImportFile(t, "inventory.csv", `identifierString,datacenterName,managementAddress,managementPort,isBorderDevice,networkTypesAllowed,ASN
+border-1,dc1,10.0.0.9,22,true,wan;quarantine,65000
+`)
+
+	var expected metalcloud.SwitchDevice
+	client.EXPECT().
+		SwitchDeviceCreate(gomock.Any(), false).
+		DoAndReturn(func(sw metalcloud.SwitchDevice, overwrite bool) (*metalcloud.SwitchDevice, error) {
+			expected = sw
+			sw.NetworkEquipmentID = 200
+			return &sw, nil
+		}).
+		Times(1)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": file,
+		"format":                "csv",
+		"return_id":             true,
+	})
+
+	ret, err := switchImportBatchCmd(&cmd, client)
+	Expect(err).To(BeNil())
+	Expect(ret).To(Equal("200\n"))
+	Expect(expected.NetworkEquipmentIdentifierString).To(Equal("border-1"))
+	Expect(expected.NetworkEquipmentManagementPort).To(Equal(22))
+	Expect(expected.NetworkEquipmentIsBorderDevice).To(BeTrue())
+	Expect(expected.NetworkEquipmentNetworkTypesAllowed).To(Equal([]string{"wan", "quarantine"}))
+	Expect(expected.NetworkEquipmentASN).To(Equal(65000))
+}
+
+func TestSwitchImportBatchCmdValidation(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSwitchImportClient(t)
+
+	client.EXPECT().
+		SwitchDeviceCreate(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	file := writeSwitchImportFile(t, "inventory.csv", `identifierString,datacenterName,managementAddress,mlagPeer,managementPort
+leaf-2b,dc1,10.0.0.5,,
+leaf-3a,dc1,10.0.0.20,leaf-3a,
+leaf-4a,dc1,10.0.0.6,leaf-4b,
+leaf-4a,dc1,not-an-ip,,x
+`)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(os.Stdin, &stdout)
+	defer configuration.SetConsoleIOChannel(os.Stdin, os.Stdout)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": file,
+		"format":                "csv",
+		"report_format":         "csv",
+	})
+
+	_, err := switchImportBatchCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal("4 of 4 records failed validation. Nothing was imported"))
+
+	out := stdout.String()
+	Expect(out).To(ContainSubstring("a switch with identifier leaf-2b already exists (#20)"))
+	Expect(out).To(ContainSubstring("management address 10.0.0.20 is used by switch leaf-2b (#20)"))
+	Expect(out).To(ContainSubstring("a switch cannot be its own MLAG peer"))
+	Expect(out).To(ContainSubstring("unknown MLAG peer leaf-4b"))
+	Expect(out).To(ContainSubstring("managementPort must be a number"))
+	Expect(out).To(ContainSubstring("identifier leaf-4a is duplicated on row 4"))
+	Expect(out).To(ContainSubstring("invalid management address not-an-ip"))
+}
+
+func TestSwitchImportBatchCmdStopsOnError(t *testing.T) {
+	RegisterTestingT(t)
+
+	client := mockSwitchImportClient(t)
+
+	client.EXPECT().
+		SwitchDeviceCreate(gomock.Any(), false).
+		Return(&metalcloud.SwitchDevice{NetworkEquipmentID: 101}, nil).
+		Times(1)
+
+	client.EXPECT().
+		SwitchDeviceCreate(gomock.Any(), false).
+		Return(nil, fmt.Errorf("switch unreachable")).
+		Times(1)
+
+	file := writeSwitchImportFile(t, "inventory.yaml", _switchImportYamlFixture)
+
+	var stdout bytes.Buffer
+	configuration.SetConsoleIOChannel(os.Stdin, &stdout)
+	defer configuration.SetConsoleIOChannel(os.Stdin, os.Stdout)
+
+	cmd := command.MakeCommand(map[string]interface{}{
+		"read_config_from_file": file,
+		"format":                "yaml",
+	})
+
+	_, err := switchImportBatchCmd(&cmd, client)
+	Expect(err).NotTo(BeNil())
+	Expect(err.Error()).To(Equal("import stopped: could not create switch leaf-1b: switch unreachable. The switches already created were not removed"))
+	Expect(stdout.String()).To(ContainSubstring("To roll back the import run:\nmetalcloud-cli switch delete --id 101 --autoconfirm\n"))
+}
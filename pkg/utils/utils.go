@@ -5,6 +5,7 @@ import (
 	"os"
 	"strings"
 
+	metalcloud "github.com/metalsoft-io/metal-cloud-sdk-go/v3"
 	"github.com/metalsoft-io/metalcloud-cli/internal/colors"
 	"github.com/metalsoft-io/metalcloud-cli/internal/command"
 )
@@ -48,3 +49,12 @@ func GetConfirmation(autoconfirm bool, message string) (bool, error) {
 
 	return command.RequestConfirmation(confirmationMessage)
 }
+
+// GetIPsAsStringArray returns the human readable form of the given IPs
+func GetIPsAsStringArray(ips []metalcloud.IP) []string {
+	sList := []string{}
+	for _, ip := range ips {
+		sList = append(sList, ip.IPHumanReadable)
+	}
+	return sList
+}
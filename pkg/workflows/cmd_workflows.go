@@ -146,6 +146,8 @@ func workflowsListCmd(c *command.Command, client metalcloud.MetalCloudClient) (s
 		},
 	}
 
+	resolver := command.NewResolver(client)
+
 	data := [][]interface{}{}
 	for _, w := range *list {
 
@@ -156,7 +158,7 @@ func workflowsListCmd(c *command.Command, client metalcloud.MetalCloudClient) (s
 		}
 
 		if w.UserIDOwner != 0 {
-			user, err = client.UserGet(w.UserIDOwner)
+			user, err = resolver.User(w.UserIDOwner)
 			if err != nil {
 				return "", err
 			}